@@ -0,0 +1,219 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package client
+
+import "fmt"
+
+import (
+	"github.com/Francesco149/kagami/channelserver/gamedata"
+	"github.com/Francesco149/kagami/channelserver/status"
+	"github.com/Francesco149/kagami/common"
+	"github.com/Francesco149/kagami/common/config"
+	"github.com/Francesco149/kagami/common/packets"
+)
+
+// Boss ids used in the boss_attempts table
+const (
+	BossPianus   = 0
+	BossPap      = 1
+	BossZakum    = 2
+	BossHorntail = 3
+	BossCount    = 4
+)
+
+// allChannels in a boss channel list means that the boss spawns on every channel
+const allChannels = 0xFF
+
+// A Boss describes the maps of a boss whose entries are limited by the world config
+type Boss struct {
+	Id   byte
+	Name string
+	Maps []int32                                 // entering the first map costs an attempt
+	Conf func(wc *config.WorldConf) *config.B0ss // picks the boss config from a world config
+}
+
+// Bosses lists the bosses limited by the world config
+var Bosses = []*Boss{
+	{BossPianus, "Pianus", []int32{230040420}, (*config.WorldConf).Pianus},
+	{BossPap, "Papulatus", []int32{220080001}, (*config.WorldConf).Pap},
+	{BossZakum, "Zakum", []int32{280030000}, (*config.WorldConf).Zakum},
+	{BossHorntail, "Horntail", []int32{240060000, 240060100, 240060200}, (*config.WorldConf).Horntail},
+}
+
+// BossByMap returns the boss that spawns in the given map or nil
+func BossByMap(mapid int32) *Boss {
+	for _, boss := range Bosses {
+		for _, id := range boss.Maps {
+			if id == mapid {
+				return boss
+			}
+		}
+	}
+	return nil
+}
+
+// spawnsOn returns true if the boss config allows the boss on the given channel
+func spawnsOn(b *config.B0ss, chanid int8) bool {
+	for _, id := range b.ChannelIds() {
+		// channel ids in the config start from 1
+		if id == allChannels || id == byte(chanid)+1 {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadMap returns the map with the given id, loading it if necessary. Boss maps on
+// channels where the boss isn't allowed are loaded without reactors so that the boss
+// can't be summoned, and their monster spawns are disabled. The spawns are updated
+// every time the map is looked up so that a rehash applies to maps that are already
+// loaded, while reactors are only decided the first time.
+func LoadMap(mapid int32) *gamedata.MapleMap {
+	st := <-status.Get
+	defer func() { status.Get <- st }()
+
+	spawns := true
+	if boss := BossByMap(mapid); boss != nil {
+		spawns = spawnsOn(boss.Conf(st.WorldConf()), st.ChanId())
+	}
+
+	m := st.MapFactory().Get(mapid, spawns, true, spawns)
+	if m != nil {
+		m.SetSpawnsDisabled(!spawns)
+	}
+	return m
+}
+
+// BossAttempts returns how many times the character entered each boss today,
+// indexed by boss id
+func BossAttempts(charid int32) (attempts [BossCount]int16, err error) {
+	st, err := common.GetDB().Prepare("SELECT boss, attempts FROM boss_attempts " +
+		"WHERE character_id = ? AND date = CURDATE()")
+	if err != nil {
+		return
+	}
+
+	res, err := st.Run(charid)
+	rows, err := res.GetRows()
+	if err != nil {
+		return
+	}
+
+	colboss := res.Map("boss")
+	colattempts := res.Map("attempts")
+
+	for _, row := range rows {
+		boss := row.Int(colboss)
+		if boss < len(attempts) {
+			attempts[boss] = int16(row.Int(colattempts))
+		}
+	}
+
+	return
+}
+
+// BossAttemptsByName is the same as BossAttempts but looks up the character by name.
+// found is false if the character doesn't exist in the given world.
+func BossAttemptsByName(name string, worldid int8) (attempts [BossCount]int16, found bool, err error) {
+	st, err := common.GetDB().Prepare("SELECT character_id FROM characters " +
+		"WHERE name = ? AND world_id = ?")
+	if err != nil {
+		return
+	}
+
+	res, err := st.Run(name, worldid)
+	rows, err := res.GetRows()
+	if err != nil || len(rows) == 0 {
+		return
+	}
+
+	found = true
+	attempts, err = BossAttempts(int32(rows[0].Int(res.Map("character_id"))))
+	return
+}
+
+// addBossAttempt counts one entry to the given boss. The counter starts over on the
+// first entry of each day.
+func addBossAttempt(charid int32, bossid byte) (err error) {
+	st, err := common.GetDB().Prepare("INSERT INTO boss_attempts(character_id, boss, attempts, date) " +
+		"VALUES(?, ?, 1, CURDATE()) ON DUPLICATE KEY UPDATE " +
+		"attempts = IF(date = CURDATE(), attempts + 1, 1), date = CURDATE()")
+	if err != nil {
+		return
+	}
+
+	_, err = st.Run(charid, bossid)
+	return
+}
+
+// enterBoss checks the boss restrictions before the player enters the given map.
+// ok is false if the player can't enter, in which case they have already been told
+// why. attempt is the boss whose daily attempt must be counted with
+// countBossAttempt once the player has entered the map, or nil.
+func (c *Connection) enterBoss(mapid int32) (attempt *Boss, ok bool, err error) {
+	boss := BossByMap(mapid)
+	if boss == nil || c.GmLevel() > 2 {
+		return nil, true, nil
+	}
+
+	st := <-status.Get
+	b := boss.Conf(st.WorldConf())
+	chanid := st.ChanId()
+	status.Get <- st
+
+	if !spawnsOn(b, chanid) {
+		return nil, false, c.bossRefused(fmt.Sprint(boss.Name, " can't be fought on this channel."))
+	}
+
+	// moving between the maps of the same boss doesn't cost an attempt
+	if mapid != boss.Maps[0] || BossByMap(c.Stats().MapId()) == boss {
+		return nil, true, nil
+	}
+
+	if b.Attempts() >= 0 {
+		attempts, err := BossAttempts(c.CharId())
+		if err != nil {
+			return nil, false, err
+		}
+
+		if attempts[boss.Id] >= b.Attempts() {
+			return nil, false, c.bossRefused(fmt.Sprint("You can only fight ", boss.Name, " ",
+				b.Attempts(), " times a day."))
+		}
+	}
+
+	return boss, true, nil
+}
+
+// countBossAttempt counts a daily attempt at the boss returned by enterBoss after
+// the player has entered its map. Does nothing if attempt is nil.
+func (c *Connection) countBossAttempt(attempt *Boss) error {
+	if attempt == nil {
+		return nil
+	}
+	return addBossAttempt(c.CharId(), attempt.Id)
+}
+
+// bossRefused tells the player why they can't enter a boss map
+func (c *Connection) bossRefused(text string) (err error) {
+	err = c.SendPacket(packets.ServerMessage(packets.ServerMessagePopup,
+		0, text, false, false))
+	if err != nil {
+		return
+	}
+
+	return c.SendPacket(packets.EnableActions())
+}
@@ -0,0 +1,434 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package client
+
+import (
+	"fmt"
+	"sort"
+)
+
+import (
+	"github.com/Francesco149/kagami/channelserver/status"
+	"github.com/Francesco149/kagami/common"
+	"github.com/Francesco149/kagami/common/interserver"
+	"github.com/Francesco149/kagami/common/packets"
+	"github.com/Francesco149/maplelib"
+	"github.com/ziutek/mymysql/mysql"
+)
+
+// Buddy is an accepted entry of the player's buddy list
+type Buddy struct {
+	name    string
+	channel int8 // -1 if offline or if the buddy doesn't have the player in its list
+}
+
+func (this *Buddy) Name() string  { return this.name }
+func (this *Buddy) Channel() int8 { return this.channel }
+
+// LoadBuddies retrieves the given character's accepted buddies from the database.
+// Pending requests stay in the database until they're accepted or denied.
+func (c *Connection) LoadBuddies(charid int32) (err error) {
+	c.buddyMut.Lock()
+	defer c.buddyMut.Unlock()
+
+	c.buddies = make(map[int32]*Buddy)
+
+	st, err := common.GetDB().Prepare("SELECT b.buddy_id, c.name FROM buddies b " +
+		"INNER JOIN characters c ON c.character_id = b.buddy_id " +
+		"WHERE b.character_id = ? AND b.pending = 0")
+	if err != nil {
+		return
+	}
+
+	res, err := st.Run(charid)
+	rows, err := res.GetRows()
+	if err != nil {
+		return
+	}
+
+	colbuddyid := res.Map("buddy_id")
+	colname := res.Map("name")
+
+	for _, row := range rows {
+		c.buddies[int32(row.Int(colbuddyid))] = &Buddy{name: row.Str(colname), channel: -1}
+	}
+
+	return
+}
+
+// BuddyIds returns the character ids of the player's buddies
+func (c *Connection) BuddyIds() (res []int32) {
+	c.buddyMut.Lock()
+	defer c.buddyMut.Unlock()
+
+	for charid := range c.buddies {
+		res = append(res, charid)
+	}
+	return
+}
+
+// OnlineBuddyIds returns the character ids of the player's buddies that are online
+func (c *Connection) OnlineBuddyIds() (res []int32) {
+	c.buddyMut.Lock()
+	defer c.buddyMut.Unlock()
+
+	for charid, buddy := range c.buddies {
+		if buddy.channel >= 0 {
+			res = append(res, charid)
+		}
+	}
+	return
+}
+
+// SendBuddyList sends the player's whole buddy list
+func (c *Connection) SendBuddyList() error {
+	c.buddyMut.Lock()
+	entries := make([]packets.BuddyEntry, 0, len(c.buddies))
+	for charid, buddy := range c.buddies {
+		entries = append(entries, packets.BuddyEntry{
+			CharId:  charid,
+			Name:    buddy.name,
+			Channel: buddy.channel,
+		})
+	}
+	c.buddyMut.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].CharId < entries[j].CharId })
+	return c.SendPacket(packets.BuddyList(entries))
+}
+
+// BuddyNotifyPacket returns the inter-server packet that tells the player's buddies
+// that the player is on the given channel (-1 for offline)
+func (c *Connection) BuddyNotifyPacket(channel int8) maplelib.Packet {
+	return interserver.BuddyNotify(c.CharId(), c.Stats().Name(), channel, false, c.BuddyIds())
+}
+
+// OnBuddyNotify updates the channel of a buddy as relayed by the worldserver.
+// Unless the notification is a reply, the buddy is told the player's channel in return.
+// Notifications from characters that aren't in the buddy list are ignored.
+func (c *Connection) OnBuddyNotify(charid int32, channel int8, reply bool,
+	ownChannel int8) (err error) {
+
+	c.buddyMut.Lock()
+	buddy := c.buddies[charid]
+	if buddy != nil {
+		buddy.channel = channel
+	}
+	c.buddyMut.Unlock()
+
+	if buddy == nil {
+		return
+	}
+
+	err = c.SendPacket(packets.BuddyChannel(charid, channel))
+	if err != nil || reply || channel < 0 {
+		return
+	}
+
+	return SendToWorld(interserver.BuddyNotify(c.CharId(), c.Stats().Name(), ownChannel,
+		true, []int32{charid}))
+}
+
+// notifyBuddy tells a single buddy which channel the player is on
+func (c *Connection) notifyBuddy(charid int32, online bool) error {
+	var channel int8 = -1
+	if online {
+		channel = c.channel()
+	}
+
+	return SendToWorld(interserver.BuddyNotify(c.CharId(), c.Stats().Name(), channel,
+		!online, []int32{charid}))
+}
+
+// channel returns the id of the channel the player is on
+func (c *Connection) channel() int8 {
+	st := <-status.Get
+	defer func() { status.Get <- st }()
+	return st.ChanId()
+}
+
+// buddyRow returns whether the given buddy list entry exists and if it's pending
+func buddyRow(charid, buddyid int32) (exists, pending bool, err error) {
+	st, err := common.GetDB().Prepare("SELECT pending FROM buddies " +
+		"WHERE character_id = ? AND buddy_id = ?")
+	if err != nil {
+		return
+	}
+
+	res, err := st.Run(charid, buddyid)
+	rows, err := res.GetRows()
+	if err != nil || len(rows) == 0 {
+		return
+	}
+
+	return true, rows[0].Int(res.Map("pending")) != 0, nil
+}
+
+// setBuddyRow creates or updates a buddy list entry on the given connection or
+// transaction
+func setBuddyRow(db mysql.ConnCommon, charid, buddyid int32, pending bool) (err error) {
+	st, err := db.Prepare("REPLACE INTO buddies(character_id, buddy_id, pending) " +
+		"VALUES(?, ?, ?)")
+	if err != nil {
+		return
+	}
+
+	_, err = st.Run(charid, buddyid, pending)
+	return
+}
+
+// deleteBuddyRow removes a buddy list entry
+func deleteBuddyRow(charid, buddyid int32) (err error) {
+	st, err := common.GetDB().Prepare("DELETE FROM buddies WHERE character_id = ? AND buddy_id = ?")
+	if err != nil {
+		return
+	}
+
+	_, err = st.Run(charid, buddyid)
+	return
+}
+
+// addBuddyEntry adds an accepted buddy to the player's list as offline
+func (c *Connection) addBuddyEntry(charid int32, name string) {
+	c.buddyMut.Lock()
+	defer c.buddyMut.Unlock()
+	c.buddies[charid] = &Buddy{name: name, channel: -1}
+}
+
+// AddBuddy adds a character to the buddy list by name and sends it a buddy request.
+// If the character already has the player in its list, the request is skipped.
+func (c *Connection) AddBuddy(name string) (err error) {
+	db := common.GetDB()
+
+	st, err := db.Prepare("SELECT character_id, name, buddylist_size FROM characters " +
+		"WHERE name = ? AND world_id = ?")
+	if err != nil {
+		return
+	}
+
+	res, err := st.Run(name, c.WorldId())
+	rows, err := res.GetRows()
+	if err != nil {
+		return
+	}
+
+	if len(rows) == 0 {
+		return c.SendPacket(packets.BuddyMessage(packets.BuddyMsgNotFound))
+	}
+
+	target := int32(rows[0].Int(res.Map("character_id")))
+	name = rows[0].Str(res.Map("name"))
+	targetSize := rows[0].Int(res.Map("buddylist_size"))
+
+	c.buddyMut.Lock()
+	_, added := c.buddies[target]
+	count := len(c.buddies)
+	c.buddyMut.Unlock()
+
+	switch {
+	case target == c.CharId():
+		return c.SendPacket(packets.BuddyMessage(packets.BuddyMsgNotFound))
+	case added:
+		return c.SendPacket(packets.BuddyMessage(packets.BuddyMsgAlreadyAdded))
+	case count >= int(c.BuddylistSize()):
+		return c.SendPacket(packets.BuddyMessage(packets.BuddyMsgListFull))
+	}
+
+	exists, pending, err := buddyRow(target, c.CharId())
+	if err != nil {
+		return
+	}
+
+	if exists && !pending {
+		// the target still has the player in its list, no need to ask again
+		err = setBuddyRow(db, c.CharId(), target, false)
+		if err != nil {
+			return
+		}
+
+		c.addBuddyEntry(target, name)
+		err = c.SendBuddyList()
+		if err != nil {
+			return
+		}
+
+		return c.notifyBuddy(target, true)
+	}
+
+	st, err = db.Prepare("SELECT COUNT(*) AS n FROM buddies WHERE character_id = ? AND pending = 0")
+	if err != nil {
+		return
+	}
+
+	res, err = st.Run(target)
+	rows, err = res.GetRows()
+	if err != nil {
+		return
+	}
+
+	if len(rows) != 0 && rows[0].Int(res.Map("n")) >= targetSize {
+		return c.SendPacket(packets.BuddyMessage(packets.BuddyMsgTargetListFull))
+	}
+
+	// both entries are written together so that a failure can't leave only one of them
+	tr, err := db.Begin()
+	if err != nil {
+		return
+	}
+
+	err = setBuddyRow(tr, c.CharId(), target, false)
+	if err == nil {
+		err = setBuddyRow(tr, target, c.CharId(), true)
+	}
+
+	if err != nil {
+		tr.Rollback()
+		return
+	}
+
+	err = tr.Commit()
+	if err != nil {
+		return
+	}
+
+	c.addBuddyEntry(target, name)
+	err = c.SendBuddyList()
+	if err != nil {
+		return
+	}
+
+	// offline targets will get the request next time they log in
+	return SendToWorld(interserver.MessageToPlayers([]int32{target},
+		packets.BuddyRequest(c.CharId(), c.Stats().Name(), c.channel())))
+}
+
+// AcceptBuddy accepts a pending buddy request
+func (c *Connection) AcceptBuddy(charid int32) (err error) {
+	exists, pending, err := buddyRow(c.CharId(), charid)
+	if err != nil {
+		return
+	}
+
+	if !exists || !pending {
+		fmt.Println(c.Stats().Name(), "tried to accept a non-existing buddy request from", charid)
+		return
+	}
+
+	c.buddyMut.Lock()
+	count := len(c.buddies)
+	c.buddyMut.Unlock()
+
+	if count >= int(c.BuddylistSize()) {
+		return c.SendPacket(packets.BuddyMessage(packets.BuddyMsgListFull))
+	}
+
+	st, err := common.GetDB().Prepare("SELECT name FROM characters WHERE character_id = ?")
+	if err != nil {
+		return
+	}
+
+	res, err := st.Run(charid)
+	rows, err := res.GetRows()
+	if err != nil {
+		return
+	}
+
+	if len(rows) == 0 {
+		return deleteBuddyRow(c.CharId(), charid)
+	}
+
+	err = setBuddyRow(common.GetDB(), c.CharId(), charid, false)
+	if err != nil {
+		return
+	}
+
+	c.addBuddyEntry(charid, rows[0].Str(res.Map("name")))
+	err = c.SendBuddyList()
+	if err != nil {
+		return
+	}
+
+	err = c.notifyBuddy(charid, true)
+	if err != nil {
+		return
+	}
+
+	return c.SendPendingBuddyRequest()
+}
+
+// RemoveBuddy removes a buddy from the list or denies its pending request.
+// The removed buddy keeps the player in its list but sees it as offline.
+func (c *Connection) RemoveBuddy(charid int32) (err error) {
+	err = deleteBuddyRow(c.CharId(), charid)
+	if err != nil {
+		return
+	}
+
+	// cancel the request sent to the buddy if it's still pending
+	_, pending, err := buddyRow(charid, c.CharId())
+	if err != nil {
+		return
+	}
+
+	if pending {
+		err = deleteBuddyRow(charid, c.CharId())
+		if err != nil {
+			return
+		}
+	}
+
+	c.buddyMut.Lock()
+	_, added := c.buddies[charid]
+	delete(c.buddies, charid)
+	c.buddyMut.Unlock()
+
+	if !added {
+		// denied a request
+		return c.SendPendingBuddyRequest()
+	}
+
+	err = c.SendBuddyList()
+	if err != nil {
+		return
+	}
+
+	return c.notifyBuddy(charid, false)
+}
+
+// SendPendingBuddyRequest shows the oldest pending buddy request, if any.
+// Requests are shown one at a time.
+func (c *Connection) SendPendingBuddyRequest() (err error) {
+	st, err := common.GetDB().Prepare("SELECT b.buddy_id, c.name FROM buddies b " +
+		"INNER JOIN characters c ON c.character_id = b.buddy_id " +
+		"WHERE b.character_id = ? AND b.pending = 1 ORDER BY b.created, b.buddy_id LIMIT 1")
+	if err != nil {
+		return
+	}
+
+	res, err := st.Run(c.CharId())
+	if err != nil {
+		return
+	}
+
+	rows, err := res.GetRows()
+	if err != nil || len(rows) == 0 {
+		return
+	}
+
+	// the channel of the requester isn't known here, the client doesn't show it anyway
+	return c.SendPacket(packets.BuddyRequest(int32(rows[0].Int(res.Map("buddy_id"))),
+		rows[0].Str(res.Map("name")), -1))
+}
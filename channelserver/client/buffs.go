@@ -0,0 +1,152 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package client
+
+import (
+	"fmt"
+	"time"
+)
+
+import (
+	"github.com/Francesco149/kagami/common/packets"
+	"github.com/Francesco149/kagami/common/utils"
+)
+
+// A Buff is a set of temporary stats given to the player by a skill or an item
+type Buff struct {
+	sourceid int32
+	stats    []utils.Pair // see packets.GiveBuff
+	mask     uint64
+	expires  time.Time
+	timer    *time.Timer
+}
+
+func (this *Buff) SourceId() int32     { return this.sourceid }
+func (this *Buff) Mask() uint64        { return this.mask }
+func (this *Buff) Expires() time.Time  { return this.expires }
+func (this *Buff) Stats() []utils.Pair { return this.stats }
+
+// GiveBuff applies the given temporary stats to the player for duration milliseconds,
+// replacing any previous buff from the same source.
+// sourceid is the skill id or the negative item id.
+func (c *Connection) GiveBuff(sourceid, duration int32, stats []utils.Pair) error {
+	buff := &Buff{
+		sourceid: sourceid,
+		stats:    stats,
+		expires:  time.Now().Add(time.Duration(duration) * time.Millisecond),
+	}
+
+	for _, pair := range stats {
+		buff.mask |= uint64(pair.First.(int))
+	}
+
+	c.buffMut.Lock()
+	if old := c.buffs[sourceid]; old != nil {
+		old.timer.Stop()
+	}
+	buff.timer = time.AfterFunc(time.Duration(duration)*time.Millisecond, func() {
+		if err := c.cancelBuff(buff); err != nil {
+			fmt.Println("Failed to cancel buff", sourceid, "for", c.Stats().Name(), ":", err)
+		}
+	})
+	c.buffs[sourceid] = buff
+	c.buffMut.Unlock()
+
+	// the packet sorts the stats in place so it gets its own copy
+	sorted := make([]utils.Pair, len(stats))
+	copy(sorted, stats)
+	return c.SendPacket(packets.GiveBuff(sourceid, duration, sorted))
+}
+
+// CancelBuff removes the buff from the given source if it's active
+func (c *Connection) CancelBuff(sourceid int32) error {
+	c.buffMut.Lock()
+	buff := c.buffs[sourceid]
+	c.buffMut.Unlock()
+
+	if buff == nil {
+		return nil
+	}
+
+	return c.cancelBuff(buff)
+}
+
+// cancelBuff removes the given buff unless it has already been replaced or cancelled
+func (c *Connection) cancelBuff(buff *Buff) error {
+	c.buffMut.Lock()
+	if c.buffs[buff.sourceid] != buff {
+		c.buffMut.Unlock()
+		return nil
+	}
+	buff.timer.Stop()
+	delete(c.buffs, buff.sourceid)
+	c.buffMut.Unlock()
+
+	return c.SendPacket(packets.CancelBuff(buff.mask))
+}
+
+// StopBuffs silently removes all of the active buffs. Used when the player disconnects.
+func (c *Connection) StopBuffs() {
+	c.buffMut.Lock()
+	defer c.buffMut.Unlock()
+
+	for sourceid, buff := range c.buffs {
+		buff.timer.Stop()
+		delete(c.buffs, sourceid)
+	}
+}
+
+// Buff returns the active buff from the given source or nil
+func (c *Connection) Buff(sourceid int32) *Buff {
+	c.buffMut.Lock()
+	defer c.buffMut.Unlock()
+	return c.buffs[sourceid]
+}
+
+// BuffedStat returns the total bonus given by the active buffs to the given stat,
+// which must be one of the packets.Buff* constants
+func (c *Connection) BuffedStat(stat int) (res int16) {
+	c.buffMut.Lock()
+	defer c.buffMut.Unlock()
+
+	for _, buff := range c.buffs {
+		for _, pair := range buff.stats {
+			if pair.First.(int) == stat {
+				res += pair.Second.(int16)
+			}
+		}
+	}
+
+	return
+}
+
+// CancelAllBuffs removes all of the active buffs and sends the cancel packets
+func (c *Connection) CancelAllBuffs() (err error) {
+	c.buffMut.Lock()
+	buffs := make([]*Buff, 0, len(c.buffs))
+	for _, buff := range c.buffs {
+		buffs = append(buffs, buff)
+	}
+	c.buffMut.Unlock()
+
+	for _, buff := range buffs {
+		if err = c.cancelBuff(buff); err != nil {
+			return
+		}
+	}
+
+	return
+}
@@ -20,7 +20,11 @@ package client
 import (
 	"errors"
 	"fmt"
+	"image"
+	"math"
 	"net"
+	"sync"
+	"time"
 )
 
 import (
@@ -28,13 +32,12 @@ import (
 	"github.com/Francesco149/kagami/channelserver/status"
 	"github.com/Francesco149/kagami/common"
 	"github.com/Francesco149/kagami/common/consts"
+	"github.com/Francesco149/kagami/common/interserver"
 	"github.com/Francesco149/kagami/common/packets"
 	"github.com/Francesco149/kagami/common/utils"
-	"github.com/Francesco149/maplelib"
+	"github.com/ziutek/mymysql/mysql"
 )
 
-// TODO: generic interface for movable entities
-
 // A client.Connection is a MapleStory in-game client connected to the channel server.
 // It's a wrapper around EncryptedConnection specialized for in-game MapleStory clients.
 // It caches various data from the database such as gm level, look and so on.
@@ -54,6 +57,31 @@ type Connection struct {
 	meso                        int32
 	stats                       *common.CharStats
 	invs                        map[int8]*Inventory
+	pos                         image.Point // current position in the map
+	stance                      byte
+	fh                          int16
+	buffs                       map[int32]*Buff // active buffs by source id
+	buffMut                     sync.Mutex
+	itemCooldowns               map[int32]time.Time // item id -> time it can be used again
+	quests                      map[int16]*QuestStatus
+	questMut                    sync.Mutex
+	skills                      map[int32]*PlayerSkill
+	skillCooldowns              map[int32]time.Time // skill id -> time it can be used again
+	keymap                      [consts.KeymapSize]packets.KeyBinding
+	macros                      []packets.SkillMacro
+	party                       *interserver.Party
+	partyMut                    sync.Mutex
+	buddies                     map[int32]*Buddy
+	buddyMut                    sync.Mutex
+	guild                       *interserver.Guild
+	guildCosts                  map[byte]int32 // mesos paid for pending guild operations
+	guildMut                    sync.Mutex
+	trade                       *Trade
+	tradeMut                    sync.Mutex
+	shop                        *gamedata.Shop // npc shop being browsed
+	storage                     *Storage       // loaded the first time it's opened
+	mapTimers                   mapTimers
+	actionMut                   sync.Mutex // serializes packet handling with timers
 }
 
 // NewConnection initializes and returns an encrypted connection to a MapleStory client
@@ -74,6 +102,12 @@ func NewConnection(basecon net.Conn, testserver bool) *Connection {
 		meso:                -1,
 		stats:               nil,
 		invs:                nil,
+		buffs:               make(map[int32]*Buff),
+		itemCooldowns:       make(map[int32]time.Time),
+		quests:              make(map[int16]*QuestStatus),
+		skills:              make(map[int32]*PlayerSkill),
+		skillCooldowns:      make(map[int32]time.Time),
+		guildCosts:          make(map[byte]int32),
 	}
 }
 
@@ -137,6 +171,50 @@ func (c *Connection) BuddylistSize() byte                 { return c.buddylistSi
 func (c *Connection) SetBuddylistSize(buddylistSize byte) { c.buddylistSize = buddylistSize }
 func (c *Connection) Alive() bool                         { return c.Stats().Hp() > 0 }
 func (c *Connection) Inventory(typ int8) *Inventory       { return c.invs[typ] }
+func (c *Connection) Pos() image.Point                    { return c.pos }
+func (c *Connection) SetPos(v image.Point)                { c.pos = v }
+func (c *Connection) Stance() byte                        { return c.stance }
+func (c *Connection) SetStance(v byte)                    { c.stance = v }
+func (c *Connection) Fh() int16                           { return c.fh }
+func (c *Connection) SetFh(v int16)                       { c.fh = v }
+
+// CharId returns the id of the character that is currently playing.
+// This makes the connection a gamedata.MapleMapPlayer.
+func (c *Connection) CharId() int32 { return c.Stats().Id() }
+
+// LockActions must be held while handling a packet or doing anything else that
+// changes the player's state from outside the packet handler, such as timers.
+// It must be acquired before status and any of the global locks.
+func (c *Connection) LockActions() { c.actionMut.Lock() }
+
+// UnlockActions releases the lock acquired by LockActions
+func (c *Connection) UnlockActions() { c.actionMut.Unlock() }
+
+// WithActionsOf runs fn while holding the action lock of another player, for changes
+// made to that player from this player's packet handler. The caller must hold its own
+// action lock and no other locks. The two locks are taken in order of character id so
+// that two players never wait on each other, which means that the caller's lock might
+// be released for a moment. fn isn't run if the other player is disconnecting.
+func (c *Connection) WithActionsOf(other *Connection, fn func()) {
+	if other == c {
+		fn()
+		return
+	}
+
+	if other.CharId() < c.CharId() {
+		c.UnlockActions()
+		other.LockActions()
+		c.LockActions()
+	} else {
+		other.LockActions()
+	}
+
+	defer other.UnlockActions()
+
+	if !other.Disconnecting() {
+		fn()
+	}
+}
 
 // LoadFromDB retrieves the given character id's data and assigns it to this connection
 func (con *Connection) LoadFromDB(charid int32) (err error) {
@@ -200,13 +278,37 @@ func (con *Connection) LoadFromDB(charid int32) (err error) {
 		}
 	}
 
+	err = con.LoadQuests(charid)
+	if err != nil {
+		return
+	}
+
+	err = con.LoadSkills(charid)
+	if err != nil {
+		return
+	}
+
+	err = con.LoadKeymap(charid)
+	if err != nil {
+		return
+	}
+
+	err = con.LoadSkillMacros(charid)
+	if err != nil {
+		return
+	}
+
+	err = con.LoadBuddies(charid)
+	if err != nil {
+		return
+	}
+
 	// TODO: do not reset uptime if the player is just xfering
 
 	con.SetUptime(0)
 	con.SetGmChat(con.GmChat() && con.GmLevel() > 0)
 
 	// TODO: get book cover (wtf is a book cover)
-	// TODO: init keymaps
 	// TODO: init hpmp
 
 	return
@@ -217,54 +319,133 @@ func (c *Connection) SetStats(v *common.CharStats) {
 	c.SetMapId(v.MapId())
 }
 
+// SetMapId loads the given map and removes the player from the previous one.
+// If the map fails to load, the player will stay in the current map.
+// NOTE: the player is only added to the new map's player list by WarpToMap
 func (c *Connection) SetMapId(mapid int32) error {
-	c.Stats().SetMapId(mapid)
-	fmt.Println("loading map", c.Stats().MapId())
-
-	st := <-status.Get
-	defer func() { status.Get <- st }()
-	c.curmap = st.MapFactory().Get(mapid, true, true, true)
+	fmt.Println("loading map", mapid)
 
-	if c.curmap == nil {
+	newmap := LoadMap(mapid)
+	if newmap == nil {
 		return errors.New("failed to load map")
 	}
+
+	if c.curmap != nil && c.curmap != newmap {
+		c.CancelTrade()
+		c.CloseShop()
+		c.CloseStorage()
+		c.StopMapTimers()
+		c.curmap.RemovePlayer(c)
+	}
+
+	c.Stats().SetMapId(mapid)
+	c.curmap = newmap
 	fmt.Println("done")
 	return nil
 }
 
-// Enter warps the client through this portal if possible
+// maxPortalDistance is the maximum distance in pixels between the player and a portal
+// he enters
+const maxPortalDistance = 200
+
+// CanEnter returns false if the player can't go through the given portal because
+// it's closed, it's a spawn point or he's too far away from it
+func (this *Connection) CanEnter(p gamedata.MaplePortal) bool {
+	if !p.Status() || p.Type() == gamedata.SPAWN_PORTAL {
+		return false
+	}
+
+	// collision portals can be triggered from a bit further away, especially the
+	// ones that launch the player
+	if gamedata.IsCollisionPortal(p.Type()) {
+		return true
+	}
+
+	dx := float64(this.Pos().X - p.Pos().X)
+	dy := float64(this.Pos().Y - p.Pos().Y)
+	if math.Sqrt(dx*dx+dy*dy) > maxPortalDistance {
+		fmt.Println(this.Stats().Name(), "tried to enter portal", p.Name(),
+			"from too far away")
+		return false
+	}
+
+	return true
+}
+
+// Enter warps the client through this portal if possible.
+// Scripted portals must be handled by the caller.
 func (this *Connection) Enter(p gamedata.IMapleGenericPortal) (err error) {
-	// TODO: check distance from portal and D/C if hacking
+	if !this.CanEnter(p) || len(p.ScriptName()) > 0 ||
+		p.TargetMapId() == gamedata.NO_TARGET_MAP {
+
+		// TODO: mystic door town portals
+		return this.SendPacket(packets.EnableActions())
+	}
 
-	changedMap := false
-	if len(p.ScriptName()) > 0 {
-		// TODO: handle 4th job portal script
+	err = this.ChangeMapToPortal(p.TargetMapId(), p.Target())
+	if err != nil {
+		// TODO: send some error
+		fmt.Println(this.Stats().Name(), "failed to enter portal", p.Name(), ":", err)
+		return this.SendPacket(packets.EnableActions())
+	}
+
+	return
+}
+
+// ChangeMapToPortal moves the player to the portal with the given name in the given
+// map. If the portal doesn't exist, the player will appear at the first portal.
+func (c *Connection) ChangeMapToPortal(mapid int32, portalname string) (err error) {
+	attempt, ok, err := c.enterBoss(mapid)
+	if !ok || err != nil {
 		return
 	}
 
-	if p.TargetMapId() != 999999999 {
-		oldmap := this.Stats().MapId()
-		err = this.SetMapId(p.TargetMapId())
-		if err != nil {
-			this.SetMapId(oldmap)
-			// TODO: send some error
-			return this.SendPacket(packets.EnableActions())
-		}
+	oldmap := c.Stats().MapId()
+	err = c.SetMapId(mapid)
+	if err != nil {
+		c.SetMapId(oldmap)
+		return
+	}
 
-		newportal := this.Map().Portal(p.Target())
-		if newportal == nil {
-			newportal = this.Map().PortalById(0)
-		}
+	// the attempt is only counted once the player is actually in the map
+	if err := c.countBossAttempt(attempt); err != nil {
+		fmt.Println("Failed to count boss attempt for", c.Stats().Name(), ":", err)
+	}
+
+	portal := c.Map().Portal(portalname)
+	if portal == nil {
+		portal = c.Map().PortalById(0)
+	}
+
+	return c.WarpToMap(c.Map(), portal)
+}
+
+// ChangeMap moves the player to the given portal of the given map.
+// If the portal doesn't exist, the player will appear at the first portal.
+func (c *Connection) ChangeMap(mapid int32, portalid int32) (err error) {
+	attempt, ok, err := c.enterBoss(mapid)
+	if !ok || err != nil {
+		return
+	}
 
-		err = this.WarpToMap(this.Map(), newportal)
-		changedMap = true
+	oldmap := c.Stats().MapId()
+	err = c.SetMapId(mapid)
+	if err != nil {
+		c.SetMapId(oldmap)
+		return
 	}
 
-	if !changedMap {
-		err = this.SendPacket(packets.EnableActions())
+	// the attempt is only counted once the player is actually in the map
+	if err := c.countBossAttempt(attempt); err != nil {
+		fmt.Println("Failed to count boss attempt for", c.Stats().Name(), ":", err)
 	}
 
-	return
+	portal := c.Map().PortalById(portalid)
+	if portal == nil {
+		portal = c.Map().PortalById(0)
+	}
+
+	return c.WarpToMap(c.Map(), portal)
 }
 
 // WarpToMap sends a map warp packet for the given map and portal.
@@ -272,18 +453,23 @@ func (this *Connection) Enter(p gamedata.IMapleGenericPortal) (err error) {
 func (c *Connection) WarpToMap(newmap *gamedata.MapleMap,
 	newportal gamedata.MaplePortal) error {
 
-	pid := newportal.Id()
+	pid := newportal.Index()
+
+	st := <-status.Get
+	chanid := st.ChanId()
+	status.Get <- st
 
-	switch newmap.Id() {
-	case 100000200, 211000100, 220000300: // dunno why you have to change portal id here
-		pid -= 2
+	err := c.SendPacket(packets.WarpToMap(newmap.Id(), pid, c.Stats().Hp(), chanid))
+	if err != nil {
+		return err
 	}
 
-	st := <-status.Get
-	defer func() { status.Get <- st }()
-	return c.SendPacket(packets.WarpToMap(newmap.Id(), pid, 50, st.ChanId())) // todo: real hp
+	c.SetPos(newportal.Pos())
+	newmap.AddPlayer(c)
 
-	// TODO: update party, player pool and everything
+	c.SyncWorld()
+	c.UpdatePartyHp(true)
+	return c.StartMapTimers()
 }
 
 // SetDBOnline updates the player's online status in the database
@@ -295,12 +481,6 @@ func (c *Connection) SetDBOnline(online bool) (err error) {
 	return
 }
 
-func (c *Connection) EncodeQuestInfo(p *maplelib.Packet) {
-	// TODO
-	p.Encode2(0) // 0 started quests
-	p.Encode2(0) // 0 completed
-}
-
 // SaveStats saves all of the player's stats to the database
 func (c *Connection) SaveStats() (err error) {
 	db := common.GetDB()
@@ -325,7 +505,8 @@ func (c *Connection) SaveStats() (err error) {
 			"gender = ?, " +
 			"skin = ?, " +
 			"face = ?, " +
-			"hair = ? " +
+			"hair = ?, " +
+			"meso = ? " +
 			"WHERE character_id = ?")
 	if err != nil {
 		return
@@ -352,22 +533,46 @@ func (c *Connection) SaveStats() (err error) {
 		c.Stats().Skin(),
 		c.Stats().Face(),
 		c.Stats().Hair(),
+		c.savedMeso(),
 		c.Stats().Id(),
 	)
 	return
 }
 
+// saveMeso saves the player's mesos on the given connection or transaction
+func (c *Connection) saveMeso(db mysql.ConnCommon) (err error) {
+	st, err := db.Prepare("UPDATE characters SET meso = ? WHERE character_id = ?")
+	if err != nil {
+		return
+	}
+
+	_, err = st.Run(c.savedMeso(), c.Stats().Id())
+	return
+}
+
 // Saves saves all of the player's information to the database
 func (c *Connection) Save() (err error) {
 	fmt.Println("Saving", c.Stats().Name(), "'s data")
 	err = c.SaveStats()
-	// TODO: save inventory
-	// TODO: save storage
+	if err != nil {
+		return
+	}
+
+	err = c.SaveInventories()
+	if err != nil {
+		return
+	}
+
+	err = c.SaveQuests()
+	if err != nil {
+		return
+	}
+
+	err = c.SaveSkills()
+	// storage is saved by every storage operation
 	// TODO: save monster book
 	// TODO: save mounts
 	// TODO: save pets
-	// TODO: save quests
-	// TODO: save skills
 	// TODO: save variables
 	return
 }
@@ -0,0 +1,244 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package client
+
+import (
+	"github.com/Francesco149/kagami/channelserver/gamedata"
+	"github.com/Francesco149/kagami/common/consts"
+	"github.com/Francesco149/kagami/common/packets"
+)
+
+// WeaponType identifies the kind of weapon a player is holding
+type WeaponType int
+
+// Possible values for WeaponType
+const (
+	WEAPON_NONE WeaponType = iota
+	WEAPON_SWORD1H
+	WEAPON_AXE1H
+	WEAPON_BLUNT1H
+	WEAPON_DAGGER
+	WEAPON_WAND
+	WEAPON_STAFF
+	WEAPON_SWORD2H
+	WEAPON_AXE2H
+	WEAPON_BLUNT2H
+	WEAPON_SPEAR
+	WEAPON_POLEARM
+	WEAPON_BOW
+	WEAPON_CROSSBOW
+	WEAPON_CLAW
+	WEAPON_KNUCKLE
+	WEAPON_GUN
+)
+
+// WeaponTypeById returns the weapon type of the given item id
+func WeaponTypeById(itemid int32) WeaponType {
+	switch itemid / 10000 {
+	case 130:
+		return WEAPON_SWORD1H
+	case 131:
+		return WEAPON_AXE1H
+	case 132:
+		return WEAPON_BLUNT1H
+	case 133:
+		return WEAPON_DAGGER
+	case 137:
+		return WEAPON_WAND
+	case 138:
+		return WEAPON_STAFF
+	case 140:
+		return WEAPON_SWORD2H
+	case 141:
+		return WEAPON_AXE2H
+	case 142:
+		return WEAPON_BLUNT2H
+	case 143:
+		return WEAPON_SPEAR
+	case 144:
+		return WEAPON_POLEARM
+	case 145:
+		return WEAPON_BOW
+	case 146:
+		return WEAPON_CROSSBOW
+	case 147:
+		return WEAPON_CLAW
+	case 148:
+		return WEAPON_KNUCKLE
+	case 149:
+		return WEAPON_GUN
+	}
+
+	return WEAPON_NONE
+}
+
+// DamageMultiplier returns the primary stat multiplier used to calculate the max damage
+func (w WeaponType) DamageMultiplier() float64 {
+	switch w {
+	case WEAPON_BOW:
+		return 3.4
+	case WEAPON_DAGGER, WEAPON_SWORD1H:
+		return 4.0
+	case WEAPON_AXE1H, WEAPON_BLUNT1H:
+		return 4.4
+	case WEAPON_SWORD2H, WEAPON_AXE2H, WEAPON_BLUNT2H, WEAPON_KNUCKLE:
+		return 4.8
+	case WEAPON_SPEAR, WEAPON_POLEARM:
+		return 5.0
+	case WEAPON_WAND, WEAPON_STAFF, WEAPON_CROSSBOW, WEAPON_CLAW, WEAPON_GUN:
+		return 3.6
+	case WEAPON_NONE: // punching
+		return 1.2
+	}
+
+	return 0
+}
+
+// maxPunchAttack is the highest weapon attack of bare hands
+const maxPunchAttack = 31
+
+// punchAttack returns the weapon attack of bare hands at the given level
+func punchAttack(level byte) int32 {
+	res := (2*int32(level) + 31) / 3
+	if res > maxPunchAttack {
+		return maxPunchAttack
+	}
+	return res
+}
+
+// Ranged returns true if the weapon needs ammo to attack
+func (w WeaponType) Ranged() bool {
+	return w == WEAPON_BOW || w == WEAPON_CROSSBOW || w == WEAPON_CLAW || w == WEAPON_GUN
+}
+
+// TwoHanded returns true if the weapon can't be used together with a shield
+func (w WeaponType) TwoHanded() bool {
+	switch w {
+	case WEAPON_SWORD2H, WEAPON_AXE2H, WEAPON_BLUNT2H, WEAPON_SPEAR, WEAPON_POLEARM,
+		WEAPON_BOW, WEAPON_CROSSBOW, WEAPON_CLAW, WEAPON_KNUCKLE, WEAPON_GUN:
+		return true
+	}
+
+	return false
+}
+
+// IsAmmoFor returns true if the given item can be shot by this weapon
+func (w WeaponType) IsAmmoFor(i gamedata.GenericItem) bool {
+	switch w {
+	case WEAPON_BOW:
+		return i.Id()/1000 == 2060
+	case WEAPON_CROSSBOW:
+		return i.Id()/1000 == 2061
+	case WEAPON_CLAW:
+		return gamedata.IsThrowingStar(i)
+	case WEAPON_GUN:
+		return gamedata.IsBullet(i)
+	}
+
+	return false
+}
+
+// Weapon returns the currently equipped weapon or nil
+func (c *Connection) Weapon() *gamedata.Equip {
+	weapon, ok := c.Inventory(consts.CashInventory + 1).Get(-consts.EquipWeapon).(*gamedata.Equip)
+	if !ok {
+		return nil
+	}
+	return weapon
+}
+
+// WeaponType returns the type of the currently equipped weapon
+func (c *Connection) WeaponType() WeaponType {
+	weapon := c.Weapon()
+	if weapon == nil {
+		return WEAPON_NONE
+	}
+	return WeaponTypeById(weapon.Id())
+}
+
+// TotalStats returns the player's stats including the bonuses from the equipped items
+// and the active buffs
+func (c *Connection) TotalStats() (str, dex, intt, luk, watk, matk int32) {
+	str = int32(c.Stats().Str())
+	dex = int32(c.Stats().Dex())
+	intt = int32(c.Stats().Int())
+	luk = int32(c.Stats().Luk())
+
+	for _, item := range c.Inventory(consts.CashInventory + 1).Map() {
+		equip, ok := item.(*gamedata.Equip)
+		if !ok {
+			continue
+		}
+
+		str += int32(equip.Str())
+		dex += int32(equip.Dex())
+		intt += int32(equip.Int())
+		luk += int32(equip.Luk())
+		watk += int32(equip.WAtk())
+		matk += int32(equip.MAtk())
+	}
+
+	watk += int32(c.BuffedStat(packets.BuffWAtk))
+	matk += int32(c.BuffedStat(packets.BuffMAtk))
+	return
+}
+
+// MaxBaseDamage returns the highest damage a basic physical attack can deal
+// with the current stats and weapon. Players without a weapon punch.
+func (c *Connection) MaxBaseDamage() int32 {
+	str, dex, _, luk, watk, _ := c.TotalStats()
+	weapon := c.WeaponType()
+
+	if weapon == WEAPON_NONE {
+		watk += punchAttack(c.Stats().Level())
+	}
+
+	if watk < 0 {
+		watk = 0
+	}
+
+	var primary, secondary int32
+
+	switch {
+	case weapon == WEAPON_BOW, weapon == WEAPON_CROSSBOW, weapon == WEAPON_GUN:
+		primary, secondary = dex, str
+
+	case c.Stats().Job()/100 == 4 && (weapon == WEAPON_CLAW || weapon == WEAPON_DAGGER):
+		primary, secondary = luk, str+dex
+
+	default:
+		primary, secondary = str, dex
+	}
+
+	res := (weapon.DamageMultiplier()*float64(primary) + float64(secondary)) / 100.0 *
+		float64(watk)
+
+	return int32(res) + 10 // just some safeguard for rounding
+}
+
+// MaxMagicDamage returns the highest damage a spell with the given spell attack
+// can deal with the current stats
+func (c *Connection) MaxMagicDamage(spellAttack int32) int32 {
+	_, _, intt, _, _, matk := c.TotalStats()
+	magic := float64(matk + intt)
+	res := ((magic*magic)/1000.0+magic)/30.0 + float64(intt)/200.0
+	return int32(res*float64(spellAttack)) + 10
+}
+
+// CanCritical returns true if the player's ranged attacks can deal critical hits
+func (c *Connection) CanCritical() bool {
+	return c.SkillLevel(SKILL_CRITICAL_SHOT) > 0 || c.SkillLevel(SKILL_CRITICAL_THROW) > 0
+}
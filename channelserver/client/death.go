@@ -0,0 +1,140 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package client
+
+import "fmt"
+
+import (
+	"github.com/Francesco149/kagami/channelserver/gamedata"
+	"github.com/Francesco149/kagami/common/packets"
+	"github.com/Francesco149/kagami/common/utils"
+)
+
+// SafetyCharm is the cash item that prevents the exp loss on death
+const SafetyCharm = 5130000
+
+// reviveHp is the hp players are revived with
+const reviveHp = 50
+
+// Exp lost on death, in percent of the exp needed for the current level.
+// Towns don't take any exp.
+const (
+	deathExpLossField   = 10
+	deathExpLossSpecial = 5 // maps with a forced return map such as boss rooms
+)
+
+// deathExpLoss returns how much exp the player loses when dying in the given map
+func (c *Connection) deathExpLoss(m *gamedata.MapleMap) int32 {
+	stats := c.Stats()
+	if m.Town() || stats.Job() == 0 || stats.Level() >= gamedata.MaxLevel {
+		return 0
+	}
+
+	percent := int64(deathExpLossField)
+	if m.ForcedReturnMap() != gamedata.NoForcedReturn {
+		percent = deathExpLossSpecial
+	}
+
+	loss := int32(int64(gamedata.ExpNeeded(stats.Level())) * percent / 100)
+	if loss > stats.Exp() {
+		loss = stats.Exp()
+	}
+	return loss
+}
+
+// die applies the death penalty after the player's hp reached zero. The client
+// shows the revival prompt by itself.
+func (c *Connection) die() (err error) {
+	fmt.Println(c.Stats().Name(), "died in map", c.Map().Id())
+
+	c.CancelTrade()
+
+	err = c.CancelAllBuffs()
+	if err != nil {
+		return
+	}
+
+	loss := c.deathExpLoss(c.Map())
+	if loss == 0 {
+		return
+	}
+
+	if c.ItemCount(SafetyCharm) > 0 {
+		_, err = c.RemoveItemById(SafetyCharm, 1)
+		if err != nil {
+			return
+		}
+
+		return c.message(fmt.Sprint("You have used the safety charm once, so your EXP "+
+			"points have not been decreased. (", c.ItemCount(SafetyCharm), " time(s) left)"))
+	}
+
+	stats := c.Stats()
+	stats.SetExp(stats.Exp() - loss)
+	return c.SendPacket(packets.UpdatePlayerStats([]utils.Pair{
+		{First: packets.UpdateExp, Second: stats.Exp()},
+	}, false))
+}
+
+// reviveMapId returns the map where the player is revived after dying in the given
+// map
+func reviveMapId(m *gamedata.MapleMap) int32 {
+	if m.ReturnMapId() == gamedata.NoForcedReturn {
+		return m.Id() // towns revive in place
+	}
+	return m.ReturnMapId()
+}
+
+// Revive brings a dead player back to life in the return map of the current map
+func (c *Connection) Revive() error {
+	if c.Alive() {
+		return nil
+	}
+
+	fmt.Println(c.Stats().Name(), "is being revived")
+	c.Stats().SetHp(reviveHp)
+	return c.ChangeMap(reviveMapId(c.Map()), 0)
+}
+
+// ReviveOnLogin revives a player who logged out while dead without sending any
+// packet. Must be called before the player is added to the map.
+func (c *Connection) ReviveOnLogin() error {
+	if c.Alive() {
+		return nil
+	}
+
+	c.Stats().SetHp(reviveHp)
+	c.Stats().SetPos(0)
+	return c.SetMapId(reviveMapId(c.Map()))
+}
+
+// TakeDamage applies the damage from a monster or the map to the player and shows
+// it to the other players
+func (c *Connection) TakeDamage(from int8, damage, monsterid int32, direction byte) error {
+	if !c.Alive() {
+		return nil
+	}
+
+	// TODO: magic guard, power guard and meso guard
+	c.Map().Broadcast(packets.DamagePlayer(c.CharId(), from, damage, monsterid,
+		direction), c.CharId())
+
+	if damage == 0 {
+		return nil
+	}
+
+	return c.AddHpMp(-damage, 0)
+}
@@ -0,0 +1,159 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package client
+
+import "fmt"
+
+import (
+	"github.com/Francesco149/kagami/channelserver/status"
+	"github.com/Francesco149/kagami/common"
+	"github.com/Francesco149/kagami/common/packets"
+)
+
+// Fame restrictions
+const (
+	FameMinLevel = 15 // minimum level to raise or drop fame
+	FameLimit    = 1  // how many times a player can fame others every WorldConf.FameTime
+)
+
+// fameCount returns how many times the player gave fame in the last window seconds.
+// If targetid isn't 0, only fame given to that character is counted.
+func (c *Connection) fameCount(targetid int32, window int64) (count int, err error) {
+	db := common.GetDB()
+
+	query := "SELECT COUNT(*) AS count FROM fame_log WHERE character_id = ? " +
+		"AND time > NOW() - INTERVAL ? SECOND"
+	args := []interface{}{c.CharId(), window}
+
+	if targetid != 0 {
+		query += " AND target_id = ?"
+		args = append(args, targetid)
+	}
+
+	st, err := db.Prepare(query)
+	if err != nil {
+		return
+	}
+
+	res, err := st.Run(args...)
+	rows, err := res.GetRows()
+	if err != nil {
+		return
+	}
+
+	count = rows[0].Int(res.Map("count"))
+	return
+}
+
+// GiveFame raises or drops the fame of the given character, which must be on the
+// same map. The fame log keeps track of the limits across restarts.
+func (c *Connection) GiveFame(targetid int32, mode byte) (err error) {
+	target, ok := c.Map().Player(targetid).(*Connection)
+	if !ok || target == c {
+		return c.SendPacket(packets.FameError(packets.FameInvalidTarget))
+	}
+
+	if c.Stats().Level() < FameMinLevel {
+		return c.SendPacket(packets.FameError(packets.FameLevelTooLow))
+	}
+
+	st := <-status.Get
+	fameTime := st.WorldConf().FameTime()
+	fameResetTime := st.WorldConf().FameResetTime()
+	status.Get <- st
+
+	count, err := c.fameCount(0, fameTime)
+	if err != nil {
+		return
+	}
+
+	if count >= FameLimit {
+		return c.SendPacket(packets.FameError(packets.FameLimitReached))
+	}
+
+	count, err = c.fameCount(targetid, fameResetTime)
+	if err != nil {
+		return
+	}
+
+	if count > 0 {
+		return c.SendPacket(packets.FameError(packets.FameSameTargetLimit))
+	}
+
+	amount := int32(-1)
+	if mode == packets.FameModeUp {
+		amount = 1
+	}
+
+	given := false
+	c.WithActionsOf(target, func() {
+		err = c.logFame(target, amount)
+		if err != nil {
+			return
+		}
+
+		given = true
+		fmt.Println(c.Stats().Name(), "gave", amount, "fame to", target.Stats().Name())
+
+		err = target.GainFame(amount, false)
+		if err != nil {
+			return
+		}
+
+		err = target.SendPacket(packets.FameReceivedNotice(c.Stats().Name(), mode))
+	})
+
+	if err != nil {
+		return
+	}
+
+	if !given {
+		// the target disconnected in the meantime
+		return c.SendPacket(packets.FameError(packets.FameInvalidTarget))
+	}
+
+	return c.SendPacket(packets.FameResponse(target.Stats().Name(), mode, target.Stats().Fame()))
+}
+
+// logFame writes the fame given to the target to the fame log and saves the target's
+// new fame in a single transaction, so that the limits are only used up if the fame
+// is actually given. The target's action lock must be held.
+func (c *Connection) logFame(target *Connection, amount int32) (err error) {
+	tr, err := common.GetDB().Begin()
+	if err != nil {
+		return
+	}
+
+	st, err := tr.Prepare("INSERT INTO fame_log(character_id, target_id, amount) VALUES(?, ?, ?)")
+	if err == nil {
+		_, err = st.Run(c.CharId(), target.CharId(), amount)
+	}
+
+	if err == nil {
+		st, err = tr.Prepare("UPDATE characters SET fame = ? WHERE character_id = ?")
+	}
+
+	if err == nil {
+		_, err = st.Run(target.fameAfter(amount), target.CharId())
+	}
+
+	if err != nil {
+		tr.Rollback()
+		return
+	}
+
+	return tr.Commit()
+}
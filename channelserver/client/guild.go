@@ -0,0 +1,308 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package client
+
+import (
+	"fmt"
+)
+
+import (
+	"github.com/Francesco149/kagami/common/interserver"
+	"github.com/Francesco149/kagami/common/packets"
+	"github.com/Francesco149/maplelib"
+)
+
+// Guild costs in mesos
+const (
+	GuildCreateCost         = 1500000
+	GuildEmblemCost         = 5000000
+	guildCapacityCostFactor = 500000 // multiplied by the number of 5-slot upgrades
+)
+
+// GuildCapacityCost returns the cost of upgrading a guild from the given capacity
+func GuildCapacityCost(capacity byte) int32 {
+	return guildCapacityCostFactor * int32(capacity-5) / 5
+}
+
+// Guild returns the player's guild or nil. The guild is owned by the worldserver and
+// must not be modified.
+func (c *Connection) Guild() *interserver.Guild {
+	c.guildMut.Lock()
+	defer c.guildMut.Unlock()
+	return c.guild
+}
+
+// SetGuild updates the player's guild as pushed by the worldserver
+func (c *Connection) SetGuild(guild *interserver.Guild) {
+	c.guildMut.Lock()
+	defer c.guildMut.Unlock()
+	c.guild = guild
+}
+
+// GuildId returns the id of the player's guild or 0 if the player isn't in a guild
+func (c *Connection) GuildId() int32 {
+	guild := c.Guild()
+	if guild == nil {
+		return 0
+	}
+	return guild.Id
+}
+
+// GuildRank returns the player's guild rank or 0 if the player isn't in a guild
+func (c *Connection) GuildRank() byte {
+	guild := c.Guild()
+	if guild == nil {
+		return 0
+	}
+
+	m := guild.Member(c.CharId())
+	if m == nil {
+		return 0
+	}
+	return m.Rank
+}
+
+// IsGuildLeader returns true if the player is the master of a guild
+func (c *Connection) IsGuildLeader() bool {
+	guild := c.Guild()
+	return guild != nil && guild.Leader == c.CharId()
+}
+
+// PayGuildCost takes the mesos for a paid guild operation before it's requested to
+// the worldserver. Returns false if the player can't pay or is still waiting on the
+// same operation. The mesos are kept aside until the worldserver either confirms the
+// operation or refuses it, in which case they're given back by RefundGuildCost.
+func (c *Connection) PayGuildCost(op byte, cost int32) (ok bool, err error) {
+	c.guildMut.Lock()
+	_, pending := c.guildCosts[op]
+	c.guildMut.Unlock()
+
+	if pending {
+		return
+	}
+
+	ok, err = c.GainMeso(-cost, false)
+	if err != nil || !ok {
+		return
+	}
+
+	c.guildMut.Lock()
+	c.guildCosts[op] = cost
+	c.guildMut.Unlock()
+
+	err = c.SaveStats()
+	return
+}
+
+// ConfirmGuildCost forgets the mesos paid for the operation that caused the given
+// guild sync, as the worldserver has committed it
+func (c *Connection) ConfirmGuildCost(syncop byte) {
+	var op byte
+
+	switch syncop {
+	case interserver.GuildSyncCreate:
+		op = interserver.GuildOpCreate
+	case interserver.GuildSyncEmblem:
+		op = interserver.GuildOpEmblem
+	case interserver.GuildSyncCapacity:
+		op = interserver.GuildOpCapacity
+	default:
+		return
+	}
+
+	c.guildMut.Lock()
+	defer c.guildMut.Unlock()
+	delete(c.guildCosts, op)
+}
+
+// RefundGuildCost gives back the mesos paid for a guild operation that the
+// worldserver refused
+func (c *Connection) RefundGuildCost(op byte) (err error) {
+	c.guildMut.Lock()
+	cost, pending := c.guildCosts[op]
+	delete(c.guildCosts, op)
+	c.guildMut.Unlock()
+
+	if !pending {
+		return
+	}
+
+	ok, err := c.GainMeso(cost, false)
+	if err != nil {
+		return
+	}
+
+	if !ok {
+		return fmt.Errorf("Couldn't refund %d mesos to %s", cost, c.Stats().Name())
+	}
+
+	return c.SaveStats()
+}
+
+// encodeGuildMember encodes the guild list entry of a member
+func encodeGuildMember(p *maplelib.Packet, m *interserver.GuildMember) {
+	packets.EncodePaddedString(p, m.Name, 13)
+	p.Encode4s(int32(m.Job))
+	p.Encode4s(int32(m.Level))
+	p.Encode4s(int32(m.Rank))
+
+	if m.Online {
+		p.Encode4s(1)
+	} else {
+		p.Encode4s(0)
+	}
+
+	p.Encode4s(3) // what the hell is this
+}
+
+// GuildInfo returns a packet that shows the full guild window. guild can be nil if
+// the player isn't in a guild.
+func GuildInfo(guild *interserver.Guild) (p maplelib.Packet) {
+	p = packets.NewEncryptedPacket(packets.OGuild)
+	p.Encode1(packets.GuildOpInfo)
+
+	if guild == nil {
+		p.Encode1(0x00)
+		return
+	}
+
+	p.Encode1(0x01)
+	p.Encode4s(guild.Id)
+	p.EncodeString(guild.Name)
+
+	for _, title := range guild.Titles {
+		p.EncodeString(title)
+	}
+
+	p.Encode1(byte(len(guild.Members)))
+
+	for _, m := range guild.Members {
+		p.Encode4s(m.CharId)
+	}
+
+	for _, m := range guild.Members {
+		encodeGuildMember(&p, m)
+	}
+
+	p.Encode4s(int32(guild.Capacity))
+	p.Encode2s(guild.EmblemBg)
+	p.Encode1(guild.EmblemBgCol)
+	p.Encode2s(guild.Emblem)
+	p.Encode1(guild.EmblemCol)
+	p.EncodeString(guild.Notice)
+	p.Encode4s(guild.Points)
+	return
+}
+
+// GuildPacket returns the client packet for a guild change pushed by the worldserver
+// (see interserver.SyncGuild) as seen by members other than the target
+func GuildPacket(op byte, guild *interserver.Guild, target int32,
+	targetName string) (p maplelib.Packet) {
+
+	p = packets.NewEncryptedPacket(packets.OGuild)
+
+	switch op {
+	case interserver.GuildSyncJoin:
+		p.Encode1(packets.GuildOpJoin)
+		p.Encode4s(guild.Id)
+		p.Encode4s(target)
+
+		if m := guild.Member(target); m != nil {
+			encodeGuildMember(&p, m)
+		}
+
+	case interserver.GuildSyncLeave, interserver.GuildSyncExpel:
+		if op == interserver.GuildSyncExpel {
+			p.Encode1(packets.GuildOpExpel)
+		} else {
+			p.Encode1(packets.GuildOpLeave)
+		}
+
+		p.Encode4s(guild.Id)
+		p.Encode4s(target)
+		p.EncodeString(targetName)
+
+	case interserver.GuildSyncDisband:
+		p.Encode1(packets.GuildOpDisband)
+		p.Encode4s(guild.Id)
+		p.Encode1(0x01)
+
+	case interserver.GuildSyncTitles:
+		p.Encode1(packets.GuildOpTitles)
+		p.Encode4s(guild.Id)
+
+		for _, title := range guild.Titles {
+			p.EncodeString(title)
+		}
+
+	case interserver.GuildSyncRank:
+		var rank byte
+		if m := guild.Member(target); m != nil {
+			rank = m.Rank
+		}
+
+		p.Encode1(packets.GuildOpRank)
+		p.Encode4s(guild.Id)
+		p.Encode4s(target)
+		p.Encode1(rank)
+
+	case interserver.GuildSyncEmblem:
+		p.Encode1(packets.GuildOpEmblem)
+		p.Encode4s(guild.Id)
+		p.Encode2s(guild.EmblemBg)
+		p.Encode1(guild.EmblemBgCol)
+		p.Encode2s(guild.Emblem)
+		p.Encode1(guild.EmblemCol)
+
+	case interserver.GuildSyncNotice:
+		p.Encode1(packets.GuildOpNotice)
+		p.Encode4s(guild.Id)
+		p.EncodeString(guild.Notice)
+
+	case interserver.GuildSyncCapacity:
+		p.Encode1(packets.GuildOpCapacity)
+		p.Encode4s(guild.Id)
+		p.Encode1(guild.Capacity)
+
+	case interserver.GuildSyncOnline:
+		online := byte(0)
+		if m := guild.Member(target); m != nil && m.Online {
+			online = 1
+		}
+
+		p.Encode1(packets.GuildOpOnline)
+		p.Encode4s(guild.Id)
+		p.Encode4s(target)
+		p.Encode1(online)
+
+	case interserver.GuildSyncMember:
+		var level, job int32
+		if m := guild.Member(target); m != nil {
+			level, job = int32(m.Level), int32(m.Job)
+		}
+
+		p.Encode1(packets.GuildOpMember)
+		p.Encode4s(guild.Id)
+		p.Encode4s(target)
+		p.Encode4s(level)
+		p.Encode4s(job)
+
+	default:
+		return GuildInfo(guild)
+	}
+
+	return
+}
@@ -25,7 +25,9 @@ import (
 import (
 	"github.com/Francesco149/kagami/channelserver/gamedata"
 	"github.com/Francesco149/kagami/common"
+	"github.com/Francesco149/kagami/common/packets"
 	"github.com/Francesco149/maplelib"
+	"github.com/ziutek/mymysql/mysql"
 )
 
 // InventoryType defines which inventory tab this inventory refers to
@@ -86,6 +88,15 @@ func NewInventory(invtype InventoryType, maxSlots int8) *Inventory {
 	}
 }
 
+// clone returns a copy of the inventory that shares the same items
+func (this *Inventory) clone() *Inventory {
+	res := NewInventory(this.typ, this.capacity)
+	for slot, item := range this.inv {
+		res.inv[slot] = item
+	}
+	return res
+}
+
 type itemSorter []gamedata.GenericItem
 
 func (this itemSorter) Len() int      { return len(this) }
@@ -145,10 +156,6 @@ func (this *Inventory) LoadFromDB(charid int32) (err error) {
 		return
 	}
 
-	colitemid := res.Map("item_id")
-	colslot := res.Map("slot")
-	colamount := res.Map("amount")
-
 	if rows == nil || len(rows) == 0 {
 		return
 	}
@@ -158,18 +165,7 @@ func (this *Inventory) LoadFromDB(charid int32) (err error) {
 			return
 		}
 
-		var it gamedata.GenericItem
-
-		if t == INVENTORY_EQUIP {
-			it = gamedata.NewEquip(int32(row.Int(colitemid)), int8(row.Int(colslot)),
-				-1) // todo: get ring id from db
-			// TODO: set equip data n shit
-		} else {
-			it = gamedata.NewItem(int32(row.Int(colitemid)), int8(row.Int(colslot)),
-				int16(row.Int(colamount)), -1) // todo: get pet id from db
-		}
-
-		if err = this.AddWithPosition(it); err != nil {
+		if err = this.AddWithPosition(loadItem(row, res, t == INVENTORY_EQUIP)); err != nil {
 			return
 		}
 	}
@@ -177,6 +173,54 @@ func (this *Inventory) LoadFromDB(charid int32) (err error) {
 	return
 }
 
+// loadItem creates an item from an items row
+func loadItem(row mysql.Row, res mysql.Result, equip bool) (it gamedata.GenericItem) {
+	itemid := int32(row.Int(res.Map("item_id")))
+	slot := int8(row.Int(res.Map("slot")))
+
+	if equip {
+		e := gamedata.NewEquip(itemid, slot, -1) // todo: get ring id from db
+		loadEquipStats(e, row, res)
+		it = e
+	} else {
+		it = gamedata.NewItem(itemid, slot, int16(row.Int(res.Map("amount"))),
+			-1) // todo: get pet id from db
+	}
+
+	it.SetOwner(row.Str(res.Map("owner")))
+	return
+}
+
+// loadEquipStats assigns the stats stored in the given items row to an equip.
+// Equips that were inserted without stats (such as the starter equips) get their
+// base stats from the wz files.
+func loadEquipStats(equip *gamedata.Equip, row mysql.Row, res mysql.Result) {
+	colslots := res.Map("upgrade_slots")
+	if row[colslots] == nil {
+		equip.SetBaseStats()
+		return
+	}
+
+	equip.SetSlots(int8(row.Int(colslots)))
+	equip.SetLevel(byte(row.Int(res.Map("scroll_level"))))
+	equip.SetLocked(int8(row.Int(res.Map("locked"))))
+	equip.SetStr(int16(row.Int(res.Map("str"))))
+	equip.SetDex(int16(row.Int(res.Map("dex"))))
+	equip.SetInt(int16(row.Int(res.Map("int"))))
+	equip.SetLuk(int16(row.Int(res.Map("luk"))))
+	equip.SetHp(int16(row.Int(res.Map("hp"))))
+	equip.SetMp(int16(row.Int(res.Map("mp"))))
+	equip.SetWAtk(int16(row.Int(res.Map("watk"))))
+	equip.SetMAtk(int16(row.Int(res.Map("matk"))))
+	equip.SetWDef(int16(row.Int(res.Map("wdef"))))
+	equip.SetMDef(int16(row.Int(res.Map("mdef"))))
+	equip.SetAcc(int16(row.Int(res.Map("acc"))))
+	equip.SetAvoid(int16(row.Int(res.Map("avoid"))))
+	equip.SetHands(int16(row.Int(res.Map("hands"))))
+	equip.SetSpeed(int16(row.Int(res.Map("speed"))))
+	equip.SetJump(int16(row.Int(res.Map("jump"))))
+}
+
 // Look for the given item in the inventory. If the item is not found, nil will be returned.
 func (this *Inventory) ById(itemId int32) gamedata.GenericItem {
 	for _, item := range this.inv {
@@ -241,6 +285,7 @@ func (this *Inventory) Move(slotA, slotB int8, maxStack int16) (err error) {
 		// merge 2 stacks
 		default:
 			itemB.SetAmount(itemA.Amount() + itemB.Amount())
+			delete(this.inv, slotA)
 		}
 
 	// swap two different items
@@ -258,6 +303,13 @@ func (this *Inventory) swap(a, b gamedata.GenericItem) {
 	b.SetPos(tmp)
 }
 
+// Take removes the whole stack located at the given slot and returns it
+func (this *Inventory) Take(slot int8) gamedata.GenericItem {
+	item := this.inv[slot]
+	delete(this.inv, slot)
+	return item
+}
+
 // Get returns the item located at the given slot
 func (this *Inventory) Get(slot int8) gamedata.GenericItem {
 	return this.inv[slot]
@@ -316,3 +368,191 @@ func (this *Inventory) Type() InventoryType {
 
 // Map returns a map by slot of the contents of the inventory.
 func (this *Inventory) Map() map[int8]gamedata.GenericItem { return this.inv }
+
+// InventoryTypeById returns the inventory tab the given item id belongs to
+func InventoryTypeById(itemid int32) InventoryType {
+	if gamedata.IsEquip(itemid) {
+		return INVENTORY_EQUIP
+	}
+	return InventoryTypeByWzName(gamedata.ItemWzCategory(itemid))
+}
+
+// CanHold returns true if the inventory has enough room to hold the given amount
+// of the given item id, taking stacking into account
+func (this *Inventory) CanHold(itemid int32, amount int16, slotMax int16) bool {
+	if slotMax < 1 {
+		slotMax = 1
+	}
+
+	needed := int(amount)
+	if slotMax > 1 {
+		for _, item := range this.inv {
+			if item.Id() == itemid && gamedata.IsStackable(item) && item.Amount() < slotMax {
+				needed -= int(slotMax - item.Amount())
+			}
+		}
+	}
+
+	if needed <= 0 {
+		return true
+	}
+
+	slots := (needed + int(slotMax) - 1) / int(slotMax)
+	return len(this.inv)+slots <= int(this.capacity)
+}
+
+// AddStacked adds an item to the inventory, stacking it on top of the existing stacks
+// of the same item before occupying free slots. Returns the items whose amount changed
+// and the newly added items. If the item doesn't fit, the inventory is left untouched
+// and ok will be false.
+func (this *Inventory) AddStacked(i gamedata.GenericItem,
+	slotMax int16) (updated, added []gamedata.GenericItem, ok bool) {
+
+	if !gamedata.IsStackable(i) || i.Type() == gamedata.ITEM_EQUIP || slotMax <= 1 {
+		if this.Add(i) < 0 {
+			return
+		}
+		return nil, []gamedata.GenericItem{i}, true
+	}
+
+	if !this.CanHold(i.Id(), i.Amount(), slotMax) {
+		return
+	}
+
+	remaining := i.Amount()
+
+	// fill up the existing stacks in slot order
+	for slot := int8(1); slot <= this.capacity && remaining > 0; slot++ {
+		item := this.inv[slot]
+		if item == nil || item.Id() != i.Id() || item.Amount() >= slotMax {
+			continue
+		}
+
+		amount := slotMax - item.Amount()
+		if amount > remaining {
+			amount = remaining
+		}
+
+		item.SetAmount(item.Amount() + amount)
+		remaining -= amount
+		updated = append(updated, item)
+	}
+
+	// put the rest in new slots
+	for remaining > 0 {
+		item := i.Clone()
+		amount := remaining
+		if amount > slotMax {
+			amount = slotMax
+		}
+
+		item.SetAmount(amount)
+		if this.Add(item) < 0 {
+			// shouldn't happen since we checked CanHold
+			return
+		}
+
+		remaining -= amount
+		added = append(added, item)
+	}
+
+	ok = true
+	return
+}
+
+// AddInventorySlotPacket returns a packet that adds a new item to the given inventory
+func AddInventorySlotPacket(invtype InventoryType, item gamedata.GenericItem,
+	fromDrop bool) (p maplelib.Packet) {
+
+	p = packets.NewEncryptedPacket(packets.OModifyInventory)
+	if fromDrop {
+		p.Encode1(1)
+	} else {
+		p.Encode1(0)
+	}
+	p.Encode1(1) // number of operations
+	p.Encode1(packets.InventoryAdd)
+	p.Encode1s(int8(invtype))
+	p.Encode2s(int16(item.Pos()))
+	item.EncodeInfo(&p)
+	return
+}
+
+// SaveInventories saves every item in the given inventories to the database in a
+// single transaction so that a failed save can't wipe the items
+func SaveInventories(charid, userid int32, worldid int8, invs map[int8]*Inventory) (err error) {
+	tr, err := common.GetDB().Begin()
+	if err != nil {
+		return
+	}
+
+	err = saveInventories(tr, charid, userid, worldid, invs)
+	if err != nil {
+		tr.Rollback()
+		return
+	}
+
+	return tr.Commit()
+}
+
+// saveInventories saves the inventories on the given connection or transaction
+func saveInventories(db mysql.ConnCommon, charid, userid int32, worldid int8,
+	invs map[int8]*Inventory) (err error) {
+
+	st, err := db.Prepare("DELETE FROM items WHERE location = 'inventory' AND character_id = ?")
+	if err != nil {
+		return
+	}
+
+	_, err = st.Run(charid)
+	if err != nil {
+		return
+	}
+
+	st, err = db.Prepare(insertItemQuery)
+	if err != nil {
+		return
+	}
+
+	for _, inv := range invs {
+		t := inv.Type()
+		if t == INVENTORY_EQUIPPED {
+			t = INVENTORY_EQUIP
+		}
+
+		for slot, item := range inv.Map() {
+			err = insertItem(st, t, slot, "inventory", charid, userid, worldid, item)
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	return
+}
+
+const insertItemQuery = "INSERT INTO items(inv, slot, location, user_id, world_id, item_id, " +
+	"character_id, amount, owner, upgrade_slots, scroll_level, locked, str, dex, `int`, " +
+	"luk, hp, mp, watk, matk, wdef, mdef, acc, avoid, hands, speed, jump) " +
+	"VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, " +
+	"?, ?, ?, ?, ?)"
+
+// insertItem inserts an item at the given location ('inventory' or 'storage') using
+// a statement prepared from insertItemQuery
+func insertItem(st mysql.Stmt, t InventoryType, slot int8, location string, charid, userid int32,
+	worldid int8, item gamedata.GenericItem) (err error) {
+
+	equip, ok := item.(*gamedata.Equip)
+	if !ok {
+		_, err = st.Run(int8(t), slot, location, userid, worldid, item.Id(), charid,
+			item.Amount(), item.Owner(), nil, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+			0, 0, 0, 0)
+	} else {
+		_, err = st.Run(int8(t), slot, location, userid, worldid, item.Id(), charid,
+			item.Amount(), item.Owner(), equip.Slots(), equip.Level(), equip.Locked(),
+			equip.Str(), equip.Dex(), equip.Int(), equip.Luk(), equip.Hp(), equip.Mp(),
+			equip.WAtk(), equip.MAtk(), equip.WDef(), equip.MDef(), equip.Acc(),
+			equip.Avoid(), equip.Hands(), equip.Speed(), equip.Jump())
+	}
+	return
+}
@@ -0,0 +1,494 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package client
+
+import (
+	"fmt"
+	"math"
+)
+
+import (
+	"github.com/Francesco149/kagami/channelserver/gamedata"
+	"github.com/Francesco149/kagami/common"
+	"github.com/Francesco149/kagami/common/consts"
+	"github.com/Francesco149/kagami/common/packets"
+	"github.com/Francesco149/kagami/common/utils"
+	"github.com/Francesco149/maplelib"
+)
+
+// InventoryById returns the inventory tab the given item id goes into or nil
+// if the id is invalid
+func (c *Connection) InventoryById(itemid int32) *Inventory {
+	t := InventoryTypeById(itemid)
+	if t == INVENTORY_INVALID {
+		return nil
+	}
+	return c.invs[int8(t)]
+}
+
+// CanHold returns true if the player has enough inventory space for the given
+// amount of the given item id
+func (c *Connection) CanHold(itemid int32, amount int16) bool {
+	inv := c.InventoryById(itemid)
+	if inv == nil {
+		return false
+	}
+	return inv.CanHold(itemid, amount, gamedata.SlotMax(itemid))
+}
+
+// GainItem adds an item to the player's inventory, stacking it when possible, and
+// sends the inventory updates. ok is false if the player doesn't have enough room.
+func (c *Connection) GainItem(item gamedata.GenericItem, fromDrop bool) (ok bool, err error) {
+	inv := c.InventoryById(item.Id())
+	if inv == nil {
+		return
+	}
+
+	updated, added, ok := inv.AddStacked(item, gamedata.SlotMax(item.Id()))
+	if !ok {
+		return
+	}
+
+	for _, it := range updated {
+		err = c.SendPacket(packets.UpdateInventorySlot(int8(inv.Type()),
+			int16(it.Pos()), it.Amount(), fromDrop))
+		if err != nil {
+			return
+		}
+	}
+
+	for _, it := range added {
+		err = c.SendPacket(AddInventorySlotPacket(inv.Type(), it, fromDrop))
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// RemoveItem takes the given amount of the item at the given slot and inventory tab
+// and sends the inventory update
+func (c *Connection) RemoveItem(invtype int8, slot int8, amount int16) error {
+	inv := c.invs[invtype]
+	inv.Remove(slot, amount)
+
+	item := inv.Get(slot)
+	if item == nil {
+		return c.SendPacket(packets.ClearInventorySlot(invtype, int16(slot), false))
+	}
+
+	return c.SendPacket(packets.UpdateInventorySlot(invtype, int16(slot), item.Amount(), false))
+}
+
+// ItemCount returns how many of the given item id the player has in their inventory
+func (c *Connection) ItemCount(itemid int32) (res int32) {
+	inv := c.InventoryById(itemid)
+	if inv == nil {
+		return
+	}
+
+	for _, item := range inv.Map() {
+		if item.Id() == itemid {
+			res += int32(item.Amount())
+		}
+	}
+
+	return
+}
+
+// GainItemById creates the given amount of the given item id and adds it to the
+// player's inventory. Equips are created with their base stats.
+// ok is false if the player doesn't have enough room.
+func (c *Connection) GainItemById(itemid int32, amount int16) (ok bool, err error) {
+	if amount <= 0 || !c.CanHold(itemid, amount) {
+		return
+	}
+
+	if InventoryTypeById(itemid) == INVENTORY_EQUIP {
+		for i := int16(0); i < amount; i++ {
+			if ok, err = c.GainItem(gamedata.NewEquipWithBaseStats(itemid, 0), false); !ok || err != nil {
+				return
+			}
+		}
+		return
+	}
+
+	return c.GainItem(gamedata.NewItem(itemid, 0, amount, -1), false)
+}
+
+// RemoveItemById takes the given amount of the given item id from the player's
+// inventory. ok is false if the player doesn't have enough of the item.
+func (c *Connection) RemoveItemById(itemid int32, amount int32) (ok bool, err error) {
+	if amount <= 0 || c.ItemCount(itemid) < amount {
+		return
+	}
+
+	inv := c.InventoryById(itemid)
+	for slot := int8(1); slot <= inv.Capacity() && amount > 0; slot++ {
+		item := inv.Get(slot)
+		if item == nil || item.Id() != itemid {
+			continue
+		}
+
+		n := int32(item.Amount())
+		if n > amount {
+			n = amount
+		}
+
+		if err = c.RemoveItem(int8(inv.Type()), slot, int16(n)); err != nil {
+			return
+		}
+
+		amount -= n
+	}
+
+	ok = true
+	return
+}
+
+// GainMeso adds the given amount of mesos to the player (negative amounts take mesos).
+// ok is false if the player would go over the meso cap or below zero.
+// show displays the gain in the bottom right corner.
+func (c *Connection) GainMeso(amount int32, show bool) (ok bool, err error) {
+	total := int64(c.Meso()) + int64(amount)
+	if total > math.MaxInt32 || total < 0 {
+		return
+	}
+
+	c.SetMeso(int32(total))
+	ok = true
+
+	err = c.SendPacket(packets.UpdatePlayerStats([]utils.Pair{
+		{First: packets.UpdateMeso, Second: c.Meso()},
+	}, true))
+	if err != nil || !show {
+		return
+	}
+
+	err = c.SendPacket(packets.ShowMesoGain(amount))
+	return
+}
+
+// SaveInventories saves all of the player's items to the database
+func (c *Connection) SaveInventories() error {
+	return SaveInventories(c.Stats().Id(), c.UserId(), c.WorldId(), c.savedInventories())
+}
+
+// Equipped returns the inventory that holds the currently worn equips
+func (c *Connection) Equipped() *Inventory { return c.invs[consts.CashInventory+1] }
+
+// EncodeLook encodes the player's appearance and worn equips to a packet
+func (c *Connection) EncodeLook(p *maplelib.Packet) {
+	equips := make([]*common.CharEquipData, 0, len(c.Equipped().Map()))
+	for slot, item := range c.Equipped().Map() {
+		equips = append(equips, common.NewCharEquipData(item.Id(), int16(slot)))
+	}
+	common.EncodeLook(p, c.Stats(), equips)
+}
+
+// UpdateLookPacket returns a packet that shows the player's current look to other players
+func (c *Connection) UpdateLookPacket() (p maplelib.Packet) {
+	p = packets.NewEncryptedPacket(packets.OUpdateCharLook)
+	p.Encode4s(c.Stats().Id())
+	p.Encode1(0x01)
+	c.EncodeLook(&p)
+	p.Encode1(0x00) // TODO: rings
+	p.Encode2(0x0000)
+	return
+}
+
+// MoveItem moves the item at slot src to slot dst in the given inventory tab,
+// stacking items when possible. ok is false if the move is invalid.
+func (c *Connection) MoveItem(invtype int8, src, dst int8) (ok bool, err error) {
+	inv := c.invs[invtype]
+	if inv == nil || src <= 0 || dst <= 0 || dst > inv.Capacity() {
+		return
+	}
+
+	itemA := inv.Get(src)
+	itemB := inv.Get(dst)
+	if itemA == nil || src == dst {
+		return
+	}
+
+	stack := itemB != nil && itemA.Id() == itemB.Id() && gamedata.IsStackable(itemA) &&
+		inv.Type() != INVENTORY_EQUIP
+
+	if err = inv.Move(src, dst, gamedata.SlotMax(itemA.Id())); err != nil {
+		return
+	}
+
+	ok = true
+
+	switch {
+	case !stack:
+		err = c.SendPacket(packets.MoveInventoryItem(invtype, int16(src), int16(dst),
+			packets.MoveNoEquip))
+
+	case inv.Get(src) == nil:
+		err = c.SendPacket(packets.MoveAndMergeInventoryItem(invtype, int16(src), int16(dst),
+			itemB.Amount()))
+
+	default:
+		err = c.SendPacket(packets.MoveAndMergeWithRestInventoryItem(invtype, int16(src),
+			int16(dst), itemA.Amount(), itemB.Amount()))
+	}
+
+	return
+}
+
+// jobCanEquip returns true if the given job satisfies an equip's reqJob bitmask
+func jobCanEquip(reqJob int16, job int16) bool {
+	branch := JobBranch(job)
+
+	switch {
+	case reqJob == 0, branch == BRANCH_GM:
+		return true
+	case reqJob < 0:
+		return branch == BRANCH_BEGINNER
+	case branch == BRANCH_BEGINNER:
+		return false
+	}
+
+	return reqJob&(1<<uint(branch-1)) != 0
+}
+
+// CanEquip returns true if the player meets the level, job and stat requirements
+// of the given equip id and the equip can be worn in the given (negative) slot
+func (c *Connection) CanEquip(itemid int32, dst int8) bool {
+	slot := -int16(dst)
+	cash := slot > 100
+	if cash {
+		slot -= 100
+	}
+
+	if !gamedata.ValidEquipSlot(itemid, slot) {
+		return false
+	}
+
+	info := gamedata.GetItemInfoProvider().Get(itemid)
+	if info == nil || cash != info.Cash() {
+		return false
+	}
+
+	str, dex, intt, luk, _, _ := c.TotalStats()
+	stats := c.Stats()
+
+	return stats.Level() >= info.ReqLevel() &&
+		jobCanEquip(info.ReqJob(), stats.Job()) &&
+		str >= int32(info.ReqStr()) &&
+		dex >= int32(info.ReqDex()) &&
+		intt >= int32(info.ReqInt()) &&
+		luk >= int32(info.ReqLuk())
+}
+
+// EquipItem moves the equip at slot src of the equip inventory to the (negative)
+// equip slot dst, taking off any equip that conflicts with it.
+// ok is false if the player can't wear the item.
+func (c *Connection) EquipItem(src, dst int8) (ok bool, err error) {
+	inv := c.invs[consts.EquipInventory]
+	equipped := c.Equipped()
+
+	item := inv.Get(src)
+	if item == nil || dst >= 0 {
+		return
+	}
+
+	if !c.CanEquip(item.Id(), dst) {
+		fmt.Println(c.Stats().Name(), "tried to equip", item.Id(), "in slot", dst,
+			"without meeting the requirements")
+		return
+	}
+
+	// equips that must be taken off before wearing this one
+	conflicts := make([]int8, 0, 1)
+	switch {
+	case gamedata.IsOverall(item.Id()):
+		conflicts = append(conflicts, -consts.EquipBottom)
+
+	case dst == -consts.EquipBottom:
+		top := equipped.Get(-consts.EquipTop)
+		if top != nil && gamedata.IsOverall(top.Id()) {
+			conflicts = append(conflicts, -consts.EquipTop)
+		}
+
+	case gamedata.IsShield(item.Id()):
+		if c.WeaponType().TwoHanded() {
+			conflicts = append(conflicts, -consts.EquipWeapon)
+		}
+
+	case dst == -consts.EquipWeapon:
+		if WeaponTypeById(item.Id()).TwoHanded() {
+			conflicts = append(conflicts, -consts.EquipShield)
+		}
+	}
+
+	for _, slot := range conflicts {
+		if equipped.Get(slot) == nil {
+			continue
+		}
+
+		free := inv.NextFreeSlot()
+		if free < 0 {
+			err = c.SendPacket(packets.InventoryFull())
+			return
+		}
+
+		if ok, err = c.UnequipItem(slot, free); !ok || err != nil {
+			return
+		}
+	}
+
+	inv.Take(src)
+	old := equipped.Take(dst)
+
+	item.SetPos(dst)
+	equipped.AddWithPosition(item)
+
+	if old != nil {
+		old.SetPos(src)
+		inv.AddWithPosition(old)
+	}
+
+	ok = true
+	err = c.SendPacket(packets.MoveInventoryItem(consts.EquipInventory, int16(src), int16(dst),
+		packets.MoveEquip))
+	return
+}
+
+// UnequipItem takes off the equip in the (negative) slot src and puts it in the free
+// slot dst of the equip inventory. ok is false if the move is invalid.
+func (c *Connection) UnequipItem(src, dst int8) (ok bool, err error) {
+	inv := c.invs[consts.EquipInventory]
+	equipped := c.Equipped()
+
+	if src >= 0 || dst <= 0 || dst > inv.Capacity() || equipped.Get(src) == nil {
+		return
+	}
+
+	if inv.Get(dst) != nil {
+		// swapping with an unequipped item isn't allowed
+		err = c.SendPacket(packets.InventoryFull())
+		return
+	}
+
+	item := equipped.Take(src)
+	item.SetPos(dst)
+	inv.AddWithPosition(item)
+
+	ok = true
+	err = c.SendPacket(packets.MoveInventoryItem(consts.EquipInventory, int16(src), int16(dst),
+		packets.MoveUnequip))
+	return
+}
+
+// DropItem takes the given quantity of the item at slot src of the given inventory tab
+// and drops it on the ground. ok is false if the drop is invalid.
+func (c *Connection) DropItem(invtype int8, src int8, quantity int16) (ok bool, err error) {
+	inv := c.invs[invtype]
+	if src < 0 {
+		inv = c.Equipped()
+	}
+
+	if inv == nil {
+		return
+	}
+
+	item := inv.Get(src)
+	if item == nil || quantity <= 0 || quantity > item.Amount() {
+		return
+	}
+
+	// rechargeable ammo is always dropped as a whole
+	if !gamedata.IsStackable(item) {
+		quantity = item.Amount()
+	}
+
+	var dropped gamedata.GenericItem
+
+	if quantity == item.Amount() {
+		dropped = inv.Take(src)
+		err = c.SendPacket(packets.ClearInventorySlot(invtype, int16(src), true))
+	} else {
+		dropped = item.Clone()
+		dropped.SetAmount(quantity)
+		inv.Remove(src, quantity)
+		err = c.SendPacket(packets.UpdateInventorySlot(invtype, int16(src), item.Amount(), true))
+	}
+
+	if err != nil {
+		return
+	}
+
+	ok = true
+
+	// untradeable items disappear when dropped
+	info := gamedata.GetItemInfoProvider().Get(dropped.Id())
+	if info != nil && !info.Tradeable() {
+		return
+	}
+
+	dropped.SetPos(0)
+	c.Map().SpawnPlayerDrop(dropped, c)
+	return
+}
+
+// GatherItems merges the stacks and moves every item of the given inventory tab
+// to the lowest free slots
+func (c *Connection) GatherItems(invtype int8) (err error) {
+	inv := c.invs[invtype]
+	if inv == nil || inv.Type() == INVENTORY_EQUIPPED {
+		return
+	}
+
+	// merge stacks of the same item into the lowest slot
+	for dst := int8(1); dst <= inv.Capacity(); dst++ {
+		for src := dst + 1; src <= inv.Capacity(); src++ {
+			a := inv.Get(dst)
+			b := inv.Get(src)
+
+			if a == nil || b == nil || a.Id() != b.Id() || !gamedata.IsStackable(a) ||
+				inv.Type() == INVENTORY_EQUIP || a.Amount() >= gamedata.SlotMax(a.Id()) {
+				continue
+			}
+
+			if _, err = c.MoveItem(invtype, src, dst); err != nil {
+				return
+			}
+		}
+	}
+
+	// fill up the gaps
+	for dst := int8(1); dst <= inv.Capacity(); dst++ {
+		if inv.Get(dst) != nil {
+			continue
+		}
+
+		for src := dst + 1; src <= inv.Capacity(); src++ {
+			if inv.Get(src) == nil {
+				continue
+			}
+
+			if _, err = c.MoveItem(invtype, src, dst); err != nil {
+				return
+			}
+			break
+		}
+	}
+
+	return c.SendPacket(packets.FinishSort(invtype))
+}
@@ -0,0 +1,329 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package client
+
+import (
+	"errors"
+	"fmt"
+)
+
+import (
+	"github.com/Francesco149/kagami/common/packets"
+	"github.com/Francesco149/kagami/common/utils"
+)
+
+// Job ids
+const (
+	JOB_BEGINNER = 0
+
+	JOB_WARRIOR       = 100
+	JOB_FIGHTER       = 110
+	JOB_CRUSADER      = 111
+	JOB_HERO          = 112
+	JOB_PAGE          = 120
+	JOB_WHITE_KNIGHT  = 121
+	JOB_PALADIN       = 122
+	JOB_SPEARMAN      = 130
+	JOB_DRAGON_KNIGHT = 131
+	JOB_DARK_KNIGHT   = 132
+
+	JOB_MAGICIAN    = 200
+	JOB_FP_WIZARD   = 210
+	JOB_FP_MAGE     = 211
+	JOB_FP_ARCHMAGE = 212
+	JOB_IL_WIZARD   = 220
+	JOB_IL_MAGE     = 221
+	JOB_IL_ARCHMAGE = 222
+	JOB_CLERIC      = 230
+	JOB_PRIEST      = 231
+	JOB_BISHOP      = 232
+
+	JOB_BOWMAN      = 300
+	JOB_HUNTER      = 310
+	JOB_RANGER      = 311
+	JOB_BOWMASTER   = 312
+	JOB_CROSSBOWMAN = 320
+	JOB_SNIPER      = 321
+	JOB_MARKSMAN    = 322
+
+	JOB_THIEF        = 400
+	JOB_ASSASSIN     = 410
+	JOB_HERMIT       = 411
+	JOB_NIGHT_LORD   = 412
+	JOB_BANDIT       = 420
+	JOB_CHIEF_BANDIT = 421
+	JOB_SHADOWER     = 422
+
+	JOB_PIRATE     = 500
+	JOB_BRAWLER    = 510
+	JOB_MARAUDER   = 511
+	JOB_BUCCANEER  = 512
+	JOB_GUNSLINGER = 520
+	JOB_OUTLAW     = 521
+	JOB_CORSAIR    = 522
+
+	JOB_GM      = 900
+	JOB_SUPERGM = 910
+)
+
+// JobAdvancement returns the advancement number of the given job
+// (0 for beginner, 1 to 4 for 1st to 4th job)
+func JobAdvancement(job int16) int {
+	switch {
+	case job == JOB_BEGINNER:
+		return 0
+	case job%100 == 0:
+		return 1
+	}
+
+	return 2 + int(job%10)
+}
+
+// IsValidJob returns true if the given job id exists in v62
+func IsValidJob(job int16) bool {
+	if job == JOB_BEGINNER || job == JOB_GM || job == JOB_SUPERGM {
+		return true
+	}
+
+	branch, path, adv := job/100, job/10%10, job%10
+	switch {
+	case branch < BRANCH_WARRIOR || branch > BRANCH_PIRATE:
+		return false
+	case path == 0:
+		return adv == 0
+	case adv > 2:
+		return false
+	case branch == BRANCH_WARRIOR || branch == BRANCH_MAGICIAN:
+		return path <= 3
+	}
+
+	return path <= 2
+}
+
+// IsNextJob returns true if next is the advancement that directly follows job
+func IsNextJob(job, next int16) bool {
+	if !IsValidJob(next) || JobBranch(next) == BRANCH_GM {
+		return false
+	}
+
+	switch JobAdvancement(job) {
+	case 0:
+		return JobAdvancement(next) == 1
+	case 1:
+		return JobAdvancement(next) == 2 && JobBranch(next) == JobBranch(job)
+	}
+
+	return next == job+1 && JobAdvancement(next) <= 4
+}
+
+// JobLevelRequired returns the level required to advance to the given job
+func JobLevelRequired(job int16) byte {
+	switch JobAdvancement(job) {
+	case 1:
+		if JobBranch(job) == BRANCH_MAGICIAN {
+			return 8
+		}
+		return 10
+	case 2:
+		return 30
+	case 3:
+		return 70
+	case 4:
+		return 120
+	}
+
+	return 0
+}
+
+// firstJobStatRequired returns the stat requirement of the 1st job of the given branch
+// as a stat update constant and the required value
+func firstJobStatRequired(branch int16) (stat int, value int16) {
+	switch branch {
+	case BRANCH_WARRIOR:
+		return packets.UpdateStr, 35
+	case BRANCH_MAGICIAN:
+		return packets.UpdateInt, 20
+	case BRANCH_PIRATE:
+		return packets.UpdateDex, 20
+	}
+
+	return packets.UpdateDex, 25
+}
+
+// advancementGrowth returns the max hp and max mp gained when advancing to the given
+// job. The 3rd and 4th job advancements give the same growth as the 2nd.
+func advancementGrowth(job int16) (hp, mp int16) {
+	switch JobAdvancement(job) {
+	case 1:
+		switch JobBranch(job) {
+		case BRANCH_WARRIOR:
+			return randRange(200, 250), 0
+		case BRANCH_MAGICIAN:
+			return 0, randRange(100, 150)
+		}
+		return randRange(100, 150), randRange(25, 50)
+
+	case 2, 3, 4:
+		switch JobBranch(job) {
+		case BRANCH_WARRIOR:
+			return randRange(300, 350), 0
+		case BRANCH_MAGICIAN:
+			return 0, randRange(450, 500)
+		}
+		return randRange(300, 350), randRange(150, 200)
+	}
+
+	return 0, 0
+}
+
+// CanAdvanceJob checks if the player meets the requirements to advance to the given
+// job. reason describes the first requirement that isn't met.
+func (c *Connection) CanAdvanceJob(job int16) (ok bool, reason string) {
+	stats := c.Stats()
+
+	if !IsNextJob(stats.Job(), job) {
+		return false, fmt.Sprintf("%d is not the next advancement of %d", job, stats.Job())
+	}
+
+	if stats.Level() < JobLevelRequired(job) {
+		return false, fmt.Sprintf("level %d is required", JobLevelRequired(job))
+	}
+
+	if JobAdvancement(job) == 1 {
+		stat, value := firstJobStatRequired(JobBranch(job))
+
+		var cur int16
+		switch stat {
+		case packets.UpdateStr:
+			cur = stats.Str()
+		case packets.UpdateDex:
+			cur = stats.Dex()
+		case packets.UpdateInt:
+			cur = stats.Int()
+		}
+
+		if cur < value {
+			return false, fmt.Sprintf("%d base stat is required", value)
+		}
+	}
+
+	return true, ""
+}
+
+// AdvanceJob advances the player to the given job if the requirements are met
+func (c *Connection) AdvanceJob(job int16) (ok bool, err error) {
+	ok, reason := c.CanAdvanceJob(job)
+	if !ok {
+		fmt.Println(c.Stats().Name(), "can't advance to job", job, "-", reason)
+		return
+	}
+
+	err = c.ChangeJob(job)
+	return
+}
+
+// ChangeJob changes the player's job without checking the requirements, applies the
+// SP, HP and MP bonuses of the advancement, resets skills that don't belong to the
+// new job and saves the character immediately
+func (c *Connection) ChangeJob(job int16) (err error) {
+	if !IsValidJob(job) {
+		return errors.New(fmt.Sprint("invalid job ", job))
+	}
+
+	stats := c.Stats()
+	oldjob := stats.Job()
+	if oldjob == job {
+		return
+	}
+
+	sp := stats.Sp()
+
+	// skills that the new job can't use are reset and their sp is refunded
+	for skillid, s := range c.skills {
+		if IsJobPathOf(job, int16(skillid/10000)) {
+			continue
+		}
+
+		if JobBranch(int16(skillid/10000)) != BRANCH_BEGINNER {
+			sp += int16(s.level)
+		}
+
+		err = c.SetSkillLevel(skillid, 0, 0)
+		if err != nil {
+			return
+		}
+	}
+
+	if IsNextJob(oldjob, job) {
+		if JobAdvancement(job) == 1 {
+			// sp for the levels past the required one is given on late advancement.
+			// gm commands can advance below the required level.
+			sp++
+			if stats.Level() > JobLevelRequired(job) {
+				sp += 3 * (int16(stats.Level()) - int16(JobLevelRequired(job)))
+			}
+		} else {
+			sp++
+		}
+
+		// ap is only given on level up in this version and beginners can already
+		// spend theirs, so advancing doesn't adjust it
+
+		hp, mp := advancementGrowth(job)
+		stats.SetMaxHp(addCapped(stats.MaxHp(), hp, MaxHpMp))
+		stats.SetMaxMp(addCapped(stats.MaxMp(), mp, MaxHpMp))
+	}
+
+	if JobBranch(job) == BRANCH_BEGINNER {
+		sp = 0
+	}
+
+	stats.SetJob(job)
+	stats.SetSp(sp)
+	stats.SetHp(stats.MaxHp())
+	stats.SetMp(stats.MaxMp())
+
+	fmt.Println(stats.Name(), "changed job from", oldjob, "to", job)
+
+	err = c.SendPacket(packets.UpdatePlayerStats([]utils.Pair{
+		{First: packets.UpdateJob, Second: stats.Job()},
+		{First: packets.UpdateMaxHp, Second: stats.MaxHp()},
+		{First: packets.UpdateHp, Second: stats.Hp()},
+		{First: packets.UpdateMaxMp, Second: stats.MaxMp()},
+		{First: packets.UpdateMp, Second: stats.Mp()},
+		{First: packets.UpdateAp, Second: stats.Ap()},
+		{First: packets.UpdateSp, Second: stats.Sp()},
+	}, false))
+	if err != nil {
+		return
+	}
+
+	err = c.SendPacket(packets.ShowOwnEffect(packets.EffectJobChange))
+	if err != nil {
+		return
+	}
+
+	c.Map().Broadcast(packets.ShowForeignEffect(c.CharId(), packets.EffectJobChange), c.CharId())
+	c.SyncWorld()
+	c.UpdatePartyHp(false)
+
+	err = c.SaveStats()
+	if err != nil {
+		return
+	}
+
+	return c.SaveSkills()
+}
@@ -0,0 +1,189 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package client
+
+import (
+	"github.com/Francesco149/kagami/common"
+	"github.com/Francesco149/kagami/common/consts"
+	"github.com/Francesco149/kagami/common/packets"
+)
+
+// LoadKeymap retrieves the given character's keyboard layout from the database
+func (c *Connection) LoadKeymap(charid int32) (err error) {
+	c.keymap = [consts.KeymapSize]packets.KeyBinding{}
+
+	st, err := common.GetDB().Prepare("SELECT * FROM keymap WHERE character_id = ?")
+	if err != nil {
+		return
+	}
+
+	res, err := st.Run(charid)
+	rows, err := res.GetRows()
+	if err != nil {
+		return
+	}
+
+	colkey := res.Map("key")
+	coltype := res.Map("type")
+	colaction := res.Map("action")
+
+	for _, row := range rows {
+		key := row.Int(colkey)
+		if key < 0 || key >= consts.KeymapSize {
+			continue
+		}
+
+		c.keymap[key] = packets.KeyBinding{
+			Type:   byte(row.Int(coltype)),
+			Action: int32(row.Int(colaction)),
+		}
+	}
+
+	return
+}
+
+// SendKeymap sends the player's keyboard layout
+func (c *Connection) SendKeymap() error { return c.SendPacket(packets.Keymap(&c.keymap)) }
+
+// ChangeKeymap changes the given key bindings and saves them immediately.
+// bindings with type KeyTypeNone clear the key.
+func (c *Connection) ChangeKeymap(changes map[int32]packets.KeyBinding) (err error) {
+	db := common.GetDB()
+
+	del, err := db.Prepare("DELETE FROM keymap WHERE character_id = ? AND `key` = ?")
+	if err != nil {
+		return
+	}
+
+	ins, err := db.Prepare("REPLACE INTO keymap(character_id, `key`, type, action) " +
+		"VALUES(?, ?, ?, ?)")
+	if err != nil {
+		return
+	}
+
+	for key, binding := range changes {
+		if key < 0 || key >= consts.KeymapSize {
+			continue
+		}
+
+		if binding.Type == consts.KeyTypeNone {
+			binding.Action = 0
+			_, err = del.Run(c.CharId(), key)
+		} else {
+			_, err = ins.Run(c.CharId(), key, binding.Type, binding.Action)
+		}
+		if err != nil {
+			return
+		}
+
+		c.keymap[key] = binding
+	}
+
+	return
+}
+
+// LoadSkillMacros retrieves the given character's skill macros from the database
+func (c *Connection) LoadSkillMacros(charid int32) (err error) {
+	c.macros = nil
+
+	st, err := common.GetDB().Prepare("SELECT * FROM skill_macros WHERE character_id = ? " +
+		"ORDER BY position")
+	if err != nil {
+		return
+	}
+
+	res, err := st.Run(charid)
+	rows, err := res.GetRows()
+	if err != nil {
+		return
+	}
+
+	colpos := res.Map("position")
+	colname := res.Map("name")
+	colshout := res.Map("shout")
+	colskill1 := res.Map("skill1")
+	colskill2 := res.Map("skill2")
+	colskill3 := res.Map("skill3")
+
+	for _, row := range rows {
+		pos := row.Int(colpos)
+		if pos >= consts.MaxSkillMacros {
+			continue
+		}
+
+		// fill the gaps left by empty slots
+		for len(c.macros) < pos {
+			c.macros = append(c.macros, packets.SkillMacro{})
+		}
+
+		c.macros = append(c.macros, packets.SkillMacro{
+			Name:  row.Str(colname),
+			Shout: row.Int(colshout) != 0,
+			Skills: [3]int32{
+				int32(row.Int(colskill1)),
+				int32(row.Int(colskill2)),
+				int32(row.Int(colskill3)),
+			},
+		})
+	}
+
+	return
+}
+
+// SendSkillMacros sends the player's skill macros. Nothing is sent if the player has
+// no macros.
+func (c *Connection) SendSkillMacros() error {
+	if len(c.macros) == 0 {
+		return nil
+	}
+	return c.SendPacket(packets.SkillMacros(c.macros))
+}
+
+// SetSkillMacros replaces the player's skill macros and saves them immediately
+func (c *Connection) SetSkillMacros(macros []packets.SkillMacro) (err error) {
+	if len(macros) > consts.MaxSkillMacros {
+		macros = macros[:consts.MaxSkillMacros]
+	}
+
+	db := common.GetDB()
+
+	st, err := db.Prepare("DELETE FROM skill_macros WHERE character_id = ?")
+	if err != nil {
+		return
+	}
+
+	_, err = st.Run(c.CharId())
+	if err != nil {
+		return
+	}
+
+	st, err = db.Prepare("INSERT INTO skill_macros(character_id, position, name, shout, " +
+		"skill1, skill2, skill3) VALUES(?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return
+	}
+
+	for i, macro := range macros {
+		_, err = st.Run(c.CharId(), i, macro.Name, macro.Shout,
+			macro.Skills[0], macro.Skills[1], macro.Skills[2])
+		if err != nil {
+			return
+		}
+	}
+
+	c.macros = macros
+	return
+}
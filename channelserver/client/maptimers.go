@@ -0,0 +1,144 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package client
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+import (
+	"github.com/Francesco149/kagami/channelserver/gamedata"
+	"github.com/Francesco149/kagami/common/packets"
+)
+
+// hpDecInterval is how often maps with decHP drain the player's hp
+const hpDecInterval = 10 * time.Second
+
+// mapTimers holds the timers started when the player enters a map with a time limit
+// or hp drain
+type mapTimers struct {
+	timeLimit *time.Timer
+	hpDec     *time.Timer
+	mut       sync.Mutex
+}
+
+// stop cancels all of the map timers
+func (this *mapTimers) stop() {
+	this.mut.Lock()
+	defer this.mut.Unlock()
+
+	if this.timeLimit != nil {
+		this.timeLimit.Stop()
+		this.timeLimit = nil
+	}
+
+	if this.hpDec != nil {
+		this.hpDec.Stop()
+		this.hpDec = nil
+	}
+}
+
+// ForcedReturnMap returns the map the player is sent to when logging in or running
+// out of time in the given map
+func ForcedReturnMap(m *gamedata.MapleMap) int32 {
+	if m.ForcedReturnMap() != gamedata.NoForcedReturn {
+		return m.ForcedReturnMap()
+	}
+	return m.ReturnMapId()
+}
+
+// HasProtectItem returns true if the player is wearing or carrying the given item
+func (c *Connection) HasProtectItem(itemid int32) bool {
+	if c.Equipped().ById(itemid) != nil {
+		return true
+	}
+	return c.ItemCount(itemid) > 0
+}
+
+// StartMapTimers shows the clock and starts the time limit and hp drain of the
+// current map. Called after the player is added to the map.
+func (c *Connection) StartMapTimers() (err error) {
+	m := c.Map()
+
+	c.mapTimers.stop()
+	c.mapTimers.mut.Lock()
+	defer c.mapTimers.mut.Unlock()
+
+	if m.TimeLimit() > 0 {
+		limit := time.Duration(m.TimeLimit()) * time.Second
+		c.mapTimers.timeLimit = time.AfterFunc(limit, func() { c.timeUp(m) })
+
+		err = c.SendPacket(packets.Timer(int32(m.TimeLimit())))
+	} else if m.Clock() {
+		err = c.SendPacket(packets.Clock(time.Now()))
+	}
+
+	if err != nil {
+		return
+	}
+
+	if m.HPDec() > 0 {
+		c.mapTimers.hpDec = time.AfterFunc(hpDecInterval, func() { c.drainHp(m) })
+	}
+
+	return
+}
+
+// StopMapTimers cancels the time limit and hp drain of the current map
+func (c *Connection) StopMapTimers() {
+	c.mapTimers.stop()
+}
+
+// timeUp sends the player out of a time limited map
+func (c *Connection) timeUp(m *gamedata.MapleMap) {
+	c.LockActions()
+	defer c.UnlockActions()
+
+	if c.Disconnecting() || c.Map() != m {
+		return
+	}
+
+	err := c.ChangeMap(ForcedReturnMap(m), 0)
+	if err != nil {
+		fmt.Println("Failed to send", c.Stats().Name(), "out of map", m.Id(), ":", err)
+	}
+}
+
+// drainHp takes away the map's decHP from the player unless they have the protect
+// item and schedules the next drain
+func (c *Connection) drainHp(m *gamedata.MapleMap) {
+	c.LockActions()
+	defer c.UnlockActions()
+
+	c.mapTimers.mut.Lock()
+	if c.Disconnecting() || c.Map() != m || c.mapTimers.hpDec == nil {
+		c.mapTimers.mut.Unlock()
+		return
+	}
+
+	c.mapTimers.hpDec = time.AfterFunc(hpDecInterval, func() { c.drainHp(m) })
+	c.mapTimers.mut.Unlock()
+
+	if !c.Alive() || (m.HPDecProtect() != 0 && c.HasProtectItem(m.HPDecProtect())) {
+		return
+	}
+
+	if err := c.AddHpMp(-m.HPDec(), 0); err != nil {
+		fmt.Println("Failed to drain hp for", c.Stats().Name(), ":", err)
+	}
+}
@@ -0,0 +1,218 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package client
+
+import (
+	"github.com/Francesco149/kagami/channelserver/status"
+	"github.com/Francesco149/kagami/common/interserver"
+	"github.com/Francesco149/kagami/common/packets"
+	"github.com/Francesco149/maplelib"
+)
+
+// Party returns the player's party or nil. The party is owned by the worldserver and
+// must not be modified.
+func (c *Connection) Party() *interserver.Party {
+	c.partyMut.Lock()
+	defer c.partyMut.Unlock()
+	return c.party
+}
+
+// SetParty updates the player's party as pushed by the worldserver
+func (c *Connection) SetParty(party *interserver.Party) {
+	c.partyMut.Lock()
+	defer c.partyMut.Unlock()
+	c.party = party
+}
+
+// PartyId returns the id of the player's party or 0 if the player isn't in a party
+func (c *Connection) PartyId() int32 {
+	party := c.Party()
+	if party == nil {
+		return 0
+	}
+	return party.Id
+}
+
+// SendToWorld sends an inter-server packet to the worldserver.
+// NOTE: this must not be called while holding the status
+func SendToWorld(p maplelib.Packet) error {
+	st := <-status.Get
+	worldConn := st.WorldConn()
+	status.Get <- st
+	return worldConn.SendPacket(p)
+}
+
+// SyncWorld notifies the worldserver that the player's job, level or map changed
+func (c *Connection) SyncWorld() {
+	if !c.Connected() {
+		return
+	}
+
+	stats := c.Stats()
+	SendToWorld(interserver.SyncPlayerUpdate(c.CharId(), stats.Job(), stats.Level(), c.Map().Id()))
+}
+
+// UpdatePartyHp shows the player's hp bar to the party members on the same map.
+// receive also shows their hp bars to the player.
+func (c *Connection) UpdatePartyHp(receive bool) {
+	party := c.Party()
+	if party == nil || c.Map() == nil {
+		return
+	}
+
+	stats := c.Stats()
+
+	for _, m := range party.Members {
+		if m.CharId == c.CharId() {
+			continue
+		}
+
+		other, ok := c.Map().Player(m.CharId).(*Connection)
+		if !ok {
+			continue
+		}
+
+		other.SendPacket(packets.UpdatePartyMemberHp(c.CharId(), stats.Hp(), stats.MaxHp()))
+
+		if receive {
+			c.SendPacket(packets.UpdatePartyMemberHp(other.CharId(),
+				other.Stats().Hp(), other.Stats().MaxHp()))
+		}
+	}
+}
+
+// encodePartyStatus encodes the members of a party as seen from the given channel
+func encodePartyStatus(p *maplelib.Packet, party *interserver.Party, forChannel int8) {
+	members := make([]*interserver.PartyMember, interserver.MaxPartySize)
+	copy(members, party.Members)
+
+	for _, m := range members {
+		if m == nil {
+			p.Encode4s(0)
+		} else {
+			p.Encode4s(m.CharId)
+		}
+	}
+
+	for _, m := range members {
+		name := ""
+		if m != nil {
+			name = m.Name
+		}
+		packets.EncodePaddedString(p, name, 13)
+	}
+
+	for _, m := range members {
+		if m == nil {
+			p.Encode4s(0)
+		} else {
+			p.Encode4s(int32(m.Job))
+		}
+	}
+
+	for _, m := range members {
+		if m == nil {
+			p.Encode4s(0)
+		} else {
+			p.Encode4s(int32(m.Level))
+		}
+	}
+
+	for _, m := range members {
+		if m == nil || m.Channel < 0 {
+			p.Encode4s(-2) // offline
+		} else {
+			p.Encode4s(int32(m.Channel))
+		}
+	}
+
+	p.Encode4s(party.Leader)
+
+	for _, m := range members {
+		if m != nil && m.Channel == forChannel {
+			p.Encode4s(m.MapId)
+		} else {
+			p.Encode4s(0)
+		}
+	}
+
+	// mystic doors
+	for range members {
+		p.Encode4s(999999999)
+		p.Encode4s(999999999)
+		p.Encode4s(-1)
+		p.Encode4s(-1)
+	}
+}
+
+// PartyPacket returns the client packet for a party change pushed by the worldserver
+// (see interserver.SyncParty). forChannel is the id of the channel the packet is
+// sent from.
+func PartyPacket(op byte, party *interserver.Party, target int32, targetName string,
+	forChannel int8) (p maplelib.Packet) {
+
+	p = packets.NewEncryptedPacket(packets.OParty)
+
+	switch op {
+	case interserver.PartySyncCreate:
+		p.Encode1(packets.PartyOpCreated)
+		p.Encode4s(party.Id)
+		p.Encode4s(999999999) // door town
+		p.Encode4s(999999999) // door target
+		p.Encode4s(0)         // door x
+		p.Encode4s(0)         // door y
+
+	case interserver.PartySyncJoin:
+		p.Encode1(packets.PartyOpJoin)
+		p.Encode4s(party.Id)
+		p.EncodeString(targetName)
+		encodePartyStatus(&p, party, forChannel)
+
+	case interserver.PartySyncLeave, interserver.PartySyncExpel:
+		p.Encode1(packets.PartyOpLeave)
+		p.Encode4s(party.Id)
+		p.Encode4s(target)
+		p.Encode1(0x01) // not disbanded
+
+		if op == interserver.PartySyncExpel {
+			p.Encode1(0x01)
+		} else {
+			p.Encode1(0x00)
+		}
+
+		p.EncodeString(targetName)
+		encodePartyStatus(&p, party, forChannel)
+
+	case interserver.PartySyncDisband:
+		p.Encode1(packets.PartyOpLeave)
+		p.Encode4s(party.Id)
+		p.Encode4s(target)
+		p.Encode1(0x00) // disbanded
+		p.Encode4s(party.Id)
+
+	case interserver.PartySyncLeader:
+		p.Encode1(packets.PartyOpLeader)
+		p.Encode4s(target)
+		p.Encode1(0x00) // what the hell is this
+
+	default:
+		p.Encode1(packets.PartyOpSilentUpdate)
+		p.Encode4s(party.Id)
+		encodePartyStatus(&p, party, forChannel)
+	}
+
+	return
+}
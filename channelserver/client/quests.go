@@ -0,0 +1,566 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package client
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+import (
+	"github.com/Francesco149/kagami/channelserver/gamedata"
+	"github.com/Francesco149/kagami/common"
+	"github.com/Francesco149/kagami/common/packets"
+	"github.com/Francesco149/kagami/common/utils"
+	"github.com/Francesco149/maplelib"
+)
+
+// QuestStatus holds a character's progress on a quest
+type QuestStatus struct {
+	id        int16
+	status    byte   // gamedata.QuestStarted or gamedata.QuestCompleted
+	progress  string // quest info string, holds monster kill counts as 3 digits each
+	completed int64  // unix time of completion in seconds
+}
+
+func (this *QuestStatus) Id() int16        { return this.id }
+func (this *QuestStatus) Status() byte     { return this.status }
+func (this *QuestStatus) Progress() string { return this.progress }
+func (this *QuestStatus) Completed() int64 { return this.completed }
+
+// mobKills returns the kill count for the i-th monster requirement
+func (this *QuestStatus) mobKills(i int) int32 {
+	if len(this.progress) < i*3+3 {
+		return 0
+	}
+
+	res, err := strconv.Atoi(this.progress[i*3 : i*3+3])
+	if err != nil {
+		return 0
+	}
+
+	return int32(res)
+}
+
+// setMobKills sets the kill count for the i-th monster requirement
+func (this *QuestStatus) setMobKills(i int, kills int32) {
+	for len(this.progress) < i*3+3 {
+		this.progress += "000"
+	}
+
+	this.progress = this.progress[:i*3] + fmt.Sprintf("%03d", kills) +
+		this.progress[i*3+3:]
+}
+
+// Quest returns the player's status on the given quest or nil if he never started it
+func (c *Connection) Quest(questid int16) *QuestStatus {
+	c.questMut.Lock()
+	defer c.questMut.Unlock()
+	return c.quests[questid]
+}
+
+// QuestState returns gamedata.QuestNotStarted, gamedata.QuestStarted or
+// gamedata.QuestCompleted
+func (c *Connection) QuestState(questid int16) byte {
+	q := c.Quest(questid)
+	if q == nil {
+		return gamedata.QuestNotStarted
+	}
+	return q.Status()
+}
+
+// LoadQuests retrieves the given character's quests from the database
+func (c *Connection) LoadQuests(charid int32) (err error) {
+	c.quests = make(map[int16]*QuestStatus)
+
+	db := common.GetDB()
+	st, err := db.Prepare("SELECT * FROM quests WHERE character_id = ?")
+	if err != nil {
+		return
+	}
+
+	res, err := st.Run(charid)
+	rows, err := res.GetRows()
+	if err != nil {
+		return
+	}
+
+	colquestid := res.Map("quest_id")
+	colstatus := res.Map("status")
+	colprogress := res.Map("progress")
+	colcompleted := res.Map("completed_time")
+
+	for _, row := range rows {
+		q := &QuestStatus{
+			id:        int16(row.Int(colquestid)),
+			status:    byte(row.Int(colstatus)),
+			progress:  row.Str(colprogress),
+			completed: row.Int64(colcompleted),
+		}
+		c.quests[q.id] = q
+	}
+
+	return
+}
+
+// SaveQuests saves the player's quests to the database
+func (c *Connection) SaveQuests() (err error) {
+	db := common.GetDB()
+
+	st, err := db.Prepare("DELETE FROM quests WHERE character_id = ?")
+	if err != nil {
+		return
+	}
+
+	_, err = st.Run(c.CharId())
+	if err != nil {
+		return
+	}
+
+	st, err = db.Prepare("INSERT INTO quests(character_id, quest_id, status, progress, " +
+		"completed_time) VALUES(?, ?, ?, ?, ?)")
+	if err != nil {
+		return
+	}
+
+	c.questMut.Lock()
+	defer c.questMut.Unlock()
+
+	for _, q := range c.quests {
+		_, err = st.Run(c.CharId(), q.id, q.status, q.progress, q.completed)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// EncodeQuestInfo encodes the started and completed quests in the character info packet
+func (c *Connection) EncodeQuestInfo(p *maplelib.Packet) {
+	c.questMut.Lock()
+	defer c.questMut.Unlock()
+
+	started := make([]*QuestStatus, 0)
+	completed := make([]*QuestStatus, 0)
+
+	for _, q := range c.quests {
+		if q.status == gamedata.QuestCompleted {
+			completed = append(completed, q)
+		} else {
+			started = append(started, q)
+		}
+	}
+
+	p.Encode2(uint16(len(started)))
+	for _, q := range started {
+		p.Encode2s(q.id)
+		p.EncodeString(q.progress)
+	}
+
+	p.Encode2(uint16(len(completed)))
+	for _, q := range completed {
+		p.Encode2s(q.id)
+		p.Encode8(utils.UnixToQuestTimestamp(q.completed))
+	}
+}
+
+// meetsQuestCheck returns true if the player satisfies the given quest requirements.
+// npcid is the npc the player is talking to or -1 to skip the npc check
+func (c *Connection) meetsQuestCheck(quest *gamedata.Quest, check *gamedata.QuestCheck,
+	npcid int32) bool {
+
+	stats := c.Stats()
+
+	if npcid >= 0 && check.Npc() != 0 && check.Npc() != npcid {
+		return false
+	}
+
+	if stats.Level() < check.LvMin() || (check.LvMax() != 0 && stats.Level() > check.LvMax()) {
+		return false
+	}
+
+	if stats.Fame() < check.Pop() {
+		return false
+	}
+
+	if len(check.Jobs()) > 0 {
+		found := false
+		for _, job := range check.Jobs() {
+			if job == stats.Job() {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	for _, prereq := range check.Quests() {
+		if c.QuestState(prereq.Id()) != prereq.State() {
+			return false
+		}
+	}
+
+	for _, item := range check.Items() {
+		if item.Count() > 0 && c.ItemCount(item.Id()) < item.Count() {
+			return false
+		}
+	}
+
+	if len(check.Mobs()) > 0 {
+		status := c.Quest(quest.Id())
+		if status == nil {
+			return false
+		}
+
+		c.questMut.Lock()
+		defer c.questMut.Unlock()
+
+		for i, mob := range check.Mobs() {
+			if status.mobKills(i) < mob.Count() {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// questRewards filters the items of a quest act by gender and picks one of the
+// random rewards
+func (c *Connection) questRewards(act *gamedata.QuestAct) (res []*gamedata.QuestItem) {
+	res = make([]*gamedata.QuestItem, 0)
+	pool := make([]*gamedata.QuestItem, 0)
+	totalProp := int32(0)
+
+	for _, item := range act.Items() {
+		if item.Gender() != 2 && item.Gender() != c.Stats().Gender() {
+			continue
+		}
+
+		if item.Prop() > 0 {
+			pool = append(pool, item)
+			totalProp += item.Prop()
+		} else {
+			res = append(res, item)
+		}
+	}
+
+	if totalProp > 0 {
+		roll := rand.Int31n(totalProp)
+		for _, item := range pool {
+			roll -= item.Prop()
+			if roll < 0 {
+				res = append(res, item)
+				break
+			}
+		}
+	}
+
+	return
+}
+
+// canHoldQuestRewards returns true if the player has room for the given items
+func (c *Connection) canHoldQuestRewards(items []*gamedata.QuestItem) bool {
+	for _, item := range items {
+		if item.Count() > 0 && !c.CanHold(item.Id(), int16(item.Count())) {
+			return false
+		}
+	}
+	return true
+}
+
+// canPayQuestAct returns true if the player has enough mesos for the fee of a quest
+// act, if any
+func (c *Connection) canPayQuestAct(act *gamedata.QuestAct) bool {
+	return act.Meso() >= 0 || c.Meso() >= -act.Meso()
+}
+
+// checkQuestAct returns true if the player has room for the rewards and can pay the
+// fee of a quest act. Otherwise the npc is told why and false is returned.
+func (c *Connection) checkQuestAct(questid int16, npcid int32, act *gamedata.QuestAct,
+	items []*gamedata.QuestItem) (ok bool, err error) {
+
+	result := byte(0)
+	switch {
+	case !c.canHoldQuestRewards(items):
+		result = packets.QuestInfoNoInvSpace
+	case !c.canPayQuestAct(act):
+		result = packets.QuestInfoNoMesos
+	default:
+		return true, nil
+	}
+
+	if npcid >= 0 {
+		err = c.SendPacket(packets.UpdateQuestInfo(questid, npcid, result))
+	}
+	return
+}
+
+// runQuestAct gives the rewards of a quest act to the player. The mesos are handled
+// first so that a fee that can't be paid doesn't give any reward.
+func (c *Connection) runQuestAct(status *QuestStatus, act *gamedata.QuestAct,
+	items []*gamedata.QuestItem) (err error) {
+
+	if act.Meso() != 0 {
+		var paid bool
+		paid, err = c.GainMeso(act.Meso(), true)
+		if err != nil {
+			return
+		}
+
+		if !paid {
+			return fmt.Errorf("%s can't receive %d mesos from quest %d",
+				c.Stats().Name(), act.Meso(), status.id)
+		}
+	}
+
+	for _, item := range items {
+		if item.Count() < 0 {
+			_, err = c.RemoveItemById(item.Id(), -item.Count())
+		} else {
+			_, err = c.GainItemById(item.Id(), int16(item.Count()))
+		}
+
+		if err != nil {
+			return
+		}
+
+		err = c.SendPacket(packets.ShowItemGainInChat(item.Id(), item.Count()))
+		if err != nil {
+			return
+		}
+	}
+
+	if act.Exp() > 0 {
+		err = c.GainExp(act.Exp(), true, true, true)
+		if err != nil {
+			return
+		}
+	}
+
+	if act.Pop() != 0 {
+		err = c.GainFame(int32(act.Pop()), true)
+		if err != nil {
+			return
+		}
+	}
+
+	if len(act.Info()) > 0 {
+		c.questMut.Lock()
+		status.progress = act.Info()
+		c.questMut.Unlock()
+	}
+
+	return
+}
+
+// CanStartQuest returns true if the player can start the given quest from the
+// given npc (pass -1 to skip the npc check)
+func (c *Connection) CanStartQuest(quest *gamedata.Quest, npcid int32) bool {
+	status := c.Quest(quest.Id())
+	check := quest.StartCheck()
+
+	if status != nil {
+		if status.Status() != gamedata.QuestCompleted || check.Interval() <= 0 {
+			return false
+		}
+
+		// repeatable quest
+		elapsed := time.Now().Unix() - status.Completed()
+		if elapsed < int64(check.Interval())*60 {
+			return false
+		}
+	}
+
+	return c.meetsQuestCheck(quest, check, npcid)
+}
+
+// StartQuest starts the given quest if the player meets the requirements.
+// npcid is the npc that gives the quest or -1 for quests started by scripts, which
+// skips the npc check.
+func (c *Connection) StartQuest(questid int16, npcid int32) (ok bool, err error) {
+	quest := gamedata.GetQuestProvider().Get(questid)
+	if quest == nil || !c.CanStartQuest(quest, npcid) {
+		return
+	}
+
+	items := c.questRewards(quest.StartAct())
+	canRun, err := c.checkQuestAct(questid, npcid, quest.StartAct(), items)
+	if err != nil || !canRun {
+		return
+	}
+
+	status := &QuestStatus{
+		id:       questid,
+		status:   gamedata.QuestStarted,
+		progress: strings.Repeat("000", len(quest.CompleteCheck().Mobs())),
+	}
+
+	c.questMut.Lock()
+	c.quests[questid] = status
+	c.questMut.Unlock()
+
+	ok = true
+
+	err = c.runQuestAct(status, quest.StartAct(), items)
+	if err != nil {
+		return
+	}
+
+	err = c.SendPacket(packets.UpdateQuest(questid, status.Progress()))
+	if err != nil || npcid < 0 {
+		return
+	}
+
+	err = c.SendPacket(packets.UpdateQuestInfo(questid, npcid, packets.QuestInfoSuccess))
+	return
+}
+
+// CompleteQuest completes the given quest and gives the rewards to the player if
+// he meets the requirements. npcid is the npc that completes the quest or -1 for
+// quests completed by scripts, which skips the npc check.
+func (c *Connection) CompleteQuest(questid int16, npcid int32) (ok bool, err error) {
+	quest := gamedata.GetQuestProvider().Get(questid)
+	status := c.Quest(questid)
+
+	if quest == nil || status == nil || status.Status() != gamedata.QuestStarted ||
+		!c.meetsQuestCheck(quest, quest.CompleteCheck(), npcid) {
+		return
+	}
+
+	items := c.questRewards(quest.CompleteAct())
+	canRun, err := c.checkQuestAct(questid, npcid, quest.CompleteAct(), items)
+	if err != nil || !canRun {
+		return
+	}
+
+	c.questMut.Lock()
+	status.status = gamedata.QuestCompleted
+	status.completed = time.Now().Unix()
+	c.questMut.Unlock()
+
+	ok = true
+
+	err = c.runQuestAct(status, quest.CompleteAct(), items)
+	if err != nil {
+		return
+	}
+
+	err = c.SendPacket(packets.CompleteQuest(questid,
+		utils.UnixToQuestTimestamp(status.Completed())))
+	if err != nil {
+		return
+	}
+
+	if npcid >= 0 {
+		err = c.SendPacket(packets.UpdateQuestInfo(questid, npcid, packets.QuestInfoSuccess))
+		if err != nil {
+			return
+		}
+	}
+
+	err = c.SendPacket(packets.ShowQuestEffect(packets.QuestEffectComplete))
+	if err != nil {
+		return
+	}
+
+	c.Map().Broadcast(packets.ShowForeignEffect(c.CharId(), packets.QuestEffectComplete),
+		c.CharId())
+
+	if next := quest.CompleteAct().NextQuest(); next != 0 {
+		_, err = c.StartQuest(next, -1)
+	}
+
+	return
+}
+
+// ForfeitQuest gives up on a started quest
+func (c *Connection) ForfeitQuest(questid int16) (ok bool, err error) {
+	c.questMut.Lock()
+	status := c.quests[questid]
+	if status == nil || status.status != gamedata.QuestStarted {
+		c.questMut.Unlock()
+		return
+	}
+	delete(c.quests, questid)
+	c.questMut.Unlock()
+
+	ok = true
+	err = c.SendPacket(packets.ForfeitQuest(questid))
+	return
+}
+
+// SetQuestProgress changes the info string of a started quest
+func (c *Connection) SetQuestProgress(questid int16, progress string) (err error) {
+	c.questMut.Lock()
+	status := c.quests[questid]
+	if status == nil || status.status != gamedata.QuestStarted {
+		c.questMut.Unlock()
+		return
+	}
+	status.progress = progress
+	c.questMut.Unlock()
+
+	return c.SendPacket(packets.UpdateQuest(questid, progress))
+}
+
+// MobKilled updates the kill count of all the started quests that require
+// the given monster id
+func (c *Connection) MobKilled(mobid int32) (err error) {
+	updated := make([]*QuestStatus, 0)
+
+	c.questMut.Lock()
+	for _, status := range c.quests {
+		if status.status != gamedata.QuestStarted {
+			continue
+		}
+
+		quest := gamedata.GetQuestProvider().Get(status.id)
+		if quest == nil {
+			continue
+		}
+
+		i := quest.MobIndex(mobid)
+		if i < 0 {
+			continue
+		}
+
+		kills := status.mobKills(i)
+		if kills >= quest.CompleteCheck().Mobs()[i].Count() {
+			continue
+		}
+
+		status.setMobKills(i, kills+1)
+		updated = append(updated, status)
+	}
+	c.questMut.Unlock()
+
+	for _, status := range updated {
+		err = c.SendPacket(packets.UpdateQuest(status.Id(), status.Progress()))
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,182 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package client
+
+import "math/rand"
+
+import (
+	"github.com/Francesco149/kagami/channelserver/gamedata"
+	"github.com/Francesco149/kagami/common/consts"
+	"github.com/Francesco149/kagami/common/packets"
+	"github.com/Francesco149/maplelib"
+)
+
+// chaosScrollRange is the maximum amount a chaos scroll adds or removes from each stat
+const chaosScrollRange = 5
+
+// scrollEquipPacket returns a packet that refreshes a scrolled equip that is being worn
+func scrollEquipPacket(equip *gamedata.Equip) (p maplelib.Packet) {
+	p = packets.NewEncryptedPacket(packets.OModifyInventory)
+	p.Encode1(0x01) // from drop
+	p.Encode1(0x02) // number of operations
+	p.Encode1(packets.InventoryRemove)
+	p.Encode1(INVENTORY_EQUIP)
+	p.Encode2s(int16(equip.Pos()))
+	p.Encode1(packets.InventoryAdd)
+	p.Encode1(INVENTORY_EQUIP)
+	p.Encode2s(int16(equip.Pos()))
+	equip.EncodeInfo(&p)
+	p.Encode1(0x01) // equipped item
+	return
+}
+
+// chaosStat randomly alters a stat that isn't zero
+func chaosStat(v int16) int16 {
+	if v == 0 {
+		return 0
+	}
+
+	v += int16(rand.Intn(2*chaosScrollRange+1) - chaosScrollRange)
+	if v < 0 {
+		return 0
+	}
+	return v
+}
+
+// applyScroll adds the stat increase of a successful scroll to the equip
+func applyScroll(equip *gamedata.Equip, scroll *gamedata.ItemInfo) {
+	if scroll.RandStat() {
+		equip.SetStr(chaosStat(equip.Str()))
+		equip.SetDex(chaosStat(equip.Dex()))
+		equip.SetInt(chaosStat(equip.Int()))
+		equip.SetLuk(chaosStat(equip.Luk()))
+		equip.SetHp(chaosStat(equip.Hp()))
+		equip.SetMp(chaosStat(equip.Mp()))
+		equip.SetWAtk(chaosStat(equip.WAtk()))
+		equip.SetMAtk(chaosStat(equip.MAtk()))
+		equip.SetWDef(chaosStat(equip.WDef()))
+		equip.SetMDef(chaosStat(equip.MDef()))
+		equip.SetAcc(chaosStat(equip.Acc()))
+		equip.SetAvoid(chaosStat(equip.Avoid()))
+		equip.SetHands(chaosStat(equip.Hands()))
+		equip.SetSpeed(chaosStat(equip.Speed()))
+		equip.SetJump(chaosStat(equip.Jump()))
+		return
+	}
+
+	equip.SetStr(equip.Str() + scroll.Str())
+	equip.SetDex(equip.Dex() + scroll.Dex())
+	equip.SetInt(equip.Int() + scroll.Int())
+	equip.SetLuk(equip.Luk() + scroll.Luk())
+	equip.SetHp(equip.Hp() + scroll.Hp())
+	equip.SetMp(equip.Mp() + scroll.Mp())
+	equip.SetWAtk(equip.WAtk() + scroll.WAtk())
+	equip.SetMAtk(equip.MAtk() + scroll.MAtk())
+	equip.SetWDef(equip.WDef() + scroll.WDef())
+	equip.SetMDef(equip.MDef() + scroll.MDef())
+	equip.SetAcc(equip.Acc() + scroll.Acc())
+	equip.SetAvoid(equip.Avoid() + scroll.Avoid())
+	equip.SetHands(equip.Hands() + scroll.Hands())
+	equip.SetSpeed(equip.Speed() + scroll.Speed())
+	equip.SetJump(equip.Jump() + scroll.Jump())
+}
+
+// ScrollEquip uses the upgrade scroll at the given USE slot on the worn equip at the
+// given (negative) slot. whiteScroll also consumes a white scroll to protect the upgrade
+// slot on failure. ok is false if the scroll can't be used.
+func (c *Connection) ScrollEquip(scrollSlot, equipSlot int8, whiteScroll bool) (ok bool, err error) {
+	use := c.invs[consts.UseInventory]
+
+	scroll := use.Get(scrollSlot)
+	equip, isEquip := c.Equipped().Get(equipSlot).(*gamedata.Equip)
+	if scroll == nil || !isEquip || !gamedata.IsUpgradeScroll(scroll.Id()) ||
+		!gamedata.CanScroll(scroll.Id(), equip.Id()) {
+		return
+	}
+
+	info := gamedata.GetItemInfoProvider().Get(scroll.Id())
+	equipInfo := gamedata.GetItemInfoProvider().Get(equip.Id())
+	if info == nil || equipInfo == nil {
+		return
+	}
+
+	cleanSlate := info.Recover() > 0
+	switch {
+	// clean slates only restore slots that were lost to failed scrolls
+	case cleanSlate && int(equip.Slots())+int(equip.Level()) >= int(equipInfo.UpgradeSlots()):
+		return
+	case !cleanSlate && equip.Slots() <= 0:
+		return
+	}
+
+	var white gamedata.GenericItem
+	if whiteScroll {
+		white = use.ById(gamedata.WhiteScrollId)
+		if white == nil {
+			return
+		}
+	}
+
+	success := rand.Intn(100) < int(info.Success())
+	destroyed := false
+
+	switch {
+	case success && cleanSlate:
+		equip.SetSlots(equip.Slots() + int8(info.Recover()))
+
+	case success:
+		applyScroll(equip, info)
+		equip.SetSlots(equip.Slots() - 1)
+		equip.SetLevel(equip.Level() + 1)
+
+	default:
+		if white == nil && !cleanSlate {
+			equip.SetSlots(equip.Slots() - 1)
+		}
+		destroyed = rand.Intn(100) < int(info.Cursed())
+	}
+
+	ok = true
+
+	if err = c.RemoveItem(consts.UseInventory, scrollSlot, 1); err != nil {
+		return
+	}
+
+	if white != nil {
+		if err = c.RemoveItem(consts.UseInventory, white.Pos(), 1); err != nil {
+			return
+		}
+	}
+
+	if destroyed {
+		c.Equipped().Take(equipSlot)
+		err = c.SendPacket(packets.ClearInventorySlot(INVENTORY_EQUIP, int16(equipSlot), false))
+	} else {
+		err = c.SendPacket(scrollEquipPacket(equip))
+	}
+
+	if err != nil {
+		return
+	}
+
+	c.Map().Broadcast(packets.ShowScrollEffect(c.Stats().Id(), success, destroyed), -1)
+
+	if destroyed {
+		c.Map().Broadcast(c.UpdateLookPacket(), c.Stats().Id())
+	}
+
+	return
+}
@@ -0,0 +1,214 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package client
+
+import (
+	"fmt"
+	"math"
+)
+
+import (
+	"github.com/Francesco149/kagami/channelserver/gamedata"
+	"github.com/Francesco149/kagami/common/packets"
+	"github.com/Francesco149/maplelib"
+)
+
+// unitPriceBits encodes the price of a single piece of rechargeable ammo the way the
+// client expects it (the upper 16 bits of the double)
+func unitPriceBits(price float64) uint16 {
+	return uint16(math.Float64bits(price) >> 48)
+}
+
+// shopPacket returns a packet that opens the given npc shop
+func shopPacket(shop *gamedata.Shop) (p maplelib.Packet) {
+	p = packets.NewEncryptedPacket(packets.OOpenNpcShop)
+	p.Encode4s(shop.NpcId())
+	p.Encode2(uint16(len(shop.Items())))
+
+	for _, item := range shop.Items() {
+		p.Encode4s(item.ItemId())
+		p.Encode4s(item.Price())
+
+		if gamedata.IsRechargeable(item.ItemId()) {
+			var unitPrice float64
+			if info := gamedata.GetItemInfoProvider().Get(item.ItemId()); info != nil {
+				unitPrice = info.UnitPrice()
+			}
+
+			p.Encode2(0)
+			p.Encode4(0) // what the hell is this
+			p.Encode2(unitPriceBits(unitPrice))
+		} else {
+			p.Encode2(1) // quantity per purchase
+		}
+
+		p.Encode2s(gamedata.SlotMax(item.ItemId()))
+	}
+
+	return
+}
+
+// Shop returns the npc shop the player is browsing or nil
+func (c *Connection) Shop() *gamedata.Shop { return c.shop }
+
+// OpenShop opens the given npc shop
+func (c *Connection) OpenShop(shop *gamedata.Shop) error {
+	c.shop = shop
+	return c.SendPacket(shopPacket(shop))
+}
+
+// CloseShop closes the npc shop the player is browsing
+func (c *Connection) CloseShop() { c.shop = nil }
+
+// BuyShopItem buys the given quantity of the item at the given position of the
+// shop window. Rechargeable ammo is always sold as a full stack.
+func (c *Connection) BuyShopItem(slot int16, itemid int32, quantity int16) (err error) {
+	if c.shop == nil {
+		return
+	}
+
+	item := c.shop.Item(slot)
+	if item == nil || item.ItemId() != itemid || quantity <= 0 {
+		fmt.Println(c.Stats().Name(), "tried to buy invalid shop item", itemid, "at", slot)
+		return c.SendPacket(packets.EnableActions())
+	}
+
+	rechargeable := gamedata.IsRechargeable(itemid)
+	if rechargeable {
+		quantity = 1
+	}
+
+	cost := int64(item.Price()) * int64(quantity)
+	if cost > int64(c.Meso()) {
+		return c.SendPacket(packets.ConfirmShopTransaction(packets.ShopResultNoMesos))
+	}
+
+	var ok bool
+
+	if rechargeable {
+		inv := c.InventoryById(itemid)
+		if inv == nil || inv.Full() {
+			return c.SendPacket(packets.ConfirmShopTransaction(packets.ShopResultInventoryFull))
+		}
+
+		ammo := gamedata.NewItem(itemid, 0, gamedata.SlotMax(itemid), -1)
+		ok, err = c.GainItem(ammo, false)
+	} else {
+		ok, err = c.GainItemById(itemid, quantity)
+	}
+
+	if err != nil {
+		return
+	}
+
+	if !ok {
+		return c.SendPacket(packets.ConfirmShopTransaction(packets.ShopResultInventoryFull))
+	}
+
+	if _, err = c.GainMeso(int32(-cost), false); err != nil {
+		return
+	}
+
+	return c.SendPacket(packets.ConfirmShopTransaction(packets.ShopResultBought))
+}
+
+// SellShopItem sells the given quantity of the item at the given inventory slot for
+// its Item.wz price. Rechargeable ammo is always sold as a whole stack.
+func (c *Connection) SellShopItem(slot int16, itemid int32, quantity int16) (err error) {
+	if c.shop == nil {
+		return
+	}
+
+	inv := c.InventoryById(itemid)
+	if inv == nil {
+		return c.SendPacket(packets.EnableActions())
+	}
+
+	item := inv.Get(int8(slot))
+	info := gamedata.GetItemInfoProvider().Get(itemid)
+	if item == nil || info == nil || item.Id() != itemid || quantity <= 0 ||
+		quantity > item.Amount() {
+
+		fmt.Println(c.Stats().Name(), "tried to sell invalid item", itemid, "at", slot)
+		return c.SendPacket(packets.EnableActions())
+	}
+
+	var earned int64
+
+	if gamedata.IsThrowingStar(item) || gamedata.IsBullet(item) {
+		quantity = item.Amount()
+		earned = int64(info.Price()) + int64(math.Ceil(info.UnitPrice()*float64(quantity)))
+	} else {
+		earned = int64(info.Price()) * int64(quantity)
+	}
+
+	if int64(c.Meso())+earned > math.MaxInt32 {
+		err = c.message("You can't hold any more mesos.")
+		if err != nil {
+			return
+		}
+		return c.SendPacket(packets.EnableActions())
+	}
+
+	err = c.RemoveItem(int8(inv.Type()), int8(slot), quantity)
+	if err != nil {
+		return
+	}
+
+	if _, err = c.GainMeso(int32(earned), false); err != nil {
+		return
+	}
+
+	return c.SendPacket(packets.ConfirmShopTransaction(packets.ShopResultSold))
+}
+
+// RechargeShopItem refills the throwing stars or bullets at the given slot of the
+// use inventory
+func (c *Connection) RechargeShopItem(slot int16) (err error) {
+	if c.shop == nil {
+		return
+	}
+
+	item := c.invs[INVENTORY_USE].Get(int8(slot))
+	if item == nil || (!gamedata.IsThrowingStar(item) && !gamedata.IsBullet(item)) {
+		fmt.Println(c.Stats().Name(), "tried to recharge invalid item at", slot)
+		return c.SendPacket(packets.EnableActions())
+	}
+
+	info := gamedata.GetItemInfoProvider().Get(item.Id())
+	slotMax := gamedata.SlotMax(item.Id())
+	missing := slotMax - item.Amount()
+	if info == nil || missing <= 0 {
+		return c.SendPacket(packets.EnableActions())
+	}
+
+	cost := int64(math.Ceil(info.UnitPrice() * float64(missing)))
+	if cost > int64(c.Meso()) {
+		return c.SendPacket(packets.ConfirmShopTransaction(packets.ShopResultNoMesos))
+	}
+
+	item.SetAmount(slotMax)
+	err = c.SendPacket(packets.UpdateInventorySlot(INVENTORY_USE, int16(slot), slotMax, false))
+	if err != nil {
+		return
+	}
+
+	if _, err = c.GainMeso(int32(-cost), false); err != nil {
+		return
+	}
+
+	return c.SendPacket(packets.ConfirmShopTransaction(packets.ShopResultSold))
+}
@@ -0,0 +1,362 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package client
+
+import (
+	"fmt"
+	"time"
+)
+
+import (
+	"github.com/Francesco149/kagami/channelserver/gamedata"
+	"github.com/Francesco149/kagami/common"
+	"github.com/Francesco149/kagami/common/packets"
+	"github.com/Francesco149/kagami/common/utils"
+	"github.com/Francesco149/maplelib"
+	"github.com/ziutek/mymysql/mysql"
+)
+
+// PlayerSkill is a skill learned by a character
+type PlayerSkill struct {
+	level       byte
+	masterLevel byte // only used by 4th job skills
+}
+
+func (this *PlayerSkill) Level() byte       { return this.level }
+func (this *PlayerSkill) MasterLevel() byte { return this.masterLevel }
+
+// skillBuffs maps buff skills that give special temporary stats to the stat.
+// the value of the stat is the x value of the skill.
+var skillBuffs = map[int32]int{
+	1101004:              packets.BuffBooster,       // sword booster
+	1101005:              packets.BuffBooster,       // axe booster
+	1101007:              packets.BuffPowerGuard,    // power guard
+	1111002:              packets.BuffCombo,         // combo attack
+	1201004:              packets.BuffBooster,       // sword booster
+	1201005:              packets.BuffBooster,       // blunt weapon booster
+	1201007:              packets.BuffPowerGuard,    // power guard
+	1301004:              packets.BuffBooster,       // spear booster
+	1301005:              packets.BuffBooster,       // polearm booster
+	1311008:              packets.BuffDragonBlood,   // dragon blood
+	2001002:              packets.BuffMagicGuard,    // magic guard
+	2311003:              packets.BuffHolySymbol,    // holy symbol
+	3101002:              packets.BuffBooster,       // bow booster
+	3101004:              packets.BuffSoulArrow,     // soul arrow
+	3201002:              packets.BuffBooster,       // crossbow booster
+	3201004:              packets.BuffSoulArrow,     // soul arrow
+	4001003:              packets.BuffDarkSight,     // dark sight
+	4101003:              packets.BuffBooster,       // claw booster
+	4111001:              packets.BuffMesoUp,        // meso up
+	SKILL_SHADOW_PARTNER: packets.BuffShadowPartner, // shadow partner
+	4201002:              packets.BuffBooster,       // dagger booster
+	4211003:              packets.BuffPickPocket,    // pick pocket
+	4211005:              packets.BuffMesoGuard,     // meso guard
+	5101006:              packets.BuffBooster,       // knuckler booster
+	5201003:              packets.BuffBooster,       // gun booster
+}
+
+// Skills that need special handling
+const (
+	SKILL_HYPER_BODY     = 1301007 // raises max hp and max mp by x% and y%
+	SKILL_SHADOW_PARTNER = 4111002 // doubles the damage lines of attacks
+	SKILL_CRITICAL_SHOT  = 3000001 // critical hits for bowmen
+	SKILL_CRITICAL_THROW = 4100001 // critical hits for assassins
+)
+
+// IsJobPathOf returns true if job is base or one of its advancements
+func IsJobPathOf(job, base int16) bool {
+	switch {
+	case job == base:
+		return true
+	case base == 0: // beginner skills are kept after advancing
+		return true
+	case base%100 == 0: // 1st job
+		return job/100 == base/100
+	}
+
+	// 2nd, 3rd and 4th job of the same path share the tens digit
+	return job/10 == base/10 && job%10 >= base%10
+}
+
+// Skill returns the given learned skill or nil
+func (c *Connection) Skill(skillid int32) *PlayerSkill { return c.skills[skillid] }
+
+// SkillLevel returns the player's level in the given skill
+func (c *Connection) SkillLevel(skillid int32) byte {
+	s := c.skills[skillid]
+	if s == nil {
+		return 0
+	}
+	return s.level
+}
+
+// SkillEffect returns the effect of the given skill at the player's level or nil if
+// the player doesn't have the skill
+func (c *Connection) SkillEffect(skillid int32) *gamedata.SkillEffect {
+	skill := gamedata.GetSkillProvider().Get(skillid)
+	if skill == nil {
+		return nil
+	}
+	return skill.Effect(c.SkillLevel(skillid))
+}
+
+// LoadSkills retrieves the given character's skills from the database
+func (c *Connection) LoadSkills(charid int32) (err error) {
+	c.skills = make(map[int32]*PlayerSkill)
+
+	db := common.GetDB()
+	st, err := db.Prepare("SELECT * FROM skills WHERE character_id = ?")
+	if err != nil {
+		return
+	}
+
+	res, err := st.Run(charid)
+	rows, err := res.GetRows()
+	if err != nil {
+		return
+	}
+
+	colskillid := res.Map("skill_id")
+	collevel := res.Map("level")
+	colmasterlevel := res.Map("master_level")
+
+	for _, row := range rows {
+		c.skills[int32(row.Int(colskillid))] = &PlayerSkill{
+			level:       byte(row.Int(collevel)),
+			masterLevel: byte(row.Int(colmasterlevel)),
+		}
+	}
+
+	return
+}
+
+// SaveSkills saves the player's skills to the database in a single transaction so
+// that a failed save can't wipe them
+func (c *Connection) SaveSkills() (err error) {
+	tr, err := common.GetDB().Begin()
+	if err != nil {
+		return
+	}
+
+	err = c.saveSkills(tr)
+	if err != nil {
+		tr.Rollback()
+		return
+	}
+
+	return tr.Commit()
+}
+
+// saveSkills saves the skills on the given connection or transaction
+func (c *Connection) saveSkills(db mysql.ConnCommon) (err error) {
+	st, err := db.Prepare("DELETE FROM skills WHERE character_id = ?")
+	if err != nil {
+		return
+	}
+
+	_, err = st.Run(c.CharId())
+	if err != nil {
+		return
+	}
+
+	st, err = db.Prepare("INSERT INTO skills(character_id, skill_id, level, master_level) " +
+		"VALUES(?, ?, ?, ?)")
+	if err != nil {
+		return
+	}
+
+	for skillid, s := range c.skills {
+		_, err = st.Run(c.CharId(), skillid, s.level, s.masterLevel)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// EncodeSkills encodes the learned skills in the character info packet
+func (c *Connection) EncodeSkills(p *maplelib.Packet) {
+	p.Encode2(uint16(len(c.skills)))
+
+	for skillid, s := range c.skills {
+		p.Encode4s(skillid)
+		p.Encode4s(int32(s.level))
+
+		skill := gamedata.GetSkillProvider().Get(skillid)
+		if skill != nil && skill.FourthJob() {
+			p.Encode4s(int32(s.masterLevel))
+		}
+	}
+}
+
+// SetSkillLevel changes the level and master level of a skill and sends the update
+func (c *Connection) SetSkillLevel(skillid int32, level, masterLevel byte) error {
+	if level == 0 && masterLevel == 0 {
+		delete(c.skills, skillid)
+	} else {
+		c.skills[skillid] = &PlayerSkill{level: level, masterLevel: masterLevel}
+	}
+
+	return c.SendPacket(packets.UpdateSkill(skillid, int32(level), int32(masterLevel)))
+}
+
+// skillMasterLevel returns the highest level the player can raise the given skill to
+func (c *Connection) skillMasterLevel(skill *gamedata.Skill) byte {
+	if !skill.FourthJob() {
+		return skill.MaxLevel()
+	}
+
+	s := c.skills[skill.Id()]
+	if s == nil {
+		return 0
+	}
+	return s.masterLevel
+}
+
+// SpendSp spends one SP on the given skill. ok is false if the player can't
+// raise the skill.
+func (c *Connection) SpendSp(skillid int32) (ok bool, err error) {
+	stats := c.Stats()
+	skill := gamedata.GetSkillProvider().Get(skillid)
+
+	switch {
+	case stats.Sp() <= 0:
+		fmt.Println(stats.Name(), "tried to distribute SP without having any")
+		return
+
+	case skill == nil:
+		fmt.Println(stats.Name(), "tried to learn non-existing skill", skillid)
+		return
+
+	case !IsJobPathOf(stats.Job(), skill.Job()):
+		fmt.Println(stats.Name(), "tried to learn skill", skillid, "from another job")
+		return
+
+	case c.SkillLevel(skillid) >= c.skillMasterLevel(skill):
+		fmt.Println(stats.Name(), "tried to raise skill", skillid, "past its master level")
+		return
+	}
+
+	for reqid, reqlevel := range skill.Reqs() {
+		if c.SkillLevel(reqid) < reqlevel {
+			fmt.Println(stats.Name(), "tried to learn skill", skillid, "without", reqid)
+			return
+		}
+	}
+
+	var masterLevel byte
+	if s := c.skills[skillid]; s != nil {
+		masterLevel = s.masterLevel
+	}
+
+	stats.SetSp(stats.Sp() - 1)
+	ok = true
+
+	err = c.SetSkillLevel(skillid, c.SkillLevel(skillid)+1, masterLevel)
+	if err != nil {
+		return
+	}
+
+	err = c.SendPacket(packets.UpdatePlayerStats([]utils.Pair{
+		{First: packets.UpdateSp, Second: stats.Sp()},
+	}, true))
+	return
+}
+
+// PaySkillCost takes the hp and mp cost of a skill and starts its cooldown.
+// ok is false if the player doesn't have enough hp or mp or the skill is cooling down.
+func (c *Connection) PaySkillCost(skillid int32, effect *gamedata.SkillEffect) (ok bool,
+	err error) {
+
+	stats := c.Stats()
+	if stats.Mp() < effect.MpCon() || (effect.HpCon() > 0 && stats.Hp() <= effect.HpCon()) {
+		return
+	}
+
+	if until, cooling := c.skillCooldowns[skillid]; cooling && time.Now().Before(until) {
+		return
+	}
+
+	if effect.Cooltime() > 0 {
+		c.skillCooldowns[skillid] = time.Now().Add(time.Duration(effect.Cooltime()) * time.Second)
+	}
+
+	ok = true
+
+	if effect.MpCon() != 0 || effect.HpCon() != 0 {
+		err = c.AddHpMp(-int32(effect.HpCon()), -int32(effect.MpCon()))
+	}
+
+	return
+}
+
+// skillBuffStats returns the temporary stats given by a buff skill
+func skillBuffStats(skillid int32, effect *gamedata.SkillEffect) (res []utils.Pair) {
+	res = make([]utils.Pair, 0)
+
+	add := func(stat int, value int16) {
+		if value != 0 {
+			res = append(res, utils.Pair{First: stat, Second: value})
+		}
+	}
+
+	add(packets.BuffWAtk, effect.WAtk())
+	add(packets.BuffWDef, effect.WDef())
+	add(packets.BuffMAtk, effect.MAtk())
+	add(packets.BuffMDef, effect.MDef())
+	add(packets.BuffAcc, effect.Acc())
+	add(packets.BuffAvoid, effect.Avoid())
+	add(packets.BuffSpeed, effect.Speed())
+	add(packets.BuffJump, effect.Jump())
+
+	if stat, ok := skillBuffs[skillid]; ok {
+		add(stat, effect.X())
+	}
+
+	if skillid == SKILL_HYPER_BODY {
+		add(packets.BuffHyperBodyHp, effect.X())
+		add(packets.BuffHyperBodyMp, effect.Y())
+	}
+
+	return
+}
+
+// UseSkill uses a non-attack skill, applying its buffs and showing its effect to the
+// other players in the map. ok is false if the player can't use the skill.
+func (c *Connection) UseSkill(skillid int32) (ok bool, err error) {
+	level := c.SkillLevel(skillid)
+	effect := c.SkillEffect(skillid)
+	if effect == nil {
+		fmt.Println(c.Stats().Name(), "tried to use skill", skillid, "without having it")
+		return
+	}
+
+	ok, err = c.PaySkillCost(skillid, effect)
+	if err != nil || !ok {
+		return
+	}
+
+	if buffs := skillBuffStats(skillid, effect); effect.Time() > 0 && len(buffs) > 0 {
+		err = c.GiveBuff(skillid, effect.Time()*1000, buffs)
+		if err != nil {
+			return
+		}
+	}
+
+	c.Map().Broadcast(packets.ShowForeignSkillEffect(c.CharId(), skillid, level), c.CharId())
+	return
+}
@@ -0,0 +1,303 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package client
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+import (
+	"github.com/Francesco149/kagami/channelserver/gamedata"
+	"github.com/Francesco149/kagami/channelserver/status"
+	"github.com/Francesco149/kagami/common/packets"
+	"github.com/Francesco149/kagami/common/utils"
+)
+
+// MaxHpMp is the highest max hp or max mp a character can have
+const MaxHpMp = 30000
+
+// Job branches as returned by JobBranch
+const (
+	BRANCH_BEGINNER = 0
+	BRANCH_WARRIOR  = 1
+	BRANCH_MAGICIAN = 2
+	BRANCH_BOWMAN   = 3
+	BRANCH_THIEF    = 4
+	BRANCH_PIRATE   = 5
+	BRANCH_GM       = 9
+)
+
+// JobBranch returns the class branch of the given job
+func JobBranch(job int16) int16 { return job / 100 }
+
+// randRange returns a random value between min and max (inclusive)
+func randRange(min, max int) int16 {
+	return int16(min + rand.Intn(max-min+1))
+}
+
+// levelUpGrowth returns the max hp and max mp gained by the given job on level up
+func levelUpGrowth(job int16) (hp, mp int16) {
+	switch JobBranch(job) {
+	case BRANCH_BEGINNER:
+		return randRange(12, 16), randRange(10, 12)
+	case BRANCH_WARRIOR:
+		return randRange(24, 28), randRange(4, 6)
+	case BRANCH_MAGICIAN:
+		return randRange(10, 14), randRange(22, 24)
+	case BRANCH_PIRATE:
+		return randRange(22, 28), randRange(18, 23)
+	}
+
+	return randRange(20, 24), randRange(14, 16)
+}
+
+// apGrowth returns the max hp and max mp gained by the given job when assigning AP to them
+func apGrowth(job int16) (hp, mp int16) {
+	switch JobBranch(job) {
+	case BRANCH_BEGINNER:
+		return randRange(8, 12), randRange(6, 8)
+	case BRANCH_WARRIOR:
+		return randRange(20, 24), randRange(2, 4)
+	case BRANCH_MAGICIAN:
+		return randRange(6, 10), randRange(18, 20)
+	case BRANCH_PIRATE:
+		return randRange(18, 22), randRange(14, 16)
+	}
+
+	return randRange(16, 20), randRange(10, 12)
+}
+
+// addCapped adds two stats without exceeding the given cap
+func addCapped(v, delta, cap int16) int16 {
+	res := int32(v) + int32(delta)
+	if res > int32(cap) {
+		return cap
+	}
+	return int16(res)
+}
+
+// GainExp gives exp to the player and levels it up when necessary.
+// show displays the exp gain message, see packets.ShowExpGain for inChat and white.
+func (c *Connection) GainExp(gain int32, show, inChat, white bool) (err error) {
+	stats := c.Stats()
+	if gain <= 0 || stats.Level() >= gamedata.MaxLevel {
+		return
+	}
+
+	total := int64(stats.Exp()) + int64(gain)
+	if total > math.MaxInt32 {
+		total = math.MaxInt32
+	}
+	stats.SetExp(int32(total))
+
+	if show {
+		err = c.SendPacket(packets.ShowExpGain(gain, inChat, white))
+		if err != nil {
+			return
+		}
+	}
+
+	st := <-status.Get
+	maxMultiLevel := int(st.WorldConf().MaxMultiLevel())
+	status.Get <- st
+
+	if maxMultiLevel < 1 {
+		maxMultiLevel = 1
+	}
+
+	levels := 0
+	for stats.Level() < gamedata.MaxLevel && stats.Exp() >= gamedata.ExpNeeded(stats.Level()) {
+		if levels >= maxMultiLevel {
+			// leftover exp can't level up more than once
+			stats.SetExp(gamedata.ExpNeeded(stats.Level()) - 1)
+			break
+		}
+
+		c.levelUp()
+		levels++
+	}
+
+	if levels == 0 {
+		return c.SendPacket(packets.UpdatePlayerStats([]utils.Pair{
+			{First: packets.UpdateExp, Second: stats.Exp()},
+		}, false))
+	}
+
+	fmt.Println(stats.Name(), "reached level", stats.Level())
+
+	err = c.SendPacket(packets.UpdatePlayerStats([]utils.Pair{
+		{First: packets.UpdateLevel, Second: stats.Level()},
+		{First: packets.UpdateExp, Second: stats.Exp()},
+		{First: packets.UpdateMaxHp, Second: stats.MaxHp()},
+		{First: packets.UpdateHp, Second: stats.Hp()},
+		{First: packets.UpdateMaxMp, Second: stats.MaxMp()},
+		{First: packets.UpdateMp, Second: stats.Mp()},
+		{First: packets.UpdateAp, Second: stats.Ap()},
+		{First: packets.UpdateSp, Second: stats.Sp()},
+	}, false))
+	if err != nil {
+		return
+	}
+
+	err = c.SendPacket(packets.ShowOwnEffect(packets.EffectLevelUp))
+	c.Map().Broadcast(packets.ShowForeignEffect(c.CharId(), packets.EffectLevelUp), c.CharId())
+	c.SyncWorld()
+	c.UpdatePartyHp(false)
+	return
+}
+
+// levelUp increases the player's level by one and applies the stat growth
+// without sending any packet
+func (c *Connection) levelUp() {
+	stats := c.Stats()
+
+	stats.SetExp(stats.Exp() - gamedata.ExpNeeded(stats.Level()))
+	stats.SetLevel(stats.Level() + 1)
+	if stats.Level() >= gamedata.MaxLevel {
+		stats.SetExp(0)
+	}
+
+	hp, mp := levelUpGrowth(stats.Job())
+	_, _, intt, _, _, _ := c.TotalStats()
+	mp += int16(intt / 10)
+
+	stats.SetMaxHp(addCapped(stats.MaxHp(), hp, MaxHpMp))
+	stats.SetMaxMp(addCapped(stats.MaxMp(), mp, MaxHpMp))
+	stats.SetHp(stats.MaxHp())
+	stats.SetMp(stats.MaxMp())
+	stats.SetAp(stats.Ap() + 5)
+
+	if JobBranch(stats.Job()) != BRANCH_BEGINNER {
+		stats.SetSp(stats.Sp() + 3)
+	}
+}
+
+// DistributeAp spends one AP on the given stat, which must be one of
+// packets.UpdateStr, UpdateDex, UpdateInt, UpdateLuk, UpdateMaxHp or UpdateMaxMp.
+// Invalid requests are logged and ignored.
+func (c *Connection) DistributeAp(stat int, maxStat int16) (err error) {
+	stats := c.Stats()
+
+	if stats.Ap() <= 0 {
+		fmt.Println(stats.Name(), "tried to distribute AP without having any")
+		return c.SendPacket(packets.EnableActions())
+	}
+
+	var set func(int16)
+	cur, growth := int16(0), int16(1)
+
+	switch stat {
+	case packets.UpdateStr:
+		cur, set = stats.Str(), stats.SetStr
+	case packets.UpdateDex:
+		cur, set = stats.Dex(), stats.SetDex
+	case packets.UpdateInt:
+		cur, set = stats.Int(), stats.SetInt
+	case packets.UpdateLuk:
+		cur, set = stats.Luk(), stats.SetLuk
+	case packets.UpdateMaxHp:
+		growth, _ = apGrowth(stats.Job())
+		cur, set, maxStat = stats.MaxHp(), stats.SetMaxHp, MaxHpMp
+	case packets.UpdateMaxMp:
+		_, growth = apGrowth(stats.Job())
+		cur, set, maxStat = stats.MaxMp(), stats.SetMaxMp, MaxHpMp
+	default:
+		fmt.Println(stats.Name(), "tried to distribute AP to an invalid stat", stat)
+		return c.SendPacket(packets.EnableActions())
+	}
+
+	if cur >= maxStat {
+		return c.SendPacket(packets.EnableActions())
+	}
+
+	newValue := addCapped(cur, growth, maxStat)
+	set(newValue)
+	stats.SetAp(stats.Ap() - 1)
+
+	return c.SendPacket(packets.UpdatePlayerStats([]utils.Pair{
+		{First: stat, Second: newValue},
+		{First: packets.UpdateAp, Second: stats.Ap()},
+	}, true))
+}
+
+// clampHpMp returns v + delta clamped between 0 and max
+func clampHpMp(v int16, delta int32, max int16) int16 {
+	res := int32(v) + delta
+	switch {
+	case res < 0:
+		return 0
+	case res > int32(max):
+		return max
+	}
+	return int16(res)
+}
+
+// AddHpMp adds the given amounts (which can be negative) to the player's hp and mp
+// within the 0 - max range and sends the stat update. The player dies if the hp
+// drops to zero.
+func (c *Connection) AddHpMp(hp, mp int32) error {
+	stats := c.Stats()
+	alive := c.Alive()
+	stats.SetHp(clampHpMp(stats.Hp(), hp, stats.MaxHp()))
+	stats.SetMp(clampHpMp(stats.Mp(), mp, stats.MaxMp()))
+
+	if hp != 0 {
+		c.UpdatePartyHp(false)
+	}
+
+	err := c.SendPacket(packets.UpdatePlayerStats([]utils.Pair{
+		{First: packets.UpdateHp, Second: stats.Hp()},
+		{First: packets.UpdateMp, Second: stats.Mp()},
+	}, true))
+	if err != nil || !alive || c.Alive() {
+		return err
+	}
+
+	return c.die()
+}
+
+// maxFame is the maximum absolute value of a player's fame
+const maxFame = 30000
+
+// fameAfter returns the player's fame after adding the given amount, capped to maxFame
+func (c *Connection) fameAfter(amount int32) int32 {
+	fame := int32(c.Stats().Fame()) + amount
+	switch {
+	case fame > maxFame:
+		fame = maxFame
+	case fame < -maxFame:
+		fame = -maxFame
+	}
+	return fame
+}
+
+// GainFame adds the given amount (which can be negative) to the player's fame.
+// show displays the gain in the chat box.
+func (c *Connection) GainFame(amount int32, show bool) (err error) {
+	fame := c.fameAfter(amount)
+	c.Stats().SetFame(int16(fame))
+
+	err = c.SendPacket(packets.UpdatePlayerStats([]utils.Pair{
+		{First: packets.UpdateFame, Second: fame},
+	}, true))
+	if err != nil || !show {
+		return
+	}
+
+	return c.SendPacket(packets.ShowFameGain(amount))
+}
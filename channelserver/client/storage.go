@@ -0,0 +1,477 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package client
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+import (
+	"github.com/Francesco149/kagami/channelserver/gamedata"
+	"github.com/Francesco149/kagami/channelserver/status"
+	"github.com/Francesco149/kagami/common"
+	"github.com/Francesco149/kagami/common/consts"
+	"github.com/Francesco149/kagami/common/packets"
+	"github.com/Francesco149/maplelib"
+	"github.com/ziutek/mymysql/mysql"
+)
+
+// Storage limits
+const (
+	MaxStorageSlots = 48
+	StorageFee      = 100 // mesos taken for each stored item
+)
+
+// Storage is the warehouse shared by all the characters of an account in a world.
+// Items are kept in the order they were stored and shown grouped by inventory tab.
+type Storage struct {
+	npcid int32 // npc the storage was opened from, 0 if closed
+	slots byte
+	meso  int32
+	items []gamedata.GenericItem
+}
+
+func (this *Storage) Slots() byte { return this.slots }
+func (this *Storage) Meso() int32 { return this.meso }
+func (this *Storage) Full() bool  { return len(this.items) >= int(this.slots) }
+
+// itemsOfType returns the stored items that go in the given inventory tab
+func (this *Storage) itemsOfType(t InventoryType) (res []gamedata.GenericItem) {
+	for _, item := range this.items {
+		if InventoryTypeById(item.Id()) == t {
+			res = append(res, item)
+		}
+	}
+	return
+}
+
+// take removes the n-th item of the given inventory tab and returns it or nil
+func (this *Storage) take(t InventoryType, n byte) gamedata.GenericItem {
+	for i, item := range this.items {
+		if InventoryTypeById(item.Id()) != t {
+			continue
+		}
+
+		if n == 0 {
+			this.items = append(this.items[:i], this.items[i+1:]...)
+			return item
+		}
+		n--
+	}
+	return nil
+}
+
+// storageSorter sorts stored items by inventory tab and item id
+type storageSorter []gamedata.GenericItem
+
+func (this storageSorter) Len() int      { return len(this) }
+func (this storageSorter) Swap(i, j int) { this[i], this[j] = this[j], this[i] }
+func (this storageSorter) Less(i, j int) bool {
+	ti, tj := InventoryTypeById(this[i].Id()), InventoryTypeById(this[j].Id())
+	if ti != tj {
+		return ti < tj
+	}
+	return this[i].Id() < this[j].Id()
+}
+
+// encodeStorageHeader encodes the slot count and tab bitmask that precede the
+// storage contents
+func encodeStorageHeader(p *maplelib.Packet, slots byte, tabs uint16) {
+	p.Encode1(slots)
+	p.Encode2(tabs)
+	p.Encode2(0x0000)
+	p.Encode4(0x00000000)
+}
+
+// encodeStorageItems encodes a list of stored items
+func encodeStorageItems(p *maplelib.Packet, items []gamedata.GenericItem) {
+	p.Encode1(byte(len(items)))
+	for _, item := range items {
+		item.EncodeInfo(p)
+	}
+}
+
+// storageOpenPacket returns a packet that opens the storage window
+func storageOpenPacket(s *Storage) (p maplelib.Packet) {
+	p = packets.NewEncryptedPacket(packets.OStorage)
+	p.Encode1(packets.StorageOpOpen)
+	p.Encode4s(s.npcid)
+	encodeStorageHeader(&p, s.slots, packets.StorageAllTabs)
+	p.Encode4s(s.meso)
+	p.Encode2(0x0000)
+	encodeStorageItems(&p, s.items)
+	p.Encode2(0x0000)
+	p.Encode1(0x00)
+	return
+}
+
+// storageTabPacket returns a packet that refreshes one tab of the storage window
+// after storing or taking out an item
+func storageTabPacket(op byte, s *Storage, t InventoryType) (p maplelib.Packet) {
+	p = packets.NewEncryptedPacket(packets.OStorage)
+	p.Encode1(op)
+	encodeStorageHeader(&p, s.slots, t.Bitmask())
+	encodeStorageItems(&p, s.itemsOfType(t))
+	return
+}
+
+// storageArrangePacket returns a packet that refreshes the whole storage window
+// after sorting it
+func storageArrangePacket(s *Storage) (p maplelib.Packet) {
+	p = packets.NewEncryptedPacket(packets.OStorage)
+	p.Encode1(packets.StorageOpArrange)
+	encodeStorageHeader(&p, s.slots, packets.StorageAllTabs)
+	encodeStorageItems(&p, s.items)
+	p.Encode1(0x00)
+	return
+}
+
+// loadStorage loads the storage of the player's account in the current world.
+// Accounts that never used the storage get an empty one with the world's default
+// amount of slots.
+func (c *Connection) loadStorage() (s *Storage, err error) {
+	db := common.GetDB()
+
+	st, err := db.Prepare("SELECT slots, mesos FROM storage WHERE user_id = ? AND world_id = ?")
+	if err != nil {
+		return
+	}
+
+	res, err := st.Run(c.UserId(), c.WorldId())
+	rows, err := res.GetRows()
+	if err != nil {
+		return
+	}
+
+	s = &Storage{}
+
+	if len(rows) == 0 {
+		stts := <-status.Get
+		s.slots = stts.WorldConf().DefaultStorageSlots()
+		status.Get <- stts
+	} else {
+		s.slots = byte(rows[0].Int(res.Map("slots")))
+		s.meso = int32(rows[0].Int(res.Map("mesos")))
+	}
+
+	st, err = db.Prepare("SELECT * FROM items WHERE location = 'storage' " +
+		"AND user_id = ? AND world_id = ? ORDER BY slot ASC")
+	if err != nil {
+		return
+	}
+
+	res, err = st.Run(c.UserId(), c.WorldId())
+	rows, err = res.GetRows()
+	if err != nil {
+		return
+	}
+
+	colinv := res.Map("inv")
+	for _, row := range rows {
+		equip := InventoryType(row.Int(colinv)) == INVENTORY_EQUIP
+		s.items = append(s.items, loadItem(row, res, equip))
+	}
+
+	return
+}
+
+// save writes the storage on the given connection or transaction. The stored items
+// are owned by the account, the character id is only kept because the items table
+// requires one.
+func (this *Storage) save(db mysql.ConnCommon, c *Connection) (err error) {
+	st, err := db.Prepare("INSERT INTO storage(user_id, world_id, slots, mesos, char_slots) " +
+		"VALUES(?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE slots = VALUES(slots), " +
+		"mesos = VALUES(mesos)")
+	if err != nil {
+		return
+	}
+
+	_, err = st.Run(c.UserId(), c.WorldId(), this.slots, this.meso, consts.InitialCharSlots)
+	if err != nil {
+		return
+	}
+
+	st, err = db.Prepare("DELETE FROM items WHERE location = 'storage' " +
+		"AND user_id = ? AND world_id = ?")
+	if err != nil {
+		return
+	}
+
+	_, err = st.Run(c.UserId(), c.WorldId())
+	if err != nil {
+		return
+	}
+
+	st, err = db.Prepare(insertItemQuery)
+	if err != nil {
+		return
+	}
+
+	for i, item := range this.items {
+		err = insertItem(st, InventoryTypeById(item.Id()), int8(i+1), "storage",
+			c.CharId(), c.UserId(), c.WorldId(), item)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// saveStorage saves the storage together with the player's inventory and mesos in a
+// single transaction so that items can't be duplicated or lost between the two
+func (c *Connection) saveStorage() (err error) {
+	tr, err := common.GetDB().Begin()
+	if err != nil {
+		return
+	}
+
+	err = saveInventories(tr, c.CharId(), c.UserId(), c.WorldId(), c.savedInventories())
+	if err == nil {
+		err = c.saveMeso(tr)
+	}
+	if err == nil {
+		err = c.storage.save(tr, c)
+	}
+
+	if err != nil {
+		tr.Rollback()
+		return
+	}
+
+	return tr.Commit()
+}
+
+// storageError shows a popup about a refused storage operation
+func (c *Connection) storageError(text string) error {
+	return c.SendPacket(packets.ServerMessage(packets.ServerMessagePopup,
+		0, text, false, false))
+}
+
+// Storage returns the player's storage, loading it if necessary
+func (c *Connection) Storage() (s *Storage, err error) {
+	if c.storage == nil {
+		c.storage, err = c.loadStorage()
+	}
+	return c.storage, err
+}
+
+// OpenStorage opens the storage window from the given npc
+func (c *Connection) OpenStorage(npcid int32) (err error) {
+	s, err := c.Storage()
+	if err != nil {
+		return
+	}
+
+	s.npcid = npcid
+	return c.SendPacket(storageOpenPacket(s))
+}
+
+// CloseStorage closes the storage window
+func (c *Connection) CloseStorage() {
+	if c.storage != nil {
+		c.storage.npcid = 0
+	}
+}
+
+// openStorage returns the storage if the player has the storage window open or nil
+func (c *Connection) openStorage() *Storage {
+	if c.storage == nil || c.storage.npcid == 0 {
+		return nil
+	}
+	return c.storage
+}
+
+// ExpandStorage adds the given amount of slots to the storage for the given fee in
+// mesos. The slots and the fee are saved in the same transaction. ok is false if the
+// storage would go over MaxStorageSlots or if the player can't pay.
+func (c *Connection) ExpandStorage(slots byte, fee int32) (ok bool, err error) {
+	s, err := c.Storage()
+	if err != nil {
+		return
+	}
+
+	if int(s.slots)+int(slots) > MaxStorageSlots || fee < 0 || c.Meso() < fee {
+		return
+	}
+
+	ok, err = c.GainMeso(-fee, true)
+	if err != nil || !ok {
+		return
+	}
+
+	s.slots += slots
+
+	err = c.saveStorage()
+	if err != nil {
+		// nothing was saved, so the player keeps the mesos
+		s.slots -= slots
+		c.GainMeso(fee, true)
+		ok = false
+	}
+
+	return
+}
+
+// StoreItem moves the given quantity of the item at the given inventory slot into
+// the storage for a fee of StorageFee mesos
+func (c *Connection) StoreItem(slot int16, itemid int32, quantity int16) (err error) {
+	s := c.openStorage()
+	if s == nil {
+		return
+	}
+
+	inv := c.InventoryById(itemid)
+	if inv == nil {
+		return c.SendPacket(packets.EnableActions())
+	}
+
+	item := inv.Get(int8(slot))
+	if item == nil || item.Id() != itemid || quantity <= 0 || quantity > item.Amount() {
+		fmt.Println(c.Stats().Name(), "tried to store invalid item", itemid, "at", slot)
+		return c.SendPacket(packets.EnableActions())
+	}
+
+	if s.Full() {
+		return c.SendPacket(packets.StorageFull())
+	}
+
+	if c.Meso() < StorageFee {
+		return c.storageError("You don't have enough mesos to store the item.")
+	}
+
+	// rechargeable ammo is always stored as a whole
+	if !gamedata.IsStackable(item) {
+		quantity = item.Amount()
+	}
+
+	var stored gamedata.GenericItem
+	invtype := int8(inv.Type())
+
+	if quantity == item.Amount() {
+		stored = inv.Take(int8(slot))
+		err = c.SendPacket(packets.ClearInventorySlot(invtype, slot, false))
+	} else {
+		stored = item.Clone()
+		stored.SetAmount(quantity)
+		inv.Remove(int8(slot), quantity)
+		err = c.SendPacket(packets.UpdateInventorySlot(invtype, slot, item.Amount(), false))
+	}
+
+	if err != nil {
+		return
+	}
+
+	stored.SetPos(0)
+	s.items = append(s.items, stored)
+
+	if _, err = c.GainMeso(-StorageFee, false); err != nil {
+		return
+	}
+
+	err = c.SendPacket(storageTabPacket(packets.StorageOpStore, s, inv.Type()))
+	if err != nil {
+		return
+	}
+
+	return c.saveStorage()
+}
+
+// TakeOutItem moves the n-th stored item of the given inventory tab back into the
+// player's inventory
+func (c *Connection) TakeOutItem(t InventoryType, n byte) (err error) {
+	s := c.openStorage()
+	if s == nil {
+		return
+	}
+
+	items := s.itemsOfType(t)
+	if int(n) >= len(items) {
+		fmt.Println(c.Stats().Name(), "tried to take out invalid storage item", t, n)
+		return c.SendPacket(packets.EnableActions())
+	}
+
+	item := items[n]
+	if !c.CanHold(item.Id(), item.Amount()) {
+		return c.storageError("Please check if you have enough room in your inventory.")
+	}
+
+	ok, err := c.GainItem(item, false)
+	if err != nil {
+		return
+	}
+
+	if !ok {
+		return c.storageError("Please check if you have enough room in your inventory.")
+	}
+
+	s.take(t, n)
+
+	err = c.SendPacket(storageTabPacket(packets.StorageOpTakeOut, s, t))
+	if err != nil {
+		return
+	}
+
+	return c.saveStorage()
+}
+
+// ArrangeStorage sorts the stored items
+func (c *Connection) ArrangeStorage() (err error) {
+	s := c.openStorage()
+	if s == nil {
+		return
+	}
+
+	sort.Stable(storageSorter(s.items))
+
+	err = c.SendPacket(storageArrangePacket(s))
+	if err != nil {
+		return
+	}
+
+	return c.saveStorage()
+}
+
+// TransferStorageMeso moves mesos between the player and the storage. Negative
+// amounts are stored and positive amounts are taken out.
+func (c *Connection) TransferStorageMeso(amount int32) (err error) {
+	s := c.openStorage()
+	if s == nil || amount == 0 {
+		return
+	}
+
+	player := int64(c.Meso()) + int64(amount)
+	stored := int64(s.meso) - int64(amount)
+	if player < 0 || stored < 0 || player > math.MaxInt32 || stored > math.MaxInt32 {
+		fmt.Println(c.Stats().Name(), "tried to transfer invalid storage meso amount", amount)
+		return c.SendPacket(packets.EnableActions())
+	}
+
+	s.meso = int32(stored)
+
+	if _, err = c.GainMeso(amount, false); err != nil {
+		return
+	}
+
+	err = c.SendPacket(packets.StorageMeso(s.slots, s.meso))
+	if err != nil {
+		return
+	}
+
+	return c.saveStorage()
+}
@@ -0,0 +1,705 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package client
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+import (
+	"github.com/Francesco149/kagami/channelserver/gamedata"
+	"github.com/Francesco149/kagami/channelserver/status"
+	"github.com/Francesco149/kagami/common"
+	"github.com/Francesco149/kagami/common/packets"
+	"github.com/Francesco149/maplelib"
+	"github.com/ziutek/mymysql/mysql"
+)
+
+// maxTradeDistance is how far apart (in pixels) two traders can be before the trade
+// is cancelled
+const maxTradeDistance = 1000
+
+// Trade is a trade window between two players on the same map.
+// The offered items and mesos are taken from the players when they're put in the
+// window and are given back if the trade is cancelled, so the exchange can't fail
+// halfway through. Until the trade ends they're still saved as the player's own.
+type Trade struct {
+	mut       sync.Mutex
+	traders   [2]*Connection // inviter, invited
+	items     [2][]gamedata.GenericItem
+	mesos     [2]int32
+	confirmed [2]bool
+	started   bool // the invited player has accepted
+	ended     bool
+}
+
+// Trade returns the player's current trade or nil
+func (c *Connection) Trade() *Trade {
+	c.tradeMut.Lock()
+	defer c.tradeMut.Unlock()
+	return c.trade
+}
+
+// SetTrade sets the player's current trade
+func (c *Connection) SetTrade(t *Trade) {
+	c.tradeMut.Lock()
+	defer c.tradeMut.Unlock()
+	c.trade = t
+}
+
+// message shows a pink message in the player's chat
+func (c *Connection) message(text string) error {
+	return c.SendPacket(packets.ServerMessage(packets.ServerMessagePinkText,
+		0, text, false, false))
+}
+
+// withTradePartner runs fn while holding the action lock of the player's trade
+// partner, if any, since closing a trade changes the inventories of both players.
+// The player must hold its own action lock and no other locks.
+func (c *Connection) withTradePartner(t *Trade, fn func()) {
+	t.mut.Lock()
+	partner := t.traders[1-t.number(c)]
+	t.mut.Unlock()
+
+	if partner == nil {
+		fn()
+		return
+	}
+
+	c.WithActionsOf(partner, fn)
+}
+
+// number returns the position of the player in the trade window
+func (this *Trade) number(c *Connection) int {
+	if this.traders[1] == c {
+		return 1
+	}
+	return 0
+}
+
+// slotUsed returns true if the given trade window slot already holds an item
+func (this *Trade) slotUsed(number int, slot int8) bool {
+	for _, item := range this.items[number] {
+		if item.Pos() == slot {
+			return true
+		}
+	}
+	return false
+}
+
+// tradeRoomPacket returns a packet that opens the trade window. partner is nil when
+// the window is opened by the inviter.
+func tradeRoomPacket(number byte, c, partner *Connection) (p maplelib.Packet) {
+	p = packets.NewEncryptedPacket(packets.OPlayerInteraction)
+	p.Encode1(packets.InteractionRoom)
+	p.Encode1(packets.InteractionTypeTrade)
+	p.Encode1(2) // max users
+	p.Encode1(number)
+
+	if partner != nil {
+		p.Encode1(0)
+		partner.EncodeLook(&p)
+		p.EncodeString(partner.Stats().Name())
+	}
+
+	p.Encode1(number)
+	c.EncodeLook(&p)
+	p.EncodeString(c.Stats().Name())
+	p.Encode1(0xFF)
+	return
+}
+
+// tradePartnerPacket returns a packet that shows the partner who accepted the trade
+func tradePartnerPacket(partner *Connection) (p maplelib.Packet) {
+	p = packets.NewEncryptedPacket(packets.OPlayerInteraction)
+	p.Encode1(packets.InteractionVisit)
+	p.Encode1(1)
+	partner.EncodeLook(&p)
+	p.EncodeString(partner.Stats().Name())
+	return
+}
+
+// tradeItemPacket returns a packet that shows an item put in the trade window.
+// number is 0 for the player's own items and 1 for the partner's.
+func tradeItemPacket(number byte, item gamedata.GenericItem) (p maplelib.Packet) {
+	p = packets.NewEncryptedPacket(packets.OPlayerInteraction)
+	p.Encode1(packets.InteractionSetItems)
+	p.Encode1(number)
+	p.Encode1s(item.Pos())
+	item.EncodeInfo(&p)
+	return
+}
+
+// tooFar returns true if the two players are too far apart to trade
+func tooFar(a, b *Connection) bool {
+	dx := float64(a.Pos().X - b.Pos().X)
+	dy := float64(a.Pos().Y - b.Pos().Y)
+	return a.Map() != b.Map() || math.Sqrt(dx*dx+dy*dy) > maxTradeDistance
+}
+
+// OpenTrade opens an empty trade window that the player can invite someone to
+func (c *Connection) OpenTrade() error {
+	if c.Trade() != nil {
+		return nil
+	}
+
+	t := &Trade{}
+	t.traders[0] = c
+	c.SetTrade(t)
+	return c.SendPacket(tradeRoomPacket(0, c, nil))
+}
+
+// InviteTrade invites a player on the same map to the player's trade window
+func (c *Connection) InviteTrade(charid int32) error {
+	t := c.Trade()
+	if t == nil {
+		return nil
+	}
+
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	if t.ended || t.traders[0] != c || t.traders[1] != nil {
+		return nil
+	}
+
+	partner, ok := c.Map().Player(charid).(*Connection)
+	switch {
+	case !ok || partner == c:
+		return c.message("The character is not on this map.")
+	case tooFar(c, partner):
+		return c.message("You are too far away to trade.")
+	case partner.Trade() != nil:
+		return c.message(partner.Stats().Name() + " is doing something else right now.")
+	}
+
+	t.traders[1] = partner
+	partner.SetTrade(t)
+	return partner.SendPacket(packets.TradeInvite(c.Stats().Name()))
+}
+
+// AcceptTrade accepts the pending trade invite and opens the trade window
+func (c *Connection) AcceptTrade() error {
+	t := c.Trade()
+	if t == nil {
+		return nil
+	}
+
+	var err error
+	c.withTradePartner(t, func() {
+		t.mut.Lock()
+		defer t.mut.Unlock()
+
+		if t.ended || t.started || t.traders[1] != c {
+			return
+		}
+
+		inviter := t.traders[0]
+		if tooFar(c, inviter) {
+			t.cancel()
+			err = c.message("You are too far away to trade.")
+			return
+		}
+
+		t.started = true
+		err = inviter.SendPacket(tradePartnerPacket(c))
+		if err != nil {
+			return
+		}
+
+		err = c.SendPacket(tradeRoomPacket(1, c, inviter))
+	})
+
+	return err
+}
+
+// DeclineTrade declines the pending trade invite
+func (c *Connection) DeclineTrade() error {
+	t := c.Trade()
+	if t == nil {
+		return nil
+	}
+
+	var err error
+	c.withTradePartner(t, func() {
+		t.mut.Lock()
+		defer t.mut.Unlock()
+
+		if t.ended || t.started || t.traders[1] != c {
+			return
+		}
+
+		t.cancel()
+		err = t.traders[0].message(c.Stats().Name() + " has declined your trade request.")
+	})
+
+	return err
+}
+
+// TradeChat shows a chat message in the trade window of both players
+func (c *Connection) TradeChat(text string) (err error) {
+	t := c.Trade()
+	if t == nil {
+		return
+	}
+
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	if t.ended || !t.started {
+		return
+	}
+
+	n := t.number(c)
+	err = c.SendPacket(packets.TradeChat(0, c.Stats().Name(), text))
+	if err != nil {
+		return
+	}
+
+	return t.traders[1-n].SendPacket(packets.TradeChat(1, c.Stats().Name(), text))
+}
+
+// TradeItem moves the given quantity of the item at slot src of the given inventory
+// tab to the given slot of the trade window. ok is false if the item can't be traded.
+func (c *Connection) TradeItem(invtype int8, src int8, quantity int16,
+	tradeSlot int8) (ok bool, err error) {
+
+	t := c.Trade()
+	if t == nil {
+		return
+	}
+
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	n := t.number(c)
+	if t.ended || !t.started || t.confirmed[n] || tradeSlot < 1 ||
+		tradeSlot > packets.MaxTradeSlots || t.slotUsed(n, tradeSlot) {
+		return
+	}
+
+	inv := c.invs[invtype]
+	if inv == nil || inv.Type() == INVENTORY_EQUIPPED || src < 1 {
+		return
+	}
+
+	item := inv.Get(src)
+	if item == nil || quantity <= 0 || quantity > item.Amount() {
+		return
+	}
+
+	info := gamedata.GetItemInfoProvider().Get(item.Id())
+	if info == nil || !info.Tradeable() {
+		return
+	}
+
+	// rechargeable ammo is always traded as a whole
+	if !gamedata.IsStackable(item) {
+		quantity = item.Amount()
+	}
+
+	var offered gamedata.GenericItem
+
+	if quantity == item.Amount() {
+		offered = inv.Take(src)
+		err = c.SendPacket(packets.ClearInventorySlot(invtype, int16(src), false))
+	} else {
+		offered = item.Clone()
+		offered.SetAmount(quantity)
+		inv.Remove(src, quantity)
+		err = c.SendPacket(packets.UpdateInventorySlot(invtype, int16(src), item.Amount(), false))
+	}
+
+	offered.SetPos(tradeSlot)
+	t.items[n] = append(t.items[n], offered)
+	ok = true
+
+	if err != nil {
+		return
+	}
+
+	err = c.SendPacket(tradeItemPacket(0, offered))
+	if err != nil {
+		return
+	}
+
+	err = t.traders[1-n].SendPacket(tradeItemPacket(1, offered))
+	return
+}
+
+// TradeMeso adds the given amount of mesos to the player's offer.
+// ok is false if the player doesn't have enough mesos.
+func (c *Connection) TradeMeso(amount int32) (ok bool, err error) {
+	t := c.Trade()
+	if t == nil {
+		return
+	}
+
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	n := t.number(c)
+	if t.ended || !t.started || t.confirmed[n] || amount <= 0 || amount > c.Meso() {
+		return
+	}
+
+	ok, err = c.GainMeso(-amount, false)
+	if !ok || err != nil {
+		return
+	}
+
+	t.mesos[n] += amount
+
+	err = c.SendPacket(packets.TradeMesoSet(0, t.mesos[n]))
+	if err != nil {
+		return
+	}
+
+	err = t.traders[1-n].SendPacket(packets.TradeMesoSet(1, t.mesos[n]))
+	return
+}
+
+// ConfirmTrade locks the player's offer and completes the trade once both players
+// have confirmed
+func (c *Connection) ConfirmTrade() (err error) {
+	t := c.Trade()
+	if t == nil {
+		return
+	}
+
+	c.withTradePartner(t, func() {
+		var completed bool
+
+		t.mut.Lock()
+		completed, err = t.confirm(c)
+		t.mut.Unlock()
+
+		// the exchange is over, so the trade doesn't need to stay locked while saving
+		if err == nil && completed {
+			err = t.save()
+		}
+	})
+
+	return
+}
+
+// confirm marks the player's offer as confirmed and exchanges the offers once both
+// players have confirmed. completed is true if the exchange happened and needs to be
+// saved. The trade must be locked.
+func (this *Trade) confirm(c *Connection) (completed bool, err error) {
+	n := this.number(c)
+	if this.ended || !this.started || this.confirmed[n] {
+		return
+	}
+
+	this.confirmed[n] = true
+
+	if !this.confirmed[1-n] {
+		err = this.traders[1-n].SendPacket(packets.TradeConfirmation())
+		return
+	}
+
+	return this.complete(), nil
+}
+
+// CancelTrade closes the player's trade window and gives the offered items and mesos
+// back to both players
+func (c *Connection) CancelTrade() {
+	t := c.Trade()
+	if t == nil {
+		return
+	}
+
+	c.withTradePartner(t, func() {
+		t.mut.Lock()
+		defer t.mut.Unlock()
+		t.cancel()
+	})
+}
+
+// CheckTradeDistance cancels the player's trade if the partner is too far away
+func (c *Connection) CheckTradeDistance() {
+	t := c.Trade()
+	if t == nil {
+		return
+	}
+
+	c.withTradePartner(t, func() {
+		t.mut.Lock()
+		defer t.mut.Unlock()
+
+		n := t.number(c)
+		partner := t.traders[1-n]
+		if t.ended || partner == nil || !tooFar(c, partner) {
+			return
+		}
+
+		t.cancel()
+		c.message("You are too far away to trade.")
+		partner.message("Your trade partner is too far away.")
+	})
+}
+
+// escrow returns copies of the items and the mesos that the player has put in an
+// open trade window
+func (c *Connection) escrow() (items []gamedata.GenericItem, meso int32) {
+	t := c.Trade()
+	if t == nil {
+		return
+	}
+
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	if t.ended {
+		return
+	}
+
+	n := t.number(c)
+	for _, item := range t.items[n] {
+		items = append(items, item.Clone())
+	}
+
+	return items, t.mesos[n]
+}
+
+// savedInventories returns the inventories that are saved for the player. Items in
+// an open trade window are saved in free slots so that they aren't lost if the
+// server goes down before the trade ends.
+func (c *Connection) savedInventories() map[int8]*Inventory {
+	items, _ := c.escrow()
+	if len(items) == 0 {
+		return c.invs
+	}
+
+	invs := make(map[int8]*Inventory, len(c.invs))
+	for k, inv := range c.invs {
+		invs[k] = inv
+	}
+
+	for _, item := range items {
+		k := int8(InventoryTypeById(item.Id()))
+		if invs[k] == nil {
+			continue
+		}
+
+		if invs[k] == c.invs[k] {
+			invs[k] = c.invs[k].clone()
+		}
+
+		if invs[k].Add(item) < 0 {
+			fmt.Println("No room to save traded item", item.Id(), "for", c.Stats().Name())
+		}
+	}
+
+	return invs
+}
+
+// savedMeso returns the mesos that are saved for the player, including the ones in
+// an open trade window
+func (c *Connection) savedMeso() int32 {
+	_, meso := c.escrow()
+	return c.Meso() + meso
+}
+
+// giveBack gives the player an item from the trade window, either their own when the
+// trade is cancelled or the partner's when it completes. If it doesn't fit, it's
+// dropped on the ground instead of being lost.
+func (c *Connection) giveBack(item gamedata.GenericItem) {
+	ok, err := c.GainItem(item, false)
+	if err != nil {
+		fmt.Println("Failed to give traded item to", c.Stats().Name(), ":", err)
+	}
+
+	if !ok && c.Map() != nil {
+		item.SetPos(0)
+		c.Map().SpawnPlayerDrop(item, c)
+	}
+}
+
+// cancel closes the trade window and gives everything back. The trade must be locked
+// and the action locks of both traders must be held.
+func (this *Trade) cancel() {
+	if this.ended {
+		return
+	}
+
+	this.ended = true
+
+	for i, c := range this.traders {
+		if c == nil {
+			continue
+		}
+
+		c.SetTrade(nil)
+
+		for _, item := range this.items[i] {
+			c.giveBack(item)
+		}
+
+		if this.mesos[i] > 0 {
+			c.GainMeso(this.mesos[i], false)
+		}
+
+		// the invited player doesn't have a window until the invite is accepted
+		if i == 0 || this.started {
+			c.SendPacket(packets.TradeResult(byte(i), packets.TradeResultCancel))
+		}
+	}
+}
+
+// canHoldTrade returns true if the player has a free slot for each of the given items
+func (c *Connection) canHoldTrade(items []gamedata.GenericItem) bool {
+	slots := make(map[InventoryType]int)
+	for _, item := range items {
+		slots[InventoryTypeById(item.Id())]++
+	}
+
+	for t, n := range slots {
+		inv := c.invs[int8(t)]
+
+		// if the inventory is full after n - 1 items, the last one won't fit
+		if inv == nil || inv.WillBeFull(n-1) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// complete exchanges the offers of the two players and returns true if they were
+// exchanged, after which the trade must be saved. The trade must be locked and the
+// action locks of both traders must be held.
+func (this *Trade) complete() bool {
+	for i, c := range this.traders {
+		receiver := this.traders[1-i]
+
+		reason := ""
+		switch {
+		case !receiver.canHoldTrade(this.items[i]):
+			reason = "doesn't have enough inventory space"
+		case int64(receiver.Meso())+int64(this.mesos[i]) > math.MaxInt32:
+			reason = "can't hold that many mesos"
+		}
+
+		if len(reason) != 0 {
+			this.cancel()
+			receiver.message("Trade unsuccessful: you " + reason + ".")
+			c.message("Trade unsuccessful: your partner " + reason + ".")
+			return false
+		}
+	}
+
+	// everything is delivered before the trade is ended so that nothing in the window
+	// is left behind
+	for i, c := range this.traders {
+		receiver := this.traders[1-i]
+
+		for _, item := range this.items[i] {
+			receiver.giveBack(item)
+		}
+
+		if this.mesos[i] > 0 {
+			ok, err := receiver.GainMeso(this.mesos[i], false)
+			if err != nil || !ok {
+				fmt.Println("Failed to give", this.mesos[i], "traded mesos from",
+					c.Stats().Name(), "to", receiver.Stats().Name(), ":", err)
+			}
+		}
+	}
+
+	this.ended = true
+
+	for i, c := range this.traders {
+		c.SetTrade(nil)
+		c.SendPacket(packets.TradeResult(byte(i), packets.TradeResultSuccess))
+	}
+
+	fmt.Println(this.traders[0].Stats().Name(), "traded with", this.traders[1].Stats().Name())
+	return true
+}
+
+// save persists the inventories and mesos of both traders and writes the trade to
+// the audit log in a single transaction. The action locks of both traders must be
+// held.
+func (this *Trade) save() (err error) {
+	tr, err := common.GetDB().Begin()
+	if err != nil {
+		return
+	}
+
+	for _, c := range this.traders {
+		err = saveInventories(tr, c.CharId(), c.UserId(), c.WorldId(), c.invs)
+		if err == nil {
+			err = c.saveMeso(tr)
+		}
+
+		if err != nil {
+			tr.Rollback()
+			return
+		}
+	}
+
+	err = this.log(tr)
+	if err != nil {
+		tr.Rollback()
+		return
+	}
+
+	return tr.Commit()
+}
+
+// log writes the trade to the trades audit tables
+func (this *Trade) log(db mysql.ConnCommon) (err error) {
+	st := <-status.Get
+	chanid := st.ChanId()
+	status.Get <- st
+
+	a, b := this.traders[0], this.traders[1]
+
+	stmt, err := db.Prepare("INSERT INTO trades(world_id, channel_id, map_id, " +
+		"character1_id, character2_id, meso1, meso2) VALUES(?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return
+	}
+
+	res, err := stmt.Run(a.WorldId(), chanid, a.Map().Id(), a.CharId(), b.CharId(),
+		this.mesos[0], this.mesos[1])
+	if err != nil {
+		return
+	}
+
+	tradeid := res.InsertId()
+
+	stmt, err = db.Prepare("INSERT INTO trade_items(trade_id, character_id, item_id, amount) " +
+		"VALUES(?, ?, ?, ?)")
+	if err != nil {
+		return
+	}
+
+	for i, c := range this.traders {
+		for _, item := range this.items[i] {
+			_, err = stmt.Run(tradeid, c.CharId(), item.Id(), item.Amount())
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	return
+}
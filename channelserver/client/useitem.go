@@ -0,0 +1,122 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package client
+
+import (
+	"fmt"
+	"time"
+)
+
+import (
+	"github.com/Francesco149/kagami/channelserver/gamedata"
+	"github.com/Francesco149/kagami/common/consts"
+	"github.com/Francesco149/kagami/common/packets"
+	"github.com/Francesco149/kagami/common/utils"
+)
+
+// townMapId is the moveTo value of return scrolls that lead to the nearest town
+const townMapId = 999999999
+
+// UseItem consumes one of the items at the given slot of the USE inventory and applies
+// its effect. ok is false if the item can't be used.
+func (c *Connection) UseItem(slot int8, itemid int32) (ok bool, err error) {
+	item := c.invs[consts.UseInventory].Get(slot)
+	if item == nil || item.Id() != itemid || !c.Alive() {
+		return
+	}
+
+	effect := gamedata.GetItemInfoProvider().Effect(itemid)
+	if effect == nil {
+		fmt.Println(c.Stats().Name(), "tried to use item", itemid, "which has no effect")
+		return
+	}
+
+	if time.Now().Before(c.itemCooldowns[itemid]) {
+		return
+	}
+
+	// figure out the destination before consuming return scrolls
+	dest := effect.MoveTo()
+	if dest == townMapId {
+		dest = c.Map().ReturnMapId()
+		if dest == townMapId {
+			dest = c.Map().Id()
+		}
+	}
+
+	if err = c.RemoveItem(consts.UseInventory, slot, 1); err != nil {
+		return
+	}
+
+	ok = true
+
+	if effect.Cooldown() > 0 {
+		c.itemCooldowns[itemid] = time.Now().Add(time.Duration(effect.Cooldown()) *
+			time.Millisecond)
+	}
+
+	if err = c.applyItemEffect(itemid, effect); err != nil {
+		return
+	}
+
+	if dest != 0 && dest != c.Map().Id() {
+		err = c.ChangeMap(dest, 0)
+	}
+
+	return
+}
+
+// applyItemEffect restores hp/mp and gives the temporary stats of an item effect
+func (c *Connection) applyItemEffect(itemid int32, effect *gamedata.ItemEffect) (err error) {
+	stats := c.Stats()
+	hp := int32(effect.Hp()) + int32(stats.MaxHp())*int32(effect.HpR())/100
+	mp := int32(effect.Mp()) + int32(stats.MaxMp())*int32(effect.MpR())/100
+
+	if hp != 0 || mp != 0 {
+		err = c.AddHpMp(hp, mp)
+	} else {
+		err = c.SendPacket(packets.EnableActions())
+	}
+
+	if err != nil {
+		return
+	}
+
+	// TODO: cure diseases once they are implemented
+
+	if !effect.Buff() {
+		return
+	}
+
+	buffs := make([]utils.Pair, 0, 9)
+	add := func(stat int, value int16) {
+		if value != 0 {
+			buffs = append(buffs, utils.Pair{First: stat, Second: value})
+		}
+	}
+
+	add(packets.BuffWAtk, effect.WAtk())
+	add(packets.BuffWDef, effect.WDef())
+	add(packets.BuffMAtk, effect.MAtk())
+	add(packets.BuffMDef, effect.MDef())
+	add(packets.BuffAcc, effect.Acc())
+	add(packets.BuffAvoid, effect.Avoid())
+	add(packets.BuffSpeed, effect.Speed())
+	add(packets.BuffJump, effect.Jump())
+
+	// item buffs use the negative item id as their source
+	return c.GiveBuff(-itemid, effect.Time(), buffs)
+}
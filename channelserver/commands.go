@@ -0,0 +1,178 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+import (
+	"github.com/Francesco149/kagami/channelserver/client"
+	"github.com/Francesco149/kagami/channelserver/gamedata"
+	"github.com/Francesco149/kagami/channelserver/status"
+	"github.com/Francesco149/kagami/common/interserver"
+	"github.com/Francesco149/kagami/common/packets"
+)
+
+// commandPrefix is the prefix of gm commands typed in the chat
+const commandPrefix = "!"
+
+// command is a gm command that can be typed in the chat
+type command struct {
+	gmLevel int32  // minimum gm level required
+	usage   string // shown by !help and on invalid parameters
+	run     func(con *client.Connection, args []string) error
+}
+
+// errUsage is returned by commands that received invalid parameters
+var errUsage = errors.New("invalid parameters")
+
+var commands map[string]*command
+
+func init() {
+	commands = map[string]*command{
+		"bosses": {1, "!bosses [name] - shows today's boss attempts of a character", cmdBosses},
+		"help":   {1, "!help - lists the available commands", cmdHelp},
+		"job":    {3, "!job <id> - changes your job without checking the requirements", cmdJob},
+		"reload": {3, "!reload - reloads the drop tables and shops from the database", cmdReload},
+		"rehash": {3, "!rehash - reloads the world configs on every server", cmdRehash},
+	}
+}
+
+// commandMessage shows a command's output to the gm
+func commandMessage(con *client.Connection, text string) error {
+	return con.SendPacket(packets.ServerMessage(packets.ServerMessagePinkText,
+		0, text, false, false))
+}
+
+// runCommand parses and runs a gm command without the prefix
+func runCommand(con *client.Connection, text string) (err error) {
+	args := strings.Fields(text)
+	if len(args) == 0 {
+		return
+	}
+
+	cmd := commands[strings.ToLower(args[0])]
+	if cmd == nil || con.GmLevel() < cmd.gmLevel {
+		return commandMessage(con, "Unknown command "+args[0])
+	}
+
+	fmt.Println(con.Stats().Name(), "used command", text)
+
+	err = cmd.run(con, args[1:])
+	if err == errUsage {
+		return commandMessage(con, "Usage: "+cmd.usage)
+	}
+	return
+}
+
+// intArg parses the i-th command argument as an integer
+func intArg(args []string, i int, bits int) (int64, error) {
+	if i >= len(args) {
+		return 0, errUsage
+	}
+
+	v, err := strconv.ParseInt(args[i], 10, bits)
+	if err != nil {
+		return 0, errUsage
+	}
+	return v, nil
+}
+
+func cmdHelp(con *client.Connection, args []string) (err error) {
+	names := make([]string, 0, len(commands))
+	for name, cmd := range commands {
+		if con.GmLevel() >= cmd.gmLevel {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		err = commandMessage(con, commands[name].usage)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func cmdJob(con *client.Connection, args []string) error {
+	job, err := intArg(args, 0, 16)
+	if err != nil {
+		return err
+	}
+
+	if !client.IsValidJob(int16(job)) {
+		return commandMessage(con, fmt.Sprint(job, " is not a valid job"))
+	}
+
+	return con.ChangeJob(int16(job))
+}
+
+func cmdReload(con *client.Connection, args []string) error {
+	gamedata.ReloadDropTables()
+	gamedata.ReloadShops()
+	return commandMessage(con, "Reloaded drop tables and shops")
+}
+
+func cmdRehash(con *client.Connection, args []string) error {
+	err := client.SendToWorld(interserver.Rehash())
+	if err != nil {
+		return err
+	}
+
+	return commandMessage(con, "Requested a config rehash")
+}
+
+func cmdBosses(con *client.Connection, args []string) (err error) {
+	name := con.Stats().Name()
+	if len(args) > 0 {
+		name = args[0]
+	}
+
+	attempts, found, err := client.BossAttemptsByName(name, con.WorldId())
+	if err != nil {
+		return
+	}
+
+	if !found {
+		return commandMessage(con, name+" doesn't exist")
+	}
+
+	st := <-status.Get
+	wc := st.WorldConf()
+	status.Get <- st
+
+	for _, boss := range client.Bosses {
+		limit := "unlimited"
+		if max := boss.Conf(wc).Attempts(); max >= 0 {
+			limit = fmt.Sprint(max)
+		}
+
+		err = commandMessage(con, fmt.Sprintf("%s: %d / %s", boss.Name,
+			attempts[boss.Id], limit))
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
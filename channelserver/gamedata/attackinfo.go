@@ -0,0 +1,167 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package gamedata
+
+import (
+	"github.com/Francesco149/kagami/common/packets"
+	"github.com/Francesco149/maplelib"
+)
+
+// AttackType identifies which attack packet an attack came from
+type AttackType int
+
+// Possible values for AttackType
+const (
+	ATTACK_CLOSE_RANGE AttackType = iota
+	ATTACK_RANGED
+	ATTACK_MAGIC
+)
+
+// Skills that need special handling when parsing or validating attacks
+const (
+	SKILL_FP_BIG_BANG     = 2121001
+	SKILL_IL_BIG_BANG     = 2221001
+	SKILL_BISHOP_BIG_BANG = 2321001
+	SKILL_CORKSCREW_BLOW  = 5101004
+	SKILL_GRENADE         = 5201002
+	SKILL_HURRICANE       = 3121004
+	SKILL_PIERCING_ARROW  = 3221001
+	SKILL_RAPID_FIRE      = 5221004
+	SKILL_SHARP_EYES_CRIT = 3221007
+	SKILL_MESO_EXPLOSION  = 4211006
+	SKILL_HEAL            = 2301002
+)
+
+// criticalDamageBitmask is set on the damage lines of sharp eyes critical hits
+const criticalDamageBitmask = -0x80000000
+
+// attackTargetUnknownLen is the number of unknown bytes after each target's object id
+const attackTargetUnknownLen = 14
+
+// AttackInfo holds the result of parsing an attack packet
+type AttackInfo struct {
+	typ                  AttackType
+	numAttackedAndDamage byte
+	skill                int32
+	charge               int32
+	stance               byte
+	speed                byte
+	direction            byte
+	starSlot             int16
+	cashStarSlot         int16
+	targets              []packets.AttackTarget
+}
+
+func (a *AttackInfo) Type() AttackType { return a.typ }
+
+// NumAttacked returns the amount of monsters hit by the attack
+func (a *AttackInfo) NumAttacked() byte { return (a.numAttackedAndDamage >> 4) & 0xF }
+
+// NumDamage returns the amount of damage lines for each monster
+func (a *AttackInfo) NumDamage() byte { return a.numAttackedAndDamage & 0xF }
+
+func (a *AttackInfo) NumAttackedAndDamage() byte { return a.numAttackedAndDamage }
+func (a *AttackInfo) Skill() int32               { return a.skill }
+func (a *AttackInfo) Stance() byte               { return a.stance }
+func (a *AttackInfo) Speed() byte                { return a.speed }
+func (a *AttackInfo) Direction() byte            { return a.direction }
+
+// Charge returns the charge time for keydown skills or -1
+func (a *AttackInfo) Charge() int32 { return a.charge }
+
+// StarSlot returns the use inventory slot of the ammo used by a ranged attack
+func (a *AttackInfo) StarSlot() int16 { return a.starSlot }
+
+// CashStarSlot returns the cash inventory slot of the cash shop stars in use or 0
+func (a *AttackInfo) CashStarSlot() int16 { return a.cashStarSlot }
+
+// Targets returns the monsters hit by the attack along with their damage lines
+func (a *AttackInfo) Targets() []packets.AttackTarget { return a.targets }
+
+// ParseAttack decodes a close range, ranged or magic attack packet
+func ParseAttack(it *maplelib.PacketIterator, typ AttackType) (res *AttackInfo, err error) {
+	res = &AttackInfo{typ: typ, charge: -1}
+
+	_, err = it.Decode1() // what the hell is this
+	res.numAttackedAndDamage, err = it.Decode1()
+	res.skill, err = it.Decode4s()
+	if err != nil {
+		return
+	}
+
+	switch res.skill {
+	case SKILL_FP_BIG_BANG, SKILL_IL_BIG_BANG, SKILL_BISHOP_BIG_BANG,
+		SKILL_CORKSCREW_BLOW, SKILL_GRENADE:
+		res.charge, err = it.Decode4s()
+	}
+
+	_, err = it.Decode1() // what the hell is this
+	res.stance, err = it.Decode1()
+	_, err = it.Decode1() // what the hell is this
+	res.speed, err = it.Decode1()
+	if err != nil {
+		return
+	}
+
+	if typ == ATTACK_RANGED {
+		_, err = it.Decode1() // what the hell is this
+		res.direction, err = it.Decode1()
+		res.starSlot, err = it.Decode2s()
+		res.cashStarSlot, err = it.Decode2s()
+		_, err = it.Decode1() // what the hell is this
+		_, err = it.Decode2() // what the hell is this
+
+		switch res.skill {
+		case SKILL_HURRICANE, SKILL_PIERCING_ARROW, SKILL_RAPID_FIRE:
+			_, err = it.Decode4() // what the hell is this
+		}
+	} else {
+		_, err = it.Decode4() // what the hell is this
+	}
+
+	if err != nil {
+		return
+	}
+
+	res.targets = make([]packets.AttackTarget, res.NumAttacked())
+	for i := range res.targets {
+		target := &res.targets[i]
+		target.Oid, err = it.Decode4s()
+
+		for j := 0; j < attackTargetUnknownLen; j++ {
+			_, err = it.Decode1() // what the hell is this
+		}
+
+		target.Damage = make([]int32, res.NumDamage())
+		for j := range target.Damage {
+			target.Damage[j], err = it.Decode4s()
+
+			if res.skill == SKILL_SHARP_EYES_CRIT {
+				target.Damage[j] &^= criticalDamageBitmask
+			}
+		}
+
+		if res.skill != SKILL_RAPID_FIRE {
+			_, err = it.Decode4() // what the hell is this
+		}
+
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,110 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package gamedata
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+)
+
+import "github.com/Francesco149/kagami/common"
+
+// DropChanceMax is the drop chance that corresponds to a 100% drop rate
+const DropChanceMax = 1000000
+
+// A DropEntry is an item or meso range that a monster can drop.
+// Meso entries have an item id of 0 and their quantity is the meso amount.
+type DropEntry struct {
+	itemid      int32
+	chance      int32
+	minQuantity int32
+	maxQuantity int32
+}
+
+func (this *DropEntry) ItemId() int32      { return this.itemid }
+func (this *DropEntry) Chance() int32      { return this.chance }
+func (this *DropEntry) MinQuantity() int32 { return this.minQuantity }
+func (this *DropEntry) MaxQuantity() int32 { return this.maxQuantity }
+func (this *DropEntry) Meso() bool         { return this.itemid == 0 }
+
+// Roll returns true if the entry should drop with the given drop rate multiplier
+func (this *DropEntry) Roll(rate int32) bool {
+	return int64(rand.Intn(DropChanceMax)) < int64(this.chance)*int64(rate)
+}
+
+// Quantity returns a random quantity within the entry's range
+func (this *DropEntry) Quantity() int32 {
+	if this.maxQuantity <= this.minQuantity {
+		return this.minQuantity
+	}
+	return this.minQuantity + rand.Int31n(this.maxQuantity-this.minQuantity+1)
+}
+
+var dropTables = make(map[int32][]*DropEntry)
+var dropTablesMut sync.Mutex
+
+// MonsterDrops returns the drop table of the given monster id.
+// Drop tables are loaded from the database the first time they are requested.
+func MonsterDrops(mobid int32) (res []*DropEntry, err error) {
+	dropTablesMut.Lock()
+	defer dropTablesMut.Unlock()
+
+	res, ok := dropTables[mobid]
+	if ok {
+		return
+	}
+
+	db := common.GetDB()
+	st, err := db.Prepare("SELECT item_id, chance, min_quantity, max_quantity " +
+		"FROM monster_drops WHERE monster_id = ?")
+	if err != nil {
+		fmt.Println("Unexpected invalid query in MonsterDrops")
+		return
+	}
+
+	qres, err := st.Run(mobid)
+	rows, err := qres.GetRows()
+	if err != nil {
+		return
+	}
+
+	colitemid := qres.Map("item_id")
+	colchance := qres.Map("chance")
+	colmin := qres.Map("min_quantity")
+	colmax := qres.Map("max_quantity")
+
+	res = make([]*DropEntry, 0, len(rows))
+	for _, row := range rows {
+		res = append(res, &DropEntry{
+			itemid:      int32(row.Int(colitemid)),
+			chance:      int32(row.Int(colchance)),
+			minQuantity: int32(row.Int(colmin)),
+			maxQuantity: int32(row.Int(colmax)),
+		})
+	}
+
+	dropTables[mobid] = res
+	return
+}
+
+// ReloadDropTables clears the cached drop tables so that they
+// will be loaded again from the database
+func ReloadDropTables() {
+	dropTablesMut.Lock()
+	dropTables = make(map[int32][]*DropEntry)
+	dropTablesMut.Unlock()
+}
@@ -45,3 +45,18 @@ func ElementalEffectivenessFromNumber(num int32) ElementalEffectiveness {
 
 	return INVALID_EFFECTIVENESS
 }
+
+// DamageMultiplier returns the multiplier applied to the damage of an attack
+// of this effectiveness
+func (ee ElementalEffectiveness) DamageMultiplier() float64 {
+	switch ee {
+	case IMMUNE:
+		return 0.0
+	case STRONG:
+		return 0.5
+	case WEAK:
+		return 1.5
+	default:
+		return 1.0
+	}
+}
@@ -15,6 +15,187 @@
 
 package gamedata
 
+import "fmt"
+
+import "github.com/Francesco149/kagami/common/consts"
+
+import "github.com/Francesco149/maplelib/wz"
+
+// DefaultSlotMax is the stack size of items that don't specify one
+const DefaultSlotMax = 100
+
+// IsEquip returns true if the given item id is an equip
+func IsEquip(itemid int32) bool {
+	return itemid/1000000 == 1
+}
+
+// ItemWzCategory returns the name of the Item.wz directory that contains the given
+// item id or an empty string for equips and invalid ids
+func ItemWzCategory(itemid int32) string {
+	switch itemid / 1000000 {
+	case 2:
+		return "Consume"
+	case 3:
+		return "Install"
+	case 4:
+		return "Etc"
+	case 5:
+		if itemid/10000 == 500 {
+			return "Pet"
+		}
+		return "Cash"
+	}
+
+	return ""
+}
+
+// ItemData returns the Item.wz node of the given non-equip item id or nil
+func ItemData(itemid int32) wz.MapleData {
+	category := ItemWzCategory(itemid)
+
+	switch category {
+	case "":
+		return nil
+
+	case "Pet":
+		data, err := GetItemWz().Get(fmt.Sprintf("Pet/%07d.img", itemid))
+		if err != nil {
+			return nil
+		}
+		return data
+	}
+
+	img, err := GetItemWz().Get(fmt.Sprintf("%s/%04d.img", category, itemid/10000))
+	if err != nil || img == nil {
+		return nil
+	}
+
+	return img.ChildByPath(fmt.Sprintf("%08d", itemid))
+}
+
+// SlotMax returns the maximum stack size of the given item id
+func SlotMax(itemid int32) int16 {
+	return GetItemInfoProvider().SlotMax(itemid)
+}
+
+// EquipWzCategory returns the name of the Character.wz directory that contains the given
+// equip id or an empty string for non-equips and unknown ids
+func EquipWzCategory(itemid int32) string {
+	if !IsEquip(itemid) {
+		return ""
+	}
+
+	switch cat := itemid / 10000; {
+	case cat == 100:
+		return "Cap"
+	case cat >= 101 && cat <= 103, cat == 112:
+		return "Accessory"
+	case cat == 104:
+		return "Coat"
+	case cat == 105:
+		return "Longcoat"
+	case cat == 106:
+		return "Pants"
+	case cat == 107:
+		return "Shoes"
+	case cat == 108:
+		return "Glove"
+	case cat == 109:
+		return "Shield"
+	case cat == 110:
+		return "Cape"
+	case cat == 111:
+		return "Ring"
+	case cat >= 130 && cat <= 170:
+		return "Weapon"
+	case cat == 180:
+		return "PetEquip"
+	case cat == 190, cat == 191:
+		return "TamingMob"
+	}
+
+	return ""
+}
+
+// EquipData returns the Character.wz node of the given equip id or nil
+func EquipData(itemid int32) wz.MapleData {
+	category := EquipWzCategory(itemid)
+	if category == "" {
+		return nil
+	}
+
+	data, err := GetCharacterWz().Get(fmt.Sprintf("%s/%08d.img", category, itemid))
+	if err != nil {
+		return nil
+	}
+
+	return data
+}
+
+// IsOverall returns true if the given equip id is an overall, which covers the bottom slot
+func IsOverall(itemid int32) bool { return itemid/10000 == 105 }
+
+// IsShield returns true if the given equip id is a shield
+func IsShield(itemid int32) bool { return itemid/10000 == 109 }
+
+// ValidEquipSlot returns true if the given equip id can be worn in the given equip slot
+// (one of the consts.Equip* values, cash slots must have 100 subtracted)
+func ValidEquipSlot(itemid int32, slot int16) bool {
+	switch cat := itemid / 10000; {
+	case cat == 100:
+		return slot == consts.EquipHelm
+	case cat == 101:
+		return slot == consts.EquipFace
+	case cat == 102:
+		return slot == consts.EquipEye
+	case cat == 103:
+		return slot == consts.EquipEarring
+	case cat == 104, cat == 105:
+		return slot == consts.EquipTop
+	case cat == 106:
+		return slot == consts.EquipBottom
+	case cat == 107:
+		return slot == consts.EquipShoe
+	case cat == 108:
+		return slot == consts.EquipGlove
+	case cat == 109:
+		return slot == consts.EquipShield
+	case cat == 110:
+		return slot == consts.EquipCape
+	case cat == 111:
+		return slot == consts.EquipRing1 || slot == consts.EquipRing2 ||
+			slot == consts.EquipRing3 || slot == consts.EquipRing4
+	case cat == 112:
+		return slot == consts.EquipPendant
+	case cat >= 130 && cat <= 170:
+		return slot == consts.EquipWeapon
+	case cat == 180:
+		return slot == consts.EquipPetEquip1 || slot == consts.EquipPetEquip2 ||
+			slot == consts.EquipPetEquip3
+	case cat == 190:
+		return slot == consts.EquipMount
+	case cat == 191:
+		return slot == consts.EquipSaddle
+	}
+
+	return false
+}
+
+// IsUpgradeScroll returns true if the given item id is a scroll that upgrades equips
+func IsUpgradeScroll(itemid int32) bool { return itemid/10000 == 204 }
+
+// WhiteScrollId is the id of the item that protects upgrade slots on scroll failure
+const WhiteScrollId = 2340000
+
+// CanScroll returns true if the given upgrade scroll can be used on the given equip id
+func CanScroll(scrollid, equipid int32) bool {
+	// chaos scrolls and clean slates work on everything
+	if scrollid/100 == 20490 || scrollid/100 == 20491 {
+		return true
+	}
+	return (scrollid/100)%100 == (equipid/10000)%100
+}
+
 func IsThrowingStar(i GenericItem) bool {
 	return i.Id() >= 2070000 && i.Id() < 2080000
 }
@@ -23,6 +204,12 @@ func IsBullet(i GenericItem) bool {
 	return i.Id()/10000 == 233
 }
 
+// IsRechargeable returns true if the given item id is a throwing star or bullet,
+// which are sold and recharged by shops
+func IsRechargeable(itemid int32) bool {
+	return itemid/10000 == 207 || itemid/10000 == 233
+}
+
 func IsStackable(i GenericItem) bool {
 	return !IsThrowingStar(i) && !IsBullet(i)
 }
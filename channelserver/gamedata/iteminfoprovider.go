@@ -0,0 +1,336 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package gamedata
+
+import (
+	"fmt"
+	"sync"
+)
+
+import "github.com/Francesco149/maplelib/wz"
+
+// ItemEffect holds the effect of a consumable item as defined in its Item.wz spec node.
+// Stat buffs last Time() milliseconds.
+type ItemEffect struct {
+	hp, mp       int16
+	hpR, mpR     int16 // percent of max hp/mp
+	time         int32
+	watk, matk   int16
+	wdef, mdef   int16
+	acc, avoid   int16
+	speed, jump  int16
+	moveTo       int32 // map to teleport to, 999999999 is the nearest town
+	cureDiseases bool
+	cooldown     int32 // milliseconds before the item can be used again
+}
+
+func (this *ItemEffect) Hp() int16          { return this.hp }
+func (this *ItemEffect) Mp() int16          { return this.mp }
+func (this *ItemEffect) HpR() int16         { return this.hpR }
+func (this *ItemEffect) MpR() int16         { return this.mpR }
+func (this *ItemEffect) Time() int32        { return this.time }
+func (this *ItemEffect) WAtk() int16        { return this.watk }
+func (this *ItemEffect) MAtk() int16        { return this.matk }
+func (this *ItemEffect) WDef() int16        { return this.wdef }
+func (this *ItemEffect) MDef() int16        { return this.mdef }
+func (this *ItemEffect) Acc() int16         { return this.acc }
+func (this *ItemEffect) Avoid() int16       { return this.avoid }
+func (this *ItemEffect) Speed() int16       { return this.speed }
+func (this *ItemEffect) Jump() int16        { return this.jump }
+func (this *ItemEffect) MoveTo() int32      { return this.moveTo }
+func (this *ItemEffect) CureDiseases() bool { return this.cureDiseases }
+func (this *ItemEffect) Cooldown() int32    { return this.cooldown }
+
+// Buff returns true if the effect gives temporary stats
+func (this *ItemEffect) Buff() bool {
+	return this.time > 0 && (this.watk != 0 || this.matk != 0 || this.wdef != 0 ||
+		this.mdef != 0 || this.acc != 0 || this.avoid != 0 || this.speed != 0 ||
+		this.jump != 0)
+}
+
+// ItemInfo holds the wz metadata of a single item id.
+// Equip-only fields are zero for other items, except for upgrade scrolls
+// which use the equip stats as their stat increase.
+type ItemInfo struct {
+	id         int32
+	name       string
+	slotMax    int16
+	price      int32
+	unitPrice  float64 // price of a single piece of rechargeable ammo
+	cash       bool
+	quest      bool
+	tradeBlock bool
+	onlyOne    bool
+
+	// requirements
+	reqLevel byte
+	reqJob   int16
+	reqStr   int16
+	reqDex   int16
+	reqInt   int16
+	reqLuk   int16
+
+	// equip base stats
+	upgradeSlots byte
+	str, dex     int16
+	intt, luk    int16
+	hp, mp       int16
+	watk, matk   int16
+	wdef, mdef   int16
+	acc, avoid   int16
+	hands        int16
+	speed, jump  int16
+
+	// upgrade scrolls, which use the equip stats as the stat increase
+	success  int16 // percent
+	cursed   int16 // percent chance of destroying the equip on failure
+	randStat bool  // chaos scroll
+	recover  int16 // upgrade slots restored (clean slate scrolls)
+
+	// consumable items
+	effect *ItemEffect
+}
+
+func (this *ItemInfo) Id() int32          { return this.id }
+func (this *ItemInfo) Name() string       { return this.name }
+func (this *ItemInfo) SlotMax() int16     { return this.slotMax }
+func (this *ItemInfo) Price() int32       { return this.price }
+func (this *ItemInfo) UnitPrice() float64 { return this.unitPrice }
+func (this *ItemInfo) Cash() bool         { return this.cash }
+func (this *ItemInfo) Quest() bool        { return this.quest }
+func (this *ItemInfo) Tradeable() bool    { return !this.tradeBlock && !this.quest }
+func (this *ItemInfo) OnlyOne() bool      { return this.onlyOne }
+func (this *ItemInfo) ReqLevel() byte     { return this.reqLevel }
+func (this *ItemInfo) ReqJob() int16      { return this.reqJob }
+func (this *ItemInfo) ReqStr() int16      { return this.reqStr }
+func (this *ItemInfo) ReqDex() int16      { return this.reqDex }
+func (this *ItemInfo) ReqInt() int16      { return this.reqInt }
+func (this *ItemInfo) ReqLuk() int16      { return this.reqLuk }
+func (this *ItemInfo) UpgradeSlots() byte { return this.upgradeSlots }
+func (this *ItemInfo) Str() int16         { return this.str }
+func (this *ItemInfo) Dex() int16         { return this.dex }
+func (this *ItemInfo) Int() int16         { return this.intt }
+func (this *ItemInfo) Luk() int16         { return this.luk }
+func (this *ItemInfo) Hp() int16          { return this.hp }
+func (this *ItemInfo) Mp() int16          { return this.mp }
+func (this *ItemInfo) WAtk() int16        { return this.watk }
+func (this *ItemInfo) MAtk() int16        { return this.matk }
+func (this *ItemInfo) WDef() int16        { return this.wdef }
+func (this *ItemInfo) MDef() int16        { return this.mdef }
+func (this *ItemInfo) Acc() int16         { return this.acc }
+func (this *ItemInfo) Avoid() int16       { return this.avoid }
+func (this *ItemInfo) Hands() int16       { return this.hands }
+func (this *ItemInfo) Speed() int16       { return this.speed }
+func (this *ItemInfo) Jump() int16        { return this.jump }
+
+func (this *ItemInfo) Success() int16 { return this.success }
+func (this *ItemInfo) Cursed() int16  { return this.cursed }
+func (this *ItemInfo) RandStat() bool { return this.randStat }
+func (this *ItemInfo) Recover() int16 { return this.recover }
+
+// Effect returns the effect of a consumable item or nil
+func (this *ItemInfo) Effect() *ItemEffect { return this.effect }
+
+// An ItemInfoProvider extracts item metadata from Item.wz, Character.wz and String.wz
+// and caches it
+type ItemInfoProvider struct {
+	mut   sync.Mutex
+	items map[int32]*ItemInfo
+}
+
+// NewItemInfoProvider initializes an empty item info cache
+func NewItemInfoProvider() *ItemInfoProvider {
+	return &ItemInfoProvider{
+		items: make(map[int32]*ItemInfo),
+	}
+}
+
+var itemInfoProvider = NewItemInfoProvider()
+
+// GetItemInfoProvider returns the global item info provider
+func GetItemInfoProvider() *ItemInfoProvider { return itemInfoProvider }
+
+// Get returns the metadata of the given item id or nil if the item doesn't exist
+func (this *ItemInfoProvider) Get(itemid int32) *ItemInfo {
+	this.mut.Lock()
+	defer this.mut.Unlock()
+
+	res, ok := this.items[itemid]
+	if ok {
+		return res
+	}
+
+	res = loadItemInfo(itemid)
+	this.items[itemid] = res // nil results are cached too so we don't hit the wz files again
+	return res
+}
+
+// SlotMax returns the maximum stack size of the given item id
+func (this *ItemInfoProvider) SlotMax(itemid int32) int16 {
+	info := this.Get(itemid)
+	if info == nil {
+		return DefaultSlotMax
+	}
+	return info.SlotMax()
+}
+
+// Name returns the name of the given item id or an empty string
+func (this *ItemInfoProvider) Name(itemid int32) string {
+	info := this.Get(itemid)
+	if info == nil {
+		return ""
+	}
+	return info.Name()
+}
+
+// Effect returns the consume effect of the given item id or nil
+func (this *ItemInfoProvider) Effect(itemid int32) *ItemEffect {
+	info := this.Get(itemid)
+	if info == nil {
+		return nil
+	}
+	return info.Effect()
+}
+
+// Reload clears the cache so that items will be loaded again from the wz files
+func (this *ItemInfoProvider) Reload() {
+	this.mut.Lock()
+	this.items = make(map[int32]*ItemInfo)
+	this.mut.Unlock()
+}
+
+// itemName looks up the name of the given item id in String.wz
+func itemName(itemid int32) string {
+	var data wz.MapleData
+
+	switch category := ItemWzCategory(itemid); {
+	case IsEquip(itemid):
+		eqpcategory := EquipWzCategory(itemid)
+		if eqpcategory == "TamingMob" {
+			eqpcategory = "Taming"
+		}
+		data = GetEqpStringImg().ChildByPath(fmt.Sprintf("Eqp/%s/%d", eqpcategory, itemid))
+	case category == "Consume":
+		data = GetConsumeStringImg().ChildByPath(fmt.Sprintf("%d", itemid))
+	case category == "Install":
+		data = GetInsStringImg().ChildByPath(fmt.Sprintf("%d", itemid))
+	case category == "Etc":
+		data = GetEtcStringImg().ChildByPath(fmt.Sprintf("Etc/%d", itemid))
+	case category == "Cash":
+		data = GetCashStringImg().ChildByPath(fmt.Sprintf("%d", itemid))
+	case category == "Pet":
+		data = GetPetStringImg().ChildByPath(fmt.Sprintf("%d", itemid))
+	}
+
+	if data == nil {
+		return ""
+	}
+
+	return wz.GetStringD(data.ChildByPath("name"), "")
+}
+
+func loadItemInfo(itemid int32) *ItemInfo {
+	var data wz.MapleData
+	if IsEquip(itemid) {
+		data = EquipData(itemid)
+	} else {
+		data = ItemData(itemid)
+	}
+
+	if data == nil {
+		return nil
+	}
+
+	info := data.ChildByPath("info")
+	if info == nil {
+		return nil
+	}
+
+	geti := func(name string) int32 { return wz.GetIntConvertD(info.ChildByPath(name), 0) }
+	res := &ItemInfo{
+		id:         itemid,
+		name:       itemName(itemid),
+		slotMax:    int16(wz.GetIntConvertD(info.ChildByPath("slotMax"), DefaultSlotMax)),
+		price:      geti("price"),
+		unitPrice:  float64(wz.GetFloatD(info.ChildByPath("unitPrice"), 0)),
+		cash:       geti("cash") != 0,
+		quest:      geti("quest") != 0,
+		tradeBlock: geti("tradeBlock") != 0,
+		onlyOne:    geti("only") != 0,
+		reqLevel:   byte(geti("reqLevel")),
+		reqJob:     int16(geti("reqJob")),
+		reqStr:     int16(geti("reqSTR")),
+		reqDex:     int16(geti("reqDEX")),
+		reqInt:     int16(geti("reqINT")),
+		reqLuk:     int16(geti("reqLUK")),
+	}
+
+	if IsEquip(itemid) || IsUpgradeScroll(itemid) {
+		res.success = int16(wz.GetIntConvertD(info.ChildByPath("success"), 100))
+		res.cursed = int16(geti("cursed"))
+		res.randStat = geti("randstat") != 0
+		res.recover = int16(geti("recover"))
+		res.str = int16(geti("incSTR"))
+		res.dex = int16(geti("incDEX"))
+		res.intt = int16(geti("incINT"))
+		res.luk = int16(geti("incLUK"))
+		res.hp = int16(geti("incMHP"))
+		res.mp = int16(geti("incMMP"))
+		res.watk = int16(geti("incPAD"))
+		res.matk = int16(geti("incMAD"))
+		res.wdef = int16(geti("incPDD"))
+		res.mdef = int16(geti("incMDD"))
+		res.acc = int16(geti("incACC"))
+		res.avoid = int16(geti("incEVA"))
+		res.hands = int16(geti("incCraft"))
+		res.speed = int16(geti("incSpeed"))
+		res.jump = int16(geti("incJump"))
+	}
+
+	if IsEquip(itemid) {
+		res.slotMax = 1
+		res.upgradeSlots = byte(geti("tuc"))
+		return res
+	}
+
+	spec := data.ChildByPath("spec")
+	if spec == nil {
+		return res
+	}
+
+	gets := func(name string) int32 { return wz.GetIntConvertD(spec.ChildByPath(name), 0) }
+	res.effect = &ItemEffect{
+		hp:     int16(gets("hp")),
+		mp:     int16(gets("mp")),
+		hpR:    int16(gets("hpR")),
+		mpR:    int16(gets("mpR")),
+		time:   gets("time"),
+		watk:   int16(gets("pad")),
+		matk:   int16(gets("mad")),
+		wdef:   int16(gets("pdd")),
+		mdef:   int16(gets("mdd")),
+		acc:    int16(gets("acc")),
+		avoid:  int16(gets("eva")),
+		speed:  int16(gets("speed")),
+		jump:   int16(gets("jump")),
+		moveTo: gets("moveTo"),
+		cureDiseases: gets("poison") != 0 || gets("seal") != 0 || gets("darkness") != 0 ||
+			gets("weakness") != 0 || gets("curse") != 0,
+		cooldown: gets("cooltime") * 1000,
+	}
+
+	return res
+}
@@ -17,6 +17,8 @@ package gamedata
 
 import (
 	"errors"
+	"math"
+	"math/rand"
 	"time"
 )
 
@@ -69,6 +71,7 @@ type GenericItem interface {
 	PetId() int32
 	Clone() GenericItem
 	Encode(p *maplelib.Packet)
+	EncodeInfo(p *maplelib.Packet) // same as Encode but without the position
 }
 
 func encodePetItemInfo(this GenericItem, p *maplelib.Packet) {
@@ -139,7 +142,10 @@ func (this *Item) Clone() GenericItem {
 
 func (this *Item) Encode(p *maplelib.Packet) {
 	p.Encode1s(this.Pos())
+	this.EncodeInfo(p)
+}
 
+func (this *Item) EncodeInfo(p *maplelib.Packet) {
 	pet := this.PetId() > -1
 
 	if pet {
@@ -196,7 +202,6 @@ func (this *Equip) Encode(p *maplelib.Packet) {
 	pos := this.Pos()
 	masking := false
 	equipped := pos < 0
-	pet := this.PetId() > -1
 
 	if equipped {
 		pos *= -1
@@ -212,6 +217,17 @@ func (this *Equip) Encode(p *maplelib.Packet) {
 		p.Encode1s(this.Pos())
 	}
 
+	this.encodeInfo(p, masking)
+}
+
+func (this *Equip) EncodeInfo(p *maplelib.Packet) {
+	this.encodeInfo(p, false)
+}
+
+func (this *Equip) encodeInfo(p *maplelib.Packet, masking bool) {
+	ring := this.RingId() > -1
+	pet := this.PetId() > -1
+
 	if this.PetId() > -1 {
 		p.Encode1(0x03)
 	} else {
@@ -272,6 +288,12 @@ func (this *Equip) Encode(p *maplelib.Packet) {
 }
 
 func (this *Equip) Type() int8        { return ITEM_EQUIP }
+func (this *Equip) Str() int16        { return this.str }
+func (this *Equip) Dex() int16        { return this.dex }
+func (this *Equip) Int() int16        { return this.intt }
+func (this *Equip) Luk() int16        { return this.luk }
+func (this *Equip) WAtk() int16       { return this.watk }
+func (this *Equip) MAtk() int16       { return this.matk }
 func (this *Equip) SetRingId(v int32) { this.ringid = v }
 func (this *Equip) RingId() int32     { return this.ringid }
 func (this *Equip) SetAmount(v int16) {
@@ -281,6 +303,103 @@ func (this *Equip) SetAmount(v int16) {
 	this.amount = v
 }
 
+// upgrade slots and scroll count
+func (this *Equip) Slots() int8      { return this.slots }
+func (this *Equip) SetSlots(v int8)  { this.slots = v }
+func (this *Equip) Level() byte      { return this.level }
+func (this *Equip) SetLevel(v byte)  { this.level = v }
+func (this *Equip) Locked() int8     { return this.locked }
+func (this *Equip) SetLocked(v int8) { this.locked = v }
+func (this *Equip) Hp() int16        { return this.hp }
+func (this *Equip) Mp() int16        { return this.mp }
+func (this *Equip) WDef() int16      { return this.wdef }
+func (this *Equip) MDef() int16      { return this.mdef }
+func (this *Equip) Acc() int16       { return this.acc }
+func (this *Equip) Avoid() int16     { return this.avoid }
+func (this *Equip) Hands() int16     { return this.hands }
+func (this *Equip) Speed() int16     { return this.speed }
+func (this *Equip) Jump() int16      { return this.jump }
+func (this *Equip) SetStr(v int16)   { this.str = v }
+func (this *Equip) SetDex(v int16)   { this.dex = v }
+func (this *Equip) SetInt(v int16)   { this.intt = v }
+func (this *Equip) SetLuk(v int16)   { this.luk = v }
+func (this *Equip) SetHp(v int16)    { this.hp = v }
+func (this *Equip) SetMp(v int16)    { this.mp = v }
+func (this *Equip) SetWAtk(v int16)  { this.watk = v }
+func (this *Equip) SetMAtk(v int16)  { this.matk = v }
+func (this *Equip) SetWDef(v int16)  { this.wdef = v }
+func (this *Equip) SetMDef(v int16)  { this.mdef = v }
+func (this *Equip) SetAcc(v int16)   { this.acc = v }
+func (this *Equip) SetAvoid(v int16) { this.avoid = v }
+func (this *Equip) SetHands(v int16) { this.hands = v }
+func (this *Equip) SetSpeed(v int16) { this.speed = v }
+func (this *Equip) SetJump(v int16)  { this.jump = v }
+
+// NewEquipWithBaseStats initializes an equip with the base stats from its wz data
+func NewEquipWithBaseStats(id int32, slot int8) *Equip {
+	res := NewEquip(id, slot, -1)
+	res.SetBaseStats()
+	return res
+}
+
+// SetBaseStats resets the equip's stats and upgrade slots to the ones in its wz data
+func (this *Equip) SetBaseStats() {
+	info := GetItemInfoProvider().Get(this.Id())
+	if info == nil {
+		return
+	}
+
+	this.slots = int8(info.UpgradeSlots())
+	this.level = 0
+	this.str = info.Str()
+	this.dex = info.Dex()
+	this.intt = info.Int()
+	this.luk = info.Luk()
+	this.hp = info.Hp()
+	this.mp = info.Mp()
+	this.watk = info.WAtk()
+	this.matk = info.MAtk()
+	this.wdef = info.WDef()
+	this.mdef = info.MDef()
+	this.acc = info.Acc()
+	this.avoid = info.Avoid()
+	this.hands = info.Hands()
+	this.speed = info.Speed()
+	this.jump = info.Jump()
+}
+
+// randomStat returns v randomly offset by up to 10% of its value (max maxRange)
+func randomStat(v int16, maxRange int) int16 {
+	if v == 0 {
+		return 0
+	}
+
+	r := int(math.Ceil(float64(v) * 0.1))
+	if r > maxRange {
+		r = maxRange
+	}
+
+	return v - int16(r) + int16(rand.Intn(2*r+1))
+}
+
+// RandomizeStats slightly alters the stats of the equip, which is done to monster drops
+func (this *Equip) RandomizeStats() {
+	this.str = randomStat(this.str, 5)
+	this.dex = randomStat(this.dex, 5)
+	this.intt = randomStat(this.intt, 5)
+	this.luk = randomStat(this.luk, 5)
+	this.hp = randomStat(this.hp, 10)
+	this.mp = randomStat(this.mp, 10)
+	this.watk = randomStat(this.watk, 5)
+	this.matk = randomStat(this.matk, 5)
+	this.wdef = randomStat(this.wdef, 10)
+	this.mdef = randomStat(this.mdef, 10)
+	this.acc = randomStat(this.acc, 5)
+	this.avoid = randomStat(this.avoid, 5)
+	this.speed = randomStat(this.speed, 5)
+	this.jump = randomStat(this.jump, 5)
+}
+
 // Clone returns a copy of this equip
 func (this *Equip) Clone() GenericItem {
 	res := NewEquip(this.Item.Id(), this.Item.Pos(), this.RingId())
@@ -23,6 +23,10 @@ import "image"
 type IMapleGenericPortal interface {
 	Id() int32     // Id returns the portal id (NOT the object id!)
 	SetId(v int32) // Id sets the portal id (NOT the object id!)
+	// Index returns the position of the portal in the map's wz data, which is what
+	// the client uses to identify the spawn point when warping
+	Index() int32
+	SetIndex(v int32)
 	Name() string
 	Target() string
 	SetStatus(v bool)
@@ -50,6 +54,7 @@ type MapleGenericPortal struct {
 	typ         int32
 	status      bool
 	pid         int32
+	index       int32
 	scriptName  string
 	portalState bool
 }
@@ -75,6 +80,8 @@ func NewMapleMapPortal() *MapleMapPortal {
 
 func (this *MapleGenericPortal) Id() int32              { return this.pid }
 func (this *MapleGenericPortal) SetId(v int32)          { this.pid = v }
+func (this *MapleGenericPortal) Index() int32           { return this.index }
+func (this *MapleGenericPortal) SetIndex(v int32)       { this.index = v }
 func (this *MapleGenericPortal) Name() string           { return this.name }
 func (this *MapleGenericPortal) Target() string         { return this.target }
 func (this *MapleGenericPortal) SetStatus(v bool)       { this.status = v }
@@ -21,13 +21,23 @@ import (
 	"fmt"
 	"image"
 	"math"
+	"math/rand"
 	"sync"
 	"sync/atomic"
+	"time"
+)
+
+import (
+	"github.com/Francesco149/kagami/common/packets"
+	"github.com/Francesco149/maplelib"
 )
 
 // MAX_OID is the maximum allowed object id
 const MAX_OID = 20000
 
+// RespawnInterval is the delay between each monster respawn check
+const RespawnInterval = 10 * time.Second
+
 // all possible maple object types as an array so that they're iterable
 var rangedMapobjectTypes = []MapleMapObjectType{ITEM, MONSTER,
 	DOOR, SUMMON, REACTOR}
@@ -36,6 +46,7 @@ var rangedMapobjectTypes = []MapleMapObjectType{ITEM, MONSTER,
 // manages the map's objects and current state.
 type MapleMap struct {
 	objects         map[int32]MapleMapObject
+	players         map[int32]MapleMapPlayer
 	monsterSpawns   []*SpawnPoint
 	spawnedMonsters int64
 	portals         map[int32]MaplePortal
@@ -46,6 +57,7 @@ type MapleMap struct {
 	returnMapId     int32
 	monsterRate     float32
 	dropsDisabled   bool
+	spawnsDisabled  bool
 	clock           bool
 	boat            bool
 	docked          bool
@@ -71,6 +83,7 @@ func (this *MapleMap) String() string {
 		`{
 	%v / %v
 	%v objects 
+	%v players 
 	%v monsterSpawns 
 	%v spawnedMonsters 
 	%v portals 
@@ -94,6 +107,7 @@ func (this *MapleMap) String() string {
 }`,
 		this.mapName, this.streetName,
 		len(this.objects),
+		len(this.players),
 		len(this.monsterSpawns),
 		atomic.LoadInt64(&this.spawnedMonsters),
 		len(this.portals),
@@ -120,6 +134,7 @@ func (this *MapleMap) String() string {
 func NewMapleMap(mmapid, mreturnMapId int32, mmonsterRate float32) *MapleMap {
 	res := &MapleMap{
 		objects:         make(map[int32]MapleMapObject),
+		players:         make(map[int32]MapleMapPlayer),
 		monsterSpawns:   make([]*SpawnPoint, 0),
 		spawnedMonsters: 0,
 		portals:         make(map[int32]MaplePortal),
@@ -131,7 +146,7 @@ func NewMapleMap(mmapid, mreturnMapId int32, mmonsterRate float32) *MapleMap {
 		dropsDisabled:   false,
 		//mapEffect: nil,
 		everlast:        false,
-		forcedReturnMap: 999999999,
+		forcedReturnMap: NoForcedReturn,
 		//mapTimer: nil,
 		dropLife:    180000,
 		decHP:       0,
@@ -151,7 +166,7 @@ func NewMapleMap(mmapid, mreturnMapId int32, mmonsterRate float32) *MapleMap {
 			res.monsterRate = 1.0 - res.monsterRate
 		}
 
-		// TODO: spawn mob respawn thread
+		go res.respawnWorker()
 	}
 
 	return res
@@ -175,6 +190,23 @@ func (this *MapleMap) PortalById(id int32) MaplePortal {
 	return this.portals[id]
 }
 
+// RandomSpawnPortal returns a random spawn point in the map or the first portal if
+// the map has no spawn points
+func (this *MapleMap) RandomSpawnPortal() MaplePortal {
+	spawns := make([]MaplePortal, 0)
+	for _, port := range this.portals {
+		if port.Type() == SPAWN_PORTAL {
+			spawns = append(spawns, port)
+		}
+	}
+
+	if len(spawns) == 0 {
+		return this.PortalById(0)
+	}
+
+	return spawns[rand.Intn(len(spawns))]
+}
+
 func (this *MapleMap) SetFootholds(f *MapleFootholdTree) {
 	this.footholds = f
 }
@@ -183,10 +215,113 @@ func (this *MapleMap) AddMapleArea(a image.Rectangle) {
 	this.areas = append(this.areas, a)
 }
 
+// SpawnMonster adds a monster to the map, shows it to all the players
+// and assigns a controller to it
 func (this *MapleMap) SpawnMonster(m *MapleMonster) {
 	m.SetMap(this)
-	// TODO
+	this.AddMapObject(m)
 	atomic.AddInt64(&this.spawnedMonsters, 1)
+
+	this.mut.Lock()
+	defer this.mut.Unlock()
+	this.broadcast(m.SpawnPacket(true), -1)
+	this.updateMonsterController(m)
+}
+
+// AddMonsterSpawn creates a spawnpoint for the given monster slightly above the
+// ground below its position and spawns the first monster if necessary
+func (this *MapleMap) AddMonsterSpawn(m *MapleMonster, mobTime int32) {
+	pos := m.Pos()
+	below := this.CalcPointBelow(pos)
+	if below != nil {
+		pos = image.Pt(below.X, below.Y-1)
+	}
+
+	sp := NewSpawnPoint(m, pos, mobTime)
+
+	this.mut.Lock()
+	this.monsterSpawns = append(this.monsterSpawns, sp)
+	disabled := this.spawnsDisabled
+	this.mut.Unlock()
+
+	if !disabled && (sp.SpawnReady() || mobTime == -1) {
+		sp.SpawnMonster(this)
+	}
+}
+
+// Respawn spawns monsters on the ready spawnpoints until the map reaches
+// its spawn limit. Nothing is spawned if the map is empty or spawns are disabled.
+func (this *MapleMap) Respawn() {
+	this.mut.Lock()
+	if len(this.players) == 0 || this.spawnsDisabled {
+		this.mut.Unlock()
+		return
+	}
+
+	spawned := atomic.LoadInt64(&this.spawnedMonsters)
+	count := int(math.Floor(float64(int64(len(this.monsterSpawns))-spawned)*
+		float64(this.monsterRate) + 0.5))
+
+	ready := make([]*SpawnPoint, 0)
+	for _, i := range rand.Perm(len(this.monsterSpawns)) {
+		if len(ready) >= count {
+			break
+		}
+
+		if this.monsterSpawns[i].SpawnReady() {
+			ready = append(ready, this.monsterSpawns[i])
+		}
+	}
+	this.mut.Unlock()
+
+	for _, sp := range ready {
+		sp.SpawnMonster(this)
+	}
+}
+
+// respawnWorker periodically respawns monsters
+func (this *MapleMap) respawnWorker() {
+	for _ = range time.Tick(RespawnInterval) {
+		this.Respawn()
+	}
+}
+
+// CalcPointBelow returns the point on the first foothold below the given position.
+// If there is no foothold below the point, nil is returned.
+func (this *MapleMap) CalcPointBelow(p image.Point) *image.Point {
+	fh := this.footholds.FindBelow(p)
+	if fh == nil {
+		return nil
+	}
+
+	dropY := fh.Y1()
+	if !fh.Wall() && fh.Y1() != fh.Y2() {
+		// slopes
+		s1 := math.Abs(float64(fh.Y2() - fh.Y1()))
+		s2 := math.Abs(float64(fh.X2() - fh.X1()))
+		s4 := math.Abs(float64(p.X - fh.X1()))
+		alpha := math.Atan(s2 / s1)
+		beta := math.Atan(s1 / s2)
+		s5 := math.Cos(alpha) * (s4 / math.Cos(beta))
+
+		if fh.Y2() < fh.Y1() {
+			dropY = fh.Y1() - int(s5)
+		} else {
+			dropY = fh.Y1() + int(s5)
+		}
+	}
+
+	res := image.Pt(p.X, dropY)
+	return &res
+}
+
+// InBounds returns true if the given point is inside the area covered by the map's footholds.
+// Flying monsters and jumping players can go slightly above the highest foothold, so a small
+// margin is allowed on the top side
+func (this *MapleMap) InBounds(p image.Point) bool {
+	const topMargin = 300
+	return p.X >= this.footholds.X1() && p.X <= this.footholds.X2() &&
+		p.Y >= this.footholds.Y1()-topMargin && p.Y <= this.footholds.Y2()
 }
 
 func (this *MapleMap) SpawnReactor(r *MapleReactor) {
@@ -202,6 +337,10 @@ func (this *MapleMap) SetStreetName(v string) {
 	this.streetName = v
 }
 
+// ReturnMapId returns the map that return scrolls lead to.
+// 999999999 means that this map is a town.
+func (this *MapleMap) ReturnMapId() int32 { return this.returnMapId }
+
 func (this *MapleMap) Id() int32 {
 	return this.mapid
 }
@@ -214,6 +353,18 @@ func (this *MapleMap) StreetName() string {
 	return this.streetName
 }
 
+// NoForcedReturn is the forced return map of maps that don't have one
+const NoForcedReturn = 999999999
+
+func (this *MapleMap) Clock() bool            { return this.clock }
+func (this *MapleMap) Town() bool             { return this.town }
+func (this *MapleMap) HPDec() int32           { return this.decHP }
+func (this *MapleMap) HPDecProtect() int32    { return this.protectItem }
+func (this *MapleMap) ForcedReturnMap() int32 { return this.forcedReturnMap }
+
+// TimeLimit returns how many seconds players can stay in the map, -1 = unlimited
+func (this *MapleMap) TimeLimit() int { return this.timeLimit }
+
 func (this *MapleMap) SetClock(v bool)            { this.clock = v }
 func (this *MapleMap) SetEverlast(v bool)         { this.everlast = v }
 func (this *MapleMap) SetTown(v bool)             { this.town = v }
@@ -223,10 +374,475 @@ func (this *MapleMap) SetForcedReturnMap(v int32) { this.forcedReturnMap = v }
 func (this *MapleMap) SetBoat(v bool)             { this.boat = v }
 func (this *MapleMap) SetTimeLimit(v int)         { this.timeLimit = v }
 
+// SpawnsDisabled returns true if no monsters are being spawned in the map
+func (this *MapleMap) SpawnsDisabled() bool {
+	this.mut.Lock()
+	defer this.mut.Unlock()
+	return this.spawnsDisabled
+}
+
+// SetSpawnsDisabled stops or resumes spawning monsters in the map. Monsters that are
+// already spawned are left alone.
+func (this *MapleMap) SetSpawnsDisabled(v bool) {
+	this.mut.Lock()
+	defer this.mut.Unlock()
+	this.spawnsDisabled = v
+}
+
+// AddPlayer adds a player to the map, sends the map's objects to it and
+// assigns it the control of any uncontrolled monster.
+// NOTE: this must be called after the map warp packet has been sent
+func (this *MapleMap) AddPlayer(p MapleMapPlayer) {
+	this.mut.Lock()
+	defer this.mut.Unlock()
+
+	this.players[p.CharId()] = p
+
+	for _, obj := range this.objects {
+		switch o := obj.(type) {
+		case *MapleMonster:
+			p.SendPacket(o.SpawnPacket(false))
+			this.updateMonsterController(o)
+
+		case *MapleMapItem:
+			p.SendPacket(o.SpawnPacket(packets.DropNoAnimation))
+
+		case *MapleNPC:
+			if !o.Hidden() {
+				p.SendPacket(o.SpawnPacket())
+			}
+		}
+	}
+}
+
+// RemovePlayer removes a player from the map and hands over
+// the monsters it was controlling to the other players
+func (this *MapleMap) RemovePlayer(p MapleMapPlayer) {
+	this.mut.Lock()
+	defer this.mut.Unlock()
+
+	delete(this.players, p.CharId())
+
+	for _, obj := range this.objects {
+		mob, ok := obj.(*MapleMonster)
+		if !ok || mob.Controller() == nil || mob.Controller().CharId() != p.CharId() {
+			continue
+		}
+
+		mob.controller = nil
+		mob.SetControllerHasAggro(false)
+		mob.SetControllerKnowsAboutAggro(false)
+		this.updateMonsterController(mob)
+	}
+}
+
+// Player returns the player with the given character id if it's in this map, otherwise nil
+func (this *MapleMap) Player(charid int32) MapleMapPlayer {
+	this.mut.Lock()
+	defer this.mut.Unlock()
+	return this.players[charid]
+}
+
+// PlayerCount returns the number of players currently in the map
+func (this *MapleMap) PlayerCount() int {
+	this.mut.Lock()
+	defer this.mut.Unlock()
+	return len(this.players)
+}
+
+// Monster returns the monster with the given object id or nil if it doesn't exist
+func (this *MapleMap) Monster(oid int32) *MapleMonster {
+	this.mut.Lock()
+	defer this.mut.Unlock()
+
+	mob, ok := this.objects[oid].(*MapleMonster)
+	if !ok {
+		return nil
+	}
+
+	return mob
+}
+
+// NPC returns the NPC with the given object id or nil if it doesn't exist
+func (this *MapleMap) NPC(oid int32) *MapleNPC {
+	this.mut.Lock()
+	defer this.mut.Unlock()
+
+	npc, _ := this.objects[oid].(*MapleNPC)
+	return npc
+}
+
+// Broadcast sends a packet to all the players in the map except the
+// given character id. Pass -1 to send the packet to everyone.
+func (this *MapleMap) Broadcast(p maplelib.Packet, except int32) {
+	this.mut.Lock()
+	defer this.mut.Unlock()
+	this.broadcast(p, except)
+}
+
+// broadcast is the non thread-safe version of Broadcast
+func (this *MapleMap) broadcast(p maplelib.Packet, except int32) {
+	for charid, player := range this.players {
+		if charid == except {
+			continue
+		}
+
+		// packets are encrypted in-place so each player needs its own copy
+		err := player.SendPacket(clonePacket(p))
+		if err != nil {
+			fmt.Println("Failed to broadcast packet to", charid, ":", err)
+		}
+	}
+}
+
+func clonePacket(p maplelib.Packet) maplelib.Packet {
+	bytes := []byte(p)
+	clone := make([]byte, len(bytes))
+	copy(clone, bytes)
+	return maplelib.Packet(clone)
+}
+
+// controlledMonsters returns how many monsters are being controlled by the given player
+func (this *MapleMap) controlledMonsters(p MapleMapPlayer) (count int) {
+	for _, obj := range this.objects {
+		mob, ok := obj.(*MapleMonster)
+		if ok && mob.Controller() != nil && mob.Controller().CharId() == p.CharId() {
+			count++
+		}
+	}
+	return
+}
+
+// updateMonsterController assigns the monster to the player that controls
+// the least monsters if its current controller is no longer in the map
+func (this *MapleMap) updateMonsterController(m *MapleMonster) {
+	if m.Controller() != nil {
+		if this.players[m.Controller().CharId()] != nil {
+			return
+		}
+
+		m.controller = nil
+	}
+
+	var newController MapleMapPlayer
+	mincontrolled := -1
+
+	for _, player := range this.players {
+		controlled := this.controlledMonsters(player)
+		if mincontrolled == -1 || controlled < mincontrolled {
+			mincontrolled = controlled
+			newController = player
+		}
+	}
+
+	if newController == nil {
+		return
+	}
+
+	m.controller = newController
+	m.SetControllerHasAggro(false)
+	m.SetControllerKnowsAboutAggro(false)
+	newController.SendPacket(m.ControlPacket(false))
+}
+
+// SwitchMonsterController hands the control of a monster over to the given player.
+// aggro determines whether the monster will immediately chase the new controller.
+func (this *MapleMap) SwitchMonsterController(m *MapleMonster, p MapleMapPlayer, aggro bool) {
+	this.mut.Lock()
+	defer this.mut.Unlock()
+	this.switchMonsterController(m, p, aggro)
+}
+
+// switchMonsterController is the non thread-safe version of SwitchMonsterController
+func (this *MapleMap) switchMonsterController(m *MapleMonster, p MapleMapPlayer, aggro bool) {
+	if this.players[p.CharId()] == nil {
+		return
+	}
+
+	old := m.Controller()
+	if old != nil && old.CharId() == p.CharId() {
+		if aggro && !m.ControllerHasAggro() {
+			m.SetControllerHasAggro(true)
+			m.SetControllerKnowsAboutAggro(false)
+			p.SendPacket(m.ControlPacket(true))
+		}
+		return
+	}
+
+	if old != nil && this.players[old.CharId()] != nil {
+		old.SendPacket(packets.StopControllingMonster(m.ObjId()))
+	}
+
+	m.controller = p
+	m.SetControllerHasAggro(aggro)
+	m.SetControllerKnowsAboutAggro(false)
+	p.SendPacket(m.ControlPacket(aggro))
+}
+
+// MonsterController returns the player controlling a monster or nil and whether the
+// monster is chasing it
+func (this *MapleMap) MonsterController(m *MapleMonster) (controller MapleMapPlayer, aggro bool) {
+	this.mut.Lock()
+	defer this.mut.Unlock()
+	return m.Controller(), m.ControllerHasAggro()
+}
+
+// MonsterPos returns the position of a monster as known by the server
+func (this *MapleMap) MonsterPos(m *MapleMonster) image.Point {
+	this.mut.Lock()
+	defer this.mut.Unlock()
+	return m.Pos()
+}
+
+// AcknowledgeMonsterMove checks that a monster movement was sent by the monster's
+// controller and returns whether the monster is chasing it, which the controller is
+// then told about. Immobile monsters lose aggro once the controller has acknowledged
+// it. ok is false if the player isn't the controller.
+func (this *MapleMap) AcknowledgeMonsterMove(m *MapleMonster, charid int32,
+	skill int8) (ok, aggro bool) {
+
+	this.mut.Lock()
+	defer this.mut.Unlock()
+
+	if m.Controller() == nil || m.Controller().CharId() != charid {
+		return
+	}
+
+	if skill == -1 && m.ControllerKnowsAboutAggro() && !m.Stats().Mobile() {
+		m.SetControllerHasAggro(false)
+		m.SetControllerKnowsAboutAggro(false)
+	}
+
+	aggro = m.ControllerHasAggro()
+	if aggro {
+		m.SetControllerKnowsAboutAggro(true)
+	}
+
+	return true, aggro
+}
+
+// MoveMonster updates a monster's position after a movement and relays the
+// movement to every player except the controller
+func (this *MapleMap) MoveMonster(m *MapleMonster, moves *Movement, useSkill bool,
+	skill int8, skillArgs [4]byte, startPos image.Point) {
+
+	this.mut.Lock()
+	defer this.mut.Unlock()
+
+	if moves.HasPos() {
+		m.SetPos(moves.EndPos())
+	}
+
+	if moves.Fh() >= 0 {
+		m.SetFh(int32(moves.Fh()))
+	}
+
+	m.SetStance(int32(moves.Stance()))
+
+	except := int32(-1)
+	if m.Controller() != nil {
+		except = m.Controller().CharId()
+	}
+
+	this.broadcast(packets.MoveMonster(m.ObjId(), useSkill, skill, skillArgs,
+		startPos, moves.Raw()), except)
+}
+
+// DamageMonster applies the damage dealt by a player to a monster and kills the monster
+// if its hp runs out. Returns true if the monster was killed by this hit.
+func (this *MapleMap) DamageMonster(p MapleMapPlayer, m *MapleMonster, damage int32) (killed bool) {
+	this.mut.Lock()
+
+	if this.objects[m.ObjId()] != m {
+		// the monster already died or left the map
+		this.mut.Unlock()
+		return
+	}
+
+	killed = m.damage(p.CharId(), damage)
+	if !killed {
+		if !m.Stats().Boss() {
+			p.SendPacket(packets.ShowMonsterHp(m.ObjId(), m.HpPercent()))
+		}
+
+		// monsters chase whoever attacks them
+		if !m.ControllerHasAggro() {
+			this.switchMonsterController(m, p, true)
+		}
+
+		this.mut.Unlock()
+		return
+	}
+
+	this.killMonster(m, packets.MonsterDie)
+	this.mut.Unlock()
+
+	if m.onKilled != nil {
+		m.onKilled(m)
+	}
+
+	return
+}
+
+// KillMonster removes a monster from the map without giving any reward.
+// animation must be one of the animations accepted by packets.KillMonster
+func (this *MapleMap) KillMonster(m *MapleMonster, animation byte) {
+	this.mut.Lock()
+	if this.objects[m.ObjId()] != m {
+		this.mut.Unlock()
+		return
+	}
+
+	this.killMonster(m, animation)
+	this.mut.Unlock()
+
+	if m.onKilled != nil {
+		m.onKilled(m)
+	}
+}
+
+// killMonster is the non thread-safe version of KillMonster that doesn't call
+// the monster's OnKilled callback
+func (this *MapleMap) killMonster(m *MapleMonster, animation byte) {
+	m.hp = 0
+	delete(this.objects, m.ObjId())
+	atomic.AddInt64(&this.spawnedMonsters, -1)
+	this.broadcast(packets.KillMonster(m.ObjId(), animation), -1)
+	m.controller = nil
+}
+
+// DropOffset is the horizontal distance between the items dropped by a monster
+const DropOffset = 25
+
+// SpawnMonsterDrops rolls the drop table of a dead monster and drops the items and mesos
+// around it. ownerid is the character id of the player that owns the drops and
+// partyid is the owner's party (0 for none), whose members can also loot them.
+// dropRate and mesoRate are the world's rate multipliers.
+func (this *MapleMap) SpawnMonsterDrops(mob *MapleMonster, ownerid, partyid int32,
+	dropRate, mesoRate int32) {
+
+	if this.dropsDisabled || mob.dropsDisabled {
+		return
+	}
+
+	table, err := MonsterDrops(mob.Id())
+	if err != nil {
+		fmt.Println("Failed to load the drop table of monster", mob.Id(), ":", err)
+		return
+	}
+
+	ffa := mob.Stats().FfaLoot()
+	d := 1
+
+	for _, entry := range table {
+		if !entry.Roll(dropRate) {
+			continue
+		}
+
+		// drops are spread alternately on the right and on the left of the monster
+		x := mob.Pos().X
+		if d%2 == 0 {
+			x += DropOffset * ((d + 1) / 2)
+		} else {
+			x -= DropOffset * (d / 2)
+		}
+
+		pos := mob.Pos()
+		below := this.CalcPointBelow(image.Pt(x, mob.Pos().Y-50))
+		if below != nil {
+			pos = *below
+		}
+
+		var drop *MapleMapItem
+
+		switch {
+		case entry.Meso():
+			meso := entry.Quantity() * mesoRate
+			if meso <= 0 {
+				continue
+			}
+			drop = NewMapleMapMeso(meso, pos, ownerid, mob, ffa)
+
+		case IsEquip(entry.ItemId()):
+			equip := NewEquipWithBaseStats(entry.ItemId(), 0)
+			equip.RandomizeStats()
+			drop = NewMapleMapItem(equip, pos, ownerid, mob, ffa)
+
+		default:
+			item := NewItem(entry.ItemId(), 0, int16(entry.Quantity()), -1)
+			drop = NewMapleMapItem(item, pos, ownerid, mob, ffa)
+		}
+
+		drop.SetPartyId(partyid)
+		this.SpawnDrop(drop)
+		d++
+	}
+}
+
+// SpawnPlayerDrop drops an item from the given player's inventory onto the ground below him
+func (this *MapleMap) SpawnPlayerDrop(item GenericItem, p MapleMapPlayer) {
+	pos := p.Pos()
+	below := this.CalcPointBelow(image.Pt(pos.X, pos.Y-50))
+	if below != nil {
+		pos = *below
+	}
+
+	this.SpawnDrop(NewPlayerMapleMapItem(item, pos, p))
+}
+
+// SpawnDrop adds an item to the map, shows it to every player and schedules its expiration
+func (this *MapleMap) SpawnDrop(drop *MapleMapItem) {
+	this.mut.Lock()
+	this.addMapObject(drop)
+	this.broadcast(drop.SpawnPacket(packets.DropAnimation), -1)
+	this.mut.Unlock()
+
+	time.AfterFunc(time.Duration(this.dropLife)*time.Millisecond, func() {
+		this.mut.Lock()
+		defer this.mut.Unlock()
+
+		if this.objects[drop.ObjId()] != drop || drop.pickedUp {
+			return
+		}
+
+		delete(this.objects, drop.ObjId())
+		this.broadcast(packets.RemoveItemFromMap(drop.ObjId(), packets.RemoveItemExpire, 0), -1)
+	})
+}
+
+// Drop returns the drop with the given object id or nil if it doesn't exist
+func (this *MapleMap) Drop(oid int32) *MapleMapItem {
+	this.mut.Lock()
+	defer this.mut.Unlock()
+
+	drop, _ := this.objects[oid].(*MapleMapItem)
+	return drop
+}
+
+// PickupDrop removes the given drop from the map and shows the given player picking it up.
+// Returns false if the drop was already picked up or expired.
+func (this *MapleMap) PickupDrop(drop *MapleMapItem, p MapleMapPlayer) bool {
+	this.mut.Lock()
+	defer this.mut.Unlock()
+
+	if this.objects[drop.ObjId()] != drop || drop.pickedUp {
+		return false
+	}
+
+	drop.pickedUp = true
+	delete(this.objects, drop.ObjId())
+	this.broadcast(packets.RemoveItemFromMap(drop.ObjId(), packets.RemoveItemPickup, p.CharId()), -1)
+	return true
+}
+
 func (this *MapleMap) AddMapObject(mapobj MapleMapObject) {
 	this.mut.Lock() // thread safety
 	defer this.mut.Unlock()
+	this.addMapObject(mapobj)
+}
 
+// addMapObject is the non thread-safe version of AddMapObject
+func (this *MapleMap) addMapObject(mapobj MapleMapObject) {
 	mapobj.SetObjId(this.runningOid)
 	this.objects[this.runningOid] = mapobj
 	this.incrementRunningOid()
@@ -55,7 +55,10 @@ func DebugPrintln(a ...interface{}) {
 }
 
 // Get looks up the given map in wz files and initializes a new map object.
-// respawns, npcs and reactors determine whether those objects will be loaded or not.
+// npcs and reactors determine whether those objects will be loaded or not. When
+// respawns is false the spawnpoints are loaded but no monsters are spawned until
+// the map's spawns are enabled. Maps are cached, so the flags only matter the first
+// time a map is loaded.
 func (f *MapleMapFactory) Get(mapid int32, respawns, npcs, reactors bool) *MapleMap {
 	DebugPrintln(fmt.Sprintf("MapleMapFactory.Get(%v, %v, %v, %v)",
 		mapid, respawns, npcs, reactors))
@@ -78,12 +81,10 @@ func (f *MapleMapFactory) Get(mapid int32, respawns, npcs, reactors bool) *Maple
 
 	// spawn rate
 	monsterRate := float32(0)
-	if respawns {
-		pmobrate := wz.GetFloat(mapData.ChildByPath("info/mobRate"))
-		if pmobrate != nil {
-			monsterRate = *pmobrate
-			DebugPrintln("found info/mobRate =", monsterRate)
-		}
+	pmobrate := wz.GetFloat(mapData.ChildByPath("info/mobRate"))
+	if pmobrate != nil {
+		monsterRate = *pmobrate
+		DebugPrintln("found info/mobRate =", monsterRate)
 	}
 	DebugPrintln("monsterRate =", monsterRate)
 
@@ -97,6 +98,7 @@ func (f *MapleMapFactory) Get(mapid int32, respawns, npcs, reactors bool) *Maple
 
 	// initialize the map
 	res = NewMapleMap(mapid, *preturnMap, monsterRate)
+	res.SetSpawnsDisabled(!respawns)
 
 	// portals
 	portalFactory := NewPortalFactory()
@@ -107,7 +109,7 @@ func (f *MapleMapFactory) Get(mapid int32, respawns, npcs, reactors bool) *Maple
 	}
 
 	DebugPrintln("\nPortals:")
-	for _, portal := range portalData.Children() {
+	for i, portal := range portalData.Children() {
 		pportaltype := wz.GetInt(portal.ChildByPath("pt"))
 		if pportaltype == nil {
 			DebugPrintln("ignored portal with no pt")
@@ -119,6 +121,10 @@ func (f *MapleMapFactory) Get(mapid int32, respawns, npcs, reactors bool) *Maple
 			DebugPrintln("portalFactory.Make returned nil")
 			return nil
 		}
+
+		// the client counts portals by their position in the wz data, which doesn't
+		// always match the id for maps that have gaps in their portal list
+		newportal.(IMapleGenericPortal).SetIndex(int32(i))
 		tmpstr := ""
 
 		if len(newportal.Target()) > 0 {
@@ -271,8 +277,12 @@ func (f *MapleMapFactory) Get(mapid int32, respawns, npcs, reactors bool) *Maple
 				}
 
 				// doesn't respawn so spawn it once immediately
-				if mobTime == -1 && respawns {
-					res.SpawnMonster(mapleMonster)
+				if mobTime == -1 {
+					if respawns {
+						res.SpawnMonster(mapleMonster)
+					}
+				} else {
+					res.AddMonsterSpawn(mapleMonster, mobTime)
 				}
 			} else {
 				//DebugPrintln("not a *MapleMonster")
@@ -286,7 +296,7 @@ func (f *MapleMapFactory) Get(mapid int32, respawns, npcs, reactors bool) *Maple
 	// reactor entities
 	DebugPrintln("\nReactor:")
 	reactorData := mapData.ChildByPath("reactor")
-	if reactorData != nil {
+	if reactorData != nil && reactors {
 		for _, reactor := range reactorData.Children() {
 			pid := wz.GetIntConvert(reactor.ChildByPath("id"))
 			if pid == nil {
@@ -322,7 +332,7 @@ func (f *MapleMapFactory) Get(mapid int32, respawns, npcs, reactors bool) *Maple
 	res.SetTown(mapData.ChildByPath("town") != nil)
 	res.SetHPDec(wz.GetIntConvertD(mapData.ChildByPath("decHP"), 0))
 	res.SetHPDecProtect(wz.GetIntConvertD(mapData.ChildByPath("protectItem"), 0))
-	res.SetForcedReturnMap(wz.GetIntD(mapData.ChildByPath("info/forcedReturn"), 999999999))
+	res.SetForcedReturnMap(wz.GetIntD(mapData.ChildByPath("info/forcedReturn"), NoForcedReturn))
 	res.SetBoat(mapData.ChildByPath("shipObj") != nil)
 	res.SetTimeLimit(int(wz.GetIntConvertD(mapData.ChildByPath("info/timeLimit"), -1)))
 
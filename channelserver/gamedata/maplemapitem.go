@@ -0,0 +1,124 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package gamedata
+
+import (
+	"image"
+	"time"
+)
+
+import (
+	"github.com/Francesco149/kagami/common/packets"
+	"github.com/Francesco149/maplelib"
+)
+
+// DropOwnershipTime is how long only the owner of a non-ffa drop is allowed to loot it
+const DropOwnershipTime = 15 * time.Second
+
+// MapleMapItem is an item or a meso bag lying on the ground
+type MapleMapItem struct {
+	*AbstractMapleMapObject
+	item     GenericItem // nil for mesos
+	meso     int32
+	ownerid  int32 // character id of the owner
+	partyid  int32 // party of the owner at the time of the drop, 0 if none
+	dropper  int32 // object id of the entity that dropped the item
+	dropFrom image.Point
+	ffa      bool
+	dropTime time.Time
+	pickedUp bool
+}
+
+func newMapleMapItem(pos image.Point, ownerid int32, dropper int32, dropFrom image.Point,
+	ffa bool) *MapleMapItem {
+
+	return &MapleMapItem{
+		AbstractMapleMapObject: NewAbstractMapleMapObject(pos, 0,
+			func(this *AbstractMapleMapObject) MapleMapObjectType {
+				return ITEM
+			}),
+		ownerid:  ownerid,
+		dropper:  dropper,
+		dropFrom: dropFrom,
+		ffa:      ffa,
+		dropTime: time.Now(),
+	}
+}
+
+// NewMapleMapItem initializes an item drop at the given position
+func NewMapleMapItem(item GenericItem, pos image.Point, ownerid int32,
+	dropper MapleMapObject, ffa bool) *MapleMapItem {
+
+	res := newMapleMapItem(pos, ownerid, dropper.ObjId(), dropper.Pos(), ffa)
+	res.item = item
+	return res
+}
+
+// NewPlayerMapleMapItem initializes an item dropped by a player at the given position.
+// Player drops can be looted by anyone.
+func NewPlayerMapleMapItem(item GenericItem, pos image.Point, p MapleMapPlayer) *MapleMapItem {
+	res := newMapleMapItem(pos, p.CharId(), p.CharId(), p.Pos(), true)
+	res.item = item
+	return res
+}
+
+// NewMapleMapMeso initializes a meso drop at the given position
+func NewMapleMapMeso(meso int32, pos image.Point, ownerid int32,
+	dropper MapleMapObject, ffa bool) *MapleMapItem {
+
+	res := newMapleMapItem(pos, ownerid, dropper.ObjId(), dropper.Pos(), ffa)
+	res.meso = meso
+	return res
+}
+
+// Item returns the dropped item or nil if this is a meso drop
+func (this *MapleMapItem) Item() GenericItem { return this.item }
+
+// Meso returns the amount of mesos or 0 if this is an item drop
+func (this *MapleMapItem) Meso() int32 { return this.meso }
+
+func (this *MapleMapItem) OwnerId() int32      { return this.ownerid }
+func (this *MapleMapItem) Ffa() bool           { return this.ffa }
+func (this *MapleMapItem) DropTime() time.Time { return this.dropTime }
+func (this *MapleMapItem) PickedUp() bool      { return this.pickedUp }
+
+// SetPartyId lets the members of the given party loot this drop as if they owned it
+func (this *MapleMapItem) SetPartyId(partyid int32) { this.partyid = partyid }
+
+// CanLoot returns true if the given character in the given party (0 for none) is
+// allowed to pick up this drop
+func (this *MapleMapItem) CanLoot(charid, partyid int32) bool {
+	return this.ffa || this.ownerid == charid ||
+		(this.partyid != 0 && this.partyid == partyid) ||
+		time.Since(this.dropTime) >= DropOwnershipTime
+}
+
+// SpawnPacket returns a packet that shows this drop to a player.
+// mod must be one of the animation modes accepted by packets.DropItemFromMapObject
+func (this *MapleMapItem) SpawnPacket(mod byte) maplelib.Packet {
+	dropType := byte(packets.DropTypeOwner)
+	if this.ffa {
+		dropType = packets.DropTypeFfa
+	}
+
+	if this.item == nil {
+		return packets.DropItemFromMapObject(mod, this.ObjId(), true, this.meso,
+			this.ownerid, dropType, this.dropper, this.dropFrom, this.Pos())
+	}
+
+	return packets.DropItemFromMapObject(mod, this.ObjId(), false, this.item.Id(),
+		this.ownerid, dropType, this.dropper, this.dropFrom, this.Pos())
+}
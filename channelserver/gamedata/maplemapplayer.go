@@ -0,0 +1,28 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package gamedata
+
+import "image"
+import "github.com/Francesco149/maplelib"
+
+// MapleMapPlayer is a generic interface for players that are currently inside a map.
+// It is implemented by the channelserver's client connections so that maps can
+// notify players without depending on the client package.
+type MapleMapPlayer interface {
+	CharId() int32 // CharId returns the player's character id
+	Pos() image.Point
+	SendPacket(p maplelib.Packet) error
+}
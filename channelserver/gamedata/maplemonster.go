@@ -17,6 +17,11 @@ package gamedata
 
 // This is a nearly 1:1 port of OdinMS' wz xml parsing, so credits to OdinMS.
 
+import (
+	"github.com/Francesco149/kagami/common/packets"
+	"github.com/Francesco149/maplelib"
+)
+
 // MapleMonster holds information about a maplestory monster.
 type MapleMonster struct {
 	*AbstractLoadedMapleLife
@@ -28,6 +33,14 @@ type MapleMonster struct {
 	venomMultiplier int32
 	fake            bool
 	dropsDisabled   bool
+
+	// the controller is the player whose client computes the monster's movement
+	controller                MapleMapPlayer
+	controllerHasAggro        bool
+	controllerKnowsAboutAggro bool
+
+	attackers map[int32]int64 // total damage dealt by each character id
+	onKilled  func(m *MapleMonster)
 }
 
 // NewMapleMonster initializes a monster with the given stats.
@@ -43,6 +56,7 @@ func NewMapleMonster(id int32, stats *MapleMonsterStats) *MapleMonster {
 	res.stats = stats
 	res.hp = stats.Hp()
 	res.mp = stats.Mp()
+	res.attackers = make(map[int32]int64)
 
 	return res
 }
@@ -59,3 +73,74 @@ func (this *MapleMonster) Stats() *MapleMonsterStats {
 func (this *MapleMonster) SetMap(v *MapleMap) {
 	this.curmap = v
 }
+
+func (this *MapleMonster) Map() *MapleMap { return this.curmap }
+func (this *MapleMonster) Hp() int32      { return this.hp }
+func (this *MapleMonster) Mp() int32      { return this.mp }
+func (this *MapleMonster) SetMp(v int32)  { this.mp = v }
+
+// Controller returns the player that is currently controlling the monster or nil
+func (this *MapleMonster) Controller() MapleMapPlayer { return this.controller }
+
+// ControllerHasAggro returns true if the monster is chasing its controller
+func (this *MapleMonster) ControllerHasAggro() bool { return this.controllerHasAggro }
+
+// ControllerKnowsAboutAggro returns true if the controller has acknowledged the aggro
+func (this *MapleMonster) ControllerKnowsAboutAggro() bool { return this.controllerKnowsAboutAggro }
+
+func (this *MapleMonster) SetControllerHasAggro(v bool)        { this.controllerHasAggro = v }
+func (this *MapleMonster) SetControllerKnowsAboutAggro(v bool) { this.controllerKnowsAboutAggro = v }
+
+// SpawnPacket returns a packet that shows this monster to a player.
+// newSpawn determines whether the spawn animation is played.
+func (this *MapleMonster) SpawnPacket(newSpawn bool) maplelib.Packet {
+	return packets.SpawnMonster(this.ObjId(), this.Id(), this.Pos(), byte(this.Stance()),
+		int16(this.Fh()), packets.MonsterSpawnNoEffect, newSpawn)
+}
+
+// ControlPacket returns a packet that assigns the control of this monster to a player
+func (this *MapleMonster) ControlPacket(aggro bool) maplelib.Packet {
+	return packets.ControlMonster(this.ObjId(), this.Id(), this.Pos(), byte(this.Stance()),
+		int16(this.Fh()), aggro)
+}
+
+// Alive returns false if the monster has been killed
+func (this *MapleMonster) Alive() bool { return this.hp > 0 }
+
+// HpPercent returns the remaining hp as a percentage of the max hp
+func (this *MapleMonster) HpPercent() byte {
+	if this.Stats().Hp() <= 0 {
+		return 0
+	}
+	return byte(int64(this.hp) * 100 / int64(this.Stats().Hp()))
+}
+
+// SetOnKilled sets a function that will be called after the monster dies
+func (this *MapleMonster) SetOnKilled(f func(m *MapleMonster)) { this.onKilled = f }
+
+// Attackers returns a copy of the total damage dealt by each character id
+func (this *MapleMonster) Attackers() map[int32]int64 {
+	res := make(map[int32]int64)
+	for charid, dmg := range this.attackers {
+		res[charid] = dmg
+	}
+	return res
+}
+
+// damage subtracts hp from the monster and records the damage dealt by the attacker.
+// Only the damage that actually took hp away is recorded.
+// Returns true if the monster died because of this hit.
+// NOTE: this is not thread-safe, use MapleMap.DamageMonster
+func (this *MapleMonster) damage(charid int32, dmg int32) bool {
+	if !this.Alive() || dmg <= 0 {
+		return false
+	}
+
+	if dmg > this.hp {
+		dmg = this.hp
+	}
+
+	this.hp -= dmg
+	this.attackers[charid] += int64(dmg)
+	return !this.Alive()
+}
@@ -72,10 +72,32 @@ func (s *MapleMonsterStats) SetSkills(v [][2]int32) { s.skills = v }
 func (s *MapleMonsterStats) SetFirstAttack(v bool)  { s.firstAttack = v }
 func (s *MapleMonsterStats) SetBuffToGive(v int32)  { s.buffToGive = v }
 
+func (s *MapleMonsterStats) Exp() int32   { return s.exp }
 func (s *MapleMonsterStats) Hp() int32    { return s.hp }
 func (s *MapleMonsterStats) Mp() int32    { return s.mp }
 func (s *MapleMonsterStats) Boss() bool   { return s.boss }
 func (s *MapleMonsterStats) Name() string { return s.name }
+func (s *MapleMonsterStats) Level() int32 { return s.level }
+
+func (s *MapleMonsterStats) Undead() bool  { return s.undead }
+func (s *MapleMonsterStats) FfaLoot() bool { return s.ffaLoot }
+
+// AnimationTime returns the duration in milliseconds of the given animation or 0
+func (s *MapleMonsterStats) AnimationTime(name string) int32 {
+	if s.animationTimes[name] == nil {
+		return 0
+	}
+	return *s.animationTimes[name]
+}
+
+// Effectiveness returns how effective the given element is against this monster
+func (s *MapleMonsterStats) Effectiveness(e Element) ElementalEffectiveness {
+	ee, ok := s.resistance[e]
+	if !ok {
+		return NORMAL
+	}
+	return ee
+}
 
 func (s *MapleMonsterStats) Mobile() bool {
 	return s.animationTimes["move"] != nil || s.animationTimes["fly"] != nil
@@ -17,6 +17,11 @@ package gamedata
 
 // This is a nearly 1:1 port of OdinMS' wz xml parsing, so credits to OdinMS.
 
+import (
+	"github.com/Francesco149/kagami/common/packets"
+	"github.com/Francesco149/maplelib"
+)
+
 // MapleNPC holds information about a NPC.
 type MapleNPC struct {
 	*AbstractLoadedMapleLife
@@ -31,6 +36,7 @@ func NewMapleNPC(id int32, name string) *MapleNPC {
 			func(this *AbstractMapleMapObject) MapleMapObjectType {
 				return NPC
 			}),
+		name:   name,
 		custom: false,
 	}
 }
@@ -38,3 +44,9 @@ func NewMapleNPC(id int32, name string) *MapleNPC {
 func (this *MapleNPC) Name() string     { return this.name }
 func (this *MapleNPC) Custom() bool     { return this.custom }
 func (this *MapleNPC) SetCustom(v bool) { this.custom = v }
+
+// SpawnPacket returns a packet that shows this NPC to a player
+func (this *MapleNPC) SpawnPacket() maplelib.Packet {
+	return packets.SpawnNpc(this.ObjId(), this.Id(), int16(this.Pos().X), int16(this.Cy()),
+		this.F() == 1, int16(this.Fh()), int16(this.Rx0()), int16(this.Rx1()))
+}
@@ -21,10 +21,36 @@ import "image"
 
 // Possible portal types
 const (
-	MAP_PORTAL  = 2
-	DOOR_PORTAL = 6
+	SPAWN_PORTAL                   = 0  // spawn point, can't be entered
+	INVISIBLE_PORTAL               = 1  // invisible map portal
+	MAP_PORTAL                     = 2  // regular map portal
+	COLLISION_PORTAL               = 3  // entered by touching it
+	CHANGEABLE_PORTAL              = 4  // portal with a changeable target
+	CHANGEABLE_INVISIBLE_PORTAL    = 5  // invisible portal with a changeable target
+	DOOR_PORTAL                    = 6  // town portal point (mystic door)
+	SCRIPT_PORTAL                  = 7  // scripted portal
+	SCRIPT_INVISIBLE_PORTAL        = 8  // invisible scripted portal
+	COLLISION_SCRIPT_PORTAL        = 9  // scripted portal entered by touching it
+	HIDDEN_PORTAL                  = 10 // only shows up when the player is near it
+	HIDDEN_SCRIPT_PORTAL           = 11 // scripted portal that only shows up when the player is near it
+	COLLISION_VERTICAL_JUMP_PORTAL = 12 // collision portal that makes the player jump
+	COLLISION_CUSTOM_IMPACT_PORTAL = 13 // collision portal that pushes the player
 )
 
+// NO_TARGET_MAP is the target map id of portals that don't lead anywhere
+const NO_TARGET_MAP = 999999999
+
+// IsCollisionPortal returns true if the given portal type is entered by touching it
+// rather than pressing up
+func IsCollisionPortal(portaltype int32) bool {
+	switch portaltype {
+	case COLLISION_PORTAL, COLLISION_SCRIPT_PORTAL, COLLISION_VERTICAL_JUMP_PORTAL,
+		COLLISION_CUSTOM_IMPACT_PORTAL:
+		return true
+	}
+	return false
+}
+
 // Possible portal statuses
 const (
 	OPEN   = true
@@ -35,6 +61,7 @@ const (
 type MaplePortal interface {
 	Type() int32 // Type returns the portal type (MAP_PORTAL, DOOR_PORTAL)
 	Id() int32
+	Index() int32 // Index returns the position of the portal in the map's wz data
 	Pos() image.Point
 	Name() string
 	Target() string
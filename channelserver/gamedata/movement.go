@@ -0,0 +1,165 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package gamedata
+
+import (
+	"errors"
+	"fmt"
+	"image"
+)
+
+import "github.com/Francesco149/maplelib"
+
+// Movement command types sent by the client in movement fragments
+const (
+	MOVE_NORMAL       = 0
+	MOVE_JUMP         = 1
+	MOVE_KNOCKBACK    = 2
+	MOVE_TELEPORT     = 3
+	MOVE_ASSAULTER    = 4
+	MOVE_FALL         = 5
+	MOVE_FLASH_JUMP   = 6
+	MOVE_RUSH         = 7
+	MOVE_UNK1         = 8
+	MOVE_UNK2         = 9
+	MOVE_CHANGE_EQUIP = 10
+	MOVE_CHAIR        = 11
+	MOVE_AIM          = 12
+	MOVE_SWORD_ATTACK = 13
+	MOVE_UNK3         = 14
+	MOVE_JUMP_DOWN    = 15
+	MOVE_WINGS        = 16
+	MOVE_FLOAT        = 17
+)
+
+// Movement holds the result of parsing a movement packet.
+// The raw fragments are kept so that the movement can be relayed as-is.
+type Movement struct {
+	raw       []byte
+	fragments byte
+	endPos    image.Point
+	stance    byte
+	fh        int16
+	hasPos    bool
+}
+
+// Raw returns the raw movement data including the fragment count
+func (m *Movement) Raw() []byte { return m.raw }
+
+// Fragments returns the number of movement fragments
+func (m *Movement) Fragments() byte { return m.fragments }
+
+// EndPos returns the last absolute position found in the movement.
+// HasPos returns false if none of the fragments had an absolute position.
+func (m *Movement) EndPos() image.Point { return m.endPos }
+func (m *Movement) HasPos() bool        { return m.hasPos }
+
+// Stance returns the stance of the entity after the movement
+func (m *Movement) Stance() byte { return m.stance }
+
+// Fh returns the last known foothold, -1 if the movement didn't touch any foothold
+func (m *Movement) Fh() int16 { return m.fh }
+
+// ParseMovement decodes a list of movement fragments from a packet iterator
+func ParseMovement(it *maplelib.PacketIterator) (res *Movement, err error) {
+	begin := []byte(*it)
+	res = &Movement{fh: -1}
+
+	res.fragments, err = it.Decode1()
+	if err != nil {
+		return
+	}
+
+	for i := byte(0); i < res.fragments; i++ {
+		var command byte
+		command, err = it.Decode1()
+		if err != nil {
+			return
+		}
+
+		switch command {
+		case MOVE_NORMAL, MOVE_FALL, MOVE_FLOAT:
+			err = res.decodeAbsolute(it, true, false)
+			if err == nil {
+				_, err = it.Decode2s() // duration
+			}
+
+		case MOVE_JUMP, MOVE_KNOCKBACK, MOVE_FLASH_JUMP, MOVE_AIM,
+			MOVE_SWORD_ATTACK, MOVE_WINGS:
+			_, err = it.Decode2s() // x velocity
+			_, err = it.Decode2s() // y velocity
+			res.stance, err = it.Decode1()
+			_, err = it.Decode2s() // duration
+
+		case MOVE_TELEPORT, MOVE_ASSAULTER, MOVE_RUSH, MOVE_UNK1, MOVE_UNK2, MOVE_UNK3:
+			err = res.decodeAbsolute(it, false, false)
+
+		case MOVE_CHANGE_EQUIP:
+			_, err = it.Decode1()
+
+		case MOVE_CHAIR:
+			var x, y int16
+			x, err = it.Decode2s()
+			y, err = it.Decode2s()
+			_, err = it.Decode2s() // what the hell is this
+			res.stance, err = it.Decode1()
+			_, err = it.Decode2s() // duration
+			res.endPos = image.Pt(int(x), int(y))
+			res.hasPos = true
+
+		case MOVE_JUMP_DOWN:
+			err = res.decodeAbsolute(it, true, true)
+			if err == nil {
+				_, err = it.Decode2s() // duration
+			}
+
+		default:
+			err = errors.New(fmt.Sprint("Unknown movement command ", command))
+		}
+
+		if err != nil {
+			return
+		}
+	}
+
+	res.raw = begin[:len(begin)-len([]byte(*it))]
+	return
+}
+
+// decodeAbsolute decodes a movement fragment that contains an absolute position
+func (m *Movement) decodeAbsolute(it *maplelib.PacketIterator, hasFh, hasOrigFh bool) (err error) {
+	x, err := it.Decode2s()
+	y, err := it.Decode2s()
+	_, err = it.Decode2s() // x wobble
+	_, err = it.Decode2s() // y wobble
+
+	if hasFh {
+		m.fh, err = it.Decode2s()
+	}
+
+	if hasOrigFh {
+		_, err = it.Decode2s() // foothold the entity jumped down from
+	}
+
+	m.stance, err = it.Decode1()
+	if err != nil {
+		return
+	}
+
+	m.endPos = image.Pt(int(x), int(y))
+	m.hasPos = true
+	return
+}
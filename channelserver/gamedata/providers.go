@@ -25,10 +25,12 @@ import (
 	"github.com/Francesco149/maplelib/wz"
 )
 
-var mapWz, mobWz, itemWz, characterWz, stringWz, reactorWz wz.MapleDataProvider
+var mapWz, mobWz, itemWz, characterWz, stringWz, reactorWz, questWz,
+	skillWz wz.MapleDataProvider
 var cashStringData, consumeStringData, eqpStringData,
 	etcStringData, insStringData, petStringData,
-	mobStringData, npcStringData, mapStringData wz.MapleData
+	mobStringData, npcStringData, mapStringData,
+	questCheckData, questActData, questInfoData wz.MapleData
 
 func GetMapWz() wz.MapleDataProvider       { return mapWz }
 func GetMobWz() wz.MapleDataProvider       { return mobWz }
@@ -36,6 +38,8 @@ func GetItemWz() wz.MapleDataProvider      { return itemWz }
 func GetCharacterWz() wz.MapleDataProvider { return characterWz }
 func GetStringWz() wz.MapleDataProvider    { return stringWz }
 func GetReactorWz() wz.MapleDataProvider   { return reactorWz }
+func GetQuestWz() wz.MapleDataProvider     { return questWz }
+func GetSkillWz() wz.MapleDataProvider     { return skillWz }
 
 func GetCashStringImg() wz.MapleData    { return cashStringData }
 func GetConsumeStringImg() wz.MapleData { return consumeStringData }
@@ -46,6 +50,9 @@ func GetPetStringImg() wz.MapleData     { return petStringData }
 func GetMobStringImg() wz.MapleData     { return mobStringData }
 func GetNpcStringImg() wz.MapleData     { return npcStringData }
 func GetMapStringImg() wz.MapleData     { return mapStringData }
+func GetQuestCheckImg() wz.MapleData    { return questCheckData }
+func GetQuestActImg() wz.MapleData      { return questActData }
+func GetQuestInfoImg() wz.MapleData     { return questInfoData }
 
 // InitProviders initializes all of the wz data providers.
 func InitProviders() (err error) {
@@ -58,6 +65,8 @@ func InitProviders() (err error) {
 		&characterWz: "wz/Character.wz",
 		&stringWz:    "wz/String.wz",
 		&reactorWz:   "wz/Reactor.wz",
+		&questWz:     "wz/Quest.wz",
+		&skillWz:     "wz/Skill.wz",
 	}
 
 	for pprovider, wzpath := range wzLoad {
@@ -81,6 +90,9 @@ func InitProviders() (err error) {
 		&mobStringData:     &utils.Pair{stringWz, "Mob.img"},
 		&npcStringData:     &utils.Pair{stringWz, "Npc.img"},
 		&mapStringData:     &utils.Pair{stringWz, "Map.img"},
+		&questCheckData:    &utils.Pair{questWz, "Check.img"},
+		&questActData:      &utils.Pair{questWz, "Act.img"},
+		&questInfoData:     &utils.Pair{questWz, "QuestInfo.img"},
 	}
 
 	for pdata, pair := range imgLoad {
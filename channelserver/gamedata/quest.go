@@ -0,0 +1,293 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package gamedata
+
+import (
+	"fmt"
+	"sync"
+)
+
+import "github.com/Francesco149/maplelib/wz"
+
+// Possible quest statuses
+const (
+	QuestNotStarted = 0
+	QuestStarted    = 1
+	QuestCompleted  = 2
+)
+
+// QuestItem is an item required or given by a quest.
+// Negative counts in quest actions take the item from the player.
+type QuestItem struct {
+	id     int32
+	count  int32
+	prop   int32 // weight in the random reward pool, 0 means always given
+	gender int8  // 0 = male, 1 = female, 2 = both
+}
+
+func (this *QuestItem) Id() int32    { return this.id }
+func (this *QuestItem) Count() int32 { return this.count }
+func (this *QuestItem) Prop() int32  { return this.prop }
+func (this *QuestItem) Gender() int8 { return this.gender }
+
+// QuestMob is a monster the player must kill to complete a quest
+type QuestMob struct {
+	id    int32
+	count int32
+}
+
+func (this *QuestMob) Id() int32    { return this.id }
+func (this *QuestMob) Count() int32 { return this.count }
+
+// QuestPrereq is the status another quest must be in to start or complete a quest
+type QuestPrereq struct {
+	id    int16
+	state byte
+}
+
+func (this *QuestPrereq) Id() int16   { return this.id }
+func (this *QuestPrereq) State() byte { return this.state }
+
+// QuestCheck holds the requirements to start or complete a quest as defined in
+// Quest.wz/Check.img
+type QuestCheck struct {
+	npc      int32
+	lvmin    byte
+	lvmax    byte // 0 means no limit
+	jobs     []int16
+	items    []*QuestItem
+	mobs     []*QuestMob
+	quests   []*QuestPrereq
+	interval int32 // minutes before a repeatable quest can be started again
+	pop      int16 // minimum fame
+}
+
+func (this *QuestCheck) Npc() int32             { return this.npc }
+func (this *QuestCheck) LvMin() byte            { return this.lvmin }
+func (this *QuestCheck) LvMax() byte            { return this.lvmax }
+func (this *QuestCheck) Jobs() []int16          { return this.jobs }
+func (this *QuestCheck) Items() []*QuestItem    { return this.items }
+func (this *QuestCheck) Mobs() []*QuestMob      { return this.mobs }
+func (this *QuestCheck) Quests() []*QuestPrereq { return this.quests }
+func (this *QuestCheck) Interval() int32        { return this.interval }
+func (this *QuestCheck) Pop() int16             { return this.pop }
+
+// QuestAct holds the rewards and actions executed when a quest is started or
+// completed as defined in Quest.wz/Act.img
+type QuestAct struct {
+	exp       int32
+	meso      int32
+	pop       int16
+	items     []*QuestItem
+	nextQuest int16
+	info      string // quest info string to set
+}
+
+func (this *QuestAct) Exp() int32          { return this.exp }
+func (this *QuestAct) Meso() int32         { return this.meso }
+func (this *QuestAct) Pop() int16          { return this.pop }
+func (this *QuestAct) Items() []*QuestItem { return this.items }
+func (this *QuestAct) NextQuest() int16    { return this.nextQuest }
+func (this *QuestAct) Info() string        { return this.info }
+
+// Quest holds the data of a quest
+type Quest struct {
+	id              int16
+	name            string
+	autoStart       bool
+	autoPreComplete bool
+	startCheck      *QuestCheck
+	completeCheck   *QuestCheck
+	startAct        *QuestAct
+	completeAct     *QuestAct
+}
+
+func (this *Quest) Id() int16                  { return this.id }
+func (this *Quest) Name() string               { return this.name }
+func (this *Quest) AutoStart() bool            { return this.autoStart }
+func (this *Quest) AutoPreComplete() bool      { return this.autoPreComplete }
+func (this *Quest) StartCheck() *QuestCheck    { return this.startCheck }
+func (this *Quest) CompleteCheck() *QuestCheck { return this.completeCheck }
+func (this *Quest) StartAct() *QuestAct        { return this.startAct }
+func (this *Quest) CompleteAct() *QuestAct     { return this.completeAct }
+
+// MobIndex returns the index of the given monster id in the kill requirements of
+// the quest or -1 if the quest doesn't require it
+func (this *Quest) MobIndex(mobid int32) int {
+	for i, mob := range this.completeCheck.Mobs() {
+		if mob.Id() == mobid {
+			return i
+		}
+	}
+	return -1
+}
+
+// A QuestProvider loads quest data from Quest.wz and caches it
+type QuestProvider struct {
+	mut    sync.Mutex
+	quests map[int16]*Quest
+}
+
+// NewQuestProvider initializes an empty quest cache
+func NewQuestProvider() *QuestProvider {
+	return &QuestProvider{
+		quests: make(map[int16]*Quest),
+	}
+}
+
+var questProvider = NewQuestProvider()
+
+// GetQuestProvider returns the global quest provider
+func GetQuestProvider() *QuestProvider { return questProvider }
+
+// Get returns the given quest or nil if it doesn't exist
+func (this *QuestProvider) Get(questid int16) *Quest {
+	this.mut.Lock()
+	defer this.mut.Unlock()
+
+	res, ok := this.quests[questid]
+	if ok {
+		return res
+	}
+
+	res = loadQuest(questid)
+	this.quests[questid] = res
+	return res
+}
+
+// Reload clears the cache so that quests will be loaded again from the wz files
+func (this *QuestProvider) Reload() {
+	this.mut.Lock()
+	this.quests = make(map[int16]*Quest)
+	this.mut.Unlock()
+}
+
+func loadQuestItems(data wz.MapleData) (res []*QuestItem) {
+	res = make([]*QuestItem, 0)
+	if data == nil {
+		return
+	}
+
+	for _, item := range data.Children() {
+		res = append(res, &QuestItem{
+			id:     wz.GetIntConvertD(item.ChildByPath("id"), 0),
+			count:  wz.GetIntConvertD(item.ChildByPath("count"), 1),
+			prop:   wz.GetIntConvertD(item.ChildByPath("prop"), 0),
+			gender: int8(wz.GetIntConvertD(item.ChildByPath("gender"), 2)),
+		})
+	}
+
+	return
+}
+
+func loadQuestCheck(data wz.MapleData) *QuestCheck {
+	res := &QuestCheck{
+		jobs:   make([]int16, 0),
+		items:  make([]*QuestItem, 0),
+		mobs:   make([]*QuestMob, 0),
+		quests: make([]*QuestPrereq, 0),
+	}
+
+	if data == nil {
+		return res
+	}
+
+	res.npc = wz.GetIntConvertD(data.ChildByPath("npc"), 0)
+	res.lvmin = byte(wz.GetIntConvertD(data.ChildByPath("lvmin"), 0))
+	res.lvmax = byte(wz.GetIntConvertD(data.ChildByPath("lvmax"), 0))
+	res.interval = wz.GetIntConvertD(data.ChildByPath("interval"), 0)
+	res.pop = int16(wz.GetIntConvertD(data.ChildByPath("pop"), 0))
+	res.items = loadQuestItems(data.ChildByPath("item"))
+
+	if jobs := data.ChildByPath("job"); jobs != nil {
+		for _, job := range jobs.Children() {
+			res.jobs = append(res.jobs, int16(wz.GetIntConvertD(job, 0)))
+		}
+	}
+
+	if mobs := data.ChildByPath("mob"); mobs != nil {
+		for _, mob := range mobs.Children() {
+			res.mobs = append(res.mobs, &QuestMob{
+				id:    wz.GetIntConvertD(mob.ChildByPath("id"), 0),
+				count: wz.GetIntConvertD(mob.ChildByPath("count"), 1),
+			})
+		}
+	}
+
+	if quests := data.ChildByPath("quest"); quests != nil {
+		for _, quest := range quests.Children() {
+			res.quests = append(res.quests, &QuestPrereq{
+				id:    int16(wz.GetIntConvertD(quest.ChildByPath("id"), 0)),
+				state: byte(wz.GetIntConvertD(quest.ChildByPath("state"), 0)),
+			})
+		}
+	}
+
+	return res
+}
+
+func loadQuestAct(data wz.MapleData) *QuestAct {
+	res := &QuestAct{items: make([]*QuestItem, 0)}
+	if data == nil {
+		return res
+	}
+
+	res.exp = wz.GetIntConvertD(data.ChildByPath("exp"), 0)
+	res.meso = wz.GetIntConvertD(data.ChildByPath("money"), 0)
+	res.pop = int16(wz.GetIntConvertD(data.ChildByPath("pop"), 0))
+	res.nextQuest = int16(wz.GetIntConvertD(data.ChildByPath("nextQuest"), 0))
+	res.info = wz.GetStringD(data.ChildByPath("info"), "")
+	res.items = loadQuestItems(data.ChildByPath("item"))
+	return res
+}
+
+func loadQuest(questid int16) *Quest {
+	path := fmt.Sprintf("%d", questid)
+
+	info := GetQuestInfoImg().ChildByPath(path)
+	check := GetQuestCheckImg().ChildByPath(path)
+	act := GetQuestActImg().ChildByPath(path)
+
+	if info == nil && check == nil && act == nil {
+		return nil
+	}
+
+	res := &Quest{id: questid}
+
+	if info != nil {
+		res.name = wz.GetStringD(info.ChildByPath("name"), "")
+		res.autoStart = wz.GetIntConvertD(info.ChildByPath("autoStart"), 0) != 0
+		res.autoPreComplete = wz.GetIntConvertD(info.ChildByPath("autoPreComplete"), 0) != 0
+	}
+
+	var startCheck, completeCheck, startAct, completeAct wz.MapleData
+
+	if check != nil {
+		startCheck = check.ChildByPath("0")
+		completeCheck = check.ChildByPath("1")
+	}
+
+	if act != nil {
+		startAct = act.ChildByPath("0")
+		completeAct = act.ChildByPath("1")
+	}
+
+	res.startCheck = loadQuestCheck(startCheck)
+	res.completeCheck = loadQuestCheck(completeCheck)
+	res.startAct = loadQuestAct(startAct)
+	res.completeAct = loadQuestAct(completeAct)
+	return res
+}
@@ -0,0 +1,106 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package gamedata
+
+import (
+	"fmt"
+	"sync"
+)
+
+import "github.com/Francesco149/kagami/common"
+
+// A ShopItem is an item sold by a npc shop
+type ShopItem struct {
+	itemid int32
+	price  int32
+}
+
+func (this *ShopItem) ItemId() int32 { return this.itemid }
+func (this *ShopItem) Price() int32  { return this.price }
+
+// A Shop is the list of items sold by a npc
+type Shop struct {
+	npcid int32
+	items []*ShopItem
+}
+
+func (this *Shop) NpcId() int32       { return this.npcid }
+func (this *Shop) Items() []*ShopItem { return this.items }
+
+// Item returns the item at the given position of the shop window or nil
+func (this *Shop) Item(slot int16) *ShopItem {
+	if slot < 0 || int(slot) >= len(this.items) {
+		return nil
+	}
+	return this.items[slot]
+}
+
+var shops = make(map[int32]*Shop)
+var shopsMut sync.Mutex
+
+// NpcShop returns the shop of the given npc id or nil if the npc doesn't have one.
+// Shops are loaded from the database the first time they are requested.
+func NpcShop(npcid int32) (res *Shop, err error) {
+	shopsMut.Lock()
+	defer shopsMut.Unlock()
+
+	res, ok := shops[npcid]
+	if ok {
+		return
+	}
+
+	db := common.GetDB()
+	st, err := db.Prepare("SELECT item_id, price FROM shop_items " +
+		"WHERE npc_id = ? ORDER BY position ASC")
+	if err != nil {
+		fmt.Println("Unexpected invalid query in NpcShop")
+		return
+	}
+
+	qres, err := st.Run(npcid)
+	rows, err := qres.GetRows()
+	if err != nil {
+		return
+	}
+
+	// npcs without a shop are cached as nil so they aren't queried again
+	if len(rows) == 0 {
+		shops[npcid] = nil
+		return
+	}
+
+	colitemid := qres.Map("item_id")
+	colprice := qres.Map("price")
+
+	res = &Shop{npcid: npcid, items: make([]*ShopItem, 0, len(rows))}
+	for _, row := range rows {
+		res.items = append(res.items, &ShopItem{
+			itemid: int32(row.Int(colitemid)),
+			price:  int32(row.Int(colprice)),
+		})
+	}
+
+	shops[npcid] = res
+	return
+}
+
+// ReloadShops clears the cached shops so that they
+// will be loaded again from the database
+func ReloadShops() {
+	shopsMut.Lock()
+	shops = make(map[int32]*Shop)
+	shopsMut.Unlock()
+}
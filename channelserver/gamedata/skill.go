@@ -0,0 +1,205 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package gamedata
+
+import (
+	"fmt"
+	"sync"
+)
+
+import "github.com/Francesco149/maplelib/wz"
+
+// SkillEffect holds the stats of a skill at a certain level as defined in
+// Skill.wz/<job>.img/skill/<skill id>/level/<level>
+type SkillEffect struct {
+	mpCon, hpCon int16
+	damage       int32 // damage percentage of physical attacks
+	mobCount     byte  // max monsters hit
+	attackCount  byte  // max damage lines per monster
+	time         int32 // buff duration in seconds
+	watk, matk   int16 // pad and mad. mad is also the spell attack of magic skills
+	wdef, mdef   int16
+	acc, avoid   int16
+	speed, jump  int16
+	x, y         int16 // skill-specific values
+	prop         int16 // success rate
+	cooltime     int32 // seconds
+}
+
+func (this *SkillEffect) MpCon() int16      { return this.mpCon }
+func (this *SkillEffect) HpCon() int16      { return this.hpCon }
+func (this *SkillEffect) Damage() int32     { return this.damage }
+func (this *SkillEffect) MobCount() byte    { return this.mobCount }
+func (this *SkillEffect) AttackCount() byte { return this.attackCount }
+func (this *SkillEffect) Time() int32       { return this.time }
+func (this *SkillEffect) WAtk() int16       { return this.watk }
+func (this *SkillEffect) MAtk() int16       { return this.matk }
+func (this *SkillEffect) WDef() int16       { return this.wdef }
+func (this *SkillEffect) MDef() int16       { return this.mdef }
+func (this *SkillEffect) Acc() int16        { return this.acc }
+func (this *SkillEffect) Avoid() int16      { return this.avoid }
+func (this *SkillEffect) Speed() int16      { return this.speed }
+func (this *SkillEffect) Jump() int16       { return this.jump }
+func (this *SkillEffect) X() int16          { return this.x }
+func (this *SkillEffect) Y() int16          { return this.y }
+func (this *SkillEffect) Prop() int16       { return this.prop }
+func (this *SkillEffect) Cooltime() int32   { return this.cooltime }
+
+// Skill holds the data of a skill
+type Skill struct {
+	id      int32
+	element Element
+	reqs    map[int32]byte // required skill id -> level
+	levels  []*SkillEffect // index 0 is level 1
+}
+
+func (this *Skill) Id() int32            { return this.id }
+func (this *Skill) Element() Element     { return this.element }
+func (this *Skill) Reqs() map[int32]byte { return this.reqs }
+func (this *Skill) MaxLevel() byte       { return byte(len(this.levels)) }
+
+// Job returns the job that learns this skill
+func (this *Skill) Job() int16 { return int16(this.id / 10000) }
+
+// FourthJob returns true if this is a 4th job skill, which needs a mastery book to
+// raise its master level
+func (this *Skill) FourthJob() bool {
+	job := this.Job()
+	return job%10 == 2 && job/100 != 9 // gm skills are always mastered
+}
+
+// Effect returns the stats of the skill at the given level or nil if the level
+// is out of range
+func (this *Skill) Effect(level byte) *SkillEffect {
+	if level < 1 || int(level) > len(this.levels) {
+		return nil
+	}
+	return this.levels[level-1]
+}
+
+// A SkillProvider loads skill data from Skill.wz and caches it
+type SkillProvider struct {
+	mut    sync.Mutex
+	skills map[int32]*Skill
+}
+
+// NewSkillProvider initializes an empty skill cache
+func NewSkillProvider() *SkillProvider {
+	return &SkillProvider{
+		skills: make(map[int32]*Skill),
+	}
+}
+
+var skillProvider = NewSkillProvider()
+
+// GetSkillProvider returns the global skill provider
+func GetSkillProvider() *SkillProvider { return skillProvider }
+
+// Get returns the given skill or nil if it doesn't exist
+func (this *SkillProvider) Get(skillid int32) *Skill {
+	this.mut.Lock()
+	defer this.mut.Unlock()
+
+	res, ok := this.skills[skillid]
+	if ok {
+		return res
+	}
+
+	res = loadSkill(skillid)
+	this.skills[skillid] = res
+	return res
+}
+
+// Reload clears the cache so that skills will be loaded again from the wz files
+func (this *SkillProvider) Reload() {
+	this.mut.Lock()
+	this.skills = make(map[int32]*Skill)
+	this.mut.Unlock()
+}
+
+func loadSkillEffect(data wz.MapleData) *SkillEffect {
+	get := func(name string) int32 { return wz.GetIntConvertD(data.ChildByPath(name), 0) }
+
+	return &SkillEffect{
+		mpCon:       int16(get("mpCon")),
+		hpCon:       int16(get("hpCon")),
+		damage:      wz.GetIntConvertD(data.ChildByPath("damage"), 100),
+		mobCount:    byte(wz.GetIntConvertD(data.ChildByPath("mobCount"), 1)),
+		attackCount: byte(wz.GetIntConvertD(data.ChildByPath("attackCount"), 1)),
+		time:        get("time"),
+		watk:        int16(get("pad")),
+		matk:        int16(get("mad")),
+		wdef:        int16(get("pdd")),
+		mdef:        int16(get("mdd")),
+		acc:         int16(get("acc")),
+		avoid:       int16(get("eva")),
+		speed:       int16(get("speed")),
+		jump:        int16(get("jump")),
+		x:           int16(get("x")),
+		y:           int16(get("y")),
+		prop:        int16(wz.GetIntConvertD(data.ChildByPath("prop"), 100)),
+		cooltime:    get("cooltime"),
+	}
+}
+
+func loadSkill(skillid int32) *Skill {
+	img, err := GetSkillWz().Get(fmt.Sprintf("%03d.img", skillid/10000))
+	if err != nil || img == nil {
+		return nil
+	}
+
+	data := img.ChildByPath(fmt.Sprintf("skill/%07d", skillid))
+	if data == nil {
+		return nil
+	}
+
+	res := &Skill{
+		id:      skillid,
+		element: NEUTRAL,
+		reqs:    make(map[int32]byte),
+		levels:  make([]*SkillEffect, 0),
+	}
+
+	if elem := wz.GetStringD(data.ChildByPath("elemAttr"), ""); len(elem) > 0 {
+		res.element = ElementFromChar(elem[:1])
+	}
+
+	if reqs := data.ChildByPath("req"); reqs != nil {
+		for _, req := range reqs.Children() {
+			var reqid int32
+			if _, err := fmt.Sscanf(req.Name(), "%d", &reqid); err != nil {
+				continue
+			}
+			res.reqs[reqid] = byte(wz.GetIntConvertD(req, 0))
+		}
+	}
+
+	levels := data.ChildByPath("level")
+	if levels == nil {
+		return res
+	}
+
+	// levels are named 1, 2, 3... but they're not guaranteed to be in order
+	for i := 1; ; i++ {
+		level := levels.ChildByPath(fmt.Sprintf("%d", i))
+		if level == nil {
+			break
+		}
+		res.levels = append(res.levels, loadSkillEffect(level))
+	}
+
+	return res
+}
@@ -58,18 +58,29 @@ func (s *SpawnPoint) SpawnReady() bool {
 		return false
 	}
 
-	return s.nextPossibleSpawn <= time.Now().UnixNano()/1000000
+	return atomic.LoadInt64(&s.nextPossibleSpawn) <= time.Now().UnixNano()/1000000
 }
 
 // SpawnMonster forces the monster to spawn.
 func (s *SpawnPoint) SpawnMonster(mapleMap *MapleMap) *MapleMonster {
 	mob := CloneMapleMonster(s.monster)
 	mob.SetPos(s.pos)
+	mob.SetFh(s.monster.Fh())
+	mob.SetF(s.monster.F())
 	atomic.AddInt64(&s.spawnedMonsters, 1)
-	// TODO: set OnKilled callback
+	mob.SetOnKilled(func(m *MapleMonster) {
+		next := time.Now().UnixNano() / 1000000
+		if s.mobTime > 0 {
+			next += int64(s.mobTime) * 1000
+		} else {
+			next += int64(m.Stats().AnimationTime("die1"))
+		}
+		atomic.StoreInt64(&s.nextPossibleSpawn, next)
+		atomic.AddInt64(&s.spawnedMonsters, -1)
+	})
 	mapleMap.SpawnMonster(mob)
 	if s.mobTime == 0 {
-		s.nextPossibleSpawn = time.Now().UnixNano()/1000000 + 5000
+		atomic.StoreInt64(&s.nextPossibleSpawn, time.Now().UnixNano()/1000000+5000)
 	}
 	return mob
 }
@@ -27,6 +27,7 @@ import (
 	"github.com/Francesco149/kagami/channelserver/client"
 	"github.com/Francesco149/kagami/channelserver/gamedata"
 	"github.com/Francesco149/kagami/channelserver/players"
+	"github.com/Francesco149/kagami/channelserver/scripting"
 	"github.com/Francesco149/kagami/channelserver/status"
 	"github.com/Francesco149/kagami/common/consts"
 	"github.com/Francesco149/kagami/common/interserver"
@@ -65,6 +66,99 @@ func Handle(con *client.Connection, p maplelib.Packet) (handled bool, err error)
 
 	case packets.IChangeMap:
 		return handleChangeMap(con, it)
+
+	case packets.IMovePlayer:
+		return handleMovePlayer(con, it)
+
+	case packets.IGeneralChat:
+		return handleGeneralChat(con, it)
+
+	case packets.IMoveLife:
+		return handleMoveLife(con, it)
+
+	case packets.IAutoAggro:
+		return handleAutoAggro(con, it)
+
+	case packets.ICloseRangeAttack:
+		return handleCloseRangeAttack(con, it)
+
+	case packets.IRangedAttack:
+		return handleRangedAttack(con, it)
+
+	case packets.IMagicAttack:
+		return handleMagicAttack(con, it)
+
+	case packets.ITakeDamage:
+		return handleTakeDamage(con, it)
+
+	case packets.IDistributeAp:
+		return handleDistributeAp(con, it)
+
+	case packets.IDistributeSp:
+		return handleDistributeSp(con, it)
+
+	case packets.IGiveFame:
+		return handleGiveFame(con, it)
+
+	case packets.IItemPickup:
+		return handleItemPickup(con, it)
+
+	case packets.IItemMove:
+		return handleItemMove(con, it)
+
+	case packets.IItemSort:
+		return handleItemSort(con, it)
+
+	case packets.IUseItem:
+		return handleUseItem(con, it)
+
+	case packets.IUseReturnScroll:
+		return handleUseReturnScroll(con, it)
+
+	case packets.IUseUpgradeScroll:
+		return handleUseUpgradeScroll(con, it)
+
+	case packets.INpcTalk:
+		return handleNpcTalk(con, it)
+
+	case packets.INpcTalkMore:
+		return handleNpcTalkMore(con, it)
+
+	case packets.INpcShop:
+		return handleNpcShop(con, it)
+
+	case packets.IStorage:
+		return handleStorage(con, it)
+
+	case packets.IQuestAction:
+		return handleQuestAction(con, it)
+
+	case packets.ISpecialMove:
+		return handleSpecialMove(con, it)
+
+	case packets.ICancelBuff:
+		return handleCancelBuff(con, it)
+
+	case packets.IMultiChat:
+		return handleMultiChat(con, it)
+
+	case packets.IPartyOperation:
+		return handlePartyOperation(con, it)
+
+	case packets.IGuildOperation:
+		return handleGuildOperation(con, it)
+
+	case packets.IPlayerInteraction:
+		return handlePlayerInteraction(con, it)
+
+	case packets.IBuddyListModify:
+		return handleBuddyListModify(con, it)
+
+	case packets.IChangeKeymap:
+		return handleChangeKeymap(con, it)
+
+	case packets.ISkillMacro:
+		return handleSkillMacro(con, it)
 	}
 
 	return false, nil // forward packet to next handler
@@ -112,9 +206,8 @@ func connectData(con *client.Connection, chanid int8) (p maplelib.Packet) {
 	con.Inventory(consts.CashInventory).Encode(&p)
 	p.Encode1(0x00)
 
-	p.Encode2(0x0000) // 0 skills for now (placeholder)
-	// TODO: encode skills id's here
-	p.Encode2(0x0000)
+	con.EncodeSkills(&p)
+	p.Encode2(0x0000) // TODO: encode skill cooldowns
 
 	con.EncodeQuestInfo(&p)
 
@@ -180,10 +273,31 @@ func handleLoadCharacter(con *client.Connection, it maplelib.PacketIterator) (ha
 		return
 	}
 
-	// TODO: check forced return map
-	// TODO: check if the player is dead and repawn him
+	// players who log out in maps such as boss rooms or time limited maps come
+	// back in the map's forced return map
+	forced := con.Map().ForcedReturnMap()
+	if forced != gamedata.NoForcedReturn && forced != con.Map().Id() {
+		err = con.SetMapId(forced)
+		if err != nil {
+			return
+		}
+		con.Stats().SetPos(0)
+	}
 
-	// TODO: init position, stance and foothold
+	err = con.ReviveOnLogin()
+	if err != nil {
+		return
+	}
+
+	spawnportal := con.Map().PortalById(int32(con.Stats().Pos()))
+	if spawnportal == nil {
+		spawnportal = con.Map().PortalById(0)
+	}
+	if spawnportal != nil {
+		con.SetPos(spawnportal.Pos())
+	}
+	con.SetStance(0)
+	con.SetFh(0)
 
 	stts := <-status.Get
 	defer func() { status.Get <- stts }()
@@ -198,16 +312,31 @@ func handleLoadCharacter(con *client.Connection, it maplelib.PacketIterator) (ha
 	}
 
 	// TODO: init pets
-	// TODO: send keymaps
-	// TODO: send update buddylist
-	// TODO: check for pending buddylist requests
-	// TODO: send skill macros
+	err = con.SendKeymap()
+	if err != nil {
+		return
+	}
+
+	err = con.SendBuddyList()
+	if err != nil {
+		return
+	}
+
+	err = con.SendSkillMacros()
+	if err != nil {
+		return
+	}
 
 	players.Lock()
 	players.Add(con)
 	players.Unlock()
 
-	// TODO: add player to map's player list
+	con.Map().AddPlayer(con)
+
+	err = con.StartMapTimers()
+	if err != nil {
+		return
+	}
 
 	fmt.Println(con.Conn().RemoteAddr().String(), "connected as", con.Stats().Name())
 
@@ -219,7 +348,15 @@ func handleLoadCharacter(con *client.Connection, it maplelib.PacketIterator) (ha
 	con.SetConnected(true)
 	fmt.Println(con.String())
 
-	stts.WorldConn().SendPacket(interserver.SyncPlayerJoinedChannel(stts.ChanId()))
+	stats := con.Stats()
+	stts.WorldConn().SendPacket(interserver.SyncPlayerJoinedChannel(stts.ChanId(), con.CharId(),
+		stats.Name(), stats.Job(), stats.Level(), con.Map().Id()))
+	stts.WorldConn().SendPacket(con.BuddyNotifyPacket(stts.ChanId()))
+
+	err = con.SendPendingBuddyRequest()
+	if err != nil {
+		return
+	}
 
 	// TODO: add to player pool
 
@@ -252,13 +389,36 @@ func handleChangeMapSpecial(con *client.Connection, it maplelib.PacketIterator)
 		err = con.SendPacket(packets.EnableActions())
 	} else {
 		fmt.Println("Sending portal enter packet")
-		err = con.Enter(portal.(gamedata.IMapleGenericPortal))
+		err = enterPortal(con, portal)
 	}
 
 	handled = err == nil
 	return
 }
 
+// enterPortal runs the portal's script if it has one, otherwise it warps the player
+// through the portal
+func enterPortal(con *client.Connection, portal gamedata.MaplePortal) (err error) {
+	if len(portal.ScriptName()) == 0 {
+		return con.Enter(portal.(gamedata.IMapleGenericPortal))
+	}
+
+	if !con.CanEnter(portal) {
+		return con.SendPacket(packets.EnableActions())
+	}
+
+	ok, err := scripting.EnterPortal(con, portal)
+	if err != nil {
+		fmt.Println("Portal script", portal.ScriptName(), "error:", err)
+	}
+
+	if !ok {
+		err = con.SendPacket(packets.EnableActions())
+	}
+
+	return
+}
+
 // handleChangeMap handles a map change or revival packet
 func handleChangeMap(con *client.Connection, it maplelib.PacketIterator) (handled bool, err error) {
 	_, err = it.Decode1()
@@ -275,7 +435,7 @@ func handleChangeMap(con *client.Connection, it maplelib.PacketIterator) (handle
 
 	switch {
 	case target != -1 && !con.Alive():
-		fmt.Println("TODO: revive player")
+		err = con.Revive()
 
 	case target != -1 && con.GmLevel() > 2:
 		// TODO: check chalkboard
@@ -294,7 +454,7 @@ func handleChangeMap(con *client.Connection, it maplelib.PacketIterator) (handle
 
 	default:
 		if portal != nil {
-			err = con.Enter(portal.(gamedata.IMapleGenericPortal))
+			err = enterPortal(con, portal)
 		} else {
 			err = con.SendPacket(packets.EnableActions())
 		}
@@ -0,0 +1,356 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import "fmt"
+
+import (
+	"github.com/Francesco149/kagami/channelserver/client"
+	"github.com/Francesco149/kagami/channelserver/gamedata"
+	"github.com/Francesco149/kagami/common/consts"
+	"github.com/Francesco149/kagami/common/packets"
+	"github.com/Francesco149/maplelib"
+)
+
+// maxDamageLine is the highest damage a single line can display in v62
+const maxDamageLine = 199999
+
+const (
+	maxSpellAttack     = 700 // highest spell attack of magic skills, used for heal
+	criticalMultiplier = 2.0 // critical shot / critical throw
+)
+
+// flagAttack logs an impossible attack
+func flagAttack(con *client.Connection, attack *gamedata.AttackInfo, reason ...interface{}) {
+	fmt.Println("[Attack]", con.Stats().Name(), "skill", attack.Skill(), "-",
+		fmt.Sprint(reason...))
+}
+
+func handleCloseRangeAttack(con *client.Connection, it maplelib.PacketIterator) (bool, error) {
+	return handleAttack(con, it, gamedata.ATTACK_CLOSE_RANGE)
+}
+
+func handleRangedAttack(con *client.Connection, it maplelib.PacketIterator) (bool, error) {
+	return handleAttack(con, it, gamedata.ATTACK_RANGED)
+}
+
+func handleMagicAttack(con *client.Connection, it maplelib.PacketIterator) (bool, error) {
+	return handleAttack(con, it, gamedata.ATTACK_MAGIC)
+}
+
+// handleAttack parses an attack, validates it, relays it to the map and applies the damage
+func handleAttack(con *client.Connection, it maplelib.PacketIterator,
+	typ gamedata.AttackType) (handled bool, err error) {
+
+	attack, err := gamedata.ParseAttack(&it, typ)
+	if err != nil {
+		return
+	}
+
+	handled = true
+
+	if !con.Alive() {
+		flagAttack(con, attack, "attacked while dead")
+		return
+	}
+
+	if attack.Skill() == gamedata.SKILL_MESO_EXPLOSION {
+		// TODO: meso explosion has its own packet structure
+		return
+	}
+
+	if attack.Skill() == 0 && (attack.NumAttacked() > 1 || attack.NumDamage() > 1) {
+		flagAttack(con, attack, "basic attack hit ", attack.NumAttacked(), " monsters ",
+			attack.NumDamage(), " times")
+		return
+	}
+
+	var skill *gamedata.Skill
+	var effect *gamedata.SkillEffect
+
+	if attack.Skill() != 0 {
+		var ok bool
+		skill, effect, ok, err = useAttackSkill(con, attack)
+		if err != nil || !ok {
+			return
+		}
+	}
+
+	projectile := int32(0)
+	if typ == gamedata.ATTACK_RANGED {
+		var ok bool
+		projectile, ok, err = consumeAmmo(con, attack)
+		if err != nil || !ok {
+			return
+		}
+	}
+
+	targets := attack.Targets()
+	clampAttack(con, attack, skill, effect, targets)
+
+	var p maplelib.Packet
+
+	switch typ {
+	case gamedata.ATTACK_CLOSE_RANGE:
+		p = packets.CloseRangeAttack(con.CharId(), attack.Skill(), attack.Stance(),
+			attack.NumAttackedAndDamage(), attack.Speed(), targets)
+	case gamedata.ATTACK_RANGED:
+		p = packets.RangedAttack(con.CharId(), attack.Skill(), attack.Stance(),
+			attack.NumAttackedAndDamage(), attack.Speed(), projectile, targets)
+	case gamedata.ATTACK_MAGIC:
+		p = packets.MagicAttack(con.CharId(), attack.Skill(), attack.Stance(),
+			attack.NumAttackedAndDamage(), attack.Speed(), targets, attack.Charge())
+	}
+
+	con.Map().Broadcast(p, con.CharId())
+
+	for _, target := range targets {
+		mob := con.Map().Monster(target.Oid)
+		if mob == nil {
+			continue
+		}
+
+		total := int32(0)
+		for _, dmg := range target.Damage {
+			total += dmg
+		}
+
+		if con.Map().DamageMonster(con, mob, total) {
+			onMonsterKilled(con, con.Map(), mob)
+		}
+	}
+
+	return
+}
+
+// clampAttack caps the damage lines of each target in place so that both the damage
+// dealt and the damage shown to the other players are possible
+func clampAttack(con *client.Connection, attack *gamedata.AttackInfo,
+	skill *gamedata.Skill, effect *gamedata.SkillEffect, targets []packets.AttackTarget) {
+
+	for _, target := range targets {
+		mob := con.Map().Monster(target.Oid)
+		maxDamage := int32(0)
+		if mob != nil {
+			maxDamage = attackDamageCap(con, attack, skill, effect, mob)
+		}
+
+		for i, dmg := range target.Damage {
+			switch {
+			case dmg < 0:
+				flagAttack(con, attack, "negative damage ", dmg, " on ", target.Oid)
+				target.Damage[i] = 0
+
+			case dmg > maxDamage && mob != nil:
+				flagAttack(con, attack, "impossible damage ", dmg, " on ", mob.Id(),
+					" (max ", maxDamage, ")")
+				target.Damage[i] = maxDamage
+
+			case mob == nil:
+				target.Damage[i] = 0
+			}
+		}
+	}
+}
+
+// useAttackSkill checks that the player has the skill used by an attack and that the
+// attack doesn't hit more monsters or more times than the skill allows, then takes
+// the skill's hp and mp cost. ok is false if the attack is not possible.
+func useAttackSkill(con *client.Connection, attack *gamedata.AttackInfo) (
+	skill *gamedata.Skill, effect *gamedata.SkillEffect, ok bool, err error) {
+
+	skill = gamedata.GetSkillProvider().Get(attack.Skill())
+	effect = con.SkillEffect(attack.Skill())
+	if skill == nil || effect == nil {
+		flagAttack(con, attack, "used a skill they don't have")
+		return
+	}
+
+	maxDamageLines := effect.AttackCount()
+	if con.Buff(client.SKILL_SHADOW_PARTNER) != nil {
+		maxDamageLines *= 2
+	}
+
+	if attack.NumAttacked() > effect.MobCount() || attack.NumDamage() > maxDamageLines {
+		flagAttack(con, attack, "skill hit ", attack.NumAttacked(), " monsters ",
+			attack.NumDamage(), " times (max ", effect.MobCount(), " monsters ",
+			maxDamageLines, " times)")
+		return
+	}
+
+	ok, err = con.PaySkillCost(attack.Skill(), effect)
+	if err == nil && !ok {
+		flagAttack(con, attack, "used a skill without enough hp/mp or during its cooldown")
+	}
+
+	return
+}
+
+// attackDamageCap returns the highest damage that a single line of the given attack
+// could deal to the given monster. skill and effect are nil for basic attacks.
+func attackDamageCap(con *client.Connection, attack *gamedata.AttackInfo,
+	skill *gamedata.Skill, effect *gamedata.SkillEffect, mob *gamedata.MapleMonster) int32 {
+
+	var max float64
+	stats := mob.Stats()
+
+	switch {
+	case attack.Skill() == gamedata.SKILL_HEAL:
+		// heal only damages undead monsters
+		if !stats.Undead() {
+			return 0
+		}
+		max = float64(con.MaxMagicDamage(maxSpellAttack))
+
+	case attack.Type() == gamedata.ATTACK_MAGIC:
+		spellAttack := int32(maxSpellAttack)
+		if effect != nil && effect.MAtk() > 0 {
+			spellAttack = int32(effect.MAtk())
+		}
+		max = float64(con.MaxMagicDamage(spellAttack))
+
+	default:
+		max = float64(con.MaxBaseDamage())
+		if effect != nil {
+			max *= float64(effect.Damage()) / 100.0
+		}
+
+		if attack.Type() == gamedata.ATTACK_RANGED && con.CanCritical() {
+			max *= criticalMultiplier
+		}
+	}
+
+	if skill == nil {
+		max *= stats.Effectiveness(gamedata.NEUTRAL).DamageMultiplier()
+	} else if skill.Element() == gamedata.NEUTRAL {
+		// TODO: elemental charges
+		max *= maxElementalMultiplier(stats)
+	} else {
+		max *= stats.Effectiveness(skill.Element()).DamageMultiplier()
+	}
+
+	if max > maxDamageLine {
+		return maxDamageLine
+	}
+
+	return int32(max)
+}
+
+// maxElementalMultiplier returns the damage multiplier of the element
+// the given monster is weakest to
+func maxElementalMultiplier(stats *gamedata.MapleMonsterStats) (res float64) {
+	for e := gamedata.NEUTRAL; e < gamedata.INVALID_ELEMENT; e++ {
+		mul := stats.Effectiveness(e).DamageMultiplier()
+		if mul > res {
+			res = mul
+		}
+	}
+	return
+}
+
+// consumeAmmo removes the ammo used by a ranged attack from the inventory and returns
+// the item id of the projectile. ok is false if the attack is not possible.
+func consumeAmmo(con *client.Connection, attack *gamedata.AttackInfo) (
+	projectile int32, ok bool, err error) {
+
+	weapon := con.WeaponType()
+	if !weapon.Ranged() {
+		flagAttack(con, attack, "ranged attack without a ranged weapon")
+		return
+	}
+
+	// cash shop stars are never consumed
+	if attack.CashStarSlot() > 0 {
+		cashStar := con.Inventory(consts.CashInventory).Get(int8(attack.CashStarSlot()))
+		if cashStar == nil {
+			flagAttack(con, attack, "used a non-existing cash star")
+			return
+		}
+
+		return cashStar.Id(), true, nil
+	}
+
+	inv := con.Inventory(consts.UseInventory)
+	ammo := inv.Get(int8(attack.StarSlot()))
+	if ammo == nil || !weapon.IsAmmoFor(ammo) {
+		flagAttack(con, attack, "used invalid ammo in slot ", attack.StarSlot())
+		return
+	}
+
+	// each damage line shoots one projectile
+	count := int16(attack.NumDamage())
+	if count < 1 {
+		count = 1
+	}
+
+	if ammo.Amount() < count {
+		flagAttack(con, attack, "not enough ammo (", ammo.Amount(), "/", count, ")")
+		return
+	}
+
+	projectile = ammo.Id()
+	inv.Remove(ammo.Pos(), count)
+
+	if inv.Get(int8(attack.StarSlot())) == nil {
+		err = con.SendPacket(packets.ClearInventorySlot(consts.UseInventory,
+			attack.StarSlot(), false))
+	} else {
+		err = con.SendPacket(packets.UpdateInventorySlot(consts.UseInventory,
+			attack.StarSlot(), ammo.Amount(), false))
+	}
+
+	ok = err == nil
+	return
+}
+
+// handleTakeDamage handles the damage taken by the player from monsters and traps
+func handleTakeDamage(con *client.Connection, it maplelib.PacketIterator) (handled bool, err error) {
+	_, err = it.Decode4() // tick count
+	from, err := it.Decode1s()
+	_, err = it.Decode1() // element
+	damage, err := it.Decode4s()
+	if err != nil {
+		return
+	}
+
+	var monsterid int32
+	var direction byte
+
+	if from != packets.DamageFromMap {
+		monsterid, err = it.Decode4s()
+		oid, err := it.Decode4s()
+		direction, err = it.Decode1()
+		if err != nil {
+			return false, err
+		}
+
+		mob := con.Map().Monster(oid)
+		if mob == nil || mob.Id() != monsterid {
+			// the monster might have just died, the damage is still applied
+			fmt.Println(con.Stats().Name(), "took damage from missing monster", monsterid)
+		}
+	}
+
+	handled = true
+
+	if damage < 0 {
+		fmt.Println(con.Stats().Name(), "sent negative damage", damage)
+		return
+	}
+
+	err = con.TakeDamage(from, damage, monsterid, direction)
+	return
+}
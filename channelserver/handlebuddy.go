@@ -0,0 +1,71 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"fmt"
+)
+
+import (
+	"github.com/Francesco149/kagami/channelserver/client"
+	"github.com/Francesco149/maplelib"
+)
+
+// Buddy list operations sent by the client
+const (
+	buddyOpAdd    = 1
+	buddyOpAccept = 2
+	buddyOpDelete = 3 // also used to deny requests
+)
+
+// handleBuddyListModify handles buddy list requests
+func handleBuddyListModify(con *client.Connection, it maplelib.PacketIterator) (handled bool, err error) {
+	op, err := it.Decode1()
+	if err != nil {
+		return
+	}
+
+	switch op {
+	case buddyOpAdd:
+		var name string
+		name, err = it.DecodeString()
+		if err != nil {
+			return
+		}
+		handled = true
+		err = con.AddBuddy(name)
+
+	case buddyOpAccept, buddyOpDelete:
+		var charid int32
+		charid, err = it.Decode4s()
+		if err != nil {
+			return
+		}
+		handled = true
+
+		if op == buddyOpAccept {
+			err = con.AcceptBuddy(charid)
+		} else {
+			err = con.RemoveBuddy(charid)
+		}
+
+	default:
+		fmt.Println(con.Stats().Name(), "sent unknown buddy list operation", op)
+		handled = true
+	}
+
+	return
+}
@@ -0,0 +1,46 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"strings"
+)
+
+import (
+	"github.com/Francesco149/kagami/channelserver/client"
+	"github.com/Francesco149/kagami/common/packets"
+	"github.com/Francesco149/maplelib"
+)
+
+// handleGeneralChat handles a chat message sent to the map. Messages that start
+// with the command prefix are treated as gm commands.
+func handleGeneralChat(con *client.Connection, it maplelib.PacketIterator) (handled bool, err error) {
+	text, err := it.DecodeString()
+	show, err := it.Decode1()
+	if err != nil {
+		return
+	}
+
+	handled = true
+
+	if strings.HasPrefix(text, commandPrefix) && con.GmLevel() > 0 {
+		err = runCommand(con, text[len(commandPrefix):])
+		return
+	}
+
+	con.Map().Broadcast(packets.ChatText(con.CharId(), text, con.GmChat(), show), -1)
+	return
+}
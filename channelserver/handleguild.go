@@ -0,0 +1,177 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"fmt"
+)
+
+import (
+	"github.com/Francesco149/kagami/channelserver/client"
+	"github.com/Francesco149/kagami/common/interserver"
+	"github.com/Francesco149/kagami/common/packets"
+	"github.com/Francesco149/maplelib"
+)
+
+// guild name limits
+const (
+	minGuildName = 4
+	maxGuildName = 12
+)
+
+// validGuildName checks that a guild name is made of 4-12 letters and digits
+func validGuildName(name string) bool {
+	if len(name) < minGuildName || len(name) > maxGuildName {
+		return false
+	}
+
+	for _, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// guildPopup shows a popup message about a refused guild request
+func guildPopup(con *client.Connection, text string) error {
+	return con.SendPacket(packets.ServerMessage(packets.ServerMessagePopup,
+		0, text, false, false))
+}
+
+// handleGuildOperation handles a guild request and forwards it to the worldserver,
+// which owns the guilds. Mesos are taken before the request and given back if the
+// worldserver refuses it.
+func handleGuildOperation(con *client.Connection, it maplelib.PacketIterator) (handled bool, err error) {
+	req, err := it.Decode1()
+	if err != nil {
+		return
+	}
+
+	var op byte
+	var cost int32
+	var target int32
+	var args []int32
+	var strs []string
+
+	switch req {
+	case packets.GuildReqCreate:
+		var name string
+		name, err = it.DecodeString()
+		if err != nil {
+			return
+		}
+
+		switch {
+		case con.Guild() != nil:
+			return true, con.SendPacket(packets.GuildMessage(packets.GuildMsgAlreadyInGuild))
+		case !validGuildName(name):
+			return true, guildPopup(con, "The guild name is invalid.")
+		case con.Meso() < client.GuildCreateCost:
+			return true, guildPopup(con, "You don't have enough mesos to create a guild.")
+		}
+
+		op = interserver.GuildOpCreate
+		cost = client.GuildCreateCost
+		strs = []string{name}
+
+	case packets.GuildReqInvite:
+		var name string
+		name, err = it.DecodeString()
+		op = interserver.GuildOpInvite
+		strs = []string{name}
+
+	case packets.GuildReqJoin:
+		target, err = it.Decode4s()
+		op = interserver.GuildOpJoin
+
+	case packets.GuildReqLeave:
+		op = interserver.GuildOpLeave
+
+	case packets.GuildReqExpel:
+		target, err = it.Decode4s()
+		op = interserver.GuildOpExpel
+
+	case packets.GuildReqTitles:
+		strs = make([]string, interserver.GuildRanks)
+		for i := range strs {
+			strs[i], err = it.DecodeString()
+		}
+		op = interserver.GuildOpTitles
+
+	case packets.GuildReqRank:
+		var rank byte
+		target, err = it.Decode4s()
+		rank, err = it.Decode1()
+		op = interserver.GuildOpRank
+		args = []int32{int32(rank)}
+
+	case packets.GuildReqEmblem:
+		bg, err := it.Decode2s()
+		bgcol, err := it.Decode1()
+		logo, err := it.Decode2s()
+		logocol, err := it.Decode1()
+		if err != nil {
+			return false, err
+		}
+
+		switch {
+		case !con.IsGuildLeader():
+			fmt.Println(con.Stats().Name(), "tried to change a guild emblem without being the leader")
+			return true, nil
+		case con.Meso() < client.GuildEmblemCost:
+			return true, guildPopup(con, "You don't have enough mesos to change the emblem.")
+		}
+
+		op = interserver.GuildOpEmblem
+		cost = client.GuildEmblemCost
+		args = []int32{int32(bg), int32(bgcol), int32(logo), int32(logocol)}
+
+	case packets.GuildReqNotice:
+		var notice string
+		notice, err = it.DecodeString()
+		op = interserver.GuildOpNotice
+		strs = []string{notice}
+
+	default:
+		fmt.Println(con.Stats().Name(), "sent unknown guild operation", req)
+		return true, nil
+	}
+
+	if err != nil {
+		return
+	}
+
+	handled = true
+
+	if cost > 0 {
+		var paid bool
+		paid, err = con.PayGuildCost(op, cost)
+		if err != nil {
+			return
+		}
+
+		if !paid {
+			return true, guildPopup(con, "You can't pay for this guild request right now.")
+		}
+	}
+
+	err = client.SendToWorld(interserver.GuildOperation(op, con.CharId(), target, args, strs))
+	return
+}
@@ -24,6 +24,7 @@ import (
 import (
 	"github.com/Francesco149/kagami/channelserver/client"
 	"github.com/Francesco149/kagami/channelserver/players"
+	"github.com/Francesco149/kagami/channelserver/scripting"
 	"github.com/Francesco149/kagami/channelserver/status"
 	"github.com/Francesco149/kagami/common"
 	"github.com/Francesco149/kagami/common/config"
@@ -51,6 +52,24 @@ func HandleInter(con *common.InterserverClient, p maplelib.Packet) (handled bool
 
 	case interserver.IOPlayerJoiningChannel:
 		return handlePlayerJoiningChannel(con, it)
+
+	case interserver.IOMessageToPlayers:
+		return handleMessageToPlayers(con, it)
+
+	case interserver.IOSyncParty:
+		return handleSyncParty(con, it)
+
+	case interserver.IOBuddyNotify:
+		return handleBuddyNotify(con, it)
+
+	case interserver.IOSyncWorldConf:
+		return handleSyncWorldConf(con, it)
+
+	case interserver.IOSyncGuild:
+		return handleSyncGuild(con, it)
+
+	case interserver.IOGuildRefused:
+		return handleGuildRefused(con, it)
 	}
 
 	return false, nil
@@ -148,6 +167,8 @@ func handleChannelConnect(con *common.InterserverClient, it maplelib.PacketItera
 			if !ok {
 				return false, errors.New("Client handler failed type assertion")
 			}
+			scon.LockActions()
+			defer scon.UnlockActions()
 			return Handle(scon, p)
 		},
 		func(con net.Conn) common.Connection {
@@ -159,9 +180,20 @@ func handleChannelConnect(con *common.InterserverClient, it maplelib.PacketItera
 				panic(errors.New(utils.MakeError("Client handler failed " +
 					"type assertion on disconnect")))
 			}
+			scon.LockActions()
+			defer scon.UnlockActions()
+			// give back the items in the trade window before saving. this takes the
+			// partner's action lock, so it must happen before status is taken.
+			scon.CancelTrade()
+			scon.SetDisconnecting(true)
+
 			st := <-status.Get
 			defer func() { status.Get <- st }()
-			st.WorldConn().SendPacket(interserver.SyncPlayerLeftChannel(st.ChanId()))
+			st.WorldConn().SendPacket(interserver.SyncPlayerLeftChannel(st.ChanId(), scon.CharId()))
+			if scon.Connected() {
+				st.WorldConn().SendPacket(scon.BuddyNotifyPacket(-1))
+			}
+
 			err = scon.SetDBOnline(false)
 			if err != nil {
 				fmt.Println(utils.MakeError("Failed to disconnect ",
@@ -174,6 +206,14 @@ func handleChannelConnect(con *common.InterserverClient, it maplelib.PacketItera
 					scon.Stats().Name(), ": ", err))
 			}
 
+			if scon.Connected() && scon.Map() != nil {
+				scon.Map().RemovePlayer(scon)
+			}
+
+			scon.StopBuffs()
+			scon.StopMapTimers()
+			scripting.EndNpc(scon.CharId())
+
 			players.Lock()
 			players.Remove(scon)
 			players.Unlock()
@@ -198,3 +238,255 @@ func handlePlayerJoiningChannel(con *common.InterserverClient, it maplelib.Packe
 	handled = err == nil
 	return
 }
+
+// handleMessageToPlayers delivers a packet relayed by the worldserver to the given
+// players if they're connected to this channel
+func handleMessageToPlayers(con *common.InterserverClient, it maplelib.PacketIterator) (handled bool, err error) {
+	count, err := it.Decode2()
+	if err != nil {
+		return
+	}
+
+	charids := make([]int32, count)
+	for i := range charids {
+		charids[i], err = it.Decode4s()
+	}
+	if err != nil {
+		return
+	}
+
+	packet := []byte(it)
+
+	players.Lock()
+	defer players.Unlock()
+
+	for _, charid := range charids {
+		player := players.Get(charid)
+		if player == nil {
+			continue
+		}
+
+		// packets are encrypted in place so every player needs its own copy
+		clone := make([]byte, len(packet))
+		copy(clone, packet)
+		player.SendPacket(maplelib.Packet(clone))
+	}
+
+	handled = true
+	return
+}
+
+// withPlayers runs fn for each of the given characters that are connected to this
+// channel while holding their action lock. The players are looked up first so that
+// the action locks are never taken while holding players.Lock.
+func withPlayers(charids []int32, fn func(player *client.Connection)) {
+	players.Lock()
+	conns := make([]*client.Connection, 0, len(charids))
+	for _, charid := range charids {
+		if player := players.Get(charid); player != nil {
+			conns = append(conns, player)
+		}
+	}
+	players.Unlock()
+
+	for _, player := range conns {
+		player.LockActions()
+		if !player.Disconnecting() {
+			fn(player)
+		}
+		player.UnlockActions()
+	}
+}
+
+// handleSyncParty updates the party of the members connected to this channel and
+// notifies them of the change
+func handleSyncParty(con *common.InterserverClient, it maplelib.PacketIterator) (handled bool, err error) {
+	op, err := it.Decode1()
+	target, err := it.Decode4s()
+	targetName, err := it.DecodeString()
+	party, err := interserver.DecodeParty(&it)
+	if err != nil {
+		return
+	}
+
+	handled = true
+
+	st := <-status.Get
+	chanid := st.ChanId()
+	status.Get <- st
+
+	charids := party.MemberIds()
+	if party.Member(target) == nil {
+		charids = append(charids, target)
+	}
+
+	withPlayers(charids, func(player *client.Connection) {
+		charid := player.CharId()
+		removed := op == interserver.PartySyncDisband ||
+			(charid == target && (op == interserver.PartySyncLeave || op == interserver.PartySyncExpel))
+
+		if removed {
+			player.SetParty(nil)
+		} else {
+			player.SetParty(party)
+		}
+
+		// only the creator needs to know about a new party
+		if op != interserver.PartySyncCreate || charid == target {
+			player.SendPacket(client.PartyPacket(op, party, target, targetName, chanid))
+		}
+
+		if !removed {
+			player.UpdatePartyHp(true)
+		}
+	})
+
+	return
+}
+
+// handleSyncGuild applies a guild change pushed by the worldserver to the members
+// connected to this channel
+func handleSyncGuild(con *common.InterserverClient, it maplelib.PacketIterator) (handled bool, err error) {
+	op, err := it.Decode1()
+	target, err := it.Decode4s()
+	targetName, err := it.DecodeString()
+	guild, err := interserver.DecodeGuild(&it)
+	if err != nil {
+		return
+	}
+
+	handled = true
+
+	charids := guild.MemberIds()
+	if guild.Member(target) == nil {
+		charids = append(charids, target)
+	}
+
+	withPlayers(charids, func(player *client.Connection) {
+		charid := player.CharId()
+		removed := op == interserver.GuildSyncDisband ||
+			(charid == target && (op == interserver.GuildSyncLeave || op == interserver.GuildSyncExpel))
+
+		if removed {
+			player.SetGuild(nil)
+		} else {
+			player.SetGuild(guild)
+		}
+
+		if charid != target {
+			// only the creator needs to know about a new guild
+			if op != interserver.GuildSyncCreate {
+				player.SendPacket(client.GuildPacket(op, guild, target, targetName))
+			}
+			return
+		}
+
+		switch op {
+		case interserver.GuildSyncCreate, interserver.GuildSyncJoin, interserver.GuildSyncOnline:
+			// the target gets the whole guild window
+			player.SendPacket(client.GuildInfo(guild))
+		default:
+			player.SendPacket(client.GuildPacket(op, guild, target, targetName))
+		}
+
+		player.ConfirmGuildCost(op)
+	})
+
+	return
+}
+
+// handleGuildRefused gives back the mesos paid by a player for a guild operation
+// that the worldserver refused
+func handleGuildRefused(con *common.InterserverClient, it maplelib.PacketIterator) (handled bool, err error) {
+	charid, err := it.Decode4s()
+	op, err := it.Decode1()
+	if err != nil {
+		return
+	}
+
+	handled = true
+
+	players.Lock()
+	player := players.Get(charid)
+	players.Unlock()
+
+	if player == nil {
+		fmt.Println("Can't refund guild operation", op, "to offline character", charid)
+		return
+	}
+
+	// the refund changes the player's mesos, so it must not run alongside the
+	// player's own packets
+	player.LockActions()
+	defer player.UnlockActions()
+
+	err = player.RefundGuildCost(op)
+	if err != nil {
+		fmt.Println("Failed to refund guild operation", op, "to", charid, ":", err)
+		err = nil
+	}
+
+	return
+}
+
+// handleBuddyNotify updates the channel of a buddy in the buddy lists of the players
+// connected to this channel
+func handleBuddyNotify(con *common.InterserverClient, it maplelib.PacketIterator) (handled bool, err error) {
+	charid, err := it.Decode4s()
+	_, err = it.DecodeString() // name
+	channel, err := it.Decode1s()
+	reply, err := it.Decode1()
+	count, err := it.Decode2()
+	if err != nil {
+		return
+	}
+
+	buddies := make([]int32, count)
+	for i := range buddies {
+		buddies[i], err = it.Decode4s()
+	}
+	if err != nil {
+		return
+	}
+
+	handled = true
+
+	st := <-status.Get
+	chanid := st.ChanId()
+	status.Get <- st
+
+	players.Lock()
+	defer players.Unlock()
+
+	for _, buddyid := range buddies {
+		player := players.Get(buddyid)
+		if player == nil {
+			continue
+		}
+
+		err = player.OnBuddyNotify(charid, channel, reply != 0, chanid)
+		if err != nil {
+			fmt.Println("Failed to update buddy list of", player.Stats().Name(), ":", err)
+			err = nil
+		}
+	}
+
+	return
+}
+
+// handleSyncWorldConf replaces the world config after a rehash. Limits such as the
+// boss attempts are read from it every time, so they apply right away.
+func handleSyncWorldConf(con *common.InterserverClient, it maplelib.PacketIterator) (handled bool, err error) {
+	conf, err := config.DecodeWorldConf(&it)
+	if err != nil {
+		return
+	}
+
+	fmt.Println("World config reloaded")
+	st := <-status.Get
+	st.SetWorldConf(conf)
+	status.Get <- st
+
+	handled = true
+	return
+}
@@ -0,0 +1,245 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+import (
+	"github.com/Francesco149/kagami/channelserver/client"
+	"github.com/Francesco149/kagami/channelserver/gamedata"
+	"github.com/Francesco149/kagami/common/packets"
+	"github.com/Francesco149/maplelib"
+)
+
+// maxPickupDistance is the maximum distance in pixels between the player and a drop he picks up
+const maxPickupDistance = 300
+
+// handleItemPickup handles a request to pick up an item or mesos from the ground
+func handleItemPickup(con *client.Connection, it maplelib.PacketIterator) (handled bool, err error) {
+	_, err = it.Decode4()  // update time
+	_, err = it.Decode1()  // what the hell is this
+	_, err = it.Decode2s() // x
+	_, err = it.Decode2s() // y
+	oid, err := it.Decode4s()
+	if err != nil {
+		return
+	}
+
+	handled = true
+
+	if !con.Alive() {
+		err = con.SendPacket(packets.EnableActions())
+		return
+	}
+
+	m := con.Map()
+	drop := m.Drop(oid)
+	if drop == nil {
+		err = con.SendPacket(packets.EnableActions())
+		return
+	}
+
+	dx := float64(drop.Pos().X - con.Pos().X)
+	dy := float64(drop.Pos().Y - con.Pos().Y)
+	if math.Sqrt(dx*dx+dy*dy) > maxPickupDistance {
+		fmt.Println(con.Stats().Name(), "tried to pick up a drop from", int(math.Sqrt(dx*dx+dy*dy)),
+			"pixels away")
+		err = con.SendPacket(packets.EnableActions())
+		return
+	}
+
+	if !drop.CanLoot(con.Stats().Id(), con.PartyId()) {
+		err = con.SendPacket(packets.EnableActions())
+		return
+	}
+
+	item := drop.Item()
+
+	// check for room before claiming the drop so that it stays on the ground
+	if item != nil && !con.CanHold(item.Id(), item.Amount()) {
+		err = con.SendPacket(packets.InventoryFull())
+		if err != nil {
+			return
+		}
+		err = con.SendPacket(packets.ShowItemUnavailable())
+		return
+	}
+
+	if !m.PickupDrop(drop, con) {
+		// someone else got it first
+		err = con.SendPacket(packets.EnableActions())
+		return
+	}
+
+	if item == nil {
+		var ok bool
+		ok, err = con.GainMeso(drop.Meso(), true)
+		if err == nil && !ok {
+			fmt.Println(con.Stats().Name(), "is at the meso cap and lost", drop.Meso(), "mesos")
+			err = con.SendPacket(packets.EnableActions())
+		}
+		return
+	}
+
+	ok, err := con.GainItem(item, true)
+	if err != nil {
+		return
+	}
+	if !ok {
+		// shouldn't happen since we checked CanHold
+		fmt.Println(con.Stats().Name(), "lost item", item.Id(), "on pickup")
+		err = con.SendPacket(packets.EnableActions())
+		return
+	}
+
+	err = con.SendPacket(packets.ShowItemGain(item.Id(), int32(item.Amount())))
+	return
+}
+
+// handleItemMove handles a request to move, equip, unequip or drop an item
+func handleItemMove(con *client.Connection, it maplelib.PacketIterator) (handled bool, err error) {
+	_, err = it.Decode4() // update time
+	invtype, err := it.Decode1s()
+	src, err := it.Decode2s()
+	dst, err := it.Decode2s()
+	quantity, err := it.Decode2s()
+	if err != nil {
+		return
+	}
+
+	handled = true
+
+	var ok bool
+	lookChanged := false
+
+	switch {
+	case src < -128 || src > 127 || dst < -128 || dst > 127:
+		// slots are stored as bytes
+
+	case dst == 0:
+		ok, err = con.DropItem(invtype, int8(src), quantity)
+		lookChanged = ok && src < 0
+
+	case src < 0 && dst > 0:
+		ok, err = con.UnequipItem(int8(src), int8(dst))
+		lookChanged = ok
+
+	case dst < 0:
+		ok, err = con.EquipItem(int8(src), int8(dst))
+		lookChanged = ok
+
+	default:
+		ok, err = con.MoveItem(invtype, int8(src), int8(dst))
+	}
+
+	if err != nil {
+		return
+	}
+
+	if !ok {
+		fmt.Println(con.Stats().Name(), "sent an invalid item move:", invtype, src, dst, quantity)
+		err = con.SendPacket(packets.EnableActions())
+		return
+	}
+
+	if lookChanged {
+		con.Map().Broadcast(con.UpdateLookPacket(), con.Stats().Id())
+	}
+
+	return
+}
+
+// handleItemSort handles a request to gather the items of an inventory tab
+func handleItemSort(con *client.Connection, it maplelib.PacketIterator) (handled bool, err error) {
+	_, err = it.Decode4() // update time
+	invtype, err := it.Decode1s()
+	if err != nil {
+		return
+	}
+
+	err = con.GatherItems(invtype)
+	handled = err == nil
+	return
+}
+
+// handleUseItem handles a request to use a consumable item such as a potion
+func handleUseItem(con *client.Connection, it maplelib.PacketIterator) (handled bool, err error) {
+	_, err = it.Decode4() // update time
+	slot, err := it.Decode2s()
+	itemid, err := it.Decode4s()
+	if err != nil {
+		return
+	}
+
+	handled = true
+
+	ok, err := con.UseItem(int8(slot), itemid)
+	if err == nil && !ok {
+		err = con.SendPacket(packets.EnableActions())
+	}
+
+	return
+}
+
+// handleUseReturnScroll handles a request to use a scroll that teleports the player
+func handleUseReturnScroll(con *client.Connection, it maplelib.PacketIterator) (handled bool, err error) {
+	_, err = it.Decode4() // update time
+	slot, err := it.Decode2s()
+	itemid, err := it.Decode4s()
+	if err != nil {
+		return
+	}
+
+	handled = true
+
+	effect := gamedata.GetItemInfoProvider().Effect(itemid)
+	if effect == nil || effect.MoveTo() == 0 {
+		fmt.Println(con.Stats().Name(), "tried to use", itemid, "as a return scroll")
+		err = con.SendPacket(packets.EnableActions())
+		return
+	}
+
+	ok, err := con.UseItem(int8(slot), itemid)
+	if err == nil && !ok {
+		err = con.SendPacket(packets.EnableActions())
+	}
+
+	return
+}
+
+// handleUseUpgradeScroll handles a request to use an upgrade scroll on an equip
+func handleUseUpgradeScroll(con *client.Connection, it maplelib.PacketIterator) (handled bool, err error) {
+	_, err = it.Decode4() // update time
+	slot, err := it.Decode2s()
+	dst, err := it.Decode2s()
+	ws, err := it.Decode2s()
+	if err != nil {
+		return
+	}
+
+	handled = true
+
+	ok, err := con.ScrollEquip(int8(slot), int8(dst), ws&2 == 2)
+	if err == nil && !ok {
+		fmt.Println(con.Stats().Name(), "sent an invalid scroll request:", slot, dst, ws)
+		err = con.SendPacket(packets.EnableActions())
+	}
+
+	return
+}
@@ -0,0 +1,101 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"fmt"
+)
+
+import (
+	"github.com/Francesco149/kagami/channelserver/client"
+	"github.com/Francesco149/kagami/common/consts"
+	"github.com/Francesco149/kagami/common/packets"
+	"github.com/Francesco149/maplelib"
+)
+
+// handleChangeKeymap handles changes to the keyboard settings
+func handleChangeKeymap(con *client.Connection, it maplelib.PacketIterator) (handled bool, err error) {
+	mode, err := it.Decode4s()
+	if err != nil {
+		return
+	}
+
+	if mode != 0 {
+		// what the hell is this
+		fmt.Println("unknown keymap change mode", mode)
+		return true, nil
+	}
+
+	count, err := it.Decode4s()
+	if err != nil {
+		return
+	}
+
+	if count < 0 || count > consts.KeymapSize {
+		err = fmt.Errorf("%s sent %d keymap changes", con.Stats().Name(), count)
+		return
+	}
+
+	changes := make(map[int32]packets.KeyBinding)
+
+	for i := int32(0); i < count; i++ {
+		key, err := it.Decode4s()
+		typ, err := it.Decode1()
+		action, err := it.Decode4s()
+		if err != nil {
+			return false, err
+		}
+
+		changes[key] = packets.KeyBinding{Type: typ, Action: action}
+	}
+
+	handled = true
+	err = con.ChangeKeymap(changes)
+	return
+}
+
+// handleSkillMacro handles changes to the skill macros
+func handleSkillMacro(con *client.Connection, it maplelib.PacketIterator) (handled bool, err error) {
+	count, err := it.Decode1()
+	if err != nil {
+		return
+	}
+
+	if count > consts.MaxSkillMacros {
+		err = fmt.Errorf("%s sent %d skill macros", con.Stats().Name(), count)
+		return
+	}
+
+	macros := make([]packets.SkillMacro, count)
+	var shout byte
+
+	for i := range macros {
+		macros[i].Name, err = it.DecodeString()
+		shout, err = it.Decode1()
+		macros[i].Shout = shout != 0
+
+		for j := range macros[i].Skills {
+			macros[i].Skills[j], err = it.Decode4s()
+		}
+		if err != nil {
+			return
+		}
+	}
+
+	handled = true
+	err = con.SetSkillMacros(macros)
+	return
+}
@@ -0,0 +1,328 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+import (
+	"github.com/Francesco149/kagami/channelserver/client"
+	"github.com/Francesco149/kagami/channelserver/gamedata"
+	"github.com/Francesco149/kagami/channelserver/status"
+	"github.com/Francesco149/kagami/common/packets"
+	"github.com/Francesco149/maplelib"
+)
+
+// maxMonsterMoveDistance is the maximum distance in pixels between the monster's position
+// known by the server and the starting point of a movement sent by the controller
+const maxMonsterMoveDistance = 500
+
+// handleMovePlayer handles a player's movement by updating the player's position
+// and relaying the movement to the other players in the map
+func handleMovePlayer(con *client.Connection, it maplelib.PacketIterator) (handled bool, err error) {
+	_, err = it.Decode1() // what the hell is this
+	_, err = it.Decode4() // what the hell is this
+	if err != nil {
+		return
+	}
+
+	moves, err := gamedata.ParseMovement(&it)
+	if err != nil {
+		return
+	}
+
+	if moves.HasPos() {
+		con.SetPos(moves.EndPos())
+	}
+
+	if moves.Fh() >= 0 {
+		con.SetFh(moves.Fh())
+	}
+
+	con.SetStance(moves.Stance())
+	con.Map().Broadcast(packets.MovePlayer(con.CharId(), moves.Raw()), con.CharId())
+	con.CheckTradeDistance()
+
+	handled = err == nil
+	return
+}
+
+// validMonsterMovement checks that a monster's movement starts close to the position
+// known by the server and doesn't end outside of the map
+func validMonsterMovement(mob *gamedata.MapleMonster, pos, startPos image.Point,
+	moves *gamedata.Movement) bool {
+
+	dx := float64(startPos.X - pos.X)
+	dy := float64(startPos.Y - pos.Y)
+	if math.Sqrt(dx*dx+dy*dy) > maxMonsterMoveDistance {
+		return false
+	}
+
+	if !moves.HasPos() {
+		return true
+	}
+
+	return mob.Map().InBounds(moves.EndPos())
+}
+
+// handleMoveLife handles a monster movement packet sent by the monster's controller
+func handleMoveLife(con *client.Connection, it maplelib.PacketIterator) (handled bool, err error) {
+	oid, err := it.Decode4s()
+	moveid, err := it.Decode2s()
+	useSkill, err := it.Decode1()
+	skill, err := it.Decode1s()
+
+	var skillArgs [4]byte
+	for i := range skillArgs {
+		skillArgs[i], err = it.Decode1()
+	}
+
+	startx, err := it.Decode2s()
+	starty, err := it.Decode2s()
+	if err != nil {
+		return
+	}
+
+	moves, err := gamedata.ParseMovement(&it)
+	if err != nil {
+		return
+	}
+
+	handled = true
+
+	mob := con.Map().Monster(oid)
+	if mob == nil {
+		// the monster probably died while the packet was being sent
+		return
+	}
+
+	// only the controller is allowed to move the monster
+	ok, aggro := con.Map().AcknowledgeMonsterMove(mob, con.CharId(), skill)
+	if !ok {
+		return
+	}
+
+	err = con.SendPacket(packets.MoveMonsterResponse(oid, moveid, int16(mob.Mp()), aggro, 0, 0))
+	if err != nil {
+		return
+	}
+
+	startPos := image.Pt(int(startx), int(starty))
+	pos := con.Map().MonsterPos(mob)
+	if !validMonsterMovement(mob, pos, startPos, moves) {
+		fmt.Println(con.Stats().Name(), "sent an invalid movement for monster",
+			mob.Id(), "from", startPos, "while the monster is at", pos)
+		return
+	}
+
+	con.Map().MoveMonster(mob, moves, useSkill > 0, skill, skillArgs, startPos)
+	return
+}
+
+// handleAutoAggro handles a request from the client to make a monster chase the player
+func handleAutoAggro(con *client.Connection, it maplelib.PacketIterator) (handled bool, err error) {
+	oid, err := it.Decode4s()
+	if err != nil {
+		return
+	}
+
+	handled = true
+
+	mob := con.Map().Monster(oid)
+	if mob == nil {
+		return
+	}
+
+	controller, aggro := con.Map().MonsterController(mob)
+
+	switch {
+	// nobody is controlling the monster or the controller left the map
+	case controller == nil, con.Map().Player(controller.CharId()) == nil:
+		con.Map().SwitchMonsterController(mob, con, true)
+
+	// the monster is not chasing its controller yet
+	case !aggro:
+		con.Map().SwitchMonsterController(mob, controller, true)
+	}
+
+	return
+}
+
+// onMonsterKilled hands out the rewards for a monster that was killed by players.
+// killer is the player whose attack killed the monster.
+func onMonsterKilled(killer *client.Connection, m *gamedata.MapleMap, mob *gamedata.MapleMonster) {
+	st := <-status.Get
+	rates := st.WorldConf().Rates()
+	status.Get <- st
+
+	attackers := mob.Attackers()
+	owner := topAttacker(attackers)
+
+	var ownerParty int32
+	if player, ok := m.Player(owner).(*client.Connection); ok {
+		ownerParty = player.PartyId()
+	}
+
+	distributeExp(killer, m, mob, attackers, owner, rates.MobExp())
+	updateQuestKills(killer, m, mob, attackers)
+	m.SpawnMonsterDrops(mob, owner, ownerParty, rates.MobDrop(), rates.MobMeso())
+}
+
+// updateQuestKills updates the quest kill counts of all of the attackers that are
+// still in the map while holding the action lock of each of them
+func updateQuestKills(killer *client.Connection, m *gamedata.MapleMap,
+	mob *gamedata.MapleMonster, attackers map[int32]int64) {
+
+	for charid := range attackers {
+		player, ok := m.Player(charid).(*client.Connection)
+		if !ok {
+			continue
+		}
+
+		killer.WithActionsOf(player, func() {
+			err := player.MobKilled(mob.Id())
+			if err != nil {
+				fmt.Println("Failed to update quest kills for", player.Stats().Name(), ":", err)
+			}
+		})
+	}
+}
+
+// topAttacker returns the character id that dealt the most damage or -1
+func topAttacker(attackers map[int32]int64) (res int32) {
+	res = -1
+	topDamage := int64(0)
+
+	for charid, dmg := range attackers {
+		if dmg > topDamage {
+			res, topDamage = charid, dmg
+		}
+	}
+
+	return
+}
+
+// partyExpBonus is the extra exp given for each party member after the first that
+// shares the exp of a monster, in percent
+const partyExpBonus = 5
+
+// partyLevelRange is how many levels below the monster a party member that didn't
+// attack it can be to still get a share of the exp
+const partyLevelRange = 5
+
+// expShare is a group of players that split the exp of a monster. Players in a party
+// share the exp with the party members in the map, other players get their own.
+type expShare struct {
+	damage     int64
+	recipients []*client.Connection
+}
+
+// distributeExp gives the monster's exp to all of the attackers that are still in
+// the map proportionally to the damage they dealt. The exp of attackers in a party
+// is split by level between the party members in the map. The exp is given from the
+// killer's packet handler while holding the action lock of each recipient.
+func distributeExp(killer *client.Connection, m *gamedata.MapleMap,
+	mob *gamedata.MapleMonster, attackers map[int32]int64, top int32, rate int32) {
+
+	baseExp := int64(mob.Stats().Exp()) * int64(rate)
+
+	totalDamage := int64(0)
+	for _, dmg := range attackers {
+		totalDamage += dmg
+	}
+
+	if totalDamage <= 0 || baseExp <= 0 {
+		return
+	}
+
+	// shares are mapped by party id, or by negative character id for solo players
+	shares := make(map[int32]*expShare)
+
+	for charid, dmg := range attackers {
+		player, ok := m.Player(charid).(*client.Connection)
+		if !ok {
+			// the attacker left the map
+			continue
+		}
+
+		key := -charid
+		party := player.Party()
+		if party != nil {
+			key = party.Id
+		}
+
+		share := shares[key]
+		if share == nil {
+			share = &expShare{}
+			shares[key] = share
+
+			if party == nil {
+				share.recipients = []*client.Connection{player}
+			} else {
+				share.recipients = partyExpRecipients(m, mob, party.MemberIds(), attackers)
+			}
+		}
+
+		share.damage += dmg
+	}
+
+	for _, share := range shares {
+		exp := baseExp * share.damage / totalDamage
+		exp = exp * int64(100+partyExpBonus*(len(share.recipients)-1)) / 100
+
+		totalLevel := int64(0)
+		for _, player := range share.recipients {
+			totalLevel += int64(player.Stats().Level())
+		}
+
+		for _, player := range share.recipients {
+			playerExp := exp * int64(player.Stats().Level()) / totalLevel
+			if playerExp < 1 {
+				playerExp = 1
+			}
+
+			killer.WithActionsOf(player, func() {
+				err := player.GainExp(int32(playerExp), true, false, player.CharId() == top)
+				if err != nil {
+					fmt.Println("Failed to give exp to", player.Stats().Name(), ":", err)
+				}
+			})
+		}
+	}
+}
+
+// partyExpRecipients returns the party members in the map that get a share of the
+// monster's exp: those who attacked it and those close enough to its level
+func partyExpRecipients(m *gamedata.MapleMap, mob *gamedata.MapleMonster,
+	members []int32, attackers map[int32]int64) (res []*client.Connection) {
+
+	for _, charid := range members {
+		player, ok := m.Player(charid).(*client.Connection)
+		if !ok {
+			continue
+		}
+
+		_, attacked := attackers[charid]
+		if attacked || int32(player.Stats().Level()) >= mob.Stats().Level()-partyLevelRange {
+			res = append(res, player)
+		}
+	}
+
+	return
+}
@@ -0,0 +1,102 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import "fmt"
+
+import (
+	"github.com/Francesco149/kagami/channelserver/client"
+	"github.com/Francesco149/kagami/channelserver/gamedata"
+	"github.com/Francesco149/kagami/channelserver/scripting"
+	"github.com/Francesco149/kagami/common/packets"
+	"github.com/Francesco149/maplelib"
+)
+
+// handleNpcTalk handles a request to start a conversation with a npc
+func handleNpcTalk(con *client.Connection, it maplelib.PacketIterator) (handled bool, err error) {
+	oid, err := it.Decode4s()
+	_, err = it.Decode4() // what the hell is this (probably the player's position)
+	if err != nil {
+		return
+	}
+
+	handled = true
+
+	npc := con.Map().NPC(oid)
+	if npc == nil {
+		fmt.Println(con.Stats().Name(), "tried to talk to non-existing npc oid", oid)
+		err = con.SendPacket(packets.EnableActions())
+		return
+	}
+
+	shop, err := gamedata.NpcShop(npc.Id())
+	if err != nil {
+		fmt.Println("Failed to load shop of npc", npc.Id(), ":", err)
+	}
+
+	if shop != nil {
+		err = con.OpenShop(shop)
+		return
+	}
+
+	ok, err := scripting.StartNpc(con, npc.Id())
+	if err != nil {
+		fmt.Println("Failed to start npc script", npc.Id(), ":", err)
+	}
+
+	if !ok {
+		fmt.Println("No script for npc", npc.Id(), npc.Name())
+		err = con.SendPacket(packets.EnableActions())
+	}
+
+	return
+}
+
+// handleNpcTalkMore handles the player's reply to a npc dialog
+func handleNpcTalkMore(con *client.Connection, it maplelib.PacketIterator) (handled bool, err error) {
+	msgType, err := it.Decode1()
+	mode, err := it.Decode1s()
+	if err != nil {
+		return
+	}
+
+	handled = true
+
+	var selection int32
+	var text string
+
+	switch msgType {
+	case packets.NpcTalkGetText:
+		if mode == 1 {
+			text, err = it.DecodeString()
+		}
+
+	case packets.NpcTalkGetNumber, packets.NpcTalkSimple:
+		if mode == 1 {
+			selection, err = it.Decode4s()
+		}
+	}
+
+	if err != nil {
+		return
+	}
+
+	if !scripting.ReplyNpc(con.CharId(), msgType, mode, selection, text) {
+		err = con.SendPacket(packets.EnableActions())
+	}
+
+	return
+}
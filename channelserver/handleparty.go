@@ -0,0 +1,127 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"fmt"
+)
+
+import (
+	"github.com/Francesco149/kagami/channelserver/client"
+	"github.com/Francesco149/kagami/common/interserver"
+	"github.com/Francesco149/kagami/common/packets"
+	"github.com/Francesco149/maplelib"
+)
+
+// handlePartyOperation handles a party request and forwards it to the worldserver,
+// which owns the parties
+func handlePartyOperation(con *client.Connection, it maplelib.PacketIterator) (handled bool, err error) {
+	op, err := it.Decode1()
+	if err != nil {
+		return
+	}
+
+	var target int32
+	var name string
+
+	switch op {
+	case interserver.PartyOpCreate:
+		if client.JobBranch(con.Stats().Job()) == client.BRANCH_BEGINNER {
+			return true, con.SendPacket(packets.PartyStatusMessage(packets.PartyMsgBeginnerCreate))
+		}
+
+	case interserver.PartyOpLeave:
+
+	case interserver.PartyOpJoin, interserver.PartyOpExpel, interserver.PartyOpLeader:
+		target, err = it.Decode4s()
+
+	case interserver.PartyOpInvite:
+		name, err = it.DecodeString()
+
+	default:
+		fmt.Println(con.Stats().Name(), "sent unknown party operation", op)
+		return true, nil
+	}
+
+	if err != nil {
+		return
+	}
+
+	handled = true
+	err = client.SendToWorld(interserver.PartyOperation(op, con.CharId(), target, name))
+	return
+}
+
+// handleMultiChat handles a buddy, party or guild chat message
+func handleMultiChat(con *client.Connection, it maplelib.PacketIterator) (handled bool, err error) {
+	chatType, err := it.Decode1()
+	count, err := it.Decode1()
+	if err != nil {
+		return
+	}
+
+	// the recipients are picked by the server, the ones sent by the client are ignored
+	err = it.Skip(int(count) * 4)
+	text, err := it.DecodeString()
+	if err != nil {
+		return
+	}
+
+	handled = true
+
+	var recipients []int32
+
+	switch chatType {
+	case packets.MultiChatBuddy:
+		recipients = con.OnlineBuddyIds()
+
+	case packets.MultiChatParty:
+		party := con.Party()
+		if party == nil {
+			return
+		}
+
+		for _, m := range party.Members {
+			if m.CharId != con.CharId() && m.Channel >= 0 {
+				recipients = append(recipients, m.CharId)
+			}
+		}
+
+	case packets.MultiChatGuild:
+		guild := con.Guild()
+		if guild == nil {
+			return
+		}
+
+		for _, m := range guild.Members {
+			if m.CharId != con.CharId() && m.Online {
+				recipients = append(recipients, m.CharId)
+			}
+		}
+
+	default:
+		fmt.Println(con.Stats().Name(), "sent unsupported chat type", chatType)
+		return
+	}
+
+	if len(recipients) == 0 {
+		return
+	}
+
+	p := packets.MultiChat(chatType, con.Stats().Name(), text)
+	err = client.SendToWorld(interserver.MessageToPlayers(recipients, p))
+	return
+}
@@ -0,0 +1,85 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import "fmt"
+
+import (
+	"github.com/Francesco149/kagami/channelserver/client"
+	"github.com/Francesco149/kagami/channelserver/scripting"
+	"github.com/Francesco149/kagami/common/packets"
+	"github.com/Francesco149/maplelib"
+)
+
+// Possible quest actions
+const (
+	questActionStart       = 1
+	questActionComplete    = 2
+	questActionForfeit     = 3
+	questActionScriptStart = 4
+	questActionScriptEnd   = 5
+)
+
+// handleQuestAction handles a request to start, complete or forfeit a quest
+func handleQuestAction(con *client.Connection, it maplelib.PacketIterator) (handled bool, err error) {
+	action, err := it.Decode1()
+	questid, err := it.Decode2s()
+	if err != nil {
+		return
+	}
+
+	handled = true
+
+	var npcid int32
+	if action != questActionForfeit {
+		npcid, err = it.Decode4s()
+		_, err = it.Decode4() // what the hell is this (probably the player's position)
+		if err != nil {
+			return
+		}
+	}
+
+	ok := false
+
+	switch action {
+	case questActionStart:
+		ok, err = con.StartQuest(questid, npcid)
+
+	case questActionComplete:
+		// TODO: some quests let the player pick a reward, the selection follows here
+		ok, err = con.CompleteQuest(questid, npcid)
+
+	case questActionForfeit:
+		ok, err = con.ForfeitQuest(questid)
+
+	case questActionScriptStart, questActionScriptEnd:
+		ok, err = scripting.StartQuest(con, npcid, questid, action == questActionScriptEnd)
+
+	default:
+		fmt.Println("Unknown quest action", action, "from", con.Stats().Name())
+	}
+
+	if err != nil {
+		return
+	}
+
+	if !ok {
+		fmt.Println(con.Stats().Name(), "failed quest action", action, "on quest", questid)
+		err = con.SendPacket(packets.EnableActions())
+	}
+
+	return
+}
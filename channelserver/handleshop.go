@@ -0,0 +1,66 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import "fmt"
+
+import (
+	"github.com/Francesco149/kagami/channelserver/client"
+	"github.com/Francesco149/kagami/common/packets"
+	"github.com/Francesco149/maplelib"
+)
+
+// handleNpcShop handles a buy, sell or recharge request in a npc shop
+func handleNpcShop(con *client.Connection, it maplelib.PacketIterator) (handled bool, err error) {
+	op, err := it.Decode1()
+	if err != nil {
+		return
+	}
+
+	handled = true
+
+	switch op {
+	case packets.ShopOpBuy, packets.ShopOpSell:
+		slot, err := it.Decode2s()
+		itemid, err := it.Decode4s()
+		quantity, err := it.Decode2s()
+		if err != nil {
+			return false, err
+		}
+
+		if op == packets.ShopOpBuy {
+			return true, con.BuyShopItem(slot, itemid, quantity)
+		}
+		return true, con.SellShopItem(slot, itemid, quantity)
+
+	case packets.ShopOpRecharge:
+		var slot int16
+		slot, err = it.Decode2s()
+		if err != nil {
+			return
+		}
+
+		err = con.RechargeShopItem(slot)
+
+	case packets.ShopOpLeave:
+		con.CloseShop()
+
+	default:
+		fmt.Println(con.Stats().Name(), "sent unknown shop operation", op)
+	}
+
+	return
+}
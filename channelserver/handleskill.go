@@ -0,0 +1,54 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"github.com/Francesco149/kagami/channelserver/client"
+	"github.com/Francesco149/kagami/common/packets"
+	"github.com/Francesco149/maplelib"
+)
+
+// handleSpecialMove handles the use of a non-attack skill such as a buff
+func handleSpecialMove(con *client.Connection, it maplelib.PacketIterator) (handled bool, err error) {
+	_, err = it.Decode4() // update time
+	skillid, err := it.Decode4s()
+	_, err = it.Decode1() // skill level, we use the one we know instead
+	if err != nil {
+		return
+	}
+
+	handled = true
+
+	_, err = con.UseSkill(skillid)
+	if err != nil {
+		return
+	}
+
+	err = con.SendPacket(packets.EnableActions())
+	return
+}
+
+// handleCancelBuff handles a request to cancel a buff skill
+func handleCancelBuff(con *client.Connection, it maplelib.PacketIterator) (handled bool, err error) {
+	skillid, err := it.Decode4s()
+	if err != nil {
+		return
+	}
+
+	handled = true
+	err = con.CancelBuff(skillid)
+	return
+}
@@ -0,0 +1,79 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"fmt"
+)
+
+import (
+	"github.com/Francesco149/kagami/channelserver/client"
+	"github.com/Francesco149/kagami/channelserver/status"
+	"github.com/Francesco149/kagami/common/packets"
+	"github.com/Francesco149/maplelib"
+)
+
+// handleDistributeAp handles a request to spend one AP on a stat
+func handleDistributeAp(con *client.Connection, it maplelib.PacketIterator) (handled bool, err error) {
+	_, err = it.Decode4() // update time
+	stat, err := it.Decode4()
+	if err != nil {
+		return
+	}
+
+	st := <-status.Get
+	maxStat := int16(st.WorldConf().MaxStat())
+	status.Get <- st
+
+	err = con.DistributeAp(int(stat), maxStat)
+	handled = err == nil
+	return
+}
+
+// handleDistributeSp handles a request to spend one SP on a skill
+func handleDistributeSp(con *client.Connection, it maplelib.PacketIterator) (handled bool, err error) {
+	_, err = it.Decode4() // update time
+	skillid, err := it.Decode4s()
+	if err != nil {
+		return
+	}
+
+	ok, err := con.SpendSp(skillid)
+	if err == nil && !ok {
+		err = con.SendPacket(packets.EnableActions())
+	}
+
+	handled = err == nil
+	return
+}
+
+// handleGiveFame handles a request to raise or drop another player's fame
+func handleGiveFame(con *client.Connection, it maplelib.PacketIterator) (handled bool, err error) {
+	targetid, err := it.Decode4s()
+	mode, err := it.Decode1()
+	if err != nil {
+		return
+	}
+
+	if mode != packets.FameModeDown && mode != packets.FameModeUp {
+		fmt.Println(con.Stats().Name(), "sent an invalid fame mode", mode)
+		return true, nil
+	}
+
+	err = con.GiveFame(targetid, mode)
+	handled = err == nil
+	return
+}
@@ -0,0 +1,75 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import "fmt"
+
+import (
+	"github.com/Francesco149/kagami/channelserver/client"
+	"github.com/Francesco149/kagami/common/packets"
+	"github.com/Francesco149/maplelib"
+)
+
+// handleStorage handles a storage window operation
+func handleStorage(con *client.Connection, it maplelib.PacketIterator) (handled bool, err error) {
+	op, err := it.Decode1()
+	if err != nil {
+		return
+	}
+
+	handled = true
+
+	switch op {
+	case packets.StorageReqTakeOut:
+		invtype, err := it.Decode1()
+		n, err := it.Decode1()
+		if err != nil {
+			return false, err
+		}
+
+		return true, con.TakeOutItem(client.InventoryType(invtype), n)
+
+	case packets.StorageReqStore:
+		slot, err := it.Decode2s()
+		itemid, err := it.Decode4s()
+		quantity, err := it.Decode2s()
+		if err != nil {
+			return false, err
+		}
+
+		return true, con.StoreItem(slot, itemid, quantity)
+
+	case packets.StorageReqArrange:
+		err = con.ArrangeStorage()
+
+	case packets.StorageReqMeso:
+		var amount int32
+		amount, err = it.Decode4s()
+		if err != nil {
+			return
+		}
+
+		err = con.TransferStorageMeso(amount)
+
+	case packets.StorageReqClose:
+		con.CloseStorage()
+
+	default:
+		fmt.Println(con.Stats().Name(), "sent unknown storage operation", op)
+	}
+
+	return
+}
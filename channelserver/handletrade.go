@@ -0,0 +1,112 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"fmt"
+)
+
+import (
+	"github.com/Francesco149/kagami/channelserver/client"
+	"github.com/Francesco149/kagami/common/packets"
+	"github.com/Francesco149/maplelib"
+)
+
+// handlePlayerInteraction handles the trade window operations
+func handlePlayerInteraction(con *client.Connection, it maplelib.PacketIterator) (handled bool, err error) {
+	op, err := it.Decode1()
+	if err != nil {
+		return
+	}
+
+	handled = true
+
+	switch op {
+	case packets.InteractionCreate:
+		var roomType byte
+		roomType, err = it.Decode1()
+		if err != nil {
+			return
+		}
+
+		if roomType != packets.InteractionTypeTrade {
+			fmt.Println(con.Stats().Name(), "tried to open unsupported room type", roomType)
+			return
+		}
+
+		err = con.OpenTrade()
+
+	case packets.InteractionInvite:
+		var charid int32
+		charid, err = it.Decode4s()
+		if err != nil {
+			return
+		}
+
+		err = con.InviteTrade(charid)
+
+	case packets.InteractionDecline:
+		err = con.DeclineTrade()
+
+	case packets.InteractionVisit:
+		err = con.AcceptTrade()
+
+	case packets.InteractionChat:
+		var text string
+		text, err = it.DecodeString()
+		if err != nil {
+			return
+		}
+
+		err = con.TradeChat(text)
+
+	case packets.InteractionExit:
+		con.CancelTrade()
+
+	case packets.InteractionSetItems:
+		invtype, err := it.Decode1s()
+		src, err := it.Decode2s()
+		quantity, err := it.Decode2s()
+		tradeSlot, err := it.Decode1s()
+		if err != nil {
+			return false, err
+		}
+
+		var ok bool
+		ok, err = con.TradeItem(invtype, int8(src), quantity, tradeSlot)
+		if err == nil && !ok {
+			fmt.Println(con.Stats().Name(), "tried to trade invalid item at", invtype, src)
+		}
+		return true, err
+
+	case packets.InteractionSetMeso:
+		var amount int32
+		amount, err = it.Decode4s()
+		if err != nil {
+			return
+		}
+
+		_, err = con.TradeMeso(amount)
+
+	case packets.InteractionConfirm:
+		err = con.ConfirmTrade()
+
+	default:
+		fmt.Println(con.Stats().Name(), "sent unknown interaction operation", op)
+	}
+
+	return
+}
@@ -71,3 +71,8 @@ func Execute(fn ClientOperationCallback) (err error) {
 	}
 	return
 }
+
+// Get returns the connected player with the given character id or nil
+func Get(charid int32) *client.Connection {
+	return characters[charid]
+}
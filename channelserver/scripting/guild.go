@@ -0,0 +1,96 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package scripting
+
+import (
+	"github.com/Francesco149/kagami/channelserver/client"
+	"github.com/Francesco149/kagami/common/interserver"
+	"github.com/Francesco149/kagami/common/packets"
+	"github.com/robertkrimen/otto"
+)
+
+// addGuildApi adds the functions used by the guild npcs to a script api object.
+// The guild changes are requested to the worldserver after taking the mesos, which
+// are given back if it refuses them.
+func addGuildApi(api map[string]interface{}, vm *otto.Otto, con *client.Connection) {
+	fns := map[string]interface{}{
+		"getGuildId":    func(call otto.FunctionCall) otto.Value { return toValue(vm, con.GuildId()) },
+		"getGuildRank":  func(call otto.FunctionCall) otto.Value { return toValue(vm, con.GuildRank()) },
+		"isGuildLeader": func(call otto.FunctionCall) otto.Value { return toValue(vm, con.IsGuildLeader()) },
+
+		"getGuildCapacity": func(call otto.FunctionCall) otto.Value {
+			guild := con.Guild()
+			if guild == nil {
+				return toValue(vm, 0)
+			}
+			return toValue(vm, guild.Capacity)
+		},
+
+		"getGuildCapacityCost": func(call otto.FunctionCall) otto.Value {
+			guild := con.Guild()
+			if guild == nil {
+				return toValue(vm, 0)
+			}
+			return toValue(vm, client.GuildCapacityCost(guild.Capacity))
+		},
+
+		// openGuildCreate() asks the player for the name of a new guild
+		"openGuildCreate": func(call otto.FunctionCall) otto.Value {
+			check(con.SendPacket(packets.GuildMessage(packets.GuildOpCreatePrompt)))
+			return otto.UndefinedValue()
+		},
+
+		// openGuildEmblem() opens the emblem editor
+		"openGuildEmblem": func(call otto.FunctionCall) otto.Value {
+			check(con.SendPacket(packets.GuildMessage(packets.GuildOpEmblemPrompt)))
+			return otto.UndefinedValue()
+		},
+
+		// increaseGuildCapacity() adds 5 slots to the guild and returns false if the
+		// player isn't the leader, doesn't have enough mesos or the guild is full
+		"increaseGuildCapacity": func(call otto.FunctionCall) otto.Value {
+			guild := con.Guild()
+			if !con.IsGuildLeader() || guild.Capacity >= interserver.GuildMaxCapacity {
+				return toValue(vm, false)
+			}
+
+			paid, err := con.PayGuildCost(interserver.GuildOpCapacity,
+				client.GuildCapacityCost(guild.Capacity))
+			check(err)
+			if !paid {
+				return toValue(vm, false)
+			}
+
+			check(client.SendToWorld(interserver.GuildOperation(interserver.GuildOpCapacity,
+				con.CharId(), 0, nil, nil)))
+			return toValue(vm, true)
+		},
+
+		"disbandGuild": func(call otto.FunctionCall) otto.Value {
+			if !con.IsGuildLeader() {
+				return toValue(vm, false)
+			}
+
+			check(client.SendToWorld(interserver.GuildOperation(interserver.GuildOpDisband,
+				con.CharId(), 0, nil, nil)))
+			return toValue(vm, true)
+		},
+	}
+
+	for k, v := range fns {
+		api[k] = v
+	}
+}
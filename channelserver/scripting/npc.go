@@ -0,0 +1,296 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package scripting
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+import (
+	"github.com/Francesco149/kagami/channelserver/client"
+	"github.com/Francesco149/kagami/common/packets"
+	"github.com/Francesco149/maplelib"
+	"github.com/robertkrimen/otto"
+)
+
+// errEnded is thrown inside the script to stop it when the conversation is over
+var errEnded = errors.New("conversation ended")
+
+// errTimeout is thrown inside the script when it runs for too long
+var errTimeout = errors.New("script timed out")
+
+// npcReply is the player's answer to an npc dialog
+type npcReply struct {
+	mode      int8
+	selection int32
+	text      string
+}
+
+// NpcConversation is a running npc script. The script runs in its own goroutine
+// from top to bottom and blocks every time it shows a dialog until the player
+// replies, so it can be written as a linear sequence of dialogs.
+type NpcConversation struct {
+	con     *client.Connection
+	npcid   int32
+	questid int16 // quest for quest scripts, 0 for regular npc scripts
+	end     bool  // true if the quest script is completing the quest
+	vm      *otto.Otto
+	msgType byte          // type of the dialog the script is waiting on
+	replies chan npcReply // player replies to the script
+	yield   chan bool     // true when the script is waiting, false when it's done
+}
+
+var conversations = make(map[int32]*NpcConversation) // by char id
+var conversationsMut sync.Mutex
+
+// StartNpc starts the script for the given npc id and runs it until it shows the
+// first dialog. ok is false if there is no script for the npc or the player is
+// already talking to an npc.
+func StartNpc(con *client.Connection, npcid int32) (ok bool, err error) {
+	return startConversation(&NpcConversation{con: con, npcid: npcid},
+		"npc", strconv.Itoa(int(npcid)))
+}
+
+// StartQuest starts the script for the given quest, which is stored as
+// quest/<quest id>.js and talks through the given npc. end is true if the player
+// is completing the quest.
+func StartQuest(con *client.Connection, npcid int32, questid int16, end bool) (ok bool,
+	err error) {
+
+	return startConversation(&NpcConversation{con: con, npcid: npcid, questid: questid,
+		end: end}, "quest", strconv.Itoa(int(questid)))
+}
+
+// startConversation runs the given script for a conversation until it shows the
+// first dialog
+func startConversation(this *NpcConversation, kind, name string) (ok bool, err error) {
+	if !Exists(kind, name) {
+		return
+	}
+
+	con := this.con
+
+	conversationsMut.Lock()
+	if conversations[con.CharId()] != nil {
+		conversationsMut.Unlock()
+		return
+	}
+
+	this.vm = otto.New()
+	this.replies = make(chan npcReply)
+	this.yield = make(chan bool, 1)
+	this.vm.Interrupt = make(chan func(), 1)
+
+	script, err := load(this.vm, kind, name)
+	if err != nil {
+		conversationsMut.Unlock()
+		return
+	}
+
+	if err = this.vm.Set("cm", this.api()); err != nil {
+		conversationsMut.Unlock()
+		return
+	}
+
+	conversations[con.CharId()] = this
+	conversationsMut.Unlock()
+
+	go this.run(script)
+	this.wait()
+	ok = true
+	return
+}
+
+// ReplyNpc passes the player's reply to the dialog of type msgType to the npc
+// script they're talking to and runs it until the next dialog.
+// handled is false if the player isn't talking to any npc.
+func ReplyNpc(charid int32, msgType byte, mode int8, selection int32,
+	text string) (handled bool) {
+
+	conversationsMut.Lock()
+	this := conversations[charid]
+	conversationsMut.Unlock()
+
+	if this == nil {
+		return
+	}
+
+	handled = true
+
+	if msgType != this.msgType {
+		fmt.Println("Npc reply type mismatch for", charid, ":", msgType,
+			"expected", this.msgType)
+		EndNpc(charid)
+		return
+	}
+
+	this.replies <- npcReply{mode: mode, selection: selection, text: text}
+	this.wait()
+	return
+}
+
+// EndNpc stops the conversation the given character is having with an npc, if any
+func EndNpc(charid int32) {
+	conversationsMut.Lock()
+	defer conversationsMut.Unlock()
+
+	this := conversations[charid]
+	if this == nil {
+		return
+	}
+
+	delete(conversations, charid)
+	close(this.replies)
+}
+
+// wait blocks until the script shows a dialog or ends. if the script doesn't yield
+// within scriptTimeout it's interrupted.
+func (this *NpcConversation) wait() {
+	select {
+	case <-this.yield:
+		return
+	case <-time.After(scriptTimeout):
+	}
+
+	fmt.Println("Npc script", this.npcid, "timed out for", this.con.CharId())
+	this.vm.Interrupt <- func() { panic(errTimeout) }
+	<-this.yield
+}
+
+// run executes the script and cleans up once it's done
+func (this *NpcConversation) run(script *otto.Script) {
+	defer func() {
+		if r := recover(); r != nil && r != errEnded {
+			fmt.Println("Npc script", this.npcid, "stopped:", r)
+		}
+
+		conversationsMut.Lock()
+		if conversations[this.con.CharId()] == this {
+			delete(conversations, this.con.CharId())
+		}
+		conversationsMut.Unlock()
+
+		err := this.con.SendPacket(packets.EnableActions())
+		if err != nil {
+			fmt.Println(err)
+		}
+
+		this.yield <- false
+	}()
+
+	_, err := this.vm.Run(script)
+	if err != nil {
+		fmt.Println("Npc script", this.npcid, "error:", err)
+	}
+}
+
+// dialog sends a dialog packet and blocks the script until the player replies.
+// the conversation ends if the player closes the dialog.
+func (this *NpcConversation) dialog(msgType byte, p maplelib.Packet) npcReply {
+	this.msgType = msgType
+
+	if err := this.con.SendPacket(p); err != nil {
+		panic(err)
+	}
+
+	this.yield <- true
+	reply, ok := <-this.replies
+	if !ok || reply.mode == -1 {
+		panic(errEnded)
+	}
+
+	return reply
+}
+
+// check panics with err if it's not nil, stopping the script
+func check(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
+
+// api returns the cm object that is exposed to the script
+func (this *NpcConversation) api() map[string]interface{} {
+	vm := this.vm
+	con := this.con
+
+	say := func(prev, next bool) func(otto.FunctionCall) otto.Value {
+		return func(call otto.FunctionCall) otto.Value {
+			reply := this.dialog(packets.NpcTalkSay,
+				packets.NpcSay(this.npcid, argString(call, 0), prev, next))
+			return toValue(vm, reply.mode == 1)
+		}
+	}
+
+	res := map[string]interface{}{
+		"sendOk":       say(false, false),
+		"sendNext":     say(false, true),
+		"sendPrev":     say(true, false),
+		"sendNextPrev": say(true, true),
+
+		"sendYesNo": func(call otto.FunctionCall) otto.Value {
+			reply := this.dialog(packets.NpcTalkYesNo,
+				packets.NpcYesNo(this.npcid, argString(call, 0)))
+			return toValue(vm, reply.mode == 1)
+		},
+
+		"sendSimple": func(call otto.FunctionCall) otto.Value {
+			reply := this.dialog(packets.NpcTalkSimple,
+				packets.NpcSimple(this.npcid, argString(call, 0)))
+			if reply.mode == 0 {
+				panic(errEnded)
+			}
+			return toValue(vm, reply.selection)
+		},
+
+		"sendGetNumber": func(call otto.FunctionCall) otto.Value {
+			reply := this.dialog(packets.NpcTalkGetNumber,
+				packets.NpcGetNumber(this.npcid, argString(call, 0),
+					int32(argInt(call, 1, 0)), int32(argInt(call, 2, 0)),
+					int32(argInt(call, 3, 0))))
+			if reply.mode == 0 {
+				panic(errEnded)
+			}
+			return toValue(vm, reply.selection)
+		},
+
+		"sendGetText": func(call otto.FunctionCall) otto.Value {
+			reply := this.dialog(packets.NpcTalkGetText,
+				packets.NpcGetText(this.npcid, argString(call, 0)))
+			if reply.mode == 0 {
+				panic(errEnded)
+			}
+			return toValue(vm, reply.text)
+		},
+
+		"dispose": func(call otto.FunctionCall) otto.Value {
+			panic(errEnded)
+		},
+
+		"getNpc":     func(call otto.FunctionCall) otto.Value { return toValue(vm, this.npcid) },
+		"getQuest":   func(call otto.FunctionCall) otto.Value { return toValue(vm, this.questid) },
+		"isQuestEnd": func(call otto.FunctionCall) otto.Value { return toValue(vm, this.end) },
+	}
+
+	addPlayerApi(res, vm, con)
+	addGuildApi(res, vm, con)
+	addStorageApi(res, vm, con, this.npcid)
+	return res
+}
@@ -0,0 +1,199 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package scripting
+
+import "fmt"
+
+import (
+	"github.com/Francesco149/kagami/channelserver/client"
+	"github.com/Francesco149/kagami/channelserver/gamedata"
+	"github.com/Francesco149/kagami/common/packets"
+	"github.com/robertkrimen/otto"
+)
+
+// getMap returns the map with the given id, loading it if necessary
+func getMap(mapid int32) *gamedata.MapleMap {
+	return client.LoadMap(mapid)
+}
+
+// warp moves the player to the given map. portal can be a portal id or name and
+// defaults to the first portal
+func warp(con *client.Connection, mapid int32, portal otto.Value) error {
+	if portal.IsDefined() && !portal.IsNumber() {
+		return con.ChangeMapToPortal(mapid, portal.String())
+	}
+
+	portalid := int32(0)
+	if portal.IsDefined() {
+		id, err := portal.ToInteger()
+		if err != nil {
+			return err
+		}
+		portalid = int32(id)
+	}
+
+	return con.ChangeMap(mapid, portalid)
+}
+
+// addPlayerApi adds the functions that query and modify the player to a script api
+// object
+func addPlayerApi(api map[string]interface{}, vm *otto.Otto, con *client.Connection) {
+	fns := map[string]interface{}{
+		"getName":  func(call otto.FunctionCall) otto.Value { return toValue(vm, con.Stats().Name()) },
+		"getLevel": func(call otto.FunctionCall) otto.Value { return toValue(vm, con.Stats().Level()) },
+		"getJob":   func(call otto.FunctionCall) otto.Value { return toValue(vm, con.Stats().Job()) },
+		"getMeso":  func(call otto.FunctionCall) otto.Value { return toValue(vm, con.Meso()) },
+		"getMapId": func(call otto.FunctionCall) otto.Value { return toValue(vm, con.Map().Id()) },
+
+		// warp(map, portal) warps the player to a portal id or name
+		"warp": func(call otto.FunctionCall) otto.Value {
+			check(warp(con, int32(argInt(call, 0, 0)), call.Argument(1)))
+			return otto.UndefinedValue()
+		},
+
+		// warpRandom(map) warps the player to a random spawn point
+		"warpRandom": func(call otto.FunctionCall) otto.Value {
+			m := getMap(int32(argInt(call, 0, 0)))
+			if m == nil {
+				panic(fmt.Errorf("map %d doesn't exist", argInt(call, 0, 0)))
+			}
+			check(con.ChangeMap(m.Id(), m.RandomSpawnPortal().Id()))
+			return otto.UndefinedValue()
+		},
+
+		"getPlayerCount": func(call otto.FunctionCall) otto.Value {
+			m := getMap(int32(argInt(call, 0, int64(con.Map().Id()))))
+			if m == nil {
+				return toValue(vm, 0)
+			}
+			return toValue(vm, m.PlayerCount())
+		},
+
+		// gainItem(id, amount) gives or takes (negative amount) items and returns
+		// false if the player doesn't have enough room or items
+		"gainItem": func(call otto.FunctionCall) otto.Value {
+			itemid := int32(argInt(call, 0, 0))
+			amount := argInt(call, 1, 1)
+
+			var ok bool
+			var err error
+
+			if amount < 0 {
+				ok, err = con.RemoveItemById(itemid, int32(-amount))
+			} else {
+				ok, err = con.GainItemById(itemid, int16(amount))
+			}
+
+			check(err)
+			return toValue(vm, ok)
+		},
+
+		"haveItem": func(call otto.FunctionCall) otto.Value {
+			itemid := int32(argInt(call, 0, 0))
+			return toValue(vm, con.ItemCount(itemid) >= int32(argInt(call, 1, 1)))
+		},
+
+		"canHold": func(call otto.FunctionCall) otto.Value {
+			itemid := int32(argInt(call, 0, 0))
+			return toValue(vm, con.CanHold(itemid, int16(argInt(call, 1, 1))))
+		},
+
+		"gainMeso": func(call otto.FunctionCall) otto.Value {
+			ok, err := con.GainMeso(int32(argInt(call, 0, 0)), true)
+			check(err)
+			return toValue(vm, ok)
+		},
+
+		"gainExp": func(call otto.FunctionCall) otto.Value {
+			check(con.GainExp(int32(argInt(call, 0, 0)), true, true, true))
+			return otto.UndefinedValue()
+		},
+
+		"canAdvanceJob": func(call otto.FunctionCall) otto.Value {
+			ok, _ := con.CanAdvanceJob(int16(argInt(call, 0, 0)))
+			return toValue(vm, ok)
+		},
+
+		// advanceJob(job) advances the player if the job requirements are met
+		"advanceJob": func(call otto.FunctionCall) otto.Value {
+			ok, err := con.AdvanceJob(int16(argInt(call, 0, 0)))
+			check(err)
+			return toValue(vm, ok)
+		},
+
+		// teachSkill(id, level, masterLevel) is used by 4th job npcs to unlock skills
+		"teachSkill": func(call otto.FunctionCall) otto.Value {
+			skillid := int32(argInt(call, 0, 0))
+			check(con.SetSkillLevel(skillid, byte(argInt(call, 1, 0)), byte(argInt(call, 2, 0))))
+			check(con.SaveSkills())
+			return otto.UndefinedValue()
+		},
+
+		// message(text) shows a pink message in the chat
+		"message": func(call otto.FunctionCall) otto.Value {
+			check(con.SendPacket(packets.ServerMessage(packets.ServerMessagePinkText,
+				0, argString(call, 0), false, false)))
+			return otto.UndefinedValue()
+		},
+
+		// quests started or completed by scripts skip the npc check and don't send
+		// the npc quest result
+		"startQuest": func(call otto.FunctionCall) otto.Value {
+			ok, err := con.StartQuest(int16(argInt(call, 0, 0)), -1)
+			check(err)
+			return toValue(vm, ok)
+		},
+
+		"completeQuest": func(call otto.FunctionCall) otto.Value {
+			ok, err := con.CompleteQuest(int16(argInt(call, 0, 0)), -1)
+			check(err)
+			return toValue(vm, ok)
+		},
+
+		"forfeitQuest": func(call otto.FunctionCall) otto.Value {
+			ok, err := con.ForfeitQuest(int16(argInt(call, 0, 0)))
+			check(err)
+			return toValue(vm, ok)
+		},
+
+		"isQuestStarted": func(call otto.FunctionCall) otto.Value {
+			state := con.QuestState(int16(argInt(call, 0, 0)))
+			return toValue(vm, state == gamedata.QuestStarted)
+		},
+
+		"isQuestCompleted": func(call otto.FunctionCall) otto.Value {
+			state := con.QuestState(int16(argInt(call, 0, 0)))
+			return toValue(vm, state == gamedata.QuestCompleted)
+		},
+
+		"getQuestProgress": func(call otto.FunctionCall) otto.Value {
+			status := con.Quest(int16(argInt(call, 0, 0)))
+			if status == nil {
+				return toValue(vm, "")
+			}
+			return toValue(vm, status.Progress())
+		},
+
+		"setQuestProgress": func(call otto.FunctionCall) otto.Value {
+			check(con.SetQuestProgress(int16(argInt(call, 0, 0)), argString(call, 1)))
+			return otto.UndefinedValue()
+		},
+	}
+
+	for k, v := range fns {
+		api[k] = v
+	}
+}
@@ -0,0 +1,78 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package scripting
+
+import (
+	"fmt"
+	"time"
+)
+
+import (
+	"github.com/Francesco149/kagami/channelserver/client"
+	"github.com/Francesco149/kagami/channelserver/gamedata"
+	"github.com/robertkrimen/otto"
+)
+
+// EnterPortal runs the script of the given portal, which is stored as
+// portal/<script name>.js. Portal scripts run synchronously and decide where
+// the player goes, if anywhere, through the pi object.
+// ok is false if the script doesn't exist or the player wasn't warped.
+func EnterPortal(con *client.Connection, portal gamedata.MaplePortal) (ok bool, err error) {
+	name := portal.ScriptName()
+	if !Exists("portal", name) {
+		fmt.Println("No script for portal", portal.Name(), name)
+		return
+	}
+
+	vm := otto.New()
+	vm.Interrupt = make(chan func(), 1)
+
+	script, err := load(vm, "portal", name)
+	if err != nil {
+		return
+	}
+
+	startmap := con.Map()
+	api := map[string]interface{}{
+		"getPortal": func(call otto.FunctionCall) otto.Value { return toValue(vm, portal.Name()) },
+	}
+	addPlayerApi(api, vm, con)
+
+	if err = vm.Set("pi", api); err != nil {
+		return
+	}
+
+	done := make(chan bool)
+	defer close(done)
+
+	go func() {
+		select {
+		case <-done:
+		case <-time.After(scriptTimeout):
+			vm.Interrupt <- func() { panic(errTimeout) }
+		}
+	}()
+
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Println("Portal script", name, "stopped:", r)
+		}
+		ok = con.Map() != startmap
+	}()
+
+	_, err = vm.Run(script)
+	return
+}
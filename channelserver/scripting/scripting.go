@@ -0,0 +1,129 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// Package scripting runs the javascript files that define the behaviour of NPCs and
+// other scripted entities
+package scripting
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+import "github.com/robertkrimen/otto"
+
+// ScriptDir is the directory that contains the scripts.
+// NPC scripts are stored as npc/<npc id>.js and portal scripts as
+// portal/<script name>.js
+var ScriptDir = "scripts"
+
+// scriptTimeout is the maximum amount of time a script can run without waiting for
+// input from the player before it's killed
+const scriptTimeout = 5 * time.Second
+
+type cachedScript struct {
+	script  *otto.Script
+	modTime time.Time
+}
+
+var cache = make(map[string]*cachedScript)
+var cacheMut sync.Mutex
+
+// scriptPath returns the path of the given script
+func scriptPath(kind, name string) string {
+	return filepath.Join(ScriptDir, kind, name+".js")
+}
+
+// Exists returns true if the given script file exists
+func Exists(kind, name string) bool {
+	_, err := os.Stat(scriptPath(kind, name))
+	return err == nil
+}
+
+// load compiles the given script or returns the cached version. Scripts are compiled
+// again when the file changes, so they can be edited while the server is running.
+func load(vm *otto.Otto, kind, name string) (res *otto.Script, err error) {
+	path := scriptPath(kind, name)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+
+	cacheMut.Lock()
+	defer cacheMut.Unlock()
+
+	cached := cache[path]
+	if cached != nil && cached.modTime.Equal(info.ModTime()) {
+		return cached.script, nil
+	}
+
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	res, err = vm.Compile(path, src)
+	if err != nil {
+		return
+	}
+
+	if cached != nil {
+		fmt.Println("Reloaded", path)
+	}
+
+	cache[path] = &cachedScript{script: res, modTime: info.ModTime()}
+	return
+}
+
+// Reload clears the script cache
+func Reload() {
+	cacheMut.Lock()
+	cache = make(map[string]*cachedScript)
+	cacheMut.Unlock()
+}
+
+// argInt returns the i-th argument of a script function call as an integer or def
+// if it wasn't passed
+func argInt(call otto.FunctionCall, i int, def int64) int64 {
+	arg := call.Argument(i)
+	if !arg.IsDefined() {
+		return def
+	}
+
+	res, err := arg.ToInteger()
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+// argString returns the i-th argument of a script function call as a string
+func argString(call otto.FunctionCall, i int) string {
+	return call.Argument(i).String()
+}
+
+// toValue converts a go value to a script value
+func toValue(vm *otto.Otto, v interface{}) otto.Value {
+	res, err := vm.ToValue(v)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
@@ -0,0 +1,60 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package scripting
+
+import (
+	"github.com/Francesco149/kagami/channelserver/client"
+	"github.com/robertkrimen/otto"
+)
+
+// addStorageApi adds the functions used by the storage npcs to a script api object
+func addStorageApi(api map[string]interface{}, vm *otto.Otto, con *client.Connection, npcid int32) {
+	fns := map[string]interface{}{
+		// openStorage() opens the account storage window
+		"openStorage": func(call otto.FunctionCall) otto.Value {
+			check(con.OpenStorage(npcid))
+			return otto.UndefinedValue()
+		},
+
+		"getStorageSlots": func(call otto.FunctionCall) otto.Value {
+			s, err := con.Storage()
+			check(err)
+			return toValue(vm, s.Slots())
+		},
+
+		"getMaxStorageSlots": func(call otto.FunctionCall) otto.Value {
+			return toValue(vm, client.MaxStorageSlots)
+		},
+
+		// expandStorage(slots, fee) adds slots to the storage for the given mesos and
+		// returns false if it would go over the maximum amount of slots or if the player
+		// can't pay
+		"expandStorage": func(call otto.FunctionCall) otto.Value {
+			slots := argInt(call, 0, 0)
+			if slots <= 0 || slots > client.MaxStorageSlots {
+				return toValue(vm, false)
+			}
+
+			ok, err := con.ExpandStorage(byte(slots), int32(argInt(call, 1, 0)))
+			check(err)
+			return toValue(vm, ok)
+		},
+	}
+
+	for k, v := range fns {
+		api[k] = v
+	}
+}
@@ -0,0 +1,22 @@
+// Mr. Wang - Henesys storage keeper
+
+var expandSlots = 4;
+var expandCost = 100000;
+
+var choice = cm.sendSimple("I'll keep your belongings safe. Every character of your " +
+	"account in this world shares the same storage.\r\n" +
+	"#L0#Open my storage#l\r\n" +
+	"#L1#Expand my storage#l");
+
+if (choice == 0) {
+	cm.openStorage();
+} else if (cm.getStorageSlots() + expandSlots > cm.getMaxStorageSlots()) {
+	cm.sendOk("Your storage can't get any bigger.");
+} else if (cm.sendYesNo("Adding " + expandSlots + " slots to your storage costs #b" +
+	expandCost + " mesos#k. Do you want to go ahead?")) {
+	if (cm.getMeso() < expandCost) {
+		cm.sendOk("You don't have enough mesos.");
+	} else if (cm.expandStorage(expandSlots, expandCost)) {
+		cm.sendOk("Your storage now has #b" + cm.getStorageSlots() + " slots#k.");
+	}
+}
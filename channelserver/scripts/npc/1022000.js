@@ -0,0 +1,32 @@
+// Dances with Balrog - warrior job instructor
+
+var job = cm.getJob();
+
+if (job == 0) {
+	if (!cm.canAdvanceJob(100)) {
+		cm.sendOk("Come back when you're at least level 10 with 35 STR.");
+	} else if (cm.sendYesNo("Do you want to become a #bWarrior#k?")) {
+		if (cm.advanceJob(100)) {
+			cm.sendOk("You're a Warrior now! Use the SP you've been given wisely.");
+		} else {
+			cm.sendOk("You can't become a Warrior right now.");
+		}
+	}
+} else if (job == 100) {
+	if (!cm.canAdvanceJob(110)) {
+		cm.sendOk("Come back when you've reached level 30.");
+	} else {
+		var choice = cm.sendSimple("Which path will you take?\r\n" +
+			"#L0#Fighter#l\r\n" +
+			"#L1#Page#l\r\n" +
+			"#L2#Spearman#l");
+
+		if (cm.advanceJob(110 + choice * 10)) {
+			cm.sendOk("Congratulations on your advancement!");
+		} else {
+			cm.sendOk("You can't take that path right now.");
+		}
+	}
+} else {
+	cm.sendOk("Keep training, young warrior.");
+}
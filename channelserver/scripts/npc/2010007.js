@@ -0,0 +1,43 @@
+// Heracle - guild headquarters
+
+var choice = cm.sendSimple("What would you like to do?\r\n" +
+	"#L0#Create a guild#l\r\n" +
+	"#L1#Increase the guild capacity#l\r\n" +
+	"#L2#Disband the guild#l\r\n" +
+	"#L3#Change the guild emblem#l");
+
+if (choice == 0) {
+	if (cm.getGuildId() != 0) {
+		cm.sendOk("You're already in a guild.");
+	} else if (cm.getMeso() < 1500000) {
+		cm.sendOk("Creating a guild costs #b1,500,000 mesos#k.");
+	} else if (cm.sendYesNo("Creating a guild costs #b1,500,000 mesos#k. Do you want to go ahead?")) {
+		cm.openGuildCreate();
+	}
+} else if (choice == 1) {
+	var cost = cm.getGuildCapacityCost();
+
+	if (!cm.isGuildLeader()) {
+		cm.sendOk("Only the guild master can increase the capacity.");
+	} else if (cm.getGuildCapacity() >= 100) {
+		cm.sendOk("Your guild can't grow any further.");
+	} else if (cm.sendYesNo("Adding 5 slots costs #b" + cost + " mesos#k. Do you want to go ahead?")) {
+		if (!cm.increaseGuildCapacity()) {
+			cm.sendOk("You don't have enough mesos.");
+		}
+	}
+} else if (choice == 2) {
+	if (!cm.isGuildLeader()) {
+		cm.sendOk("Only the guild master can disband the guild.");
+	} else if (cm.sendYesNo("Do you really want to disband your guild? This can't be undone.")) {
+		cm.disbandGuild();
+	}
+} else {
+	if (!cm.isGuildLeader()) {
+		cm.sendOk("Only the guild master can change the emblem.");
+	} else if (cm.getMeso() < 5000000) {
+		cm.sendOk("Changing the emblem costs #b5,000,000 mesos#k.");
+	} else {
+		cm.openGuildEmblem();
+	}
+}
@@ -0,0 +1,29 @@
+// Maple Administrator - example npc script
+// scripts run from top to bottom and every send* call waits for the player to reply
+
+cm.sendNext("Hello, #b" + cm.getName() + "#k! I'm the Maple Administrator.");
+
+var choice = cm.sendSimple("What can I do for you?\r\n" +
+	"#L0#Take me to Henesys#l\r\n" +
+	"#L1#I'd like some potions#l\r\n" +
+	"#L2#Nothing, thanks#l");
+
+if (choice == 0) {
+	if (cm.sendYesNo("Do you really want to go to #bHenesys#k?")) {
+		cm.warp(100000000, 0);
+	}
+} else if (choice == 1) {
+	var amount = cm.sendGetNumber("How many #t2000000# do you want? They're 10 mesos each.", 1, 1, 100);
+
+	if (cm.getMeso() < amount * 10) {
+		cm.sendOk("You don't have enough mesos.");
+	} else if (!cm.canHold(2000000, amount)) {
+		cm.sendOk("Please make some room in your use inventory first.");
+	} else {
+		cm.gainMeso(-amount * 10);
+		cm.gainItem(2000000, amount);
+		cm.sendOk("Here you go!");
+	}
+} else {
+	cm.sendOk("Have a nice day!");
+}
@@ -0,0 +1,8 @@
+// Free Market entrance - example portal script
+// sends the player to the free market. players below level 10 aren't allowed in.
+
+if (pi.getLevel() < 10) {
+	pi.message("You must be at least level 10 to enter the Free Market.");
+} else {
+	pi.warp(910000000, "out00");
+}
@@ -0,0 +1,10 @@
+// example portal script that sends the player to a random spawn point of a
+// map, but only if there's nobody else in it
+
+var target = 100000000;
+
+if (pi.getPlayerCount(target) > 0) {
+	pi.message("Someone is already in there.");
+} else {
+	pi.warpRandom(target);
+}
@@ -33,6 +33,11 @@ type CharEquipData struct {
 	slot int16
 }
 
+// NewCharEquipData initializes an equip entry with the given item id and (negative) slot
+func NewCharEquipData(id int32, slot int16) *CharEquipData {
+	return &CharEquipData{id: id, slot: slot}
+}
+
 // GetCharEquipsFromDB retrieves all of the given character's equips from
 // the database and returns them as an array
 func GetCharEquipsFromDB(characterId int32) (res []*CharEquipData, err error) {
@@ -112,6 +117,21 @@ func (this *CharStats) Face() int32  { return this.face }
 func (this *CharStats) Hair() int32  { return this.hair }
 
 func (this *CharStats) SetMapId(v int32) { this.mapp = v }
+func (this *CharStats) SetLevel(v byte)  { this.level = v }
+func (this *CharStats) SetJob(v int16)   { this.job = v }
+func (this *CharStats) SetStr(v int16)   { this.str = v }
+func (this *CharStats) SetDex(v int16)   { this.dex = v }
+func (this *CharStats) SetInt(v int16)   { this.intt = v }
+func (this *CharStats) SetLuk(v int16)   { this.luk = v }
+func (this *CharStats) SetHp(v int16)    { this.hp = v }
+func (this *CharStats) SetMaxHp(v int16) { this.maxhp = v }
+func (this *CharStats) SetMp(v int16)    { this.mp = v }
+func (this *CharStats) SetMaxMp(v int16) { this.maxmp = v }
+func (this *CharStats) SetAp(v int16)    { this.ap = v }
+func (this *CharStats) SetSp(v int16)    { this.sp = v }
+func (this *CharStats) SetExp(v int32)   { this.exp = v }
+func (this *CharStats) SetFame(v int16)  { this.fame = v }
+func (this *CharStats) SetPos(v int8)    { this.pos = v }
 
 func (this *CharStats) String() string {
 	return fmt.Sprintf(
@@ -278,11 +298,16 @@ func (c *CharData) Stats() *CharStats { return c.CharStats }
 
 // EncodeEquips serializes the character's equips to a packet
 func (c *CharData) EncodeEquips(p *maplelib.Packet) {
-	p.Encode1s(c.Stats().Gender()) // yes it repeats gender, skin, face, hair and idk why
-	p.Encode1s(c.Stats().Skin())
-	p.Encode4s(c.Stats().Face())
+	EncodeLook(p, c.Stats(), c.equips)
+}
+
+// EncodeLook serializes a character's appearance with the given equips to a packet
+func EncodeLook(p *maplelib.Packet, stats *CharStats, equips []*CharEquipData) {
+	p.Encode1s(stats.Gender()) // yes it repeats gender, skin, face, hair and idk why
+	p.Encode1s(stats.Skin())
+	p.Encode4s(stats.Face())
 	p.Encode1(0x00)
-	p.Encode4s(c.Stats().Hair())
+	p.Encode4s(stats.Hair())
 
 	// I'm not sure how this all works but it's some logic to encode
 	// equips in such a way that the client can determine which ones are
@@ -293,7 +318,7 @@ func (c *CharData) EncodeEquips(p *maplelib.Packet) {
 
 	var equipmap [consts.EquippedSlots][2]int32
 
-	for _, equip := range c.equips {
+	for _, equip := range equips {
 		slot := -equip.slot
 
 		if slot > 100 {
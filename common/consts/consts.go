@@ -245,3 +245,32 @@ var WorldMaxZakumAttempts = [WorldCount]int16{2, 2}
 
 // WorldMaxHorntailAttempts contains a list of the maximum Pianus attempts allowed on each world, -1 = unlimited
 var WorldMaxHorntailAttempts = [WorldCount]int16{-1, -1}
+
+const KeymapSize = 90    // KeymapSize is the number of keys in the keyboard settings
+const MaxSkillMacros = 5 // MaxSkillMacros is the number of skill macro slots
+
+// Key binding types
+const (
+	KeyTypeNone   = 0
+	KeyTypeSkill  = 1
+	KeyTypeItem   = 2
+	KeyTypeFace   = 3
+	KeyTypeMenu   = 4
+	KeyTypeAction = 5
+	KeyTypeFaceEx = 6
+	KeyTypeMacro  = 8
+)
+
+// DefaultKeymap is the keyboard layout of new characters as key, type, action
+var DefaultKeymap = [][3]int32{
+	{18, KeyTypeMenu, 0}, {65, KeyTypeFaceEx, 106}, {2, KeyTypeMenu, 10}, {23, KeyTypeMenu, 1},
+	{3, KeyTypeMenu, 12}, {4, KeyTypeMenu, 13}, {5, KeyTypeMenu, 18}, {6, KeyTypeMenu, 24},
+	{16, KeyTypeMenu, 8}, {17, KeyTypeMenu, 5}, {19, KeyTypeMenu, 4}, {25, KeyTypeMenu, 19},
+	{26, KeyTypeMenu, 14}, {27, KeyTypeAction, 15}, {31, KeyTypeAction, 2}, {34, KeyTypeMenu, 17},
+	{35, KeyTypeMenu, 11}, {37, KeyTypeAction, 3}, {38, KeyTypeMenu, 20}, {40, KeyTypeMenu, 16},
+	{43, KeyTypeMenu, 9}, {44, KeyTypeAction, 50}, {45, KeyTypeMenu, 51}, {46, KeyTypeMenu, 6},
+	{50, KeyTypeMenu, 7}, {56, KeyTypeAction, 53}, {59, KeyTypeFaceEx, 100}, {60, KeyTypeFaceEx, 101},
+	{61, KeyTypeFaceEx, 102}, {62, KeyTypeFaceEx, 103}, {63, KeyTypeFaceEx, 104}, {64, KeyTypeFaceEx, 105},
+	{57, KeyTypeAction, 54}, {48, KeyTypeMenu, 22}, {29, KeyTypeAction, 52}, {7, KeyTypeMenu, 21},
+	{24, KeyTypeMenu, 25}, {33, KeyTypeMenu, 26}, {41, KeyTypeMenu, 23}, {39, KeyTypeMenu, 27},
+}
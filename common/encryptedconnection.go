@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"sync"
 	"time"
 )
 
@@ -62,6 +63,7 @@ type EncryptedConnection struct {
 	lastping   int64
 	lastactive int64 // unused in client mode
 	isclient   bool
+	sendmut    sync.Mutex // packets can be sent from other connections' threads
 }
 
 func (c *EncryptedConnection) IsClient() bool {
@@ -338,6 +340,9 @@ func (c *EncryptedConnection) RecvPacket() (packet maplelib.Packet, err error) {
 // SendPacket encrypts and sends the given packet. NOTE: the packet must have
 // a 4 byte placeholder at the beginning for the encrypted header
 func (c *EncryptedConnection) SendPacket(p maplelib.Packet) error {
+	c.sendmut.Lock()
+	defer c.sendmut.Unlock()
+
 	if debugPackets {
 		fmt.Println(c.Conn().RemoteAddr(), "->", p)
 	}
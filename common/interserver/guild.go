@@ -0,0 +1,236 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package interserver
+
+import (
+	"github.com/Francesco149/kagami/common/packets"
+	"github.com/Francesco149/maplelib"
+)
+
+// Guild limits
+const (
+	GuildMinCapacity = 10
+	GuildMaxCapacity = 100
+	GuildRanks       = 5
+)
+
+// Guild ranks
+const (
+	GuildRankMaster   = 1
+	GuildRankJrMaster = 2
+	GuildRankMember   = 3
+	GuildRankLowest   = GuildRanks
+)
+
+// Guild operations requested by channels through GuildOperation()
+const (
+	GuildOpCreate   = 0 // strs: name
+	GuildOpInvite   = 1 // strs: invited name
+	GuildOpJoin     = 2 // target: guild id
+	GuildOpLeave    = 3
+	GuildOpExpel    = 4 // target: expelled member
+	GuildOpTitles   = 5 // strs: the 5 rank titles
+	GuildOpRank     = 6 // target: member, args: new rank
+	GuildOpEmblem   = 7 // args: background, background color, logo, logo color
+	GuildOpNotice   = 8 // strs: notice
+	GuildOpCapacity = 9
+	GuildOpDisband  = 10
+)
+
+// Guild changes pushed by the worldserver through SyncGuild(). target is the member
+// that caused the change.
+const (
+	GuildSyncCreate   = 0
+	GuildSyncJoin     = 1
+	GuildSyncLeave    = 2
+	GuildSyncExpel    = 3
+	GuildSyncDisband  = 4
+	GuildSyncTitles   = 5
+	GuildSyncRank     = 6
+	GuildSyncEmblem   = 7
+	GuildSyncNotice   = 8
+	GuildSyncCapacity = 9
+	GuildSyncOnline   = 10 // target logged in or out
+	GuildSyncMember   = 11 // target's job or level changed
+)
+
+// GuildMember is a member of a guild
+type GuildMember struct {
+	CharId int32
+	Name   string
+	Job    int16
+	Level  byte
+	Rank   byte
+	Online bool
+}
+
+// Guild is a persistent group of players owned by the worldserver
+type Guild struct {
+	Id          int32
+	Name        string
+	Leader      int32
+	Notice      string
+	Titles      [GuildRanks]string
+	Capacity    byte
+	EmblemBg    int16
+	EmblemBgCol byte
+	Emblem      int16
+	EmblemCol   byte
+	Points      int32
+	Members     []*GuildMember
+}
+
+// Member returns the member with the given character id or nil
+func (this *Guild) Member(charid int32) *GuildMember {
+	for _, m := range this.Members {
+		if m.CharId == charid {
+			return m
+		}
+	}
+	return nil
+}
+
+// MemberIds returns the character ids of all the members
+func (this *Guild) MemberIds() (res []int32) {
+	for _, m := range this.Members {
+		res = append(res, m.CharId)
+	}
+	return
+}
+
+// Encode writes the guild to an inter-server packet
+func (this *Guild) Encode(p *maplelib.Packet) {
+	p.Encode4s(this.Id)
+	p.EncodeString(this.Name)
+	p.Encode4s(this.Leader)
+	p.EncodeString(this.Notice)
+
+	for _, title := range this.Titles {
+		p.EncodeString(title)
+	}
+
+	p.Encode1(this.Capacity)
+	p.Encode2s(this.EmblemBg)
+	p.Encode1(this.EmblemBgCol)
+	p.Encode2s(this.Emblem)
+	p.Encode1(this.EmblemCol)
+	p.Encode4s(this.Points)
+	p.Encode1(byte(len(this.Members)))
+
+	for _, m := range this.Members {
+		p.Encode4s(m.CharId)
+		p.EncodeString(m.Name)
+		p.Encode2s(m.Job)
+		p.Encode1(m.Level)
+		p.Encode1(m.Rank)
+
+		if m.Online {
+			p.Encode1(0x01)
+		} else {
+			p.Encode1(0x00)
+		}
+	}
+}
+
+// DecodeGuild reads a guild encoded by Guild.Encode
+func DecodeGuild(it *maplelib.PacketIterator) (res *Guild, err error) {
+	res = &Guild{}
+	res.Id, err = it.Decode4s()
+	res.Name, err = it.DecodeString()
+	res.Leader, err = it.Decode4s()
+	res.Notice, err = it.DecodeString()
+
+	for i := range res.Titles {
+		res.Titles[i], err = it.DecodeString()
+	}
+
+	res.Capacity, err = it.Decode1()
+	res.EmblemBg, err = it.Decode2s()
+	res.EmblemBgCol, err = it.Decode1()
+	res.Emblem, err = it.Decode2s()
+	res.EmblemCol, err = it.Decode1()
+	res.Points, err = it.Decode4s()
+	count, err := it.Decode1()
+	if err != nil {
+		return
+	}
+
+	for i := byte(0); i < count; i++ {
+		m := &GuildMember{}
+		m.CharId, err = it.Decode4s()
+		m.Name, err = it.DecodeString()
+		m.Job, err = it.Decode2s()
+		m.Level, err = it.Decode1()
+		m.Rank, err = it.Decode1()
+		online, err := it.Decode1()
+		if err != nil {
+			return res, err
+		}
+		m.Online = online != 0
+		res.Members = append(res.Members, m)
+	}
+
+	return
+}
+
+// GuildOperation returns a packet that requests a guild operation to the worldserver.
+// See the GuildOp constants for the meaning of target, args and strs.
+func GuildOperation(op byte, charid, target int32, args []int32, strs []string) (p maplelib.Packet) {
+	p = packets.NewEncryptedPacket(IOGuildOperation)
+	p.Encode1(op)
+	p.Encode4s(charid)
+	p.Encode4s(target)
+
+	p.Encode1(byte(len(args)))
+	for _, arg := range args {
+		p.Encode4s(arg)
+	}
+
+	p.Encode1(byte(len(strs)))
+	for _, str := range strs {
+		p.EncodeString(str)
+	}
+	return
+}
+
+// SyncGuild returns a packet that notifies a channel that a guild has changed.
+// On leave and expel the guild no longer contains the target, so its name is sent
+// separately.
+func SyncGuild(op byte, guild *Guild, target int32, targetName string) (p maplelib.Packet) {
+	p = packets.NewEncryptedPacket(IOSyncGuild)
+	p.Encode1(op)
+	p.Encode4s(target)
+	p.EncodeString(targetName)
+	guild.Encode(&p)
+	return
+}
+
+// GuildRefused returns a packet that notifies a channel that a paid guild operation
+// requested by one of its players didn't go through
+func GuildRefused(charid int32, op byte) (p maplelib.Packet) {
+	p = packets.NewEncryptedPacket(IOGuildRefused)
+	p.Encode4s(charid)
+	p.Encode1(op)
+	return
+}
+
+// SyncCharacterDeleted returns a packet that notifies the worldserver that a
+// character has been deleted
+func SyncCharacterDeleted(charid int32) (p maplelib.Packet) {
+	p = packets.NewEncryptedPacket(IOSyncCharacterDeleted)
+	p.Encode4s(charid)
+	return
+}
@@ -29,4 +29,15 @@ const (
 	IOSyncChannelPopulation   = 0x1010
 	IOMessageToChannel        = 0x1011
 	IOPlayerJoiningChannel    = 0x1012
+	IOSyncPlayerUpdate        = 0x1013
+	IOMessageToPlayers        = 0x1014
+	IOPartyOperation          = 0x1015
+	IOSyncParty               = 0x1016
+	IOBuddyNotify             = 0x1017
+	IOGuildOperation          = 0x1018
+	IOSyncGuild               = 0x1019
+	IOSyncCharacterDeleted    = 0x1020
+	IORehash                  = 0x1021
+	IOSyncWorldConf           = 0x1022
+	IOGuildRefused            = 0x1023
 )
@@ -16,6 +16,7 @@
 package interserver
 
 import (
+	"github.com/Francesco149/kagami/common/config"
 	"github.com/Francesco149/kagami/common/packets"
 	"github.com/Francesco149/maplelib"
 )
@@ -68,16 +69,35 @@ func RegisterChannel(channelId int8, ipbytes []byte, channelPort int16) (p maple
 }
 
 // SyncPlayerJoinedChannel returns a packet that notifies the worldserver that a player has joined a channel
-func SyncPlayerJoinedChannel(channelid int8) (p maplelib.Packet) {
+func SyncPlayerJoinedChannel(channelid int8, charid int32, name string,
+	job int16, level byte, mapid int32) (p maplelib.Packet) {
+
 	p = packets.NewEncryptedPacket(IOSyncPlayerJoinedChannel)
 	p.Encode1s(channelid)
+	p.Encode4s(charid)
+	p.EncodeString(name)
+	p.Encode2s(job)
+	p.Encode1(level)
+	p.Encode4s(mapid)
 	return
 }
 
 // SyncPlayerLeftChannel returns a packet that notifies the worldserver that a player has left a channel
-func SyncPlayerLeftChannel(channelid int8) (p maplelib.Packet) {
+func SyncPlayerLeftChannel(channelid int8, charid int32) (p maplelib.Packet) {
 	p = packets.NewEncryptedPacket(IOSyncPlayerLeftChannel)
 	p.Encode1s(channelid)
+	p.Encode4s(charid)
+	return
+}
+
+// SyncPlayerUpdate returns a packet that notifies the worldserver that a player's job,
+// level or map has changed
+func SyncPlayerUpdate(charid int32, job int16, level byte, mapid int32) (p maplelib.Packet) {
+	p = packets.NewEncryptedPacket(IOSyncPlayerUpdate)
+	p.Encode4s(charid)
+	p.Encode2s(job)
+	p.Encode1(level)
+	p.Encode4s(mapid)
 	return
 }
 
@@ -98,6 +118,44 @@ func MessageToChannel(chanid int8, packet maplelib.Packet) (p maplelib.Packet) {
 	return
 }
 
+// MessageToPlayers returns a packet that must be relayed to the given characters
+// wherever they are. It's sent by channels to the worldserver, which forwards it to
+// the channels the characters are on.
+func MessageToPlayers(charids []int32, packet maplelib.Packet) (p maplelib.Packet) {
+	p = packets.NewEncryptedPacket(IOMessageToPlayers)
+	p.Encode2(uint16(len(charids)))
+	for _, charid := range charids {
+		p.Encode4s(charid)
+	}
+	p.Append([]byte(packet))
+	return
+}
+
+// BuddyNotify returns a packet that tells the given buddies which channel a player is
+// on (-1 if offline). It's sent by channels to the worldserver, which forwards it to
+// the channels the buddies are on. reply is false if the buddies must answer with
+// their own channel.
+func BuddyNotify(charid int32, name string, channel int8, reply bool,
+	buddies []int32) (p maplelib.Packet) {
+
+	p = packets.NewEncryptedPacket(IOBuddyNotify)
+	p.Encode4s(charid)
+	p.EncodeString(name)
+	p.Encode1s(channel)
+
+	if reply {
+		p.Encode1(0x01)
+	} else {
+		p.Encode1(0x00)
+	}
+
+	p.Encode2(uint16(len(buddies)))
+	for _, buddy := range buddies {
+		p.Encode4s(buddy)
+	}
+	return
+}
+
 // PlayerJoiningChannel returns a packet that notifies the channel server that a player is joining
 func PlayerJoiningChannel(charid int32, ip []byte) (p maplelib.Packet) {
 	p = packets.NewEncryptedPacket(IOPlayerJoiningChannel)
@@ -105,3 +163,18 @@ func PlayerJoiningChannel(charid int32, ip []byte) (p maplelib.Packet) {
 	p.EncodeBuffer(ip)
 	return
 }
+
+// Rehash returns a packet that asks the loginserver to reload the world configs.
+// Channels send it to the worldserver, which forwards it to the loginserver.
+func Rehash() (p maplelib.Packet) {
+	p = packets.NewEncryptedPacket(IORehash)
+	return
+}
+
+// SyncWorldConf returns a packet that replaces the world config of a worldserver or
+// channelserver after a rehash
+func SyncWorldConf(conf *config.WorldConf) (p maplelib.Packet) {
+	p = packets.NewEncryptedPacket(IOSyncWorldConf)
+	conf.Encode(&p)
+	return
+}
@@ -0,0 +1,149 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package interserver
+
+import (
+	"github.com/Francesco149/kagami/common/packets"
+	"github.com/Francesco149/maplelib"
+)
+
+// MaxPartySize is the maximum number of members in a party
+const MaxPartySize = 6
+
+// Party operations requested by channels through PartyOperation().
+// They match the operations sent by the client.
+const (
+	PartyOpCreate = 1
+	PartyOpLeave  = 2
+	PartyOpJoin   = 3
+	PartyOpInvite = 4
+	PartyOpExpel  = 5
+	PartyOpLeader = 6
+)
+
+// Party changes pushed by the worldserver through SyncParty()
+const (
+	PartySyncCreate  = 0
+	PartySyncJoin    = 1
+	PartySyncLeave   = 2
+	PartySyncExpel   = 3
+	PartySyncDisband = 4
+	PartySyncLeader  = 5
+	PartySyncUpdate  = 6 // a member changed channel, map, level or job
+)
+
+// PartyMember is a member of a party as seen by the worldserver
+type PartyMember struct {
+	CharId  int32
+	Name    string
+	Job     int16
+	Level   byte
+	Channel int8 // -1 if offline
+	MapId   int32
+}
+
+// Party is a group of players owned by the worldserver
+type Party struct {
+	Id      int32
+	Leader  int32
+	Members []*PartyMember
+}
+
+// Member returns the member with the given character id or nil
+func (this *Party) Member(charid int32) *PartyMember {
+	for _, m := range this.Members {
+		if m.CharId == charid {
+			return m
+		}
+	}
+	return nil
+}
+
+// MemberIds returns the character ids of all the members
+func (this *Party) MemberIds() (res []int32) {
+	for _, m := range this.Members {
+		res = append(res, m.CharId)
+	}
+	return
+}
+
+// Encode writes the party to an inter-server packet
+func (this *Party) Encode(p *maplelib.Packet) {
+	p.Encode4s(this.Id)
+	p.Encode4s(this.Leader)
+	p.Encode1(byte(len(this.Members)))
+
+	for _, m := range this.Members {
+		p.Encode4s(m.CharId)
+		p.EncodeString(m.Name)
+		p.Encode2s(m.Job)
+		p.Encode1(m.Level)
+		p.Encode1s(m.Channel)
+		p.Encode4s(m.MapId)
+	}
+}
+
+// DecodeParty reads a party encoded by Party.Encode
+func DecodeParty(it *maplelib.PacketIterator) (res *Party, err error) {
+	res = &Party{}
+	res.Id, err = it.Decode4s()
+	res.Leader, err = it.Decode4s()
+	count, err := it.Decode1()
+	if err != nil {
+		return
+	}
+
+	for i := byte(0); i < count; i++ {
+		m := &PartyMember{}
+		m.CharId, err = it.Decode4s()
+		m.Name, err = it.DecodeString()
+		m.Job, err = it.Decode2s()
+		m.Level, err = it.Decode1()
+		m.Channel, err = it.Decode1s()
+		m.MapId, err = it.Decode4s()
+		if err != nil {
+			return
+		}
+		res.Members = append(res.Members, m)
+	}
+
+	return
+}
+
+// PartyOperation returns a packet that requests a party operation to the worldserver.
+// target is the character id of the expelled member or new leader, or the party id
+// when joining. name is the name of the invited player.
+func PartyOperation(op byte, charid int32, target int32, name string) (p maplelib.Packet) {
+	p = packets.NewEncryptedPacket(IOPartyOperation)
+	p.Encode1(op)
+	p.Encode4s(charid)
+	p.Encode4s(target)
+	p.EncodeString(name)
+	return
+}
+
+// SyncParty returns a packet that notifies a channel that a party has changed.
+// target is the member that joined, left, was expelled or became leader.
+// On leave and expel the party no longer contains the target, so its name is sent
+// separately.
+func SyncParty(op byte, party *Party, target int32, targetName string) (p maplelib.Packet) {
+	p = packets.NewEncryptedPacket(IOSyncParty)
+	p.Encode1(op)
+	p.Encode4s(target)
+	p.EncodeString(targetName)
+	party.Encode(&p)
+	return
+}
@@ -0,0 +1,40 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package common
+
+import (
+	"github.com/Francesco149/kagami/common/consts"
+	"github.com/ziutek/mymysql/mysql"
+)
+
+// CreateDefaultKeymap saves the default keyboard layout for a newly created character
+// on the given connection or transaction
+func CreateDefaultKeymap(db mysql.ConnCommon, charid int32) (err error) {
+	st, err := db.Prepare("INSERT INTO keymap(character_id, `key`, type, action) " +
+		"VALUES(?, ?, ?, ?)")
+	if err != nil {
+		return
+	}
+
+	for _, binding := range consts.DefaultKeymap {
+		_, err = st.Run(charid, binding[0], binding[1], binding[2])
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
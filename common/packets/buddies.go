@@ -0,0 +1,103 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package packets
+
+import "github.com/Francesco149/maplelib"
+
+// ***********************************************************************
+// Buddy list
+
+// Buddy list packet operations
+const (
+	BuddyOpUpdate   = 0x07
+	BuddyOpRequest  = 0x09
+	BuddyOpChannel  = 0x14
+	BuddyOpCapacity = 0x15
+)
+
+// Possible values for BuddyMessage()
+const (
+	BuddyMsgListFull       = 11 // your buddy list is full
+	BuddyMsgTargetListFull = 12 // the other player's buddy list is full
+	BuddyMsgAlreadyAdded   = 13 // that character is already registered as your buddy
+	BuddyMsgNotFound       = 15 // that character is not registered
+)
+
+// BuddyEntry is a character in a buddy list
+type BuddyEntry struct {
+	CharId  int32
+	Name    string
+	Channel int8 // -1 if offline
+}
+
+// BuddyList returns a packet that sends the whole buddy list
+func BuddyList(buddies []BuddyEntry) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OBuddyList)
+	p.Encode1(BuddyOpUpdate)
+	p.Encode1(byte(len(buddies)))
+
+	for _, buddy := range buddies {
+		p.Encode4s(buddy.CharId)
+		EncodePaddedString(&p, buddy.Name, 13)
+		p.Encode1(0x00) // what the hell is this
+		p.Encode4s(int32(buddy.Channel))
+	}
+
+	for range buddies {
+		p.Encode4s(0) // what the hell is this
+	}
+
+	return
+}
+
+// BuddyRequest returns a packet that asks the player to accept a buddy request
+func BuddyRequest(charid int32, name string, channel int8) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OBuddyList)
+	p.Encode1(BuddyOpRequest)
+	p.Encode4s(charid)
+	p.EncodeString(name)
+	p.Encode4s(charid)
+	EncodePaddedString(&p, name, 13)
+	p.Encode1(0x01) // what the hell is this
+	p.Encode4s(int32(channel))
+	p.Encode1(0x00) // what the hell is this
+	return
+}
+
+// BuddyChannel returns a packet that updates the channel of a buddy (-1 if offline)
+func BuddyChannel(charid int32, channel int8) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OBuddyList)
+	p.Encode1(BuddyOpChannel)
+	p.Encode4s(charid)
+	p.Encode1(0x00) // what the hell is this
+	p.Encode4s(int32(channel))
+	return
+}
+
+// BuddyCapacity returns a packet that changes the maximum size of the buddy list
+func BuddyCapacity(capacity byte) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OBuddyList)
+	p.Encode1(BuddyOpCapacity)
+	p.Encode1(capacity)
+	return
+}
+
+// BuddyMessage returns a packet that shows one of the BuddyMsg messages
+func BuddyMessage(msg byte) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OBuddyList)
+	p.Encode1(msg)
+	return
+}
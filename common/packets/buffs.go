@@ -0,0 +1,100 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package packets
+
+import "sort"
+
+import (
+	"github.com/Francesco149/kagami/common/utils"
+	"github.com/Francesco149/maplelib"
+)
+
+// ***********************************************************************
+// Buffs
+
+// Possible temporary stat bits for GiveBuff and CancelBuff
+const (
+	BuffWAtk  = 0x00000001
+	BuffWDef  = 0x00000002
+	BuffMAtk  = 0x00000004
+	BuffMDef  = 0x00000008
+	BuffAcc   = 0x00000010
+	BuffAvoid = 0x00000020
+	BuffHands = 0x00000040
+	BuffSpeed = 0x00000080
+	BuffJump  = 0x00000100
+
+	BuffMagicGuard    = 0x00000200
+	BuffDarkSight     = 0x00000400
+	BuffBooster       = 0x00000800
+	BuffPowerGuard    = 0x00001000
+	BuffHyperBodyHp   = 0x00002000
+	BuffHyperBodyMp   = 0x00004000
+	BuffInvincible    = 0x00008000
+	BuffSoulArrow     = 0x00010000
+	BuffStun          = 0x00020000
+	BuffPoison        = 0x00040000
+	BuffSeal          = 0x00080000
+	BuffDarkness      = 0x00100000
+	BuffCombo         = 0x00200000
+	BuffWkCharge      = 0x00400000
+	BuffDragonBlood   = 0x00800000
+	BuffHolySymbol    = 0x01000000
+	BuffMesoUp        = 0x02000000
+	BuffShadowPartner = 0x04000000
+	BuffPickPocket    = 0x08000000
+	BuffMesoGuard     = 0x10000000
+	BuffWeaken        = 0x40000000
+)
+
+// GiveBuff returns a packet that applies temporary stats to the local player.
+// The stats array must contain a pair for each buffed stat. The first element of each
+// pair must be one of the Buff* constants and the second one the int16 value of the stat.
+// sourceid is the skill id or the negative item id that caused the buff and duration
+// is in milliseconds.
+func GiveBuff(sourceid, duration int32, stats []utils.Pair) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OGiveBuff)
+
+	mask := 0
+	for _, pair := range stats {
+		mask |= pair.First.(int)
+	}
+
+	p.Encode8(uint64(mask))
+
+	if len(stats) > 1 {
+		sort.Sort(statSorter(stats))
+	}
+
+	for _, pair := range stats {
+		p.Encode2s(pair.Second.(int16))
+		p.Encode4s(sourceid)
+		p.Encode4s(duration)
+	}
+
+	p.Encode2(0x0000) // what the hell is this
+	p.Encode1(0x00)   // what the hell is this
+	return
+}
+
+// CancelBuff returns a packet that removes the given temporary stats from the local player.
+// mask is a combination of Buff* constants.
+func CancelBuff(mask uint64) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OCancelBuff)
+	p.Encode8(mask)
+	p.Encode1(0x01) // what the hell is this
+	return
+}
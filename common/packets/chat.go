@@ -0,0 +1,39 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package packets
+
+import "github.com/Francesco149/maplelib"
+
+// ***********************************************************************
+// Chat
+
+// ChatText returns a packet that shows a chat message over a player's head.
+// gm shows the message with a white background.
+// show is the flag sent by the client to hide the message from the chat box (macros)
+func ChatText(charid int32, text string, gm bool, show byte) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OChatText)
+	p.Encode4s(charid)
+
+	if gm {
+		p.Encode1(0x01)
+	} else {
+		p.Encode1(0x00)
+	}
+
+	p.EncodeString(text)
+	p.Encode1(show)
+	return
+}
@@ -0,0 +1,51 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package packets
+
+import (
+	"time"
+)
+
+import "github.com/Francesco149/maplelib"
+
+// ***********************************************************************
+// Map clocks
+
+// Clock types
+const (
+	ClockTime  = 1 // current time of the day
+	ClockTimer = 2 // countdown
+)
+
+// Clock returns a packet that shows the current time of the day at the top of the
+// screen
+func Clock(now time.Time) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OClock)
+	p.Encode1(ClockTime)
+	p.Encode1(byte(now.Hour()))
+	p.Encode1(byte(now.Minute()))
+	p.Encode1(byte(now.Second()))
+	return
+}
+
+// Timer returns a packet that shows a countdown of the given amount of seconds at
+// the top of the screen
+func Timer(seconds int32) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OClock)
+	p.Encode1(ClockTimer)
+	p.Encode4s(seconds)
+	return
+}
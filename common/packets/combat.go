@@ -0,0 +1,114 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package packets
+
+import "github.com/Francesco149/maplelib"
+
+// ***********************************************************************
+// Combat
+
+// AttackTarget is a monster hit by an attack along with the damage lines it received
+type AttackTarget struct {
+	Oid    int32
+	Damage []int32
+}
+
+// encodeAttack serializes the data shared by all the attack packets
+func encodeAttack(p *maplelib.Packet, charid, skill int32, stance,
+	numAttackedAndDamage, speed byte, projectile int32, targets []AttackTarget) {
+
+	p.Encode4s(charid)
+	p.Encode1(numAttackedAndDamage)
+
+	if skill > 0 {
+		p.Encode1(0xFF) // skill level, too low and some skills don't display properly
+		p.Encode4s(skill)
+	} else {
+		p.Encode1(0x00)
+	}
+
+	p.Encode1(0x00) // what the hell is this
+	p.Encode1(stance)
+	p.Encode1(speed)
+	p.Encode1(0x0A) // what the hell is this
+	p.Encode4s(projectile)
+
+	for _, target := range targets {
+		p.Encode4s(target.Oid)
+		p.Encode1(0xFF) // what the hell is this
+
+		for _, dmg := range target.Damage {
+			p.Encode4s(dmg)
+		}
+	}
+}
+
+// CloseRangeAttack returns a packet that shows a player's melee attack to the other players
+func CloseRangeAttack(charid, skill int32, stance, numAttackedAndDamage, speed byte,
+	targets []AttackTarget) (p maplelib.Packet) {
+
+	p = NewEncryptedPacket(OCloseRangeAttack)
+	encodeAttack(&p, charid, skill, stance, numAttackedAndDamage, speed, 0, targets)
+	return
+}
+
+// RangedAttack returns a packet that shows a player's ranged attack to the other players.
+// projectile is the item id of the consumed ammo.
+func RangedAttack(charid, skill int32, stance, numAttackedAndDamage, speed byte,
+	projectile int32, targets []AttackTarget) (p maplelib.Packet) {
+
+	p = NewEncryptedPacket(ORangedAttack)
+	encodeAttack(&p, charid, skill, stance, numAttackedAndDamage, speed, projectile, targets)
+	return
+}
+
+// MagicAttack returns a packet that shows a player's magic attack to the other players.
+// charge is the charge time of keydown spells such as big bang, or -1.
+func MagicAttack(charid, skill int32, stance, numAttackedAndDamage, speed byte,
+	targets []AttackTarget, charge int32) (p maplelib.Packet) {
+
+	p = NewEncryptedPacket(OMagicAttack)
+	encodeAttack(&p, charid, skill, stance, numAttackedAndDamage, speed, 0, targets)
+
+	if charge != -1 {
+		p.Encode4s(charge)
+	}
+
+	return
+}
+
+// Sources of the damage taken by a player
+const (
+	DamageFromMap   = -2 // traps and obstacles
+	DamageFromTouch = -1 // touching a monster
+	// 0 and above is the index of the monster's attack
+)
+
+// DamagePlayer returns a packet that shows the damage taken by a player to the other
+// players. from is one of the DamageFrom constants or a monster attack index.
+func DamagePlayer(charid int32, from int8, damage, monsterid int32,
+	direction byte) (p maplelib.Packet) {
+
+	p = NewEncryptedPacket(ODamagePlayer)
+	p.Encode4s(charid)
+	p.Encode1s(from)
+	p.Encode4s(damage)
+	p.Encode4s(monsterid)
+	p.Encode1(direction)
+	p.Encode2(0x0000) // no power guard
+	p.Encode4s(damage)
+	return
+}
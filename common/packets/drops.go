@@ -0,0 +1,106 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package packets
+
+import "image"
+
+import "github.com/Francesco149/maplelib"
+
+// ***********************************************************************
+// Drops
+
+// Possible values for the animation mode in DropItemFromMapObject()
+const (
+	DropAnimation   = 1 // the item pops out of the dropper
+	DropNoAnimation = 2 // the item is already on the ground
+	DropDisappear   = 3 // the item appears and immediately disappears
+)
+
+// Possible values for the drop type in DropItemFromMapObject()
+const (
+	DropTypeOwner = 0 // only the owner can loot the item until it times out
+	DropTypeParty = 1 // only the owner's party can loot the item until it times out
+	DropTypeFfa   = 2 // free for all
+)
+
+// DropItemFromMapObject returns a packet that shows an item or a meso drop on the ground.
+// itemid must contain the meso amount for meso drops.
+// dropper is the object id of the entity that dropped the item.
+func DropItemFromMapObject(mod byte, oid int32, meso bool, itemid int32, ownerid int32,
+	dropType byte, dropper int32, dropFrom, dropTo image.Point) (p maplelib.Packet) {
+
+	p = NewEncryptedPacket(ODropItemFromMapObject)
+	p.Encode1(mod)
+	p.Encode4s(oid)
+
+	if meso {
+		p.Encode1(0x01)
+	} else {
+		p.Encode1(0x00)
+	}
+
+	p.Encode4s(itemid)
+	p.Encode4s(ownerid)
+	p.Encode1(dropType)
+	p.Encode2s(int16(dropTo.X))
+	p.Encode2s(int16(dropTo.Y))
+
+	if mod != DropNoAnimation {
+		p.Encode4s(ownerid)
+		p.Encode2s(int16(dropFrom.X))
+		p.Encode2s(int16(dropFrom.Y))
+	} else {
+		p.Encode4s(dropper)
+	}
+
+	p.Encode1(0x00) // what the hell is this
+
+	if mod != DropNoAnimation {
+		p.Encode1(0x00) // what the hell is this
+		p.Encode1(0x01) // pets can pick up mesos
+	}
+
+	if !meso {
+		p.Append([]byte{0x80, 0x05}) // item magic
+		p.Encode4s(400967355)        // no expiration
+		p.Encode1(0x00)              // don't show expiration
+		p.Encode1(0x01)              // pets can pick up equips
+	}
+
+	return
+}
+
+// Possible values for the animation in RemoveItemFromMap()
+const (
+	RemoveItemExpire  = 0 // the item fades out
+	RemoveItemNoAnim  = 1 // the item vanishes
+	RemoveItemPickup  = 2 // the item is picked up by a player
+	RemoveItemExplode = 4 // the item explodes (meso explosion)
+)
+
+// RemoveItemFromMap returns a packet that removes an item from the ground.
+// charid is the player that picked up the item and is only used by pickup animations.
+func RemoveItemFromMap(oid int32, animation byte, charid int32) (p maplelib.Packet) {
+	p = NewEncryptedPacket(ORemoveItemFromMap)
+	p.Encode1(animation)
+	p.Encode4s(oid)
+
+	if animation >= RemoveItemPickup {
+		p.Encode4s(charid)
+	}
+
+	return
+}
@@ -0,0 +1,66 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package packets
+
+import "github.com/Francesco149/maplelib"
+
+// ***********************************************************************
+// Fame
+
+// Fame request modes
+const (
+	FameModeDown = 0
+	FameModeUp   = 1
+)
+
+// Results of a fame request
+const (
+	FameOk              = 0 // sent to the player who gave fame
+	FameInvalidTarget   = 1 // incorrect user name
+	FameLevelTooLow     = 2 // users under level 15 can't raise or drop fame
+	FameLimitReached    = 3 // can't raise or drop fame anymore today
+	FameSameTargetLimit = 4 // can't raise or drop fame for this character this month
+	FameReceived        = 5 // sent to the player who received fame
+)
+
+// FameResponse returns a packet that tells the player that they raised or dropped
+// the fame of target, which now has the given amount of fame
+func FameResponse(target string, mode byte, fame int16) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OFameResponse)
+	p.Encode1(FameOk)
+	p.EncodeString(target)
+	p.Encode1(mode)
+	p.Encode2s(fame)
+	p.Encode2(0x0000)
+	return
+}
+
+// FameReceivedNotice returns a packet that tells the player that from raised or
+// dropped their fame
+func FameReceivedNotice(from string, mode byte) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OFameResponse)
+	p.Encode1(FameReceived)
+	p.EncodeString(from)
+	p.Encode1(mode)
+	return
+}
+
+// FameError returns a packet that shows why a fame request was refused
+func FameError(result byte) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OFameResponse)
+	p.Encode1(result)
+	return
+}
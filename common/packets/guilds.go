@@ -0,0 +1,78 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package packets
+
+import "github.com/Francesco149/maplelib"
+
+// ***********************************************************************
+// Guilds
+
+// Guild packet operations
+const (
+	GuildOpCreatePrompt = 0x01 // asks for the name of a new guild
+	GuildOpInvite       = 0x05
+	GuildOpEmblemPrompt = 0x11 // opens the emblem editor
+	GuildOpInfo         = 0x1A
+	GuildOpJoin         = 0x27
+	GuildOpLeave        = 0x2C
+	GuildOpExpel        = 0x2F
+	GuildOpDisband      = 0x32
+	GuildOpCapacity     = 0x3A
+	GuildOpMember       = 0x3C
+	GuildOpOnline       = 0x3D
+	GuildOpTitles       = 0x3E
+	GuildOpRank         = 0x40
+	GuildOpEmblem       = 0x42
+	GuildOpNotice       = 0x44
+)
+
+// Guild requests sent by the client
+const (
+	GuildReqCreate = 0x02
+	GuildReqInvite = 0x05
+	GuildReqJoin   = 0x06
+	GuildReqLeave  = 0x07
+	GuildReqExpel  = 0x08
+	GuildReqTitles = 0x0D
+	GuildReqRank   = 0x0E
+	GuildReqEmblem = 0x0F
+	GuildReqNotice = 0x10
+)
+
+// Possible values for GuildMessage()
+const (
+	GuildMsgNameInUse      = 0x1C // the name is already in use
+	GuildMsgAlreadyInGuild = 0x28 // the player is already in a guild
+	GuildMsgNotFound       = 0x2A // the player is not online
+	GuildMsgNotInGuild     = 0x2D // the player is not in a guild
+)
+
+// GuildMessage returns a packet that shows one of the GuildMsg messages or opens one
+// of the prompts
+func GuildMessage(msg byte) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OGuild)
+	p.Encode1(msg)
+	return
+}
+
+// GuildInvite returns a packet that shows a guild invite from the given player
+func GuildInvite(guildid int32, from string) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OGuild)
+	p.Encode1(GuildOpInvite)
+	p.Encode4s(guildid)
+	p.EncodeString(from)
+	return
+}
@@ -39,9 +39,72 @@ const (
 
 	// channel server
 	OWarpToMap     = 0x005C // warp to map
+	OClock         = 0x006E
 	OServerMessage = 0x0041
 	OChangeChannel = 0x0010
 	OUpdateStats   = 0x001C
+	OGiveBuff      = 0x001D
+	OCancelBuff    = 0x001E
+	OUpdateSkills  = 0x0021
+	OSkillMacro    = 0x005B
+	OKeymap        = 0x0107
+
+	// status and effects
+	OShowStatusInfo    = 0x0024
+	OShowForeignEffect = 0x0099
+	OShowOwnEffect     = 0x009A
+	OFameResponse      = 0x0023
+
+	// quests
+	OShowItemGainInChat = 0x00A1 // also used for quest effects
+	OUpdateQuestInfo    = 0x00A6
+
+	// inventory
+	OModifyInventory  = 0x001A
+	OFinishSort       = 0x0031
+	OUpdateCharLook   = 0x0098
+	OShowScrollEffect = 0x007E
+
+	// movement
+	OMovePlayer = 0x008D
+
+	// chat
+	OChatText  = 0x007A
+	OMultiChat = 0x0058
+
+	// party
+	OParty               = 0x003B
+	OBuddyList           = 0x003C
+	OGuild               = 0x003E
+	OUpdatePartyMemberHp = 0x009C
+
+	// combat
+	OCloseRangeAttack = 0x008E
+	ORangedAttack     = 0x008F
+	OMagicAttack      = 0x0090
+	ODamagePlayer     = 0x0094
+
+	// monsters
+	OSpawnMonster        = 0x00AF
+	OKillMonster         = 0x00B0
+	OSpawnMonsterControl = 0x00B1
+	OMoveMonster         = 0x00B2
+	OMoveMonsterResponse = 0x00B3
+	OShowMonsterHp       = 0x00BD
+
+	// drops
+	ODropItemFromMapObject = 0x00CD
+	ORemoveItemFromMap     = 0x00CE
+
+	// npcs
+	OSpawnNpc = 0x00C2
+	ONpcTalk  = 0x00ED
+
+	// trades and shops
+	OPlayerInteraction      = 0x00F5
+	OOpenNpcShop            = 0x00EE
+	OConfirmShopTransaction = 0x00EF
+	OStorage                = 0x00F0
 )
 
 // Recv packet headers
@@ -71,9 +134,39 @@ const (
 	IPlayerUpdateIgnore  = 0x00C0 // shouldn't be received by the login server
 
 	// channel server
-	ILoadCharacter    = 0x0014
-	IPlayerUpdate     = 0x00C0
-	IChangeMapSpecial = 0x005C
-	IChangeMap        = 0x0023
-	IMovePlayer       = 0x0026
+	ILoadCharacter     = 0x0014
+	IPlayerUpdate      = 0x00C0
+	IChangeMapSpecial  = 0x005C
+	IChangeMap         = 0x0023
+	IMovePlayer        = 0x0026
+	IGeneralChat       = 0x002E
+	IMultiChat         = 0x006B
+	IPartyOperation    = 0x0070
+	IGuildOperation    = 0x0072
+	IBuddyListModify   = 0x0076
+	IPlayerInteraction = 0x006F
+	ICloseRangeAttack  = 0x0029
+	IRangedAttack      = 0x002A
+	IMagicAttack       = 0x002B
+	ITakeDamage        = 0x002D
+	INpcTalk           = 0x0036
+	INpcTalkMore       = 0x0038
+	INpcShop           = 0x0039
+	IStorage           = 0x003A
+	IQuestAction       = 0x0062
+	ISkillMacro        = 0x0065
+	IChangeKeymap      = 0x007B
+	IItemSort          = 0x0040
+	IItemMove          = 0x0042
+	IUseItem           = 0x0043
+	IUseReturnScroll   = 0x004E
+	IUseUpgradeScroll  = 0x004F
+	IDistributeAp      = 0x0050
+	IDistributeSp      = 0x0052
+	IGiveFame          = 0x0057
+	ISpecialMove       = 0x0053
+	ICancelBuff        = 0x0054
+	IMoveLife          = 0x009D
+	IAutoAggro         = 0x009E
+	IItemPickup        = 0x00AB
 )
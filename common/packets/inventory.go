@@ -0,0 +1,164 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package packets
+
+import "github.com/Francesco149/maplelib"
+
+// ***********************************************************************
+// Inventory
+
+// Possible operation modes in inventory modification packets
+const (
+	InventoryAdd    = 0 // adds a new item
+	InventoryAmount = 1 // updates the amount of an item
+	InventoryMove   = 2 // moves an item to another slot
+	InventoryRemove = 3 // clears a slot
+)
+
+// encodeInventoryHeader writes the header of an inventory modification packet.
+// fromDrop must be true if the modification was caused by a drop or a pickup.
+func encodeInventoryHeader(p *maplelib.Packet, fromDrop bool, operations byte) {
+	if fromDrop {
+		p.Encode1(0x01)
+	} else {
+		p.Encode1(0x00)
+	}
+
+	p.Encode1(operations)
+}
+
+// UpdateInventorySlot returns a packet that updates the amount of the item at the given slot
+func UpdateInventorySlot(invtype int8, slot int16, amount int16,
+	fromDrop bool) (p maplelib.Packet) {
+
+	p = NewEncryptedPacket(OModifyInventory)
+	encodeInventoryHeader(&p, fromDrop, 1)
+	p.Encode1(InventoryAmount)
+	p.Encode1s(invtype)
+	p.Encode2s(slot)
+	p.Encode2s(amount)
+	return
+}
+
+// ClearInventorySlot returns a packet that removes the item at the given slot
+func ClearInventorySlot(invtype int8, slot int16, fromDrop bool) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OModifyInventory)
+	encodeInventoryHeader(&p, fromDrop, 1)
+	p.Encode1(InventoryRemove)
+	p.Encode1s(invtype)
+	p.Encode2s(slot)
+
+	if slot < 0 {
+		p.Encode1(0x01) // equipped item
+	}
+
+	return
+}
+
+// InventoryFull returns an empty inventory modification packet, which
+// tells the client that the item can't fit in the inventory
+func InventoryFull() (p maplelib.Packet) {
+	p = NewEncryptedPacket(OModifyInventory)
+	encodeInventoryHeader(&p, true, 0)
+	return
+}
+
+// Possible values for the equip indicator of MoveInventoryItem
+const (
+	MoveNoEquip = -1 // normal slot-to-slot move
+	MoveUnequip = 1  // an equipped item is moved to the inventory
+	MoveEquip   = 2  // an item is moved from the inventory to an equip slot
+)
+
+// MoveInventoryItem returns a packet that moves the item at slot src to slot dst.
+// equipIndicator must be one of MoveNoEquip, MoveUnequip, MoveEquip
+func MoveInventoryItem(invtype int8, src, dst int16, equipIndicator int8) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OModifyInventory)
+	encodeInventoryHeader(&p, true, 1)
+	p.Encode1(InventoryMove)
+	p.Encode1s(invtype)
+	p.Encode2s(src)
+	p.Encode2s(dst)
+
+	if equipIndicator != MoveNoEquip {
+		p.Encode1s(equipIndicator)
+	}
+
+	return
+}
+
+// MoveAndMergeInventoryItem returns a packet that merges the whole stack at slot src
+// into the stack at slot dst, which will end up with the given total amount
+func MoveAndMergeInventoryItem(invtype int8, src, dst, total int16) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OModifyInventory)
+	encodeInventoryHeader(&p, true, 2)
+	p.Encode1(InventoryRemove)
+	p.Encode1s(invtype)
+	p.Encode2s(src)
+	p.Encode1(InventoryAmount)
+	p.Encode1s(invtype)
+	p.Encode2s(dst)
+	p.Encode2s(total)
+	return
+}
+
+// MoveAndMergeWithRestInventoryItem returns a packet that merges part of the stack at
+// slot src into the stack at slot dst, leaving srcAmount items in the source slot
+func MoveAndMergeWithRestInventoryItem(invtype int8, src, dst, srcAmount,
+	dstAmount int16) (p maplelib.Packet) {
+
+	p = NewEncryptedPacket(OModifyInventory)
+	encodeInventoryHeader(&p, true, 2)
+	p.Encode1(InventoryAmount)
+	p.Encode1s(invtype)
+	p.Encode2s(src)
+	p.Encode2s(srcAmount)
+	p.Encode1(InventoryAmount)
+	p.Encode1s(invtype)
+	p.Encode2s(dst)
+	p.Encode2s(dstAmount)
+	return
+}
+
+// FinishSort returns a packet that unlocks the given inventory tab after it's been sorted
+func FinishSort(invtype int8) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OFinishSort)
+	p.Encode1(0x01)
+	p.Encode1s(invtype)
+	return
+}
+
+// ShowScrollEffect returns a packet that shows the result of an upgrade scroll
+// used by the given character
+func ShowScrollEffect(charid int32, success, destroyed bool) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OShowScrollEffect)
+	p.Encode4s(charid)
+
+	if success {
+		p.Encode1(0x01)
+	} else {
+		p.Encode1(0x00)
+	}
+
+	if destroyed {
+		p.Encode1(0x01)
+	} else {
+		p.Encode1(0x00)
+	}
+
+	p.Encode2(0x0000) // what the hell is this
+	return
+}
@@ -0,0 +1,72 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package packets
+
+import (
+	"github.com/Francesco149/kagami/common/consts"
+	"github.com/Francesco149/maplelib"
+)
+
+// ***********************************************************************
+// Keymap and skill macros
+
+// KeyBinding is the type and action bound to a key
+type KeyBinding struct {
+	Type   byte
+	Action int32
+}
+
+// SkillMacro is a sequence of up to 3 skills triggered by one key
+type SkillMacro struct {
+	Name   string
+	Shout  bool // shows the macro name in the chat when used
+	Skills [3]int32
+}
+
+// Keymap returns a packet that sends the player's keyboard layout
+func Keymap(keymap *[consts.KeymapSize]KeyBinding) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OKeymap)
+	p.Encode1(0x00) // what the hell is this
+
+	for _, binding := range keymap {
+		p.Encode1(binding.Type)
+		p.Encode4s(binding.Action)
+	}
+
+	return
+}
+
+// SkillMacros returns a packet that sends the player's skill macros
+func SkillMacros(macros []SkillMacro) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OSkillMacro)
+	p.Encode1(byte(len(macros)))
+
+	for _, macro := range macros {
+		p.EncodeString(macro.Name)
+
+		if macro.Shout {
+			p.Encode1(0x01)
+		} else {
+			p.Encode1(0x00)
+		}
+
+		for _, skillid := range macro.Skills {
+			p.Encode4s(skillid)
+		}
+	}
+
+	return
+}
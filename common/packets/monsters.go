@@ -0,0 +1,158 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package packets
+
+import "image"
+
+import "github.com/Francesco149/maplelib"
+
+// ***********************************************************************
+// Monsters
+
+// Possible values for the spawn effect in SpawnMonster()
+const (
+	MonsterSpawnNoEffect = 0 // the monster just appears
+	MonsterSpawnFade     = 1 // fade-in spawn used for summoned mobs
+)
+
+// encodeMonsterSpawn serializes the data shared by the spawn and control packets
+func encodeMonsterSpawn(p *maplelib.Packet, oid, mobid int32, pos image.Point,
+	stance byte, fh int16, effect byte, newSpawn bool) {
+
+	p.Encode4s(oid)
+	p.Encode1(0x05) // what the hell is this, it's either 1 or 5
+	p.Encode4s(mobid)
+	p.Encode4(0x00000000) // monster status
+	p.Encode2s(int16(pos.X))
+	p.Encode2s(int16(pos.Y))
+	p.Encode1(stance)
+	p.Encode2(0x0000) // what the hell is this
+	p.Encode2s(fh)
+
+	if effect != MonsterSpawnNoEffect {
+		p.Encode1(effect)
+		p.Encode1(0x00)
+		p.Encode2(0x0000)
+	}
+
+	if newSpawn {
+		p.Encode2s(-2)
+	} else {
+		p.Encode2s(-1)
+	}
+
+	p.Encode4(0x00000000)
+}
+
+// SpawnMonster returns a packet that shows a monster to a player.
+// newSpawn determines whether the monster will play its spawn animation.
+func SpawnMonster(oid, mobid int32, pos image.Point, stance byte, fh int16,
+	effect byte, newSpawn bool) (p maplelib.Packet) {
+
+	p = NewEncryptedPacket(OSpawnMonster)
+	encodeMonsterSpawn(&p, oid, mobid, pos, stance, fh, effect, newSpawn)
+	return
+}
+
+// ControlMonster returns a packet that makes the player control the movement of a monster.
+// aggro determines whether the monster will chase the player.
+func ControlMonster(oid, mobid int32, pos image.Point, stance byte, fh int16,
+	aggro bool) (p maplelib.Packet) {
+
+	p = NewEncryptedPacket(OSpawnMonsterControl)
+	if aggro {
+		p.Encode1(0x02)
+	} else {
+		p.Encode1(0x01)
+	}
+	encodeMonsterSpawn(&p, oid, mobid, pos, stance, fh, MonsterSpawnNoEffect, false)
+	return
+}
+
+// StopControllingMonster returns a packet that tells the player to stop controlling a monster
+func StopControllingMonster(oid int32) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OSpawnMonsterControl)
+	p.Encode1(0x00)
+	p.Encode4s(oid)
+	return
+}
+
+// Possible values for the animation in KillMonster()
+const (
+	MonsterDisappear = 0 // the monster vanishes without animation
+	MonsterDie       = 1 // the monster plays its death animation
+)
+
+// KillMonster returns a packet that removes a monster from the map
+func KillMonster(oid int32, animation byte) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OKillMonster)
+	p.Encode4s(oid)
+	p.Encode1(animation)
+	return
+}
+
+// MoveMonster returns a packet that relays a monster's movement to the other players.
+// movement must contain the raw movement fragments as sent by the controller,
+// including the fragment count.
+func MoveMonster(oid int32, useSkill bool, skill int8, skillArgs [4]byte,
+	startPos image.Point, movement []byte) (p maplelib.Packet) {
+
+	p = NewEncryptedPacket(OMoveMonster)
+	p.Encode4s(oid)
+
+	if useSkill {
+		p.Encode1(0x01)
+	} else {
+		p.Encode1(0x00)
+	}
+
+	p.Encode1s(skill)
+	p.Append(skillArgs[:])
+	p.Encode2s(int16(startPos.X))
+	p.Encode2s(int16(startPos.Y))
+	p.Append(movement)
+	return
+}
+
+// MoveMonsterResponse returns a packet that acknowledges a monster movement packet
+// received from the controller
+func MoveMonsterResponse(oid int32, moveid int16, mp int16, aggro bool,
+	skillid, skilllevel byte) (p maplelib.Packet) {
+
+	p = NewEncryptedPacket(OMoveMonsterResponse)
+	p.Encode4s(oid)
+	p.Encode2s(moveid)
+
+	if aggro {
+		p.Encode1(0x01)
+	} else {
+		p.Encode1(0x00)
+	}
+
+	p.Encode2s(mp)
+	p.Encode1(skillid)
+	p.Encode1(skilllevel)
+	return
+}
+
+// ShowMonsterHp returns a packet that shows the hp bar of a monster.
+// percent must be a value between 0 and 100.
+func ShowMonsterHp(oid int32, percent byte) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OShowMonsterHp)
+	p.Encode4s(oid)
+	p.Encode1(percent)
+	return
+}
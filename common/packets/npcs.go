@@ -0,0 +1,116 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package packets
+
+import "github.com/Francesco149/maplelib"
+
+// ***********************************************************************
+// NPCs
+
+// Possible NPC dialog types
+const (
+	NpcTalkSay       = 0 // text with next/prev/ok buttons
+	NpcTalkYesNo     = 1
+	NpcTalkGetText   = 2
+	NpcTalkGetNumber = 3
+	NpcTalkSimple    = 4 // text with a selection menu
+)
+
+// SpawnNpc returns a packet that shows a NPC to the player.
+// flip is true if the NPC faces left.
+func SpawnNpc(oid, npcid int32, x, cy int16, flip bool, fh, rx0, rx1 int16) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OSpawnNpc)
+	p.Encode4s(oid)
+	p.Encode4s(npcid)
+	p.Encode2s(x)
+	p.Encode2s(cy)
+
+	if flip {
+		p.Encode1(0x00)
+	} else {
+		p.Encode1(0x01)
+	}
+
+	p.Encode2s(fh)
+	p.Encode2s(rx0)
+	p.Encode2s(rx1)
+	p.Encode1(0x01) // what the hell is this
+	return
+}
+
+// encodeNpcTalkHeader writes the common part of all the NPC dialog packets
+func encodeNpcTalkHeader(p *maplelib.Packet, npcid int32, msgType byte, text string) {
+	p.Encode1(0x04) // what the hell is this
+	p.Encode4s(npcid)
+	p.Encode1(msgType)
+	p.EncodeString(text)
+}
+
+// NpcSay returns a packet that shows a NPC dialog with the given buttons.
+// A dialog without prev and next has an ok button.
+func NpcSay(npcid int32, text string, prev, next bool) (p maplelib.Packet) {
+	p = NewEncryptedPacket(ONpcTalk)
+	encodeNpcTalkHeader(&p, npcid, NpcTalkSay, text)
+
+	if prev {
+		p.Encode1(0x01)
+	} else {
+		p.Encode1(0x00)
+	}
+
+	if next {
+		p.Encode1(0x01)
+	} else {
+		p.Encode1(0x00)
+	}
+
+	return
+}
+
+// NpcYesNo returns a packet that shows a NPC dialog with yes and no buttons
+func NpcYesNo(npcid int32, text string) (p maplelib.Packet) {
+	p = NewEncryptedPacket(ONpcTalk)
+	encodeNpcTalkHeader(&p, npcid, NpcTalkYesNo, text)
+	return
+}
+
+// NpcSimple returns a packet that shows a NPC dialog with a selection menu.
+// The menu options are embedded in the text with the #L tags.
+func NpcSimple(npcid int32, text string) (p maplelib.Packet) {
+	p = NewEncryptedPacket(ONpcTalk)
+	encodeNpcTalkHeader(&p, npcid, NpcTalkSimple, text)
+	return
+}
+
+// NpcGetText returns a packet that shows a NPC dialog with a text box
+func NpcGetText(npcid int32, text string) (p maplelib.Packet) {
+	p = NewEncryptedPacket(ONpcTalk)
+	encodeNpcTalkHeader(&p, npcid, NpcTalkGetText, text)
+	p.Encode4(0x00000000) // what the hell is this
+	p.Encode4(0x00000000) // what the hell is this
+	return
+}
+
+// NpcGetNumber returns a packet that shows a NPC dialog with a number box
+func NpcGetNumber(npcid int32, text string, def, min, max int32) (p maplelib.Packet) {
+	p = NewEncryptedPacket(ONpcTalk)
+	encodeNpcTalkHeader(&p, npcid, NpcTalkGetNumber, text)
+	p.Encode4s(def)
+	p.Encode4s(min)
+	p.Encode4s(max)
+	p.Encode4(0x00000000) // what the hell is this
+	return
+}
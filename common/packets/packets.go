@@ -398,7 +398,7 @@ type statSorter []utils.Pair
 func (this statSorter) Len() int      { return len(this) }
 func (this statSorter) Swap(i, j int) { this[i], this[j] = this[j], this[i] }
 func (this statSorter) Less(i, j int) bool {
-	return this[i].Second.(int32) < this[j].Second.(int32)
+	return this[i].First.(int) < this[j].First.(int)
 }
 
 // UpdatePlayerStats returns a packet that updates the local player's stats.
@@ -412,8 +412,8 @@ func (this statSorter) Less(i, j int) bool {
 // UpdateFace, UpdateHair: int32
 // UpdateLevel: byte
 // UpdateJob, UpdateStr, UpdateDex, UpdateInt, UpdateLuk, UpdateHp, UpdateMaxHp, UpdateMp,
-// 	UpdateMaxMp, UpdateAp, UpdateSp, UpdateExp, UpdateFame, UpdateMeso: int16
-// UpdatePet: int32
+// 	UpdateMaxMp, UpdateAp, UpdateSp: int16
+// UpdateExp, UpdateFame, UpdateMeso, UpdatePet: int32
 func UpdatePlayerStats(stats []utils.Pair, itemReaction bool) (p maplelib.Packet) {
 	p = NewEncryptedPacket(OUpdateStats)
 
@@ -471,3 +471,14 @@ func WarpToMap(mapid int32, portalid int32, hp int16, channel int8) (p maplelib.
 	p.Encode8(0x1FFFFFFFFFFFFFFF)
 	return
 }
+
+// MovePlayer returns a packet that relays a player's movement to the other players.
+// movement must contain the raw movement fragments as sent by the client,
+// including the fragment count.
+func MovePlayer(charid int32, movement []byte) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OMovePlayer)
+	p.Encode4s(charid)
+	p.Encode4(0x00000000) // what the hell is this
+	p.Append(movement)
+	return
+}
@@ -0,0 +1,90 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package packets
+
+import "github.com/Francesco149/maplelib"
+
+// ***********************************************************************
+// Party
+
+// Party packet operations
+const (
+	PartyOpSilentUpdate = 0x07
+	PartyOpLeave        = 0x0C // also used for expel and disband
+	PartyOpJoin         = 0x0F
+	PartyOpInvite       = 0x04
+	PartyOpCreated      = 0x08
+	PartyOpLeader       = 0x1A
+)
+
+// Possible values for PartyStatusMessage()
+const (
+	PartyMsgBeginnerCreate = 10 // a beginner can't create a party
+	PartyMsgNotInParty     = 11 // you have yet to join a party
+	PartyMsgAlreadyInParty = 16 // already joined a party
+	PartyMsgFull           = 17 // the party is already full
+	PartyMsgNotFound       = 19 // unable to find the requested character in this channel
+	PartyMsgInviteDenied   = 23 // has denied the invitation
+)
+
+// PartyInvite returns a packet that shows a party invite from the given player
+func PartyInvite(partyid int32, from string) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OParty)
+	p.Encode1(PartyOpInvite)
+	p.Encode4s(partyid)
+	p.EncodeString(from)
+	return
+}
+
+// PartyStatusMessage returns a packet that shows one of the PartyMsg messages
+func PartyStatusMessage(msg byte) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OParty)
+	p.Encode1(msg)
+	return
+}
+
+// UpdatePartyMemberHp returns a packet that updates the hp bar of a party member
+// on the same map
+func UpdatePartyMemberHp(charid int32, hp, maxhp int16) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OUpdatePartyMemberHp)
+	p.Encode4s(charid)
+	p.Encode4s(int32(hp))
+	p.Encode4s(int32(maxhp))
+	return
+}
+
+// Possible chat types for MultiChat()
+const (
+	MultiChatBuddy = 0
+	MultiChatParty = 1
+	MultiChatGuild = 2
+)
+
+// MultiChat returns a buddy, party or guild chat message
+func MultiChat(chatType byte, name, text string) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OMultiChat)
+	p.Encode1(chatType)
+	p.EncodeString(name)
+	p.EncodeString(text)
+	return
+}
+
+// EncodePaddedString writes a string padded with zeros to the given length
+func EncodePaddedString(p *maplelib.Packet, s string, length int) {
+	buf := make([]byte, length)
+	copy(buf, s)
+	p.Append(buf)
+}
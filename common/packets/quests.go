@@ -0,0 +1,98 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package packets
+
+import "github.com/Francesco149/maplelib"
+
+// ***********************************************************************
+// Quests
+
+// Possible values for the effect in ShowQuestEffect()
+const (
+	QuestEffectItemGain = 3
+	QuestEffectComplete = 9
+)
+
+// Possible values for the result in UpdateQuestInfo()
+const (
+	QuestInfoSuccess    = 8  // the npc dialog continues
+	QuestInfoNoInvSpace = 10 // not enough inventory space for the rewards
+	QuestInfoNoMesos    = 11 // not enough mesos to pay the quest fee
+)
+
+// UpdateQuest returns a packet that starts or updates a quest for the player.
+// progress is the quest info string, which holds the monster kill counts for
+// quests that require killing monsters.
+func UpdateQuest(questid int16, progress string) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OShowStatusInfo)
+	p.Encode1(StatusInfoQuest)
+	p.Encode2s(questid)
+	p.Encode1(0x01) // started
+	p.EncodeString(progress)
+	p.Encode8(0x0000000000000000) // what the hell is this
+	return
+}
+
+// ForfeitQuest returns a packet that removes a started quest from the quest log
+func ForfeitQuest(questid int16) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OShowStatusInfo)
+	p.Encode1(StatusInfoQuest)
+	p.Encode2s(questid)
+	p.Encode1(0x00) // not started
+	p.Encode1(0x00) // what the hell is this
+	p.Encode8(0x0000000000000000)
+	return
+}
+
+// CompleteQuest returns a packet that marks a quest as completed. completedTime is
+// a quest timestamp (see utils.UnixToQuestTimestamp)
+func CompleteQuest(questid int16, completedTime uint64) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OShowStatusInfo)
+	p.Encode1(StatusInfoQuest)
+	p.Encode2s(questid)
+	p.Encode1(0x02) // completed
+	p.Encode8(completedTime)
+	return
+}
+
+// UpdateQuestInfo returns a packet that tells the client the result of a quest
+// start/complete request so the npc dialog can continue
+func UpdateQuestInfo(questid int16, npcid int32, result byte) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OUpdateQuestInfo)
+	p.Encode1(result)
+	p.Encode2s(questid)
+	p.Encode4s(npcid)
+	p.Encode4(0x00000000) // what the hell is this
+	return
+}
+
+// ShowQuestEffect returns a packet that plays a quest related effect on the player
+func ShowQuestEffect(effect byte) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OShowItemGainInChat)
+	p.Encode1(effect)
+	return
+}
+
+// ShowItemGainInChat returns a packet that shows the amount of items gained or lost
+// from a quest in the chat box
+func ShowItemGainInChat(itemid int32, quantity int32) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OShowItemGainInChat)
+	p.Encode1(QuestEffectItemGain)
+	p.Encode1(0x01) // number of items
+	p.Encode4s(itemid)
+	p.Encode4s(quantity)
+	return
+}
@@ -0,0 +1,44 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package packets
+
+import "github.com/Francesco149/maplelib"
+
+// ***********************************************************************
+// Npc shops
+
+// Shop operations sent by the client
+const (
+	ShopOpBuy      = 0x00
+	ShopOpSell     = 0x01
+	ShopOpRecharge = 0x02
+	ShopOpLeave    = 0x03
+)
+
+// Possible values for ConfirmShopTransaction()
+const (
+	ShopResultBought        = 0x00
+	ShopResultNoMesos       = 0x02
+	ShopResultInventoryFull = 0x03
+	ShopResultSold          = 0x08 // also used for recharges
+)
+
+// ConfirmShopTransaction returns a packet that shows the result of a shop operation
+func ConfirmShopTransaction(result byte) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OConfirmShopTransaction)
+	p.Encode1(result)
+	return
+}
@@ -0,0 +1,56 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package packets
+
+import "github.com/Francesco149/maplelib"
+
+// ***********************************************************************
+// Skills
+
+// EffectSkillUse is the effect id that plays a skill's animation on a player
+const EffectSkillUse = 1
+
+// UpdateSkill returns a packet that changes the level and master level of a skill
+func UpdateSkill(skillid, level, masterLevel int32) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OUpdateSkills)
+	p.Encode1(0x01)   // what the hell is this
+	p.Encode2(0x0001) // number of skills
+	p.Encode4s(skillid)
+	p.Encode4s(level)
+	p.Encode4s(masterLevel)
+	p.Encode1(0x04) // what the hell is this
+	return
+}
+
+// ShowOwnSkillEffect returns a packet that plays a skill's animation on the local player
+func ShowOwnSkillEffect(skillid int32, level byte) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OShowItemGainInChat)
+	p.Encode1(EffectSkillUse)
+	p.Encode4s(skillid)
+	p.Encode1(level)
+	return
+}
+
+// ShowForeignSkillEffect returns a packet that plays a skill's animation on another
+// player
+func ShowForeignSkillEffect(charid, skillid int32, level byte) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OShowForeignEffect)
+	p.Encode4s(charid)
+	p.Encode1(EffectSkillUse)
+	p.Encode4s(skillid)
+	p.Encode1(level)
+	return
+}
@@ -0,0 +1,116 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package packets
+
+import "github.com/Francesco149/maplelib"
+
+// ***********************************************************************
+// Status messages and effects
+
+// Possible values for the message type of status info packets
+const (
+	StatusInfoDrop  = 0
+	StatusInfoQuest = 1
+	StatusInfoExp   = 3
+	StatusInfoFame  = 4
+	StatusInfoMeso  = 5
+)
+
+// Possible values for the effect in ShowOwnEffect() and ShowForeignEffect()
+const (
+	EffectLevelUp   = 0
+	EffectJobChange = 8
+)
+
+// ShowExpGain returns a packet that shows the amount of exp gained by the player.
+// inChat shows the message in the chat box instead of the bottom right corner.
+// white shows the message in white, which is used for the top attacker of a monster.
+func ShowExpGain(gain int32, inChat, white bool) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OShowStatusInfo)
+	p.Encode1(StatusInfoExp)
+
+	if white {
+		p.Encode1(0x01)
+	} else {
+		p.Encode1(0x00)
+	}
+
+	p.Encode4s(gain)
+
+	if inChat {
+		p.Encode1(0x01)
+	} else {
+		p.Encode1(0x00)
+	}
+
+	p.Encode4(0x00000000) // event bonus exp
+	p.Encode4(0x00000000) // party bonus exp
+	p.Encode4(0x00000000) // what the hell is this
+	return
+}
+
+// ShowOwnEffect returns a packet that plays an effect on the local player
+func ShowOwnEffect(effect byte) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OShowOwnEffect)
+	p.Encode1(effect)
+	return
+}
+
+// ShowForeignEffect returns a packet that plays an effect on another player
+func ShowForeignEffect(charid int32, effect byte) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OShowForeignEffect)
+	p.Encode4s(charid)
+	p.Encode1(effect)
+	return
+}
+
+// ShowItemGain returns a packet that shows the amount of items picked up by the player
+func ShowItemGain(itemid int32, quantity int32) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OShowStatusInfo)
+	p.Encode1(StatusInfoDrop)
+	p.Encode1(0x00) // item
+	p.Encode4s(itemid)
+	p.Encode4s(quantity)
+	p.Encode4(0x00000000) // what the hell is this
+	p.Encode4(0x00000000) // what the hell is this
+	return
+}
+
+// ShowMesoGain returns a packet that shows the amount of mesos picked up by the player
+func ShowMesoGain(gain int32) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OShowStatusInfo)
+	p.Encode1(StatusInfoDrop)
+	p.Encode1(0x01) // mesos
+	p.Encode4s(gain)
+	p.Encode2(0x0000) // internet cafe bonus
+	return
+}
+
+// ShowFameGain returns a packet that shows the amount of fame gained by the player
+func ShowFameGain(gain int32) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OShowStatusInfo)
+	p.Encode1(StatusInfoFame)
+	p.Encode4s(gain)
+	return
+}
+
+// ShowItemUnavailable returns a packet that tells the player that an item can't be looted
+func ShowItemUnavailable() (p maplelib.Packet) {
+	p = NewEncryptedPacket(OShowStatusInfo)
+	p.Encode1(StatusInfoDrop)
+	p.Encode1(0xFE)
+	return
+}
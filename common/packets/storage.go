@@ -0,0 +1,62 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package packets
+
+import "github.com/Francesco149/maplelib"
+
+// ***********************************************************************
+// Storage
+
+// Storage operations sent by the client
+const (
+	StorageReqTakeOut = 0x04
+	StorageReqStore   = 0x05
+	StorageReqArrange = 0x06
+	StorageReqMeso    = 0x07
+	StorageReqClose   = 0x08
+)
+
+// Storage packet operations
+const (
+	StorageOpTakeOut = 0x09
+	StorageOpStore   = 0x0D
+	StorageOpArrange = 0x0F
+	StorageOpFull    = 0x11
+	StorageOpMeso    = 0x13
+	StorageOpOpen    = 0x15
+)
+
+// StorageAllTabs is the tab bitmask that refreshes every tab of the storage window
+const StorageAllTabs = 0x7E
+
+// StorageFull returns a packet that tells the player that the storage is full
+func StorageFull() (p maplelib.Packet) {
+	p = NewEncryptedPacket(OStorage)
+	p.Encode1(StorageOpFull)
+	return
+}
+
+// StorageMeso returns a packet that updates the mesos in the storage window
+func StorageMeso(slots byte, meso int32) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OStorage)
+	p.Encode1(StorageOpMeso)
+	p.Encode1(slots)
+	p.Encode2(0x0002) // meso bitmask
+	p.Encode2(0x0000)
+	p.Encode4(0x00000000)
+	p.Encode4s(meso)
+	return
+}
@@ -0,0 +1,97 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package packets
+
+import "github.com/Francesco149/maplelib"
+
+// ***********************************************************************
+// Trades
+
+// Player interaction operations, shared by the trade window and player shops
+const (
+	InteractionCreate   = 0x00
+	InteractionInvite   = 0x02
+	InteractionDecline  = 0x03
+	InteractionVisit    = 0x04
+	InteractionRoom     = 0x05
+	InteractionChat     = 0x06
+	InteractionExit     = 0x0A
+	InteractionSetItems = 0x0E
+	InteractionSetMeso  = 0x0F
+	InteractionConfirm  = 0x10
+)
+
+// Room types for InteractionCreate
+const (
+	InteractionTypeTrade = 0x03
+)
+
+// Possible values for TradeResult()
+const (
+	TradeResultCancel  = 0x02
+	TradeResultSuccess = 0x06
+)
+
+// MaxTradeSlots is the number of item slots each player has in the trade window
+const MaxTradeSlots = 9
+
+// TradeInvite returns a packet that shows a trade invite from the given player
+func TradeInvite(from string) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OPlayerInteraction)
+	p.Encode1(InteractionInvite)
+	p.Encode1(InteractionTypeTrade)
+	p.EncodeString(from)
+	p.Encode4(0) // what the hell is this
+	return
+}
+
+// TradeMesoSet returns a packet that updates the mesos offered by one of the
+// traders. number is 0 for the player's own offer and 1 for the partner's.
+func TradeMesoSet(number byte, meso int32) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OPlayerInteraction)
+	p.Encode1(InteractionSetMeso)
+	p.Encode1(number)
+	p.Encode4s(meso)
+	return
+}
+
+// TradeConfirmation returns a packet that tells the player that the partner has
+// confirmed the trade
+func TradeConfirmation() (p maplelib.Packet) {
+	p = NewEncryptedPacket(OPlayerInteraction)
+	p.Encode1(InteractionConfirm)
+	return
+}
+
+// TradeResult returns a packet that closes the trade window with one of the
+// TradeResult values
+func TradeResult(number, result byte) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OPlayerInteraction)
+	p.Encode1(InteractionExit)
+	p.Encode1(number)
+	p.Encode1(result)
+	return
+}
+
+// TradeChat returns a chat message shown in the trade window
+func TradeChat(number byte, name, text string) (p maplelib.Packet) {
+	p = NewEncryptedPacket(OPlayerInteraction)
+	p.Encode1(InteractionChat)
+	p.Encode1(0x08) // what the hell is this
+	p.Encode1(number)
+	p.EncodeString(name + " : " + text)
+	return
+}
@@ -98,6 +98,25 @@ CREATE TABLE `items` (
   `world_id` int(11) NOT NULL,
   `item_id` int(11) NOT NULL,
   `amount` int(11) NOT NULL DEFAULT '1',
+  `upgrade_slots` tinyint(4) DEFAULT NULL,
+  `scroll_level` tinyint(3) unsigned NOT NULL DEFAULT '0',
+  `locked` tinyint(4) NOT NULL DEFAULT '0',
+  `str` smallint(6) NOT NULL DEFAULT '0',
+  `dex` smallint(6) NOT NULL DEFAULT '0',
+  `int` smallint(6) NOT NULL DEFAULT '0',
+  `luk` smallint(6) NOT NULL DEFAULT '0',
+  `hp` smallint(6) NOT NULL DEFAULT '0',
+  `mp` smallint(6) NOT NULL DEFAULT '0',
+  `watk` smallint(6) NOT NULL DEFAULT '0',
+  `matk` smallint(6) NOT NULL DEFAULT '0',
+  `wdef` smallint(6) NOT NULL DEFAULT '0',
+  `mdef` smallint(6) NOT NULL DEFAULT '0',
+  `acc` smallint(6) NOT NULL DEFAULT '0',
+  `avoid` smallint(6) NOT NULL DEFAULT '0',
+  `hands` smallint(6) NOT NULL DEFAULT '0',
+  `speed` smallint(6) NOT NULL DEFAULT '0',
+  `jump` smallint(6) NOT NULL DEFAULT '0',
+  `owner` varchar(12) NOT NULL DEFAULT '',
   PRIMARY KEY (`character_id`,`inv`,`slot`,`location`),
   CONSTRAINT `items_ibfk_1` FOREIGN KEY (`character_id`) REFERENCES `characters` (`character_id`) ON DELETE CASCADE
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8;
@@ -111,3 +130,150 @@ CREATE TABLE `storage` (
   PRIMARY KEY (`user_id`,`world_id`),
   CONSTRAINT `storage_ibfk_1` FOREIGN KEY (`user_id`) REFERENCES `accounts` (`id`) ON DELETE CASCADE
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE `quests` (
+  `character_id` int(11) NOT NULL,
+  `quest_id` smallint(6) NOT NULL,
+  `status` tinyint(4) NOT NULL,
+  `progress` varchar(255) NOT NULL DEFAULT '',
+  `completed_time` bigint(20) NOT NULL DEFAULT '0',
+  PRIMARY KEY (`character_id`,`quest_id`),
+  CONSTRAINT `quests_ibfk_1` FOREIGN KEY (`character_id`) REFERENCES `characters` (`character_id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE `skills` (
+  `character_id` int(11) NOT NULL,
+  `skill_id` int(11) NOT NULL,
+  `level` tinyint(3) unsigned NOT NULL,
+  `master_level` tinyint(3) unsigned NOT NULL DEFAULT '0',
+  PRIMARY KEY (`character_id`,`skill_id`),
+  CONSTRAINT `skills_ibfk_1` FOREIGN KEY (`character_id`) REFERENCES `characters` (`character_id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE `keymap` (
+  `character_id` int(11) NOT NULL,
+  `key` int(11) NOT NULL,
+  `type` tinyint(3) unsigned NOT NULL,
+  `action` int(11) NOT NULL,
+  PRIMARY KEY (`character_id`,`key`),
+  CONSTRAINT `keymap_ibfk_1` FOREIGN KEY (`character_id`) REFERENCES `characters` (`character_id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE `skill_macros` (
+  `character_id` int(11) NOT NULL,
+  `position` tinyint(3) unsigned NOT NULL,
+  `name` varchar(13) NOT NULL,
+  `shout` tinyint(1) NOT NULL DEFAULT '0',
+  `skill1` int(11) NOT NULL DEFAULT '0',
+  `skill2` int(11) NOT NULL DEFAULT '0',
+  `skill3` int(11) NOT NULL DEFAULT '0',
+  PRIMARY KEY (`character_id`,`position`),
+  CONSTRAINT `skill_macros_ibfk_1` FOREIGN KEY (`character_id`) REFERENCES `characters` (`character_id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE `buddies` (
+  `character_id` int(11) NOT NULL,
+  `buddy_id` int(11) NOT NULL,
+  `pending` tinyint(1) NOT NULL DEFAULT '0',
+  `created` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`character_id`,`buddy_id`),
+  CONSTRAINT `buddies_ibfk_1` FOREIGN KEY (`character_id`) REFERENCES `characters` (`character_id`) ON DELETE CASCADE,
+  CONSTRAINT `buddies_ibfk_2` FOREIGN KEY (`buddy_id`) REFERENCES `characters` (`character_id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE `guilds` (
+  `guild_id` int(11) NOT NULL AUTO_INCREMENT,
+  `world_id` tinyint(3) unsigned NOT NULL,
+  `name` varchar(12) NOT NULL,
+  `leader_id` int(11) NOT NULL,
+  `notice` varchar(101) NOT NULL DEFAULT '',
+  `rank1_title` varchar(12) NOT NULL DEFAULT 'Master',
+  `rank2_title` varchar(12) NOT NULL DEFAULT 'Jr. Master',
+  `rank3_title` varchar(12) NOT NULL DEFAULT 'Member',
+  `rank4_title` varchar(12) NOT NULL DEFAULT 'Member',
+  `rank5_title` varchar(12) NOT NULL DEFAULT 'Member',
+  `capacity` tinyint(3) unsigned NOT NULL DEFAULT '10',
+  `emblem_bg` smallint(6) NOT NULL DEFAULT '0',
+  `emblem_bg_color` tinyint(3) unsigned NOT NULL DEFAULT '0',
+  `emblem` smallint(6) NOT NULL DEFAULT '0',
+  `emblem_color` tinyint(3) unsigned NOT NULL DEFAULT '0',
+  `points` int(11) NOT NULL DEFAULT '0',
+  PRIMARY KEY (`guild_id`),
+  UNIQUE KEY `world_name` (`world_id`,`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE `guild_members` (
+  `character_id` int(11) NOT NULL,
+  `guild_id` int(11) NOT NULL,
+  `rank` tinyint(3) unsigned NOT NULL DEFAULT '5',
+  PRIMARY KEY (`character_id`),
+  KEY `guild_id` (`guild_id`),
+  CONSTRAINT `guild_members_ibfk_1` FOREIGN KEY (`character_id`) REFERENCES `characters` (`character_id`) ON DELETE CASCADE,
+  CONSTRAINT `guild_members_ibfk_2` FOREIGN KEY (`guild_id`) REFERENCES `guilds` (`guild_id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE `trades` (
+  `trade_id` int(11) NOT NULL AUTO_INCREMENT,
+  `time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `world_id` tinyint(3) unsigned NOT NULL,
+  `channel_id` tinyint(3) unsigned NOT NULL,
+  `map_id` int(11) NOT NULL,
+  `character1_id` int(11) NOT NULL,
+  `character2_id` int(11) NOT NULL,
+  `meso1` int(11) NOT NULL DEFAULT '0',
+  `meso2` int(11) NOT NULL DEFAULT '0',
+  PRIMARY KEY (`trade_id`),
+  KEY `character1_id` (`character1_id`),
+  KEY `character2_id` (`character2_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE `trade_items` (
+  `trade_id` int(11) NOT NULL,
+  `character_id` int(11) NOT NULL,
+  `item_id` int(11) NOT NULL,
+  `amount` smallint(6) NOT NULL,
+  KEY `trade_id` (`trade_id`),
+  CONSTRAINT `trade_items_ibfk_1` FOREIGN KEY (`trade_id`) REFERENCES `trades` (`trade_id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE `fame_log` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `character_id` int(11) NOT NULL,
+  `target_id` int(11) NOT NULL,
+  `amount` tinyint(4) NOT NULL,
+  `time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `character_time` (`character_id`,`time`),
+  CONSTRAINT `fame_log_ibfk_1` FOREIGN KEY (`character_id`) REFERENCES `characters` (`character_id`) ON DELETE CASCADE,
+  CONSTRAINT `fame_log_ibfk_2` FOREIGN KEY (`target_id`) REFERENCES `characters` (`character_id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE `boss_attempts` (
+  `character_id` int(11) NOT NULL,
+  `boss` tinyint(3) unsigned NOT NULL,
+  `attempts` smallint(6) NOT NULL DEFAULT '0',
+  `date` date NOT NULL,
+  PRIMARY KEY (`character_id`,`boss`),
+  CONSTRAINT `boss_attempts_ibfk_1` FOREIGN KEY (`character_id`) REFERENCES `characters` (`character_id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE `monster_drops` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `monster_id` int(11) NOT NULL,
+  `item_id` int(11) NOT NULL DEFAULT '0',
+  `chance` int(11) NOT NULL,
+  `min_quantity` int(11) NOT NULL DEFAULT '1',
+  `max_quantity` int(11) NOT NULL DEFAULT '1',
+  PRIMARY KEY (`id`),
+  KEY `monster_id` (`monster_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE `shop_items` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `npc_id` int(11) NOT NULL,
+  `item_id` int(11) NOT NULL,
+  `price` int(11) NOT NULL,
+  `position` smallint(6) NOT NULL DEFAULT '0',
+  PRIMARY KEY (`id`),
+  KEY `npc_id` (`npc_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
@@ -32,6 +32,7 @@ import (
 	"github.com/Francesco149/kagami/loginserver/validators"
 	"github.com/Francesco149/kagami/loginserver/worlds"
 	"github.com/Francesco149/maplelib"
+	"github.com/ziutek/mymysql/mysql"
 )
 
 // Handle handles loginserver packets
@@ -704,6 +705,70 @@ func sendChar(char *common.CharData) (p maplelib.Packet) {
 	return
 }
 
+// createChar inserts a new character along with its starting equips and keymap
+// in a single transaction and returns its id
+func createChar(con *client.Connection, name string, face, hair int32, skin, gender int8,
+	str, dex, intt, luk int16, top, bottom, shoes, weapon int32) (charid int32, err error) {
+
+	tr, err := common.GetDB().Begin()
+	if err != nil {
+		return
+	}
+
+	charid, err = insertChar(tr, con, name, face, hair, skin, gender,
+		str, dex, intt, luk, top, bottom, shoes, weapon)
+	if err != nil {
+		tr.Rollback()
+		return
+	}
+
+	err = tr.Commit()
+	return
+}
+
+// insertChar inserts a new character along with its starting equips and keymap
+// on the given connection or transaction
+func insertChar(db mysql.ConnCommon, con *client.Connection, name string, face, hair int32,
+	skin, gender int8, str, dex, intt, luk int16,
+	top, bottom, shoes, weapon int32) (charid int32, err error) {
+
+	st, err := db.Prepare("INSERT INTO characters(name, user_id, world_id, " +
+		"face, hair, skin, gender, str, dex, `int`, luk) " +
+		"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return
+	}
+
+	res, err := st.Run(name, con.Id(), con.WorldId(), face, hair, skin, gender, str, dex, intt, luk)
+	if err != nil {
+		return
+	}
+
+	charid = int32(res.InsertId())
+
+	// create equips
+	starting := []struct {
+		id   int32
+		slot int16
+	}{
+		{top, -consts.EquipTop},
+		{bottom, -consts.EquipBottom},
+		{shoes, -consts.EquipShoe},
+		{weapon, -consts.EquipWeapon},
+		{consts.BeginnersGuidebook, 1},
+	}
+
+	for _, item := range starting {
+		err = items.Create(db, con, item.id, charid, item.slot)
+		if err != nil {
+			return
+		}
+	}
+
+	err = common.CreateDefaultKeymap(db, charid)
+	return
+}
+
 // handleCreateChar handles a character creation packet
 func handleCreateChar(con *client.Connection, it maplelib.PacketIterator) (handled bool, err error) {
 	handled = false
@@ -776,30 +841,15 @@ func handleCreateChar(con *client.Connection, it maplelib.PacketIterator) (handl
 	}
 
 	// all data has been validated, the character can be safely created
-	db := common.GetDB()
-	st, err := db.Prepare("INSERT INTO characters(name, user_id, world_id, " +
-		"face, hair, skin, gender, str, dex, `int`, luk) " +
-		"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
-	res, err := st.Run(name, con.Id(), con.WorldId(), face, hair+haircolor, skincolor, gender, str, dex, intt, luk)
-	if err != nil {
-		return
-	}
-
-	charid := int32(res.InsertId())
-
-	// create equips
-	err = items.Create(con, top, charid, -consts.EquipTop)
-	err = items.Create(con, bottom, charid, -consts.EquipBottom)
-	err = items.Create(con, shoes, charid, -consts.EquipShoe)
-	err = items.Create(con, weapon, charid, -consts.EquipWeapon)
-	err = items.Create(con, consts.BeginnersGuidebook, charid, 1)
+	charid, err := createChar(con, name, face, hair+haircolor, skincolor, gender,
+		str, dex, intt, luk, top, bottom, shoes, weapon)
 	if err != nil {
 		return
 	}
 
 	// get the newly created character's data
-	st, err = db.Prepare("SELECT * FROM characters WHERE character_id = ?")
-	res, err = st.Run(charid)
+	st, err := common.GetDB().Prepare("SELECT * FROM characters WHERE character_id = ?")
+	res, err := st.Run(charid)
 	rows, err := res.GetRows()
 	if err != nil {
 		return
@@ -883,11 +933,41 @@ func handleDeleteChar(con *client.Connection, it maplelib.PacketIterator) (handl
 	   worldid := byte(rows[0].Int(colworldid))
 	*/
 
+	// guild masters must disband their guild first
+	st, err := db.Prepare("SELECT guild_id FROM guilds WHERE leader_id = ?")
+	if err != nil {
+		return
+	}
+
+	res, err := st.Run(charid)
+	rows, err := res.GetRows()
+	if err != nil {
+		return
+	}
+
 	// check birthday code
 	if bdaycode != con.CharDeletePassword() {
 		status = packets.DeleteInvalidCode
+	} else if len(rows) != 0 {
+		status = packets.DeleteFail
 	} else {
-		// TODO: remove character from guild
+		// the storage is shared by the whole account, so the stored items are handed
+		// over to another character of the same world before the foreign key
+		// deletes them. they're lost if this is the last character in the world.
+		st, err = db.Prepare("UPDATE items i JOIN characters c ON c.user_id = i.user_id " +
+			"AND c.world_id = i.world_id AND c.character_id <> i.character_id " +
+			"SET i.character_id = c.character_id " +
+			"WHERE i.character_id = ? AND i.location = 'storage'")
+		if err != nil {
+			return
+		}
+
+		_, err = st.Run(charid)
+		if err != nil {
+			return
+		}
+
+		// the guild membership is removed by the foreign key
 		// TODO: delete pets
 		st, sterr := db.Prepare("DELETE FROM characters WHERE character_id = ?")
 		err = sterr
@@ -913,6 +993,10 @@ func handleDeleteChar(con *client.Connection, it maplelib.PacketIterator) (handl
 		return
 	}
 
+	if status == packets.DeleteOk && w.WorldCon() != nil {
+		err = w.WorldCon().SendPacket(interserver.SyncCharacterDeleted(charid))
+	}
+
 	handled = err == nil
 	return
 }
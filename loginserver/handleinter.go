@@ -21,6 +21,7 @@ import (
 )
 
 import (
+	"github.com/Francesco149/kagami/common/consts"
 	"github.com/Francesco149/kagami/common/interserver"
 	"github.com/Francesco149/kagami/common/utils"
 	"github.com/Francesco149/kagami/loginserver/worlds"
@@ -71,6 +72,9 @@ func HandleInter(con *worlds.Connection, p maplelib.Packet) (handled bool, err e
 
 	case interserver.IOSyncChannelPopulation:
 		return syncChannelPopulation(con, it)
+
+	case interserver.IORehash:
+		return handleRehash(con, it)
 	}
 
 	return false, nil // forward packet to next handler
@@ -167,3 +171,27 @@ func syncChannelPopulation(con *worlds.Connection, it maplelib.PacketIterator) (
 	handled = err == nil
 	return
 }
+
+// handleRehash reloads the world configs and sends them to the connected worlds
+func handleRehash(con *worlds.Connection, it maplelib.PacketIterator) (handled bool, err error) {
+	fmt.Println("Rehash requested by world", con.WorldId())
+	loadDefaultWorlds()
+
+	worlds.Lock()
+	defer worlds.Unlock()
+
+	for _, id := range consts.WorldId {
+		w := worlds.Get(id)
+		if w == nil || !w.Connected() || w.WorldCon() == nil {
+			continue
+		}
+
+		err = w.WorldCon().SendPacket(interserver.SyncWorldConf(w.Conf()))
+		if err != nil {
+			return
+		}
+	}
+
+	handled = true
+	return
+}
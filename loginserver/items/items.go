@@ -17,22 +17,25 @@
 package items
 
 import (
-	"github.com/Francesco149/kagami/common"
 	"github.com/Francesco149/kagami/loginserver/client"
+	"github.com/ziutek/mymysql/mysql"
 )
 
 // getItemInventory returns the item's inventory (equip, use...)
 func getItemInventory(itemId int32) int8 { return int8(itemId / 1000000) }
 
-// Create adds an item to a character's inventory
-func Create(con *client.Connection, id, charid int32, slot int16) (err error) {
+// Create adds an item to a character's inventory on the given connection or transaction
+func Create(db mysql.ConnCommon, con *client.Connection, id, charid int32, slot int16) (err error) {
 	itype := getItemInventory(id)
 
 	// TODO: obtain item info from wz files
 
-	db := common.GetDB()
 	st, err := db.Prepare("INSERT INTO items(inv, slot, location, user_id, world_id, item_id, character_id) " +
 		"VALUES(?, ?, 'inventory', ?, ?, ?, ?)")
+	if err != nil {
+		return
+	}
+
 	_, err = st.Run(itype, slot, con.Id(), con.WorldId(), id, charid)
 	return
 }
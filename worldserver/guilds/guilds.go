@@ -0,0 +1,343 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// Package guilds keeps the world's guilds cached and in sync with the database
+package guilds
+
+import (
+	"fmt"
+	"sync"
+)
+
+import (
+	"github.com/Francesco149/kagami/common"
+	"github.com/Francesco149/kagami/common/interserver"
+	"github.com/ziutek/mymysql/mysql"
+)
+
+var mut sync.Mutex
+var guilds = make(map[int32]*interserver.Guild) // cached guilds mapped by id
+var memberOf = make(map[int32]int32)            // charid -> guild id, 0 if not in a guild
+var invites = make(map[int32]int32)             // invited charid -> guild id
+
+// DefaultTitles are the rank titles of a new guild
+var DefaultTitles = [interserver.GuildRanks]string{"Master", "Jr. Master", "Member", "Member", "Member"}
+
+// Lock locks the guilds mutex.
+// Must be called before performing any operation on
+// the guilds
+func Lock() {
+	mut.Lock()
+}
+
+// Unlock unlocks the guilds mutex.
+func Unlock() {
+	mut.Unlock()
+}
+
+// load retrieves a guild and its members from the database
+func load(id int32) (g *interserver.Guild, err error) {
+	db := common.GetDB()
+
+	st, err := db.Prepare("SELECT * FROM guilds WHERE guild_id = ?")
+	if err != nil {
+		return
+	}
+
+	res, err := st.Run(id)
+	rows, err := res.GetRows()
+	if err != nil || len(rows) == 0 {
+		return
+	}
+
+	row := rows[0]
+	g = &interserver.Guild{
+		Id:          id,
+		Name:        row.Str(res.Map("name")),
+		Leader:      int32(row.Int(res.Map("leader_id"))),
+		Notice:      row.Str(res.Map("notice")),
+		Capacity:    byte(row.Int(res.Map("capacity"))),
+		EmblemBg:    int16(row.Int(res.Map("emblem_bg"))),
+		EmblemBgCol: byte(row.Int(res.Map("emblem_bg_color"))),
+		Emblem:      int16(row.Int(res.Map("emblem"))),
+		EmblemCol:   byte(row.Int(res.Map("emblem_color"))),
+		Points:      int32(row.Int(res.Map("points"))),
+	}
+
+	for i := range g.Titles {
+		g.Titles[i] = row.Str(res.Map(fmt.Sprintf("rank%d_title", i+1)))
+	}
+
+	st, err = db.Prepare("SELECT gm.character_id, gm.`rank`, c.name, c.job, c.level " +
+		"FROM guild_members gm INNER JOIN characters c ON c.character_id = gm.character_id " +
+		"WHERE gm.guild_id = ?")
+	if err != nil {
+		return
+	}
+
+	res, err = st.Run(id)
+	rows, err = res.GetRows()
+	if err != nil {
+		return
+	}
+
+	for _, row := range rows {
+		m := &interserver.GuildMember{
+			CharId: int32(row.Int(res.Map("character_id"))),
+			Name:   row.Str(res.Map("name")),
+			Job:    int16(row.Int(res.Map("job"))),
+			Level:  byte(row.Int(res.Map("level"))),
+			Rank:   byte(row.Int(res.Map("rank"))),
+		}
+		g.Members = append(g.Members, m)
+		memberOf[m.CharId] = id
+	}
+
+	guilds[id] = g
+	return
+}
+
+// guilds.Get gets a guild by id, loading it from the database if it's not cached.
+// Returns nil if the guild doesn't exist.
+func Get(id int32) (*interserver.Guild, error) {
+	if g := guilds[id]; g != nil {
+		return g, nil
+	}
+	return load(id)
+}
+
+// guilds.Of returns the guild of the given character or nil
+func Of(charid int32) (*interserver.Guild, error) {
+	id, ok := memberOf[charid]
+	if ok {
+		if id == 0 {
+			return nil, nil
+		}
+		return Get(id)
+	}
+
+	st, err := common.GetDB().Prepare("SELECT guild_id FROM guild_members WHERE character_id = ?")
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := st.Run(charid)
+	rows, err := res.GetRows()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		memberOf[charid] = 0
+		return nil, nil
+	}
+
+	return Get(int32(rows[0].Int(res.Map("guild_id"))))
+}
+
+// guilds.NameTaken returns true if a guild with the given name exists in the world
+func NameTaken(name string, worldid int8) (bool, error) {
+	st, err := common.GetDB().Prepare("SELECT guild_id FROM guilds WHERE name = ? AND world_id = ?")
+	if err != nil {
+		return false, err
+	}
+
+	res, err := st.Run(name, worldid)
+	rows, err := res.GetRows()
+	return len(rows) != 0, err
+}
+
+// guilds.Create creates a new guild led by the given member. The guild and its leader
+// are written in a single transaction and the guild is only cached once committed.
+func Create(name string, worldid int8, leader *interserver.GuildMember) (g *interserver.Guild, err error) {
+	tr, err := common.GetDB().Begin()
+	if err != nil {
+		return
+	}
+
+	st, err := tr.Prepare("INSERT INTO guilds(world_id, name, leader_id, capacity, " +
+		"rank1_title, rank2_title, rank3_title, rank4_title, rank5_title) " +
+		"VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		tr.Rollback()
+		return
+	}
+
+	res, err := st.Run(worldid, name, leader.CharId, interserver.GuildMinCapacity,
+		DefaultTitles[0], DefaultTitles[1], DefaultTitles[2], DefaultTitles[3], DefaultTitles[4])
+	if err != nil {
+		tr.Rollback()
+		return
+	}
+
+	created := &interserver.Guild{
+		Id:       int32(res.InsertId()),
+		Name:     name,
+		Leader:   leader.CharId,
+		Titles:   DefaultTitles,
+		Capacity: interserver.GuildMinCapacity,
+	}
+
+	leader.Rank = interserver.GuildRankMaster
+	err = insertMember(tr, created, leader)
+	if err != nil {
+		tr.Rollback()
+		return
+	}
+
+	err = tr.Commit()
+	if err != nil {
+		return
+	}
+
+	g = created
+	guilds[g.Id] = g
+	addCachedMember(g, leader)
+	return
+}
+
+// guilds.AddMember adds a member to a guild and clears the member's invite
+func AddMember(g *interserver.Guild, m *interserver.GuildMember) (err error) {
+	err = insertMember(common.GetDB(), g, m)
+	if err != nil {
+		return
+	}
+
+	addCachedMember(g, m)
+	return
+}
+
+// insertMember writes a guild member on the given connection or transaction
+func insertMember(db mysql.ConnCommon, g *interserver.Guild, m *interserver.GuildMember) (err error) {
+	st, err := db.Prepare("INSERT INTO guild_members(character_id, guild_id, `rank`) " +
+		"VALUES(?, ?, ?)")
+	if err != nil {
+		return
+	}
+
+	_, err = st.Run(m.CharId, g.Id, m.Rank)
+	return
+}
+
+// addCachedMember adds a member that was written to the database to the cached guild
+// and clears the member's invite
+func addCachedMember(g *interserver.Guild, m *interserver.GuildMember) {
+	g.Members = append(g.Members, m)
+	memberOf[m.CharId] = g.Id
+	delete(invites, m.CharId)
+}
+
+// guilds.RemoveMember removes a member from a guild and returns it or nil if the
+// character isn't in the guild
+func RemoveMember(g *interserver.Guild, charid int32) (m *interserver.GuildMember, err error) {
+	st, err := common.GetDB().Prepare("DELETE FROM guild_members WHERE character_id = ?")
+	if err != nil {
+		return
+	}
+
+	_, err = st.Run(charid)
+	if err != nil {
+		return
+	}
+
+	for i, member := range g.Members {
+		if member.CharId == charid {
+			g.Members = append(g.Members[:i], g.Members[i+1:]...)
+			m = member
+			break
+		}
+	}
+
+	memberOf[charid] = 0
+	return
+}
+
+// guilds.SetRank changes the rank of a member
+func SetRank(g *interserver.Guild, m *interserver.GuildMember, rank byte) (err error) {
+	st, err := common.GetDB().Prepare("UPDATE guild_members SET `rank` = ? WHERE character_id = ?")
+	if err != nil {
+		return
+	}
+
+	_, err = st.Run(rank, m.CharId)
+	if err == nil {
+		m.Rank = rank
+	}
+	return
+}
+
+// guilds.Save saves the guild's leader, notice, titles, capacity, emblem and points
+func Save(g *interserver.Guild) (err error) {
+	st, err := common.GetDB().Prepare("UPDATE guilds SET leader_id = ?, notice = ?, " +
+		"rank1_title = ?, rank2_title = ?, rank3_title = ?, rank4_title = ?, rank5_title = ?, " +
+		"capacity = ?, emblem_bg = ?, emblem_bg_color = ?, emblem = ?, emblem_color = ?, " +
+		"points = ? WHERE guild_id = ?")
+	if err != nil {
+		return
+	}
+
+	_, err = st.Run(g.Leader, g.Notice, g.Titles[0], g.Titles[1], g.Titles[2], g.Titles[3],
+		g.Titles[4], g.Capacity, g.EmblemBg, g.EmblemBgCol, g.Emblem, g.EmblemCol, g.Points, g.Id)
+	return
+}
+
+// guilds.Disband deletes a guild. The members are kept in the guild object so that
+// they can be notified.
+func Disband(g *interserver.Guild) (err error) {
+	st, err := common.GetDB().Prepare("DELETE FROM guilds WHERE guild_id = ?")
+	if err != nil {
+		return
+	}
+
+	_, err = st.Run(g.Id)
+	if err != nil {
+		return
+	}
+
+	for _, m := range g.Members {
+		memberOf[m.CharId] = 0
+	}
+
+	for charid, id := range invites {
+		if id == g.Id {
+			delete(invites, charid)
+		}
+	}
+
+	delete(guilds, g.Id)
+	return
+}
+
+// guilds.Forget drops a deleted character from the cache
+func Forget(charid int32) {
+	delete(memberOf, charid)
+	delete(invites, charid)
+}
+
+// guilds.Invite records a pending invite to the given guild
+func Invite(charid int32, g *interserver.Guild) {
+	invites[charid] = g.Id
+}
+
+// guilds.Invited returns true if the character has a pending invite to the given guild
+func Invited(charid int32, guildid int32) bool {
+	id, ok := invites[charid]
+	return ok && id == guildid
+}
+
+// guilds.ClearInvite removes the pending invite of a character
+func ClearInvite(charid int32) {
+	delete(invites, charid)
+}
@@ -0,0 +1,70 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"fmt"
+)
+
+import (
+	"github.com/Francesco149/kagami/common/interserver"
+	"github.com/Francesco149/kagami/worldserver/channels"
+	"github.com/Francesco149/kagami/worldserver/players"
+	"github.com/Francesco149/maplelib"
+)
+
+// handleBuddyNotify relays the channel of a player to the channels of its online buddies
+func handleBuddyNotify(con *channels.Connection, it maplelib.PacketIterator) (handled bool, err error) {
+	charid, err := it.Decode4s()
+	name, err := it.DecodeString()
+	channel, err := it.Decode1s()
+	reply, err := it.Decode1()
+	count, err := it.Decode2()
+	if err != nil {
+		return
+	}
+
+	buddies := make([]int32, count)
+	for i := range buddies {
+		buddies[i], err = it.Decode4s()
+	}
+	if err != nil {
+		return
+	}
+
+	players.Lock()
+	byChannel := players.ChannelsOf(buddies)
+	players.Unlock()
+
+	channels.Lock()
+	defer channels.Unlock()
+
+	for chanid, ids := range byChannel {
+		ch := channels.Get(chanid)
+		if ch == nil {
+			continue
+		}
+
+		err = ch.Conn().SendPacket(interserver.BuddyNotify(charid, name, channel, reply != 0, ids))
+		if err != nil {
+			fmt.Println("Failed to relay buddy status to channel", chanid, ":", err)
+			err = nil
+		}
+	}
+
+	handled = true
+	return
+}
@@ -101,6 +101,24 @@ func HandleChan(con *channels.Connection, p maplelib.Packet) (handled bool, err
 
 	case interserver.IOSyncPlayerLeftChannel:
 		return syncPlayerLeftChannel(con, it)
+
+	case interserver.IOSyncPlayerUpdate:
+		return syncPlayerUpdate(con, it)
+
+	case interserver.IOMessageToPlayers:
+		return handleMessageToPlayers(con, it)
+
+	case interserver.IOPartyOperation:
+		return handlePartyOperation(con, it)
+
+	case interserver.IOBuddyNotify:
+		return handleBuddyNotify(con, it)
+
+	case interserver.IOGuildOperation:
+		return handleGuildOperation(con, it)
+
+	case interserver.IORehash:
+		return handleRehash(con, it)
 	}
 
 	return false, nil
@@ -110,10 +128,17 @@ func HandleChan(con *channels.Connection, p maplelib.Packet) (handled bool, err
 // tells the worldserver that a player has joined the channel
 func syncPlayerJoinedChannel(con *channels.Connection, it maplelib.PacketIterator) (handled bool, err error) {
 	chanid, err := it.Decode1s()
+	charid, err := it.Decode4s()
+	name, err := it.DecodeString()
+	job, err := it.Decode2s()
+	level, err := it.Decode1()
+	mapid, err := it.Decode4s()
 	if err != nil {
 		return
 	}
 
+	playerOnline(charid, name, job, level, chanid, mapid)
+
 	channels.Lock()
 	defer channels.Unlock()
 	ch := channels.Get(chanid)
@@ -136,10 +161,13 @@ func syncPlayerJoinedChannel(con *channels.Connection, it maplelib.PacketIterato
 // tells the worldserver that a player has left the channel
 func syncPlayerLeftChannel(con *channels.Connection, it maplelib.PacketIterator) (handled bool, err error) {
 	chanid, err := it.Decode1s()
+	charid, err := it.Decode4s()
 	if err != nil {
 		return
 	}
 
+	playerOffline(charid)
+
 	channels.Lock()
 	defer channels.Unlock()
 	ch := channels.Get(chanid)
@@ -157,3 +185,14 @@ func syncPlayerLeftChannel(con *channels.Connection, it maplelib.PacketIterator)
 	handled = err == nil
 	return
 }
+
+// handleRehash forwards a rehash request from a channel to the loginserver, which
+// owns the world configs
+func handleRehash(con *channels.Connection, it maplelib.PacketIterator) (handled bool, err error) {
+	status.Lock()
+	defer status.Unlock()
+
+	err = status.LoginConn().SendPacket(interserver.Rehash())
+	handled = err == nil
+	return
+}
@@ -0,0 +1,485 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"fmt"
+)
+
+import (
+	"github.com/Francesco149/kagami/common"
+	"github.com/Francesco149/kagami/common/interserver"
+	"github.com/Francesco149/kagami/common/packets"
+	"github.com/Francesco149/kagami/worldserver/channels"
+	"github.com/Francesco149/kagami/worldserver/guilds"
+	"github.com/Francesco149/kagami/worldserver/players"
+	"github.com/Francesco149/kagami/worldserver/status"
+	"github.com/Francesco149/maplelib"
+)
+
+// NOTE: the functions in this file expect the players and guilds mutexes to be
+// locked in this order and lock the channels mutex themselves
+
+const maxGuildNotice = 100
+
+// maxGuildTitle is the maximum length of a guild rank title
+const maxGuildTitle = 12
+
+// syncGuild notifies the channels of all the online members of a guild and of the
+// target character of a guild change
+func syncGuild(op byte, guild *interserver.Guild, target int32, targetName string) {
+	charids := append(guild.MemberIds(), target)
+	byChannel := players.ChannelsOf(charids)
+
+	channels.Lock()
+	defer channels.Unlock()
+
+	for chanid := range byChannel {
+		ch := channels.Get(chanid)
+		if ch == nil {
+			continue
+		}
+
+		err := ch.Conn().SendPacket(interserver.SyncGuild(op, guild, target, targetName))
+		if err != nil {
+			fmt.Println("Failed to sync guild", guild.Id, "to channel", chanid, ":", err)
+		}
+	}
+}
+
+// refuseGuildOperation tells the channel of a player that a paid guild operation
+// failed so that the mesos can be given back
+func refuseGuildOperation(charid int32, op byte) {
+	byChannel := players.ChannelsOf([]int32{charid})
+
+	channels.Lock()
+	defer channels.Unlock()
+
+	for chanid := range byChannel {
+		ch := channels.Get(chanid)
+		if ch == nil {
+			continue
+		}
+
+		err := ch.Conn().SendPacket(interserver.GuildRefused(charid, op))
+		if err != nil {
+			fmt.Println("Failed to refuse guild operation to channel", chanid, ":", err)
+		}
+	}
+}
+
+// guildMember creates a guild member with the lowest rank from an online player
+func guildMember(p *players.Player) *interserver.GuildMember {
+	return &interserver.GuildMember{
+		CharId: p.CharId(),
+		Name:   p.Name(),
+		Job:    p.Job(),
+		Level:  p.Level(),
+		Rank:   interserver.GuildRankLowest,
+		Online: true,
+	}
+}
+
+// updateGuildMember copies the player's current state to its guild, if any, and
+// notifies the guild's channels
+func updateGuildMember(op byte, charid int32) {
+	guild, err := guilds.Of(charid)
+	if err != nil {
+		fmt.Println("Failed to load the guild of", charid, ":", err)
+		return
+	}
+
+	if guild == nil {
+		return
+	}
+
+	m := guild.Member(charid)
+	if m == nil {
+		return
+	}
+
+	if p := players.Get(charid); p != nil {
+		m.Name = p.Name()
+		m.Job = p.Job()
+		m.Level = p.Level()
+		m.Online = true
+	} else {
+		m.Online = false
+	}
+
+	syncGuild(op, guild, charid, m.Name)
+}
+
+// handleGuildOperation handles a guild request from a player
+func handleGuildOperation(con *channels.Connection, it maplelib.PacketIterator) (handled bool, err error) {
+	op, err := it.Decode1()
+	charid, err := it.Decode4s()
+	target, err := it.Decode4s()
+	nargs, err := it.Decode1()
+	if err != nil {
+		return
+	}
+
+	args := make([]int32, nargs)
+	for i := range args {
+		args[i], err = it.Decode4s()
+	}
+
+	nstrs, err := it.Decode1()
+	if err != nil {
+		return
+	}
+
+	strs := make([]string, nstrs)
+	for i := range strs {
+		strs[i], err = it.DecodeString()
+	}
+	if err != nil {
+		return
+	}
+
+	handled = true
+
+	status.Lock()
+	worldid := status.WorldId()
+	status.Unlock()
+
+	players.Lock()
+	defer players.Unlock()
+	guilds.Lock()
+	defer guilds.Unlock()
+
+	player := players.Get(charid)
+	if player == nil {
+		fmt.Println("Offline character", charid, "requested guild operation", op)
+		return
+	}
+
+	// paid operations are charged by the channel before being requested, so it must
+	// be told when they don't go through
+	committed := false
+	defer func() {
+		switch op {
+		case interserver.GuildOpCreate, interserver.GuildOpEmblem, interserver.GuildOpCapacity:
+			if !committed {
+				refuseGuildOperation(charid, op)
+			}
+		}
+	}()
+
+	guild, err := guilds.Of(charid)
+	if err != nil {
+		return
+	}
+
+	message := func(msg byte) { sendToPlayers([]int32{charid}, packets.GuildMessage(msg)) }
+	notice := func(text string) {
+		sendToPlayers([]int32{charid}, packets.ServerMessage(packets.ServerMessagePopup,
+			0, text, false, false))
+	}
+
+	// rank of the player or 0 if not in a guild
+	var rank byte
+	if guild != nil {
+		rank = guild.Member(charid).Rank
+	}
+
+	switch op {
+	case interserver.GuildOpCreate:
+		if guild != nil {
+			message(packets.GuildMsgAlreadyInGuild)
+			return
+		}
+
+		if len(strs) != 1 {
+			return
+		}
+
+		var taken bool
+		taken, err = guilds.NameTaken(strs[0], worldid)
+		if err != nil {
+			return
+		}
+
+		if taken {
+			message(packets.GuildMsgNameInUse)
+			return
+		}
+
+		guild, err = guilds.Create(strs[0], worldid, guildMember(player))
+		if err != nil {
+			return
+		}
+
+		fmt.Println(player.Name(), "created guild", guild.Name)
+		committed = true
+		syncGuild(interserver.GuildSyncCreate, guild, charid, player.Name())
+
+	case interserver.GuildOpInvite:
+		if guild == nil || rank > interserver.GuildRankJrMaster || len(strs) != 1 {
+			fmt.Println(player.Name(), "tried to invite without permission")
+			return
+		}
+
+		invited := players.GetByName(strs[0])
+		if invited == nil {
+			message(packets.GuildMsgNotFound)
+			return
+		}
+
+		var other *interserver.Guild
+		other, err = guilds.Of(invited.CharId())
+		switch {
+		case err != nil:
+			return
+		case other != nil:
+			message(packets.GuildMsgAlreadyInGuild)
+		case len(guild.Members) >= int(guild.Capacity):
+			notice("The guild is full.")
+		default:
+			guilds.Invite(invited.CharId(), guild)
+			sendToPlayers([]int32{invited.CharId()}, packets.GuildInvite(guild.Id, player.Name()))
+		}
+
+	case interserver.GuildOpJoin:
+		if guild != nil {
+			message(packets.GuildMsgAlreadyInGuild)
+			return
+		}
+
+		if !guilds.Invited(charid, target) {
+			fmt.Println(player.Name(), "tried to join guild", target, "without an invite")
+			return
+		}
+
+		guild, err = guilds.Get(target)
+		if err != nil || guild == nil {
+			guilds.ClearInvite(charid)
+			return
+		}
+
+		if len(guild.Members) >= int(guild.Capacity) {
+			guilds.ClearInvite(charid)
+			notice("The guild is full.")
+			return
+		}
+
+		err = guilds.AddMember(guild, guildMember(player))
+		if err != nil {
+			return
+		}
+
+		syncGuild(interserver.GuildSyncJoin, guild, charid, player.Name())
+
+	case interserver.GuildOpLeave:
+		if guild == nil {
+			message(packets.GuildMsgNotInGuild)
+			return
+		}
+
+		if guild.Leader == charid {
+			notice("The guild master must disband the guild.")
+			return
+		}
+
+		_, err = guilds.RemoveMember(guild, charid)
+		if err != nil {
+			return
+		}
+
+		syncGuild(interserver.GuildSyncLeave, guild, charid, player.Name())
+
+	case interserver.GuildOpExpel:
+		if guild == nil || rank > interserver.GuildRankJrMaster {
+			fmt.Println(player.Name(), "tried to expel", target, "without permission")
+			return
+		}
+
+		m := guild.Member(target)
+		if m == nil || m.Rank <= rank {
+			fmt.Println(player.Name(), "tried to expel", target, "with a higher or equal rank")
+			return
+		}
+
+		_, err = guilds.RemoveMember(guild, target)
+		if err != nil {
+			return
+		}
+
+		syncGuild(interserver.GuildSyncExpel, guild, target, m.Name)
+
+	case interserver.GuildOpTitles:
+		if guild == nil || rank != interserver.GuildRankMaster || len(strs) != interserver.GuildRanks {
+			fmt.Println(player.Name(), "tried to change guild titles without permission")
+			return
+		}
+
+		for i, title := range strs {
+			// the last two ranks are optional
+			if (len(title) == 0 && i < interserver.GuildRankMember) || len(title) > maxGuildTitle {
+				return
+			}
+		}
+
+		titles := guild.Titles
+		copy(guild.Titles[:], strs)
+
+		err = guilds.Save(guild)
+		if err != nil {
+			guild.Titles = titles
+			return
+		}
+
+		syncGuild(interserver.GuildSyncTitles, guild, charid, player.Name())
+
+	case interserver.GuildOpRank:
+		if guild == nil || rank > interserver.GuildRankJrMaster || len(args) != 1 {
+			fmt.Println(player.Name(), "tried to change a guild rank without permission")
+			return
+		}
+
+		// nobody can be promoted to master or to the player's own rank
+		newrank := byte(args[0])
+		m := guild.Member(target)
+		if m == nil || m.Rank <= rank || newrank <= rank || newrank > interserver.GuildRankLowest {
+			fmt.Println(player.Name(), "tried to set", target, "to invalid rank", newrank)
+			return
+		}
+
+		err = guilds.SetRank(guild, m, newrank)
+		if err != nil {
+			return
+		}
+
+		syncGuild(interserver.GuildSyncRank, guild, target, m.Name)
+
+	case interserver.GuildOpEmblem:
+		if guild == nil || guild.Leader != charid || len(args) != 4 {
+			fmt.Println(player.Name(), "tried to change the guild emblem without permission")
+			return
+		}
+
+		bg, bgcol, logo, logocol := guild.EmblemBg, guild.EmblemBgCol, guild.Emblem, guild.EmblemCol
+		guild.EmblemBg = int16(args[0])
+		guild.EmblemBgCol = byte(args[1])
+		guild.Emblem = int16(args[2])
+		guild.EmblemCol = byte(args[3])
+
+		err = guilds.Save(guild)
+		if err != nil {
+			// the mesos are refunded, so the emblem must not stay changed
+			guild.EmblemBg, guild.EmblemBgCol, guild.Emblem, guild.EmblemCol = bg, bgcol, logo, logocol
+			return
+		}
+
+		committed = true
+		syncGuild(interserver.GuildSyncEmblem, guild, charid, player.Name())
+
+	case interserver.GuildOpNotice:
+		if guild == nil || rank > interserver.GuildRankJrMaster || len(strs) != 1 {
+			fmt.Println(player.Name(), "tried to change the guild notice without permission")
+			return
+		}
+
+		if len(strs[0]) > maxGuildNotice {
+			return
+		}
+
+		notice := guild.Notice
+		guild.Notice = strs[0]
+		err = guilds.Save(guild)
+		if err != nil {
+			guild.Notice = notice
+			return
+		}
+
+		syncGuild(interserver.GuildSyncNotice, guild, charid, player.Name())
+
+	case interserver.GuildOpCapacity:
+		if guild == nil || guild.Leader != charid {
+			fmt.Println(player.Name(), "tried to increase guild capacity without permission")
+			return
+		}
+
+		if guild.Capacity >= interserver.GuildMaxCapacity {
+			notice("The guild can't grow any further.")
+			return
+		}
+
+		guild.Capacity += 5
+		err = guilds.Save(guild)
+		if err != nil {
+			guild.Capacity -= 5
+			return
+		}
+
+		committed = true
+		syncGuild(interserver.GuildSyncCapacity, guild, charid, player.Name())
+
+	case interserver.GuildOpDisband:
+		if guild == nil || guild.Leader != charid {
+			fmt.Println(player.Name(), "tried to disband a guild without being the leader")
+			return
+		}
+
+		err = guilds.Disband(guild)
+		if err != nil {
+			return
+		}
+
+		fmt.Println(player.Name(), "disbanded guild", guild.Name)
+		syncGuild(interserver.GuildSyncDisband, guild, charid, player.Name())
+
+	default:
+		fmt.Println("Unknown guild operation", op)
+	}
+
+	return
+}
+
+// handleCharacterDeleted drops a deleted character from its guild. The database row
+// has already been removed by the loginserver.
+func handleCharacterDeleted(con *common.InterserverClient, it maplelib.PacketIterator) (handled bool, err error) {
+	charid, err := it.Decode4s()
+	if err != nil {
+		return
+	}
+
+	players.Lock()
+	defer players.Unlock()
+	guilds.Lock()
+	defer guilds.Unlock()
+
+	guild, err := guilds.Of(charid)
+	if err != nil {
+		return
+	}
+
+	if guild != nil {
+		m, err := guilds.RemoveMember(guild, charid)
+		if err != nil {
+			return false, err
+		}
+
+		if m != nil {
+			syncGuild(interserver.GuildSyncLeave, guild, charid, m.Name)
+		}
+	}
+
+	guilds.Forget(charid)
+	handled = true
+	return
+}
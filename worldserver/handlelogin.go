@@ -45,6 +45,12 @@ func HandleLogin(con *common.InterserverClient, p maplelib.Packet) (handled bool
 
 	case interserver.IOMessageToChannel:
 		return handleMessageToChannel(con, it)
+
+	case interserver.IOSyncCharacterDeleted:
+		return handleCharacterDeleted(con, it)
+
+	case interserver.IOSyncWorldConf:
+		return handleSyncWorldConf(con, it)
 	}
 
 	return false, nil
@@ -104,6 +110,8 @@ func handleWorldConnect(con *common.InterserverClient, it maplelib.PacketIterato
 			}
 
 			fmt.Println("Removing channel", deletechanid)
+			channelOffline(deletechanid)
+
 			status.Lock()
 			channels.Lock()
 			defer status.Unlock()
@@ -135,3 +143,24 @@ func handleMessageToChannel(con *common.InterserverClient, it maplelib.PacketIte
 	handled = err == nil
 	return
 }
+
+// handleSyncWorldConf replaces the world config after a rehash and passes it on to
+// the channels
+func handleSyncWorldConf(con *common.InterserverClient, it maplelib.PacketIterator) (handled bool, err error) {
+	conf, err := config.DecodeWorldConf(&it)
+	if err != nil {
+		return
+	}
+
+	fmt.Println("World config reloaded")
+	status.Lock()
+	status.SetConf(conf)
+	status.Unlock()
+
+	channels.Lock()
+	defer channels.Unlock()
+	err = channels.SendToAllChannels(interserver.SyncWorldConf(conf))
+
+	handled = err == nil
+	return
+}
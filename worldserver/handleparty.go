@@ -0,0 +1,343 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"fmt"
+)
+
+import (
+	"github.com/Francesco149/kagami/common/interserver"
+	"github.com/Francesco149/kagami/common/packets"
+	"github.com/Francesco149/kagami/worldserver/channels"
+	"github.com/Francesco149/kagami/worldserver/guilds"
+	"github.com/Francesco149/kagami/worldserver/parties"
+	"github.com/Francesco149/kagami/worldserver/players"
+	"github.com/Francesco149/maplelib"
+)
+
+// NOTE: the functions in this file expect the players, parties and guilds mutexes to
+// be locked in this order and lock the channels mutex themselves
+
+// sendToPlayers relays a client packet to the channels of the given characters
+func sendToPlayers(charids []int32, p maplelib.Packet) {
+	byChannel := players.ChannelsOf(charids)
+
+	channels.Lock()
+	defer channels.Unlock()
+
+	for chanid, ids := range byChannel {
+		ch := channels.Get(chanid)
+		if ch == nil {
+			continue
+		}
+
+		err := ch.Conn().SendPacket(interserver.MessageToPlayers(ids, p))
+		if err != nil {
+			fmt.Println("Failed to relay packet to channel", chanid, ":", err)
+		}
+	}
+}
+
+// syncParty notifies the channels of all the members of a party and of the target
+// character of a party change
+func syncParty(op byte, party *interserver.Party, target int32, targetName string) {
+	charids := append(party.MemberIds(), target)
+	byChannel := players.ChannelsOf(charids)
+
+	channels.Lock()
+	defer channels.Unlock()
+
+	for chanid := range byChannel {
+		ch := channels.Get(chanid)
+		if ch == nil {
+			continue
+		}
+
+		err := ch.Conn().SendPacket(interserver.SyncParty(op, party, target, targetName))
+		if err != nil {
+			fmt.Println("Failed to sync party", party.Id, "to channel", chanid, ":", err)
+		}
+	}
+}
+
+// partyMember creates a party member from an online player
+func partyMember(p *players.Player) *interserver.PartyMember {
+	return &interserver.PartyMember{
+		CharId:  p.CharId(),
+		Name:    p.Name(),
+		Job:     p.Job(),
+		Level:   p.Level(),
+		Channel: p.Channel(),
+		MapId:   p.MapId(),
+	}
+}
+
+// updatePartyMember copies the player's current state to its party, if any, and
+// notifies the party's channels
+func updatePartyMember(charid int32) {
+	party := parties.Of(charid)
+	if party == nil {
+		return
+	}
+
+	m := party.Member(charid)
+	if p := players.Get(charid); p != nil {
+		*m = *partyMember(p)
+	} else {
+		m.Channel = -1
+	}
+
+	syncParty(interserver.PartySyncUpdate, party, charid, m.Name)
+}
+
+// playerOnline registers a player that joined a channel and updates its party and
+// guild
+func playerOnline(charid int32, name string, job int16, level byte, chanid int8, mapid int32) {
+	players.Lock()
+	defer players.Unlock()
+	parties.Lock()
+	defer parties.Unlock()
+	guilds.Lock()
+	defer guilds.Unlock()
+
+	players.Add(charid, name, job, level, chanid, mapid)
+	updatePartyMember(charid)
+	updateGuildMember(interserver.GuildSyncOnline, charid)
+}
+
+// playerOffline unregisters a player that left a channel and updates its party and
+// guild. The player stays in the party so that it can come back on another channel.
+func playerOffline(charid int32) {
+	players.Lock()
+	defer players.Unlock()
+	parties.Lock()
+	defer parties.Unlock()
+	guilds.Lock()
+	defer guilds.Unlock()
+
+	players.Remove(charid)
+	parties.ClearInvite(charid)
+	guilds.ClearInvite(charid)
+	updatePartyMember(charid)
+	updateGuildMember(interserver.GuildSyncOnline, charid)
+}
+
+// channelOffline marks all the players of a channel that went down as offline
+func channelOffline(chanid int8) {
+	players.Lock()
+	defer players.Unlock()
+	parties.Lock()
+	defer parties.Unlock()
+	guilds.Lock()
+	defer guilds.Unlock()
+
+	for _, charid := range players.RemoveChannel(chanid) {
+		parties.ClearInvite(charid)
+		guilds.ClearInvite(charid)
+		updatePartyMember(charid)
+		updateGuildMember(interserver.GuildSyncOnline, charid)
+	}
+}
+
+// syncPlayerUpdate handles a request from the channelserver that tells the
+// worldserver that a player's job, level or map has changed
+func syncPlayerUpdate(con *channels.Connection, it maplelib.PacketIterator) (handled bool, err error) {
+	charid, err := it.Decode4s()
+	job, err := it.Decode2s()
+	level, err := it.Decode1()
+	mapid, err := it.Decode4s()
+	if err != nil {
+		return
+	}
+
+	players.Lock()
+	defer players.Unlock()
+	parties.Lock()
+	defer parties.Unlock()
+	guilds.Lock()
+	defer guilds.Unlock()
+
+	p := players.Get(charid)
+	if p == nil {
+		fmt.Println("Channel", con.ChannelId(), "tried to update offline character", charid)
+		return true, nil
+	}
+
+	// guilds don't care about map changes
+	guildChanged := p.Job() != job || p.Level() != level
+
+	p.SetJob(job)
+	p.SetLevel(level)
+	p.SetMapId(mapid)
+	updatePartyMember(charid)
+
+	if guildChanged {
+		updateGuildMember(interserver.GuildSyncMember, charid)
+	}
+
+	handled = true
+	return
+}
+
+// handleMessageToPlayers relays a client packet to the channels of the given players
+func handleMessageToPlayers(con *channels.Connection, it maplelib.PacketIterator) (handled bool, err error) {
+	count, err := it.Decode2()
+	if err != nil {
+		return
+	}
+
+	charids := make([]int32, count)
+	for i := range charids {
+		charids[i], err = it.Decode4s()
+	}
+	if err != nil {
+		return
+	}
+
+	players.Lock()
+	defer players.Unlock()
+	sendToPlayers(charids, maplelib.Packet(it))
+
+	handled = true
+	return
+}
+
+// handlePartyOperation handles a party request from a player
+func handlePartyOperation(con *channels.Connection, it maplelib.PacketIterator) (handled bool, err error) {
+	op, err := it.Decode1()
+	charid, err := it.Decode4s()
+	target, err := it.Decode4s()
+	name, err := it.DecodeString()
+	if err != nil {
+		return
+	}
+
+	handled = true
+
+	players.Lock()
+	defer players.Unlock()
+	parties.Lock()
+	defer parties.Unlock()
+
+	player := players.Get(charid)
+	if player == nil {
+		fmt.Println("Offline character", charid, "requested party operation", op)
+		return
+	}
+
+	party := parties.Of(charid)
+	message := func(msg byte) { sendToPlayers([]int32{charid}, packets.PartyStatusMessage(msg)) }
+
+	switch op {
+	case interserver.PartyOpCreate:
+		if party != nil {
+			message(packets.PartyMsgAlreadyInParty)
+			return
+		}
+
+		party = parties.Create(partyMember(player))
+		fmt.Println(player.Name(), "created party", party.Id)
+		syncParty(interserver.PartySyncCreate, party, charid, player.Name())
+
+	case interserver.PartyOpLeave:
+		if party == nil {
+			message(packets.PartyMsgNotInParty)
+			return
+		}
+
+		if party.Leader == charid {
+			fmt.Println(player.Name(), "disbanded party", party.Id)
+			parties.Disband(party)
+			syncParty(interserver.PartySyncDisband, party, charid, player.Name())
+			return
+		}
+
+		parties.RemoveMember(party, charid)
+		syncParty(interserver.PartySyncLeave, party, charid, player.Name())
+
+	case interserver.PartyOpJoin:
+		if party != nil {
+			message(packets.PartyMsgAlreadyInParty)
+			return
+		}
+
+		newparty := parties.Get(target)
+		if newparty == nil || !parties.Invited(charid, target) {
+			fmt.Println(player.Name(), "tried to join party", target, "without an invite")
+			return
+		}
+
+		if len(newparty.Members) >= interserver.MaxPartySize {
+			parties.ClearInvite(charid)
+			message(packets.PartyMsgFull)
+			return
+		}
+
+		parties.AddMember(newparty, partyMember(player))
+		syncParty(interserver.PartySyncJoin, newparty, charid, player.Name())
+
+	case interserver.PartyOpInvite:
+		if party == nil {
+			message(packets.PartyMsgNotInParty)
+			return
+		}
+
+		invited := players.GetByName(name)
+		switch {
+		case invited == nil:
+			message(packets.PartyMsgNotFound)
+		case parties.Of(invited.CharId()) != nil:
+			message(packets.PartyMsgAlreadyInParty)
+		case len(party.Members) >= interserver.MaxPartySize:
+			message(packets.PartyMsgFull)
+		default:
+			parties.Invite(invited.CharId(), party)
+			sendToPlayers([]int32{invited.CharId()}, packets.PartyInvite(party.Id, player.Name()))
+		}
+
+	case interserver.PartyOpExpel:
+		if party == nil || party.Leader != charid || target == charid {
+			fmt.Println(player.Name(), "tried to expel", target, "without being the leader")
+			return
+		}
+
+		m := parties.RemoveMember(party, target)
+		if m == nil {
+			return
+		}
+		syncParty(interserver.PartySyncExpel, party, target, m.Name)
+
+	case interserver.PartyOpLeader:
+		if party == nil || party.Leader != charid {
+			fmt.Println(player.Name(), "tried to change leader without being the leader")
+			return
+		}
+
+		m := party.Member(target)
+		if m == nil || m.Channel == -1 {
+			return
+		}
+
+		party.Leader = target
+		syncParty(interserver.PartySyncLeader, party, target, m.Name)
+
+	default:
+		fmt.Println("Unknown party operation", op)
+	}
+
+	return
+}
@@ -0,0 +1,123 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// Package parties keeps track of the world's parties
+package parties
+
+import (
+	"sync"
+)
+
+import (
+	"github.com/Francesco149/kagami/common/interserver"
+)
+
+var mut sync.Mutex
+var parties = make(map[int32]*interserver.Party) // parties mapped by id
+var memberOf = make(map[int32]int32)             // charid -> party id
+var invites = make(map[int32]int32)              // invited charid -> party id
+var nextId int32 = 1
+
+// Lock locks the parties mutex.
+// Must be called before performing any operation on
+// the parties
+func Lock() {
+	mut.Lock()
+}
+
+// Unlock unlocks the parties mutex.
+func Unlock() {
+	mut.Unlock()
+}
+
+// parties.Create creates a new party led by the given member
+func Create(leader *interserver.PartyMember) *interserver.Party {
+	party := &interserver.Party{
+		Id:      nextId,
+		Leader:  leader.CharId,
+		Members: []*interserver.PartyMember{leader},
+	}
+	nextId++
+
+	parties[party.Id] = party
+	memberOf[leader.CharId] = party.Id
+	return party
+}
+
+// parties.Get gets a party by id. Returns nil if the id doesn't exist.
+func Get(id int32) *interserver.Party {
+	return parties[id]
+}
+
+// parties.Of returns the party of the given character or nil
+func Of(charid int32) *interserver.Party {
+	id, ok := memberOf[charid]
+	if !ok {
+		return nil
+	}
+	return parties[id]
+}
+
+// parties.AddMember adds a member to a party and clears the member's invite
+func AddMember(party *interserver.Party, m *interserver.PartyMember) {
+	party.Members = append(party.Members, m)
+	memberOf[m.CharId] = party.Id
+	delete(invites, m.CharId)
+}
+
+// parties.RemoveMember removes a member from a party and returns it or nil if the
+// character isn't in the party
+func RemoveMember(party *interserver.Party, charid int32) *interserver.PartyMember {
+	for i, m := range party.Members {
+		if m.CharId == charid {
+			party.Members = append(party.Members[:i], party.Members[i+1:]...)
+			delete(memberOf, charid)
+			return m
+		}
+	}
+	return nil
+}
+
+// parties.Disband removes a party. The members are kept in the party object so that
+// they can be notified.
+func Disband(party *interserver.Party) {
+	for _, m := range party.Members {
+		delete(memberOf, m.CharId)
+	}
+
+	for charid, id := range invites {
+		if id == party.Id {
+			delete(invites, charid)
+		}
+	}
+
+	delete(parties, party.Id)
+}
+
+// parties.Invite records a pending invite to the given party
+func Invite(charid int32, party *interserver.Party) {
+	invites[charid] = party.Id
+}
+
+// parties.Invited returns true if the character has a pending invite to the given party
+func Invited(charid int32, partyid int32) bool {
+	id, ok := invites[charid]
+	return ok && id == partyid
+}
+
+// parties.ClearInvite removes the pending invite of a character
+func ClearInvite(charid int32) {
+	delete(invites, charid)
+}
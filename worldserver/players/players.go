@@ -0,0 +1,116 @@
+/*
+   Copyright 2014 Franc[e]sco (lolisamurai@tfwno.gf)
+   This file is part of kagami.
+   kagami is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   kagami is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with kagami. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// Package players keeps track of the characters that are online in the world
+package players
+
+import (
+	"strings"
+	"sync"
+)
+
+// Player is an online character and the channel it's on
+type Player struct {
+	charid  int32
+	name    string
+	job     int16
+	level   byte
+	channel int8
+	mapid   int32
+}
+
+func (p *Player) CharId() int32    { return p.charid }
+func (p *Player) Name() string     { return p.name }
+func (p *Player) Job() int16       { return p.job }
+func (p *Player) SetJob(v int16)   { p.job = v }
+func (p *Player) Level() byte      { return p.level }
+func (p *Player) SetLevel(v byte)  { p.level = v }
+func (p *Player) Channel() int8    { return p.channel }
+func (p *Player) MapId() int32     { return p.mapid }
+func (p *Player) SetMapId(v int32) { p.mapid = v }
+
+var mut sync.Mutex
+var players = make(map[int32]*Player) // online players mapped by charid
+
+// Lock locks the players mutex.
+// Must be called before performing any operation on
+// the players
+func Lock() {
+	mut.Lock()
+}
+
+// Unlock unlocks the players mutex.
+func Unlock() {
+	mut.Unlock()
+}
+
+// players.Add marks a character as online on the given channel
+func Add(charid int32, name string, job int16, level byte, channel int8, mapid int32) *Player {
+	p := &Player{
+		charid:  charid,
+		name:    name,
+		job:     job,
+		level:   level,
+		channel: channel,
+		mapid:   mapid,
+	}
+	players[charid] = p
+	return p
+}
+
+// players.Remove marks a character as offline
+func Remove(charid int32) {
+	delete(players, charid)
+}
+
+// players.Get gets an online character by id. Returns nil if the character is offline.
+func Get(charid int32) *Player {
+	return players[charid]
+}
+
+// players.GetByName gets an online character by name (case insensitive).
+// Returns nil if the character is offline.
+func GetByName(name string) *Player {
+	for _, p := range players {
+		if strings.EqualFold(p.name, name) {
+			return p
+		}
+	}
+	return nil
+}
+
+// players.ChannelsOf groups the given online characters by channel.
+// Offline characters are skipped.
+func ChannelsOf(charids []int32) map[int8][]int32 {
+	res := make(map[int8][]int32)
+	for _, charid := range charids {
+		if p := players[charid]; p != nil {
+			res[p.channel] = append(res[p.channel], charid)
+		}
+	}
+	return res
+}
+
+// players.RemoveChannel marks all the characters on the given channel as offline
+// and returns their ids
+func RemoveChannel(channel int8) (res []int32) {
+	for charid, p := range players {
+		if p.channel == channel {
+			res = append(res, charid)
+			delete(players, charid)
+		}
+	}
+	return
+}